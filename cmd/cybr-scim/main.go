@@ -0,0 +1,170 @@
+// Command cybr-scim administers users, groups, safes, safe permissions, and privileged data
+// through the CyberArk Identity SCIM API without writing Go.
+//
+// Usage:
+//		cybr-scim [flags] <resource> <verb> [identifier...]
+//
+// Resources are users, groups, safes, safe-permissions, privileged-data, and discovery.
+// Verbs are list, get, create, update, patch, and delete. Safe permissions are identified
+// by the Safe name and the User or Group name, the other resources by a single identifier.
+//
+// The exit status is 0 on success, 3 when the resource does not exist, 2 when the SCIM API
+// rejects the request otherwise, and 1 for every other failure.
+//
+// Example Usage:
+//		cybr-scim users list --filter 'userName sw "john"' --sort userName -o table
+//		cybr-scim safes get ExampleSafe -o yaml
+//		cybr-scim safe-permissions create -f permission.yaml
+//		cybr-scim users patch 12 --replace active=false
+//		cybr-scim discovery config
+//
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/pflag"
+	cybr_pam_scim "github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/config"
+)
+
+// options holds the parsed command line flags
+type options struct {
+	config             string
+	profile            string
+	output             string
+	filter             string
+	sort               string
+	sortOrder          string
+	attributes         []string
+	excludedAttributes []string
+	pageSize           int
+	file               string
+	add                []string
+	replace            []string
+	remove             []string
+}
+
+const usage = `Usage: cybr-scim [flags] <resource> <verb> [identifier...]
+
+Resources:
+  users             Users, identified by id
+  groups            Groups, identified by id
+  safes             Safes (Containers), identified by name
+  safe-permissions  Safe members (ContainerPermissions), identified by Safe and User or Group name
+  privileged-data   Accounts (PrivilegedData), identified by id
+  discovery         config, resource-types, or schemas of the service provider
+
+Verbs:
+  list              List the resources matching --filter
+  get               Get a single resource
+  create            Create a resource from --file
+  update            Replace a resource with the content of --file
+  patch             Modify a resource with --file or --add, --replace, and --remove
+  delete            Delete a resource
+
+Flags:
+`
+
+// Exit statuses of the command besides 0
+const (
+	exitFailure  = 1
+	exitRejected = 2
+	exitNotFound = 3
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintln(os.Stderr, "cybr-scim:", err)
+		os.Exit(exitCode(err))
+	}
+}
+
+// exitCode returns the exit status of an error returned by run, scripts can tell a missing
+// resource from a request rejected by the SCIM API and from other failures
+func exitCode(err error) int {
+	var scimErr *cybr_pam_scim.ScimError
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, cybr_pam_scim.ErrNotFound):
+		return exitNotFound
+	case errors.As(err, &scimErr):
+		return exitRejected
+	}
+
+	return exitFailure
+}
+
+func run(args []string, stdout io.Writer, stderr io.Writer) error {
+	var opts options
+	flags := pflag.NewFlagSet("cybr-scim", pflag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&opts.config, "config", "", "configuration file (default $HOME/.cybr-scim.yaml)")
+	flags.StringVarP(&opts.profile, "profile", "p", "", "profile of the configuration file (default $CYBR_SCIM_PROFILE or the default_profile)")
+	flags.StringVarP(&opts.output, "output", "o", "table", "output format: table, json, yaml, or csv")
+	flags.StringVar(&opts.filter, "filter", "", "SCIM filter expression of list")
+	flags.StringVar(&opts.sort, "sort", "", "attribute list sorts by")
+	flags.StringVar(&opts.sortOrder, "sort-order", "", "ascending or descending")
+	flags.StringSliceVar(&opts.attributes, "attributes", nil, "attributes requested by list and get and output, e.g. id,userName,name.givenName")
+	flags.StringSliceVar(&opts.excludedAttributes, "excluded-attributes", nil, "attributes omitted by list and get, e.g. groups,emails")
+	flags.IntVar(&opts.pageSize, "page-size", cybr_pam_scim.DefaultPageSize, "number of resources requested per page by list")
+	flags.StringVarP(&opts.file, "file", "f", "", "JSON or YAML file of create, update, or patch, - reads standard input")
+	flags.StringArrayVar(&opts.add, "add", nil, "patch add operation as path=value, the value may be JSON")
+	flags.StringArrayVar(&opts.replace, "replace", nil, "patch replace operation as path=value, the value may be JSON")
+	flags.StringArrayVar(&opts.remove, "remove", nil, "patch remove operation as path")
+	showVersion := flags.Bool("version", false, "print the version and exit")
+	config.RegisterFlags(flags)
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if *showVersion {
+		fmt.Fprintln(stdout, cybr_pam_scim.FullVersionName)
+		return nil
+	}
+	if flags.NArg() < 2 {
+		flags.Usage()
+		return errors.New("a resource and a verb are required")
+	}
+
+	format, err := newFormatter(opts.output, stdout)
+	if err != nil {
+		return err
+	}
+
+	p, err := config.Load(config.Options{File: opts.config, Profile: opts.profile, Flags: flags})
+	if err != nil {
+		return err
+	}
+	s, err := config.NewServiceFromProfile(p)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	resource, verb, ids := flags.Arg(0), flags.Arg(1), flags.Args()[2:]
+	if resource == "discovery" {
+		return discover(ctx, s, verb, opts, format)
+	}
+	r, ok := resources[resource]
+	if !ok {
+		return fmt.Errorf("unknown resource %q, accepted values are users, groups, safes, safe-permissions, privileged-data, or discovery", resource)
+	}
+
+	return r.run(ctx, s, verb, ids, opts, format)
+}
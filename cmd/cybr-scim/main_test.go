@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/scimtest"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
+)
+
+// cli runs the command against a scimtest.Server with a profile made of flags
+type cli struct {
+	t     *testing.T
+	srv   *scimtest.Server
+	flags []string
+}
+
+func newCLI(t *testing.T) *cli {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	for _, env := range os.Environ() {
+		if name := env[:strings.Index(env, "=")]; strings.HasPrefix(name, "CYBR_SCIM_") {
+			t.Setenv(name, "")
+			os.Unsetenv(name)
+		}
+	}
+
+	srv := scimtest.NewServer()
+	t.Cleanup(srv.Close)
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return &cli{t: t, srv: srv, flags: []string{
+		"--url", strings.TrimPrefix(srv.URL, "https://"),
+		"--token", srv.Token,
+		"--tls-ca-file", caFile,
+	}}
+}
+
+// run returns the standard output and the exit status of the command
+func (c *cli) run(args ...string) (string, int) {
+	c.t.Helper()
+	var stdout, stderr bytes.Buffer
+	err := run(append(append([]string{}, c.flags...), args...), &stdout, &stderr)
+
+	return stdout.String(), exitCode(err)
+}
+
+// seed stores a resource of the endpoint and returns its id
+func (c *cli) seed(endpoint string, resource interface{}) string {
+	c.t.Helper()
+	id, err := c.srv.Seed(endpoint, resource)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	return id
+}
+
+// table splits the lines of the table output into their cells
+func table(output string) [][]string {
+	var rows [][]string
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		rows = append(rows, strings.Fields(line))
+	}
+
+	return rows
+}
+
+func TestRunResources(t *testing.T) {
+	c := newCLI(t)
+	userId := c.seed("Users", types.User{UserName: "john.smith", DisplayName: "John", Active: true})
+	groupId := c.seed("Groups", types.Group{DisplayName: "AppAdmins"})
+	c.seed("Containers", types.Container{Name: "ExampleSafe", Description: "Example"})
+	c.seed("ContainerPermissions", types.ContainerPermission{
+		Container: types.ContainerRef{Name: "ExampleSafe"},
+		User:      types.UserRef{Display: "john.smith"},
+		Rights:    types.ViewerRights,
+	})
+	accountId := c.seed("PrivilegedData", types.PrivilegedData{
+		Name: "admin-account",
+		Type: "password",
+		UrnIetfParamsScimSchemasCyberark10PrivilegedData: types.UrnIetfParamsScimSchemasCyberark10PrivilegedData{Safe: "ExampleSafe"},
+	})
+
+	tests := []struct {
+		resource string
+		ids      []string
+		// header and row of the table output of list
+		header []string
+		row    []string
+		// attribute and value of the json output of get
+		attribute string
+		value     string
+	}{
+		{"users", []string{userId}, []string{"ID", "USERNAME", "DISPLAYNAME", "ACTIVE"}, []string{userId, "john.smith", "John", "true"}, "userName", "john.smith"},
+		{"groups", []string{groupId}, []string{"ID", "DISPLAYNAME"}, []string{groupId, "AppAdmins"}, "displayName", "AppAdmins"},
+		{"safes", []string{"ExampleSafe"}, []string{"NAME", "DESCRIPTION", "MANAGINGCPM"}, []string{"ExampleSafe", "Example"}, "name", "ExampleSafe"},
+		{"safe-permissions", []string{"ExampleSafe", "john.smith"}, []string{"CONTAINER.NAME", "USER.DISPLAY", "GROUP.DISPLAY", "RIGHTS"}, []string{"ExampleSafe", "john.smith", "RetrieveAccounts,ListAccounts"}, "id", "ExampleSafe:john.smith"},
+		{"privileged-data", []string{accountId}, []string{"ID", "NAME", "TYPE", "SAFE"}, []string{accountId, "admin-account", "password", "ExampleSafe"}, "name", "admin-account"},
+	}
+	for _, tt := range tests {
+		// The table format is the default
+		output, code := c.run(tt.resource, "list")
+		if code != 0 {
+			t.Errorf("%s list: exit status %d", tt.resource, code)
+			continue
+		}
+		rows := table(output)
+		if len(rows) != 2 || strings.Join(rows[0], " ") != strings.Join(tt.header, " ") || strings.Join(rows[1], " ") != strings.Join(tt.row, " ") {
+			t.Errorf("%s list:\n%s\nwant the header %v and the row %v", tt.resource, output, tt.header, tt.row)
+		}
+
+		output, code = c.run(append([]string{tt.resource, "get", "-o", "json"}, tt.ids...)...)
+		var document map[string]interface{}
+		if err := json.Unmarshal([]byte(output), &document); code != 0 || err != nil {
+			t.Errorf("%s get: exit status %d and output %s, want a JSON object: %v", tt.resource, code, output, err)
+			continue
+		}
+		if document[tt.attribute] != tt.value {
+			t.Errorf("%s get: %s = %v, want %s", tt.resource, tt.attribute, document[tt.attribute], tt.value)
+		}
+
+		output, code = c.run(tt.resource, "list", "-o", "json")
+		var documents []map[string]interface{}
+		if err := json.Unmarshal([]byte(output), &documents); code != 0 || err != nil || len(documents) != 1 || documents[0][tt.attribute] != tt.value {
+			t.Errorf("%s list -o json: exit status %d and output %s, want a JSON array of the resource", tt.resource, code, output)
+		}
+	}
+
+	// --attributes selects the columns
+	output, code := c.run("users", "list", "--attributes", "userName,displayName")
+	if rows := table(output); code != 0 || len(rows) != 2 || strings.Join(rows[0], " ") != "USERNAME DISPLAYNAME" || strings.Join(rows[1], " ") != "john.smith John" {
+		t.Errorf("users list --attributes:\n%s", output)
+	}
+}
+
+func TestRunExitCodes(t *testing.T) {
+	c := newCLI(t)
+	c.seed("Users", types.User{UserName: "john.smith"})
+	user := filepath.Join(t.TempDir(), "user.yaml")
+	if err := os.WriteFile(user, []byte("userName: john.smith\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	group := filepath.Join(t.TempDir(), "group.yaml")
+	if err := os.WriteFile(group, []byte("displayName: AppAdmins\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		fault *scimtest.Fault
+		args  []string
+		code  int
+	}{
+		{"success", nil, []string{"users", "list"}, 0},
+		{"not found", nil, []string{"users", "get", "99"}, exitNotFound},
+		{"safe not found", nil, []string{"safes", "delete", "MissingSafe"}, exitNotFound},
+		{"conflict", nil, []string{"users", "create", "-f", user}, exitRejected},
+		{"server error", &scimtest.Fault{Method: http.MethodPost, Path: "/Groups", Status: http.StatusInternalServerError, Times: 1}, []string{"groups", "create", "-f", group}, exitRejected},
+		{"invalid filter", nil, []string{"users", "list", "--filter", "userName zz 1"}, exitFailure},
+		{"unknown resource", nil, []string{"accounts", "list"}, exitFailure},
+		{"missing verb", nil, []string{"users"}, exitFailure},
+		{"invalid profile", nil, []string{"users", "list", "--auth", "saml"}, exitFailure},
+	}
+	for _, tt := range tests {
+		if tt.fault != nil {
+			c.srv.InjectFault(*tt.fault)
+		}
+		if _, code := c.run(tt.args...); code != tt.code {
+			t.Errorf("%s: exit status %d, want %d", tt.name, code, tt.code)
+		}
+	}
+}
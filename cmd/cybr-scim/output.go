@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/filter"
+	"gopkg.in/yaml.v3"
+)
+
+// column is an attribute shown by the table and csv formats
+type column struct {
+	header string
+	path   string
+}
+
+// columns returns the columns of attribute paths, headers omit the schema URI
+func columns(paths ...string) []column {
+	result := make([]column, 0, len(paths))
+	for _, path := range paths {
+		header := path
+		if p := filter.ParsePath(path); p.URI != "" {
+			header = strings.TrimPrefix(path, p.URI+":")
+		}
+		result = append(result, column{header: header, path: path})
+	}
+
+	return result
+}
+
+// formatter writes resources as table, json, yaml, or csv
+type formatter struct {
+	format string
+	w      io.Writer
+}
+
+func newFormatter(format string, w io.Writer) (*formatter, error) {
+	switch format {
+	case "table", "json", "yaml", "csv":
+		return &formatter{format: format, w: w}, nil
+	}
+
+	return nil, fmt.Errorf("unknown output format %q, accepted values are table, json, yaml, or csv", format)
+}
+
+// write outputs the resources. Only the attributes are written when provided, the table
+// and csv formats show the default columns otherwise. A single resource is written as an
+// object instead of a list by the json and yaml formats.
+func (f *formatter) write(defaults []column, attributes []string, resources []interface{}, single bool) error {
+	documents := make([]map[string]interface{}, 0, len(resources))
+	for _, resource := range resources {
+		document, err := toDocument(resource)
+		if err != nil {
+			return err
+		}
+		if len(attributes) > 0 {
+			document = project(document, attributes)
+		}
+		documents = append(documents, document)
+	}
+
+	cols := defaults
+	if len(attributes) > 0 {
+		cols = columns(attributes...)
+	}
+
+	var output interface{} = documents
+	if single && len(documents) == 1 {
+		output = documents[0]
+	}
+
+	switch f.format {
+	case "json":
+		encoder := json.NewEncoder(f.w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(output)
+	case "yaml":
+		encoder := yaml.NewEncoder(f.w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(output); err != nil {
+			return err
+		}
+		return encoder.Close()
+	case "csv":
+		w := csv.NewWriter(f.w)
+		w.Write(headers(cols))
+		for _, document := range documents {
+			w.Write(row(document, cols))
+		}
+		w.Flush()
+		return w.Error()
+	}
+
+	w := tabwriter.NewWriter(f.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, strings.ToUpper(strings.Join(headers(cols), "\t")))
+	for _, document := range documents {
+		fmt.Fprintln(w, strings.Join(row(document, cols), "\t"))
+	}
+
+	return w.Flush()
+}
+
+// toDocument converts a resource to its JSON representation
+func toDocument(resource interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+	var document map[string]interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+
+	return document, nil
+}
+
+// project keeps the attributes of the document, schema URIs and sub-attributes are supported
+func project(document map[string]interface{}, attributes []string) map[string]interface{} {
+	result := map[string]interface{}{}
+	for _, attribute := range attributes {
+		p := filter.ParsePath(attribute)
+		source, target := document, result
+		if p.URI != "" {
+			extension, ok := lookup(document, p.URI).(map[string]interface{})
+			if !ok {
+				continue
+			}
+			source = extension
+			if _, ok := result[p.URI]; !ok {
+				result[p.URI] = map[string]interface{}{}
+			}
+			target = result[p.URI].(map[string]interface{})
+		}
+
+		value := lookup(source, p.Name)
+		if value == nil {
+			continue
+		}
+		if p.SubAttribute == "" {
+			target[p.Name] = value
+			continue
+		}
+		if elements, ok := value.([]interface{}); ok {
+			// Sub-attributes of multi-valued attributes are kept in every element
+			existing, _ := target[p.Name].([]interface{})
+			if existing == nil {
+				existing = make([]interface{}, len(elements))
+				for i := range existing {
+					existing[i] = map[string]interface{}{}
+				}
+				target[p.Name] = existing
+			}
+			for i, element := range elements {
+				if m, ok := element.(map[string]interface{}); ok && i < len(existing) {
+					if sub := lookup(m, p.SubAttribute); sub != nil {
+						existing[i].(map[string]interface{})[p.SubAttribute] = sub
+					}
+				}
+			}
+			continue
+		}
+		if parent, ok := value.(map[string]interface{}); ok {
+			sub := lookup(parent, p.SubAttribute)
+			if sub == nil {
+				continue
+			}
+			existing, _ := target[p.Name].(map[string]interface{})
+			if existing == nil {
+				existing = map[string]interface{}{}
+				target[p.Name] = existing
+			}
+			existing[p.SubAttribute] = sub
+		}
+	}
+
+	return result
+}
+
+func headers(cols []column) []string {
+	result := make([]string, 0, len(cols))
+	for _, col := range cols {
+		result = append(result, col.header)
+	}
+
+	return result
+}
+
+func row(document map[string]interface{}, cols []column) []string {
+	result := make([]string, 0, len(cols))
+	for _, col := range cols {
+		result = append(result, format(resolve(document, col.path)))
+	}
+
+	return result
+}
+
+// resolve returns the value of an attribute path, sub-attributes of multi-valued attributes
+// are returned as a list
+func resolve(document map[string]interface{}, path string) interface{} {
+	p := filter.ParsePath(path)
+	if p.URI != "" {
+		extension, ok := lookup(document, p.URI).(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		document = extension
+	}
+
+	value := lookup(document, p.Name)
+	if p.SubAttribute == "" {
+		return value
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return lookup(v, p.SubAttribute)
+	case []interface{}:
+		var values []interface{}
+		for _, element := range v {
+			if m, ok := element.(map[string]interface{}); ok {
+				if sub := lookup(m, p.SubAttribute); sub != nil {
+					values = append(values, sub)
+				}
+			}
+		}
+		return values
+	}
+
+	return nil
+}
+
+// lookup returns the value of a key, attribute names are case insensitive
+func lookup(document map[string]interface{}, name string) interface{} {
+	if value, ok := document[name]; ok {
+		return value
+	}
+	for key, value := range document {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+
+	return nil
+}
+
+// format renders a value in a table cell, lists of scalars are joined with commas
+func format(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, element := range v {
+			values = append(values, format(element))
+		}
+		return strings.Join(values, ",")
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		pairs := make([]string, 0, len(keys))
+		for _, key := range keys {
+			pairs = append(pairs, key+"="+format(v[key]))
+		}
+		return strings.Join(pairs, ";")
+	}
+
+	return fmt.Sprint(value)
+}
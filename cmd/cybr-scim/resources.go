@@ -0,0 +1,421 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	cybr_pam_scim "github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/filter"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
+	"gopkg.in/yaml.v3"
+)
+
+// resource maps the verbs of a resource onto the functions of the Service
+type resource struct {
+	ids     []string
+	columns []column
+	list    func(ctx context.Context, s *cybr_pam_scim.Service, opts *cybr_pam_scim.ListOptions) ([]interface{}, error)
+	get     func(ctx context.Context, s *cybr_pam_scim.Service, ids []string, opts ...cybr_pam_scim.ReadOption) (interface{}, error)
+	create  func(ctx context.Context, s *cybr_pam_scim.Service, data []byte) (interface{}, error)
+	update  func(ctx context.Context, s *cybr_pam_scim.Service, ids []string, data []byte) (interface{}, error)
+	patch   func(ctx context.Context, s *cybr_pam_scim.Service, ids []string, patch *types.PatchRequest) (interface{}, error)
+	delete  func(ctx context.Context, s *cybr_pam_scim.Service, ids []string) error
+}
+
+var resources = map[string]*resource{
+	"users": {
+		ids:     []string{"id"},
+		columns: columns("id", "userName", "displayName", "active"),
+		list: func(ctx context.Context, s *cybr_pam_scim.Service, opts *cybr_pam_scim.ListOptions) ([]interface{}, error) {
+			return list(ctx, s.Users(), opts)
+		},
+		get: func(ctx context.Context, s *cybr_pam_scim.Service, ids []string, opts ...cybr_pam_scim.ReadOption) (interface{}, error) {
+			return s.GetUserById(ctx, ids[0], opts...)
+		},
+		create: func(ctx context.Context, s *cybr_pam_scim.Service, data []byte) (interface{}, error) {
+			var user types.User
+			if err := decode(data, &user); err != nil {
+				return nil, err
+			}
+			return s.AddUser(ctx, user)
+		},
+		update: func(ctx context.Context, s *cybr_pam_scim.Service, ids []string, data []byte) (interface{}, error) {
+			var user types.User
+			if err := decode(data, &user); err != nil {
+				return nil, err
+			}
+			identify(&user.Id, ids, 0)
+			return s.UpdateUser(ctx, user)
+		},
+		patch: func(ctx context.Context, s *cybr_pam_scim.Service, ids []string, patch *types.PatchRequest) (interface{}, error) {
+			return s.PatchUser(ctx, ids[0], patch)
+		},
+		delete: func(ctx context.Context, s *cybr_pam_scim.Service, ids []string) error {
+			return s.DeleteUser(ctx, ids[0])
+		},
+	},
+	"groups": {
+		ids:     []string{"id"},
+		columns: columns("id", "displayName"),
+		list: func(ctx context.Context, s *cybr_pam_scim.Service, opts *cybr_pam_scim.ListOptions) ([]interface{}, error) {
+			return list(ctx, s.Groups(), opts)
+		},
+		get: func(ctx context.Context, s *cybr_pam_scim.Service, ids []string, opts ...cybr_pam_scim.ReadOption) (interface{}, error) {
+			return s.GetGroupById(ctx, ids[0], opts...)
+		},
+		create: func(ctx context.Context, s *cybr_pam_scim.Service, data []byte) (interface{}, error) {
+			var group types.Group
+			if err := decode(data, &group); err != nil {
+				return nil, err
+			}
+			return s.AddGroup(ctx, group)
+		},
+		update: func(ctx context.Context, s *cybr_pam_scim.Service, ids []string, data []byte) (interface{}, error) {
+			var group types.Group
+			if err := decode(data, &group); err != nil {
+				return nil, err
+			}
+			identify(&group.Id, ids, 0)
+			return s.UpdateGroup(ctx, group)
+		},
+		patch: func(ctx context.Context, s *cybr_pam_scim.Service, ids []string, patch *types.PatchRequest) (interface{}, error) {
+			return s.PatchGroup(ctx, ids[0], patch)
+		},
+		delete: func(ctx context.Context, s *cybr_pam_scim.Service, ids []string) error {
+			return s.DeleteGroup(ctx, ids[0])
+		},
+	},
+	"safes": {
+		ids:     []string{"name"},
+		columns: columns("name", "description", "urn:ietf:params:scim:schemas:cyberark:1.0:Safe:ManagingCPM"),
+		list: func(ctx context.Context, s *cybr_pam_scim.Service, opts *cybr_pam_scim.ListOptions) ([]interface{}, error) {
+			return list(ctx, s.Safes(), opts)
+		},
+		get: func(ctx context.Context, s *cybr_pam_scim.Service, ids []string, opts ...cybr_pam_scim.ReadOption) (interface{}, error) {
+			return s.GetSafeByName(ctx, ids[0], opts...)
+		},
+		create: func(ctx context.Context, s *cybr_pam_scim.Service, data []byte) (interface{}, error) {
+			var safe types.Container
+			if err := decode(data, &safe); err != nil {
+				return nil, err
+			}
+			return s.AddSafe(ctx, safe)
+		},
+		update: func(ctx context.Context, s *cybr_pam_scim.Service, ids []string, data []byte) (interface{}, error) {
+			var safe types.Container
+			if err := decode(data, &safe); err != nil {
+				return nil, err
+			}
+			identify(&safe.Name, ids, 0)
+			// Safes are identified by their name
+			identify(&safe.Id, []string{safe.Name}, 0)
+			return s.UpdateSafe(ctx, safe)
+		},
+		patch: func(ctx context.Context, s *cybr_pam_scim.Service, ids []string, patch *types.PatchRequest) (interface{}, error) {
+			return s.PatchSafe(ctx, ids[0], patch)
+		},
+		delete: func(ctx context.Context, s *cybr_pam_scim.Service, ids []string) error {
+			return s.DeleteSafe(ctx, ids[0])
+		},
+	},
+	"safe-permissions": {
+		ids:     []string{"safe", "member"},
+		columns: columns("container.name", "user.display", "group.display", "rights"),
+		list: func(ctx context.Context, s *cybr_pam_scim.Service, opts *cybr_pam_scim.ListOptions) ([]interface{}, error) {
+			return list(ctx, s.SafePermissions(), opts)
+		},
+		get: func(ctx context.Context, s *cybr_pam_scim.Service, ids []string, opts ...cybr_pam_scim.ReadOption) (interface{}, error) {
+			return s.GetSafePermissionsByName(ctx, ids[0], ids[1], opts...)
+		},
+		create: func(ctx context.Context, s *cybr_pam_scim.Service, data []byte) (interface{}, error) {
+			var permission types.ContainerPermission
+			if err := decode(data, &permission); err != nil {
+				return nil, err
+			}
+			return s.AddSafePermissions(ctx, permission)
+		},
+		update: func(ctx context.Context, s *cybr_pam_scim.Service, ids []string, data []byte) (interface{}, error) {
+			var permission types.ContainerPermission
+			if err := decode(data, &permission); err != nil {
+				return nil, err
+			}
+			identify(&permission.Container.Name, ids, 0)
+			if permission.User.Display == "" && permission.Group.Display == "" {
+				return nil, errors.New("the file must set user.display or group.display of the safe member")
+			}
+			return s.UpdateSafePermissions(ctx, permission)
+		},
+		patch: func(ctx context.Context, s *cybr_pam_scim.Service, ids []string, patch *types.PatchRequest) (interface{}, error) {
+			return s.PatchSafePermission(ctx, ids[0], ids[1], patch)
+		},
+		delete: func(ctx context.Context, s *cybr_pam_scim.Service, ids []string) error {
+			return s.DeleteSafePermission(ctx, ids[0], ids[1])
+		},
+	},
+	"privileged-data": {
+		ids:     []string{"id"},
+		columns: columns("id", "name", "type", "urn:ietf:params:scim:schemas:cyberark:1.0:PrivilegedData:safe"),
+		list: func(ctx context.Context, s *cybr_pam_scim.Service, opts *cybr_pam_scim.ListOptions) ([]interface{}, error) {
+			return list(ctx, s.PrivilegedData(), opts)
+		},
+		get: func(ctx context.Context, s *cybr_pam_scim.Service, ids []string, opts ...cybr_pam_scim.ReadOption) (interface{}, error) {
+			return s.GetPrivilegedDataById(ctx, ids[0], opts...)
+		},
+		create: func(ctx context.Context, s *cybr_pam_scim.Service, data []byte) (interface{}, error) {
+			var privilegedData types.PrivilegedData
+			if err := decode(data, &privilegedData); err != nil {
+				return nil, err
+			}
+			return s.AddPrivilegedData(ctx, privilegedData)
+		},
+		update: func(ctx context.Context, s *cybr_pam_scim.Service, ids []string, data []byte) (interface{}, error) {
+			var privilegedData types.PrivilegedData
+			if err := decode(data, &privilegedData); err != nil {
+				return nil, err
+			}
+			identify(&privilegedData.Id, ids, 0)
+			return s.UpdatePrivilegedData(ctx, privilegedData)
+		},
+		patch: func(ctx context.Context, s *cybr_pam_scim.Service, ids []string, patch *types.PatchRequest) (interface{}, error) {
+			return s.PatchPrivilegedData(ctx, ids[0], patch)
+		},
+		delete: func(ctx context.Context, s *cybr_pam_scim.Service, ids []string) error {
+			return s.DeletePrivilegedData(ctx, ids[0])
+		},
+	},
+}
+
+// run performs a verb on the resource and writes the result
+func (r *resource) run(ctx context.Context, s *cybr_pam_scim.Service, verb string, ids []string, opts options, format *formatter) error {
+	arity := func(accepted ...int) error {
+		for _, n := range accepted {
+			if len(ids) == n {
+				return nil
+			}
+		}
+		if len(r.ids) == 1 {
+			return fmt.Errorf("%s requires the %s of the resource", verb, r.ids[0])
+		}
+		return fmt.Errorf("%s requires the %s of the resource", verb, strings.Join(r.ids, " and "))
+	}
+
+	switch verb {
+	case "list":
+		if err := arity(0); err != nil {
+			return err
+		}
+		listOptions := &cybr_pam_scim.ListOptions{
+			SortBy:             opts.sort,
+			SortOrder:          opts.sortOrder,
+			PageSize:           opts.pageSize,
+			Attributes:         opts.attributes,
+			ExcludedAttributes: opts.excludedAttributes,
+		}
+		if opts.filter != "" {
+			expr, err := filter.Parse(opts.filter)
+			if err != nil {
+				return fmt.Errorf("invalid filter provided: %w", err)
+			}
+			listOptions.Filter = expr
+		}
+		values, err := r.list(ctx, s, listOptions)
+		if err != nil {
+			return err
+		}
+		return format.write(r.columns, opts.attributes, values, false)
+	case "get":
+		if err := arity(len(r.ids)); err != nil {
+			return err
+		}
+		value, err := r.get(ctx, s, ids, cybr_pam_scim.Attributes(opts.attributes...), cybr_pam_scim.ExcludedAttributes(opts.excludedAttributes...))
+		if err != nil {
+			return err
+		}
+		return format.write(r.columns, opts.attributes, []interface{}{value}, true)
+	case "create":
+		if err := arity(0); err != nil {
+			return err
+		}
+		data, err := readFile(opts.file)
+		if err != nil {
+			return err
+		}
+		value, err := r.create(ctx, s, data)
+		if err != nil {
+			return err
+		}
+		return format.write(r.columns, opts.attributes, []interface{}{value}, true)
+	case "update":
+		// Identifiers are optional, the file may identify the resource
+		if len(ids) > len(r.ids) {
+			return arity(len(r.ids))
+		}
+		data, err := readFile(opts.file)
+		if err != nil {
+			return err
+		}
+		value, err := r.update(ctx, s, ids, data)
+		if err != nil {
+			return err
+		}
+		return format.write(r.columns, opts.attributes, []interface{}{value}, true)
+	case "patch":
+		if err := arity(len(r.ids)); err != nil {
+			return err
+		}
+		patch, err := patchRequest(opts)
+		if err != nil {
+			return err
+		}
+		value, err := r.patch(ctx, s, ids, patch)
+		if err != nil {
+			return err
+		}
+		return format.write(r.columns, opts.attributes, []interface{}{value}, true)
+	case "delete":
+		if err := arity(len(r.ids)); err != nil {
+			return err
+		}
+		return r.delete(ctx, s, ids)
+	}
+
+	return fmt.Errorf("unknown verb %q, accepted values are list, get, create, update, patch, or delete", verb)
+}
+
+// discover writes the service provider configuration, resource types, or schemas
+func discover(ctx context.Context, s *cybr_pam_scim.Service, verb string, opts options, format *formatter) error {
+	switch verb {
+	case "config":
+		config, err := s.GetServiceProviderConfig(ctx)
+		if err != nil {
+			return err
+		}
+		return format.write(columns("patch.supported", "bulk.supported", "bulk.maxOperations", "filter.supported", "filter.maxResults", "sort.supported", "etag.supported", "changePassword.supported"), opts.attributes, []interface{}{config}, true)
+	case "resource-types":
+		resourceTypes, err := s.GetResourceTypes(ctx)
+		if err != nil {
+			return err
+		}
+		return format.write(columns("name", "endpoint", "schema"), opts.attributes, values(resourceTypes.Resources), false)
+	case "schemas":
+		schemas, err := s.GetSchemas(ctx)
+		if err != nil {
+			return err
+		}
+		return format.write(columns("id", "name", "description"), opts.attributes, values(schemas.Resources), false)
+	}
+
+	return fmt.Errorf("unknown discovery %q, accepted values are config, resource-types, or schemas", verb)
+}
+
+func list[T any](ctx context.Context, c *cybr_pam_scim.Collection[T], opts *cybr_pam_scim.ListOptions) ([]interface{}, error) {
+	resources, err := c.List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return values(resources), nil
+}
+
+func values[T any](resources []T) []interface{} {
+	result := make([]interface{}, 0, len(resources))
+	for i := range resources {
+		result = append(result, &resources[i])
+	}
+
+	return result
+}
+
+// identify sets an identifying attribute from the identifier arguments unless the file sets it
+func identify(attribute *string, ids []string, index int) {
+	if *attribute == "" && index < len(ids) {
+		*attribute = ids[index]
+	}
+}
+
+// readFile reads the file of the --file flag, - reads standard input
+func readFile(path string) ([]byte, error) {
+	switch path {
+	case "":
+		return nil, errors.New("--file is required")
+	case "-":
+		return io.ReadAll(os.Stdin)
+	}
+
+	return os.ReadFile(path)
+}
+
+// decode unmarshals a JSON or YAML document into v. YAML is converted to JSON first so
+// that the json tags of the types package apply.
+func decode(data []byte, v interface{}) error {
+	var document interface{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return fmt.Errorf("failed to parse file: %w", err)
+	}
+	converted, err := json.Marshal(document)
+	if err != nil {
+		return fmt.Errorf("failed to parse file: %w", err)
+	}
+	if err := json.Unmarshal(converted, v); err != nil {
+		return fmt.Errorf("failed to parse file: %w", err)
+	}
+
+	return nil
+}
+
+// patchRequest builds the PATCH request from the --file flag followed by the operations of
+// the --add, --replace, and --remove flags
+func patchRequest(opts options) (*types.PatchRequest, error) {
+	patch := types.NewPatchRequest()
+	if opts.file != "" {
+		data, err := readFile(opts.file)
+		if err != nil {
+			return nil, err
+		}
+		if err := decode(data, patch); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, operation := range opts.add {
+		path, value, err := patchValue(operation)
+		if err != nil {
+			return nil, err
+		}
+		patch.Add(path, value)
+	}
+	for _, operation := range opts.replace {
+		path, value, err := patchValue(operation)
+		if err != nil {
+			return nil, err
+		}
+		patch.Replace(path, value)
+	}
+	for _, path := range opts.remove {
+		patch.Remove(path)
+	}
+	if len(patch.Operations) == 0 {
+		return nil, errors.New("patch requires --file, --add, --replace, or --remove")
+	}
+
+	return patch, nil
+}
+
+// patchValue splits path=value, the value is decoded as JSON and used as string otherwise
+func patchValue(operation string) (string, interface{}, error) {
+	path, raw, ok := strings.Cut(operation, "=")
+	if !ok {
+		return "", nil, fmt.Errorf("invalid patch operation %q, expected path=value", operation)
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		value = raw
+	}
+
+	return path, value, nil
+}
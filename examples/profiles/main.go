@@ -0,0 +1,42 @@
+package main
+
+////// Profiles Overview /////////////////////////////////////////////////////////
+//
+// This example loads a named profile from config.yml and builds a Service with
+// the authentication method of the profile. The profile is selected with the
+// CYBR_SCIM_PROFILE environment variable (e.g. CYBR_SCIM_PROFILE=staging) and
+// every setting may be overridden by its environment variable, e.g.
+// CYBR_SCIM_CLIENT_SECRET.
+//
+//////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/config"
+)
+
+func main() {
+	// Load and validate the profile, every missing or invalid setting is reported
+	p, err := config.Load(config.Options{File: "config.yml"})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Create the Service with the authentication, proxy, and TLS settings of the profile
+	s, err := config.NewServiceFromProfile(p)
+	if err != nil {
+		log.Fatalf("Authentication Failed. %s", err)
+	}
+
+	// Utilize the service to interact with the SCIM API
+	users, err := s.Users().List(context.Background(), nil)
+	if err != nil {
+		log.Fatalf("Error Retrieving users. %s", err)
+	}
+	for _, user := range users {
+		fmt.Printf("%s: %s\n", p.Name, user.UserName)
+	}
+}
@@ -0,0 +1,172 @@
+package cybr_pam_scim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/filter"
+)
+
+// commonAttributes are defined for every resource type without being part of its schemas
+var commonAttributes = []string{"id", "externalId", "meta", "schemas"}
+
+// ReadOption restricts the attributes returned by the Get functions
+type ReadOption func(*readOptions)
+
+type readOptions struct {
+	attributes         []string
+	excludedAttributes []string
+}
+
+// Attributes requests only the listed attributes (RFC 7644 section 3.4.2.5), in addition to
+// the attributes the service provider always returns such as id. Sub-attributes are listed as
+// "name.givenName", extension attributes and whole extensions by their schema URI.
+//
+// Example Usage:
+//		user, err := s.GetUserById(context.Background, "8", cybr_pam_scim.Attributes("userName", "emails.value"))
+//
+func Attributes(names ...string) ReadOption {
+	return func(o *readOptions) {
+		o.attributes = append(o.attributes, names...)
+	}
+}
+
+// ExcludedAttributes requests every attribute returned by default except the listed attributes
+// (RFC 7644 section 3.4.2.5). It cannot be combined with Attributes.
+//
+// Example Usage:
+//		accounts, err := s.GetPrivilegedData(context.Background,
+//			cybr_pam_scim.ExcludedAttributes("urn:ietf:params:scim:schemas:cyberark:1.0:PrivilegedData:properties"))
+//
+func ExcludedAttributes(names ...string) ReadOption {
+	return func(o *readOptions) {
+		o.excludedAttributes = append(o.excludedAttributes, names...)
+	}
+}
+
+// readPath appends the attributes or excludedAttributes query parameter of the options to the
+// path of a GET request after validating the attribute names of the resource type
+func (s *Service) readPath(ctx context.Context, resourceType string, path string, opts []ReadOption) (string, error) {
+	var o readOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	query := url.Values{}
+	if err := s.attributeQuery(ctx, resourceType, query, o.attributes, o.excludedAttributes); err != nil {
+		return "", err
+	}
+	if len(query) == 0 {
+		return path, nil
+	}
+	if strings.Contains(path, "?") {
+		return path + "&" + encodeQuery(query), nil
+	}
+
+	return path + "?" + encodeQuery(query), nil
+}
+
+// attributeQuery validates the attribute names and sets the attributes or excludedAttributes
+// query parameter
+func (s *Service) attributeQuery(ctx context.Context, resourceType string, query url.Values, attributes []string, excludedAttributes []string) error {
+	if len(attributes) == 0 && len(excludedAttributes) == 0 {
+		return nil
+	}
+	if len(attributes) > 0 && len(excludedAttributes) > 0 {
+		return errors.New("attributes and excludedAttributes cannot be combined")
+	}
+
+	capabilities := s.capabilities(ctx)
+	if err := capabilities.ValidateAttributes(resourceType, attributes...); err != nil {
+		return fmt.Errorf("invalid attributes provided: %w", err)
+	}
+	if err := capabilities.ValidateAttributes(resourceType, excludedAttributes...); err != nil {
+		return fmt.Errorf("invalid excludedAttributes provided: %w", err)
+	}
+
+	if len(attributes) > 0 {
+		query.Set("attributes", strings.Join(attributes, ","))
+	}
+	if len(excludedAttributes) > 0 {
+		query.Set("excludedAttributes", strings.Join(excludedAttributes, ","))
+	}
+
+	return nil
+}
+
+// ValidateAttributes reports an error wrapping filter.ErrUnknownAttribute if an attribute name
+// is not defined by the schemas of the resource type (e.g. User, Container). Nothing is
+// validated if the service provider does not publish the resource type, and the attributes of
+// a schema it does not publish are accepted.
+func (c *Capabilities) ValidateAttributes(resourceType string, names ...string) error {
+	rt, ok := c.ResourceType(resourceType)
+	if !ok {
+		return nil
+	}
+	schemaIds := []string{rt.Schema}
+	for _, extension := range rt.SchemaExtensions {
+		schemaIds = append(schemaIds, extension.Schema)
+	}
+
+	for _, name := range names {
+		if !c.hasAttribute(rt.Schema, schemaIds, name) {
+			return fmt.Errorf("%w %q for %s", filter.ErrUnknownAttribute, name, rt.Name)
+		}
+	}
+
+	return nil
+}
+
+// hasAttribute reports whether one of the schemas defines the attribute, unqualified
+// attributes belong to the core schema
+func (c *Capabilities) hasAttribute(core string, schemaIds []string, name string) bool {
+	// A schema URI selects every attribute of an extension
+	if containsFold(schemaIds, name) {
+		return true
+	}
+
+	path := filter.ParsePath(name)
+	if path.URI == "" {
+		path.URI = core
+	}
+	if strings.EqualFold(path.URI, core) && containsFold(commonAttributes, path.Name) {
+		return true
+	}
+	if !containsFold(schemaIds, path.URI) {
+		return false
+	}
+	schema, ok := c.Schema(path.URI)
+	if !ok {
+		return true
+	}
+
+	for _, attr := range schema.Attributes {
+		if !strings.EqualFold(attr.Name, path.Name) {
+			continue
+		}
+		if path.SubAttribute == "" || len(attr.SubAttributes) == 0 {
+			return true
+		}
+		for _, sub := range attr.SubAttributes {
+			if strings.EqualFold(sub.Name, path.SubAttribute) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,165 @@
+package cybr_pam_scim_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/filter"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/scimtest"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
+)
+
+// queries records the query of the requests to the Users endpoint
+type queries struct {
+	mu   sync.Mutex
+	list []url.Values
+}
+
+func (q *queries) middleware(next cybr_pam_scim.Handler) cybr_pam_scim.Handler {
+	return func(r *http.Request) (*http.Response, error) {
+		if strings.Contains(strings.ToLower(r.URL.Path), "/users") {
+			q.mu.Lock()
+			q.list = append(q.list, r.URL.Query())
+			q.mu.Unlock()
+		}
+		return next(r)
+	}
+}
+
+func (q *queries) last() url.Values {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.list) == 0 {
+		return nil
+	}
+
+	return q.list[len(q.list)-1]
+}
+
+// recordingService returns a Service of the server recording the queries of the Users endpoint
+func recordingService(srv *scimtest.Server) (*cybr_pam_scim.Service, *queries) {
+	q := &queries{}
+	client := cybr_pam_scim.NewClient(srv.Client(), cybr_pam_scim.Options{ApiURL: srv.URL})
+	client.Use(q.middleware)
+
+	return cybr_pam_scim.NewServiceWithClient(client), q
+}
+
+func TestAttributesQuery(t *testing.T) {
+	srv := scimtest.NewServer()
+	defer srv.Close()
+	id, err := srv.Seed("Users", types.User{UserName: "john.smith", DisplayName: "John Smith"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, q := recordingService(srv)
+	ctx := context.Background()
+
+	attributes := []string{
+		"userName",
+		"name.givenName",
+		"emails.value",
+		"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department",
+		"urn:ietf:params:scim:schemas:cyberark:1.0:User",
+	}
+	user, err := s.GetUserById(ctx, id, cybr_pam_scim.Attributes(attributes[:2]...), cybr_pam_scim.Attributes(attributes[2:]...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := q.last()
+	if got, want := query.Get("attributes"), strings.Join(attributes, ","); got != want || query.Get("excludedAttributes") != "" {
+		t.Errorf("query = %v, want attributes=%s", query, want)
+	}
+	if user.UserName != "john.smith" || user.DisplayName != "" {
+		t.Errorf("user = %+v, want only userName", user)
+	}
+
+	// Appended to the query of the path
+	excluded := []string{"displayName", "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"}
+	if _, err := s.GetUsersIndex(ctx, 1, 5, cybr_pam_scim.ExcludedAttributes(excluded...)); err != nil {
+		t.Fatal(err)
+	}
+	query = q.last()
+	if query.Get("startIndex") != "1" || query.Get("count") != "5" || query.Get("excludedAttributes") != strings.Join(excluded, ",") || query.Get("attributes") != "" {
+		t.Errorf("query = %v, want startIndex, count, and excludedAttributes", query)
+	}
+
+	// No query parameter without options
+	if _, err := s.GetUserById(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if query := q.last(); len(query) != 0 {
+		t.Errorf("query = %v, want none", query)
+	}
+}
+
+func TestAttributesCombined(t *testing.T) {
+	srv := scimtest.NewServer()
+	defer srv.Close()
+	s, q := recordingService(srv)
+
+	_, err := s.GetUserById(context.Background(), "1", cybr_pam_scim.Attributes("userName"), cybr_pam_scim.ExcludedAttributes("displayName"))
+	if err == nil || !strings.Contains(err.Error(), "attributes and excludedAttributes cannot be combined") {
+		t.Errorf("error = %v, want attributes and excludedAttributes rejected", err)
+	}
+	if q.last() != nil {
+		t.Errorf("request sent with %v", q.last())
+	}
+}
+
+func TestAttributesUnknown(t *testing.T) {
+	srv := scimtest.NewServer()
+	defer srv.Close()
+	s, q := recordingService(srv)
+	ctx := context.Background()
+
+	tests := []struct {
+		opt  cybr_pam_scim.ReadOption
+		want string
+	}{
+		{cybr_pam_scim.Attributes("userName", "nickname2"), `invalid attributes provided: unknown attribute "nickname2" for User`},
+		{cybr_pam_scim.Attributes("name.nickName"), `invalid attributes provided: unknown attribute "name.nickName" for User`},
+		{cybr_pam_scim.ExcludedAttributes("urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:salary"), `invalid excludedAttributes provided: unknown attribute "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:salary" for User`},
+		// An extension which is not a schema of the resource type
+		{cybr_pam_scim.Attributes("urn:ietf:params:scim:schemas:cyberark:1.0:PrivilegedData:safe"), `invalid attributes provided: unknown attribute "urn:ietf:params:scim:schemas:cyberark:1.0:PrivilegedData:safe" for User`},
+	}
+	for _, tt := range tests {
+		_, err := s.GetUserById(ctx, "1", tt.opt)
+		if !errors.Is(err, filter.ErrUnknownAttribute) || !strings.HasSuffix(err.Error(), tt.want) {
+			t.Errorf("error = %v, want %s", err, tt.want)
+		}
+	}
+	if q.last() != nil {
+		t.Errorf("request sent with %v", q.last())
+	}
+
+	// Attribute names are case insensitive
+	if _, err := s.GetUsersIndex(ctx, 1, 5, cybr_pam_scim.Attributes("USERNAME", "Name.GivenName", "META")); err != nil {
+		t.Errorf("error = %v, want case insensitive names accepted", err)
+	}
+}
+
+func TestValidateAttributesWithoutCapabilities(t *testing.T) {
+	var capabilities *cybr_pam_scim.Capabilities
+	if err := capabilities.ValidateAttributes("User", "nickname2"); err != nil {
+		t.Errorf("error = %v, want nothing validated", err)
+	}
+
+	// The attributes are sent unvalidated when the discovery fails
+	srv := scimtest.NewServer()
+	defer srv.Close()
+	srv.InjectFault(scimtest.Fault{Method: http.MethodGet, Path: "/Schemas", Status: http.StatusNotFound})
+	s, q := recordingService(srv)
+	if _, err := s.GetUsersIndex(context.Background(), 1, 5, cybr_pam_scim.Attributes("nickname2")); err != nil {
+		t.Fatal(err)
+	}
+	if query := q.last(); query.Get("attributes") != "nickname2" {
+		t.Errorf("query = %v, want attributes=nickname2", query)
+	}
+}
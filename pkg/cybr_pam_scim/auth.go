@@ -3,6 +3,7 @@ package cybr_pam_scim
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/clientcredentials"
@@ -13,12 +14,71 @@ type tokenSource struct {
 	conf *clientcredentials.Config
 }
 
+// Token requests a new token from the SCIM server using Client Credentials
+func (ts *tokenSource) Token() (*oauth2.Token, error) {
+	return ts.conf.Token(ts.ctx)
+}
+
+type refreshTokenSource struct {
+	mu    sync.Mutex
+	ctx   context.Context
+	conf  *oauth2.Config
+	token *oauth2.Token
+	used  bool
+}
+
+// Token returns the initial Resource Owner token while it is valid, every later call
+// redeems the Refresh Token for a new token.
+func (ts *refreshTokenSource) Token() (*oauth2.Token, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if !ts.used && ts.token.Valid() {
+		ts.used = true
+		return ts.token, nil
+	}
+
+	authToken, err := ts.conf.TokenSource(ts.ctx, &oauth2.Token{RefreshToken: ts.token.RefreshToken}).Token()
+	if err != nil {
+		return nil, err
+	}
+	ts.token = authToken
+	ts.used = true
+
+	return authToken, nil
+}
+
 // OauthCredClient returns a validated Oauth2 Authentication Token based on the following provided information:
 //   clientID - Username for the SCIM Application (e.g. "identity-privilege-integration-user$@example.com")
 //   clientSecret - Password for the SCIM Application
 //   clientAppID - ID for the SCIM Application
 //   clientURL - URL for the SCIM Application (e.g. "example.my.idaptive.app")
 func OauthCredClient(clientID, clientSecret, clientAppID, clientURL string) (*oauth2.Token, error) {
+	// Request new token from SCIM server using Client Credentials
+	authToken, err := OauthCredClientTokenSource(clientID, clientSecret, clientAppID, clientURL).Token()
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain SCIM Oauth2 Token %w", err)
+	}
+
+	return authToken, nil
+}
+
+// OauthCredClientTokenSource returns an oauth2.TokenSource which requests a new Oauth2 Authentication
+// Token via the Client Credentials workflow every time it is called. It accepts the same information
+// as OauthCredClient and is intended for use with NewServiceWithTokenSource.
+//
+// Example Usage:
+//		ts := cybr_pam_scim.OauthCredClientTokenSource(clientId, clientSecret, clientAppId, clientUrl)
+//		s := cybr_pam_scim.NewServiceWithTokenSource(clientUrl, "scim", "v2", false, ts)
+//
+func OauthCredClientTokenSource(clientID, clientSecret, clientAppID, clientURL string) oauth2.TokenSource {
+	return OauthCredClientTokenSourceContext(context.Background(), clientID, clientSecret, clientAppID, clientURL)
+}
+
+// OauthCredClientTokenSourceContext is OauthCredClientTokenSource with a context used for every
+// token request. An *http.Client stored under the oauth2.HTTPClient context key is used to
+// request tokens, e.g. to honor proxy and TLS settings.
+func OauthCredClientTokenSourceContext(ctx context.Context, clientID, clientSecret, clientAppID, clientURL string) oauth2.TokenSource {
 	// Establish oauth2/clientcredentials config with user provided data
 	var credentialConfig = clientcredentials.Config{
 		ClientID:     clientID,
@@ -29,13 +89,29 @@ func OauthCredClient(clientID, clientSecret, clientAppID, clientURL string) (*oa
 	}
 
 	// Create tokenSource with provided configuration info
-	ts := &tokenSource{
-		ctx:  context.Background(),
+	return &tokenSource{
+		ctx:  ctx,
 		conf: &credentialConfig,
 	}
+}
 
-	// Request new token from SCIM server using Client Credentials
-	authToken, err := ts.conf.Token(context.Background())
+// OauthResourceOwner returns a validated Oauth2 Authentication Token with Refresh Token based on the following provided information:
+//   clientID - Username for the SCIM Application (e.g. "identity-privilege-integration-user$@example.com")
+//   clientSecret - Password for the SCIM Application
+//   clientAppID - ID for the SCIM Application
+//   clientURL - URL for the SCIM Application (e.g. "example.my.idaptive.app")
+//   resourceUsername - Username for the Resource Owner
+//   resourcePassword - Password for the Resource Owner
+func OauthResourceOwner(clientID, clientSecret, clientAppID, clientURL, resourceUsername, resourcePassword string) (*oauth2.Token, error) {
+	return OauthResourceOwnerContext(context.Background(), clientID, clientSecret, clientAppID, clientURL, resourceUsername, resourcePassword)
+}
+
+// OauthResourceOwnerContext is OauthResourceOwner with a context used for the token request. An
+// *http.Client stored under the oauth2.HTTPClient context key is used to request the token.
+func OauthResourceOwnerContext(ctx context.Context, clientID, clientSecret, clientAppID, clientURL, resourceUsername, resourcePassword string) (*oauth2.Token, error) {
+	conf := resourceOwnerConfig(clientID, clientSecret, clientAppID, clientURL)
+
+	authToken, err := conf.PasswordCredentialsToken(ctx, resourceUsername, resourcePassword)
 	if err != nil {
 		return nil, fmt.Errorf("failed to obtain SCIM Oauth2 Token %w", err)
 	}
@@ -43,34 +119,47 @@ func OauthCredClient(clientID, clientSecret, clientAppID, clientURL string) (*oa
 	return authToken, nil
 }
 
-// OauthResourceOwner returns a validated Oauth2 Authentication Token with Refresh Token based on the following provided information:
+// OauthResourceOwnerTokenSource returns an oauth2.TokenSource which redeems the Refresh Token of an
+// Oauth2 Authentication Token previously returned by OauthResourceOwner. The provided token is
+// returned first while it is valid, later calls request a new token with the Refresh Token.
 //   clientID - Username for the SCIM Application (e.g. "identity-privilege-integration-user$@example.com")
 //   clientSecret - Password for the SCIM Application
 //   clientAppID - ID for the SCIM Application
 //   clientURL - URL for the SCIM Application (e.g. "example.my.idaptive.app")
-//   resourceUsername - Username for the Resource Owner
-//   resourcePassword - Password for the Resource Owner
-func OauthResourceOwner(clientID, clientSecret, clientAppID, clientURL, resourceUsername, resourcePassword string) (*oauth2.Token, error) {
+//   authToken - Token containing the Refresh Token returned by OauthResourceOwner
+//
+// Example Usage:
+//		authToken, err := cybr_pam_scim.OauthResourceOwner(clientId, clientSecret, clientAppId, clientUrl, username, password)
+//		ts := cybr_pam_scim.OauthResourceOwnerTokenSource(clientId, clientSecret, clientAppId, clientUrl, authToken)
+//		s := cybr_pam_scim.NewServiceWithTokenSource(clientUrl, "scim", "v2", false, ts)
+//
+func OauthResourceOwnerTokenSource(clientID, clientSecret, clientAppID, clientURL string, authToken *oauth2.Token) oauth2.TokenSource {
+	return OauthResourceOwnerTokenSourceContext(context.Background(), clientID, clientSecret, clientAppID, clientURL, authToken)
+}
+
+// OauthResourceOwnerTokenSourceContext is OauthResourceOwnerTokenSource with a context used for
+// every token request. An *http.Client stored under the oauth2.HTTPClient context key is used to
+// request tokens.
+func OauthResourceOwnerTokenSourceContext(ctx context.Context, clientID, clientSecret, clientAppID, clientURL string, authToken *oauth2.Token) oauth2.TokenSource {
+	return &refreshTokenSource{
+		ctx:   ctx,
+		conf:  resourceOwnerConfig(clientID, clientSecret, clientAppID, clientURL),
+		token: authToken,
+	}
+}
+
+func resourceOwnerConfig(clientID, clientSecret, clientAppID, clientURL string) *oauth2.Config {
 	endpoint := oauth2.Endpoint{
 		AuthURL:   "https://" + clientURL + "/oauth2/authorize/" + clientAppID,
 		TokenURL:  "https://" + clientURL + "/oauth2/token/" + clientAppID,
 		AuthStyle: 0,
 	}
 
-	// Establish oauth2/clientcredentials config with user provided data
-	var resourceOwnerConfig = oauth2.Config{
+	// Establish oauth2 config with user provided data
+	return &oauth2.Config{
 		ClientID:     clientID,
 		ClientSecret: clientSecret,
 		Endpoint:     endpoint,
 		Scopes:       []string{"scim"},
 	}
-
-	ctx := context.Background()
-
-	authToken, err := resourceOwnerConfig.PasswordCredentialsToken(ctx, resourceUsername, resourcePassword)
-	if err != nil {
-		return nil, fmt.Errorf("failed to obtain SCIM Oauth2 Token %w", err)
-	}
-
-	return authToken, nil
 }
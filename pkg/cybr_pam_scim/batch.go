@@ -0,0 +1,27 @@
+package cybr_pam_scim
+
+// defaultMaxPayloadSize is the request size limit assumed when the service provider does not provide one
+const defaultMaxPayloadSize = 1 << 20
+
+// splitBatches splits items into batches of at most maxItems items whose combined size, as
+// reported by size, does not exceed maxSize. An item larger than maxSize is placed in its own batch.
+// Limits lower than one are ignored.
+func splitBatches[T any](items []T, maxItems int, maxSize int, size func(T) int) [][]T {
+	var batches [][]T
+	var batch []T
+	batchSize := 0
+	for _, item := range items {
+		itemSize := size(item)
+		if len(batch) > 0 && ((maxItems > 0 && len(batch) >= maxItems) || (maxSize > 0 && batchSize+itemSize > maxSize)) {
+			batches = append(batches, batch)
+			batch, batchSize = nil, 0
+		}
+		batch = append(batch, item)
+		batchSize += itemSize
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+
+	return batches
+}
@@ -0,0 +1,382 @@
+package cybr_pam_scim
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
+)
+
+// DefaultBulkMaxOperations is the number of operations per bulk request used when the
+// service provider does not provide a limit
+const DefaultBulkMaxOperations = 100
+
+// bulkRequestOverhead is reserved for the bulk request around its operations
+const bulkRequestOverhead = 256
+
+var (
+	ErrBulkNotSupported     = errors.New("bulk operations are not supported by the service provider")
+	ErrBulkOperationSkipped = errors.New("the bulk operation was not processed")
+)
+
+// BulkRef identifies an operation of a BulkRequest. The reference returned for a POST operation
+// may be used in later operations in place of the id of the resource it creates.
+type BulkRef struct {
+	Index  int
+	BulkId string
+}
+
+// String returns the reference in the "bulkId:<id>" form used by SCIM bulk requests
+func (r BulkRef) String() string {
+	return "bulkId:" + r.BulkId
+}
+
+// BulkRequest builds a SCIM bulk request mixing operations on any resource type.
+// Operations are sent in the order they were added.
+//
+// Example Usage:
+//		bulk := cybr_pam_scim.NewBulkRequest()
+//		userRef := bulk.Post("Users", types.User{UserName: "john.smith", ...})
+//		bulk.Post("ContainerPermissions", types.ContainerPermission{
+//			Container: types.ContainerRef{Name: "ExampleSafe"},
+//			User:      types.UserRef{Value: userRef.String()},
+//			Rights:    types.ViewerRights,
+//		})
+//		bulk.Delete("Users/12")
+//		result, err := s.Bulk(context.Background, bulk)
+//
+type BulkRequest struct {
+	// FailOnErrors stops processing once the number of operations rejected by the SCIM API reaches
+	// it, zero processes every operation. Operations which were not processed do not count.
+	FailOnErrors int
+
+	operations []types.BulkOperation
+	err        error
+}
+
+// NewBulkRequest returns an empty BulkRequest
+func NewBulkRequest() *BulkRequest {
+	return &BulkRequest{}
+}
+
+// Post adds an operation creating the data at the path (e.g. Users)
+func (b *BulkRequest) Post(path string, data interface{}) BulkRef {
+	return b.add(http.MethodPost, path, data)
+}
+
+// Put adds an operation replacing the resource at the path (e.g. Users/12)
+func (b *BulkRequest) Put(path string, data interface{}) BulkRef {
+	return b.add(http.MethodPut, path, data)
+}
+
+// Patch adds an operation modifying the resource at the path (e.g. Groups/3)
+func (b *BulkRequest) Patch(path string, patch *types.PatchRequest) BulkRef {
+	if err := patch.Validate(); err != nil && b.err == nil {
+		b.err = fmt.Errorf("invalid bulk operation %d: %w", len(b.operations), err)
+	}
+
+	return b.add(http.MethodPatch, path, patch)
+}
+
+// Delete adds an operation deleting the resource at the path (e.g. Containers/ExampleSafe)
+func (b *BulkRequest) Delete(path string) BulkRef {
+	return b.add(http.MethodDelete, path, nil)
+}
+
+// Len returns the number of operations
+func (b *BulkRequest) Len() int {
+	return len(b.operations)
+}
+
+func (b *BulkRequest) add(method, path string, data interface{}) BulkRef {
+	ref := BulkRef{Index: len(b.operations), BulkId: "op" + strconv.Itoa(len(b.operations)+1)}
+	operation := types.BulkOperation{
+		Method: method,
+		BulkId: ref.BulkId,
+		Path:   "/" + strings.TrimPrefix(path, "/"),
+	}
+	if data != nil {
+		encoded, err := json.Marshal(data)
+		if err != nil && b.err == nil {
+			b.err = fmt.Errorf("invalid bulk operation %d: %w", ref.Index, err)
+		}
+		operation.Data = encoded
+	}
+	b.operations = append(b.operations, operation)
+
+	return ref
+}
+
+// BulkOperationResult is the result of a single operation of a BulkRequest. Err is nil for
+// successful operations, a *ScimError for operations rejected by the SCIM API, and
+// ErrBulkOperationSkipped for operations which were not processed.
+type BulkOperationResult struct {
+	Method   string
+	Path     string
+	BulkId   string
+	Location string
+	Version  string
+	Status   int
+	Err      error
+}
+
+// Id returns the id of the resource from its location, e.g. the id of a created resource
+func (r BulkOperationResult) Id() string {
+	if r.Location == "" {
+		return ""
+	}
+
+	return r.Location[strings.LastIndex(r.Location, "/")+1:]
+}
+
+// BulkResult holds the results of every operation of a BulkRequest in the order they were added.
+// Failed counts the operations rejected by the SCIM API and Skipped the operations which were
+// not processed, e.g. operations referencing a failed operation or following FailOnErrors.
+type BulkResult struct {
+	Operations []BulkOperationResult
+	Failed     int
+	Skipped    int
+}
+
+// Result returns the result of the referenced operation
+func (r *BulkResult) Result(ref BulkRef) BulkOperationResult {
+	return r.Operations[ref.Index]
+}
+
+// BulkError is returned by Bulk when some operations failed or were not processed.
+// The results of every operation are available in the returned BulkResult.
+type BulkError struct {
+	Failed  int
+	Skipped int
+	First   error
+}
+
+func (e *BulkError) Error() string {
+	return fmt.Sprintf("bulk request failed, %d operation(s) failed and %d operation(s) were not processed: %v", e.Failed, e.Skipped, e.First)
+}
+
+func (e *BulkError) Unwrap() error {
+	return e.First
+}
+
+// Bulk sends the operations of a BulkRequest to the SCIM API. Operations are split into
+// chunks respecting the maximum number of operations and payload size of the service provider.
+// References to resources created in an earlier chunk are replaced by their id, operations
+// referencing a failed operation are not sent. FailOnErrors applies to the request as a whole
+// and counts the operations rejected by the SCIM API only.
+//
+// The returned BulkResult contains one result per operation. If any operation failed a
+// *BulkError is returned along with the result.
+//
+// Example Usage:
+//		result, err := s.Bulk(context.Background, bulk)
+//		var bulkErr *cybr_pam_scim.BulkError
+//		if errors.As(err, &bulkErr) {
+//			for _, op := range result.Operations {
+//				if op.Err != nil {
+//					fmt.Println(op.Method, op.Path, op.Err)
+//				}
+//			}
+//		}
+//		userId := result.Result(userRef).Id()
+//
+func (s *Service) Bulk(ctx context.Context, bulk *BulkRequest) (*BulkResult, error) {
+	if bulk.err != nil {
+		return nil, bulk.err
+	}
+	capabilities := s.capabilities(ctx)
+	if !capabilities.BulkSupported() {
+		return nil, ErrBulkNotSupported
+	}
+	maxOperations := DefaultBulkMaxOperations
+	if n := capabilities.MaxBulkOperations(); n > 0 {
+		maxOperations = n
+	}
+	maxPayloadSize := defaultMaxPayloadSize
+	if n := capabilities.MaxBulkPayloadSize(); n > 0 {
+		maxPayloadSize = n
+	}
+
+	result := &BulkResult{Operations: make([]BulkOperationResult, len(bulk.operations))}
+	indexes := make([]int, len(bulk.operations))
+	for i, operation := range bulk.operations {
+		indexes[i] = i
+		result.Operations[i] = BulkOperationResult{Method: operation.Method, Path: operation.Path, BulkId: operation.BulkId, Err: ErrBulkOperationSkipped}
+	}
+
+	state := &bulkState{ids: map[string]string{}, failed: map[string]bool{}}
+	chunks := splitBatches(indexes, maxOperations, maxPayloadSize-bulkRequestOverhead, func(i int) int {
+		encoded, _ := json.Marshal(bulk.operations[i])
+		return len(encoded) + 1
+	})
+	for _, chunk := range chunks {
+		if bulk.FailOnErrors > 0 && state.errors >= bulk.FailOnErrors {
+			break
+		}
+
+		request := types.BulkRequest{Schemas: []string{types.BulkRequestSchema}}
+		if bulk.FailOnErrors > 0 {
+			request.FailOnErrors = bulk.FailOnErrors - state.errors
+		}
+		var sent []int
+		for _, i := range chunk {
+			operation, err := state.resolve(bulk.operations[i])
+			if err != nil {
+				result.Operations[i].Err = err
+				state.failed[operation.BulkId] = true
+				continue
+			}
+			request.Operations = append(request.Operations, operation)
+			sent = append(sent, i)
+		}
+		if len(sent) == 0 {
+			continue
+		}
+
+		var response types.BulkResponse
+		if err := s.client.Post(ctx, "/Bulk", request, &response); err != nil {
+			return result, fmt.Errorf("failed to send bulk request: %w", err)
+		}
+		state.record(result, bulk.operations, sent, response)
+	}
+
+	var first error
+	for _, operation := range result.Operations {
+		switch {
+		case operation.Err == nil:
+		case errors.Is(operation.Err, ErrBulkOperationSkipped):
+			result.Skipped++
+		default:
+			result.Failed++
+		}
+		if operation.Err != nil && first == nil {
+			first = operation.Err
+		}
+	}
+	if first != nil {
+		return result, &BulkError{Failed: result.Failed, Skipped: result.Skipped, First: first}
+	}
+
+	return result, nil
+}
+
+// bulkState tracks the ids of created resources and the failed operations across chunks.
+// errors counts the operations rejected by the SCIM API.
+type bulkState struct {
+	ids    map[string]string
+	failed map[string]bool
+	errors int
+}
+
+// fail records an operation rejected by the SCIM API
+func (st *bulkState) fail(bulkId string) {
+	st.failed[bulkId] = true
+	st.errors++
+}
+
+// created records the id of a resource created by a POST operation, from its location or the
+// returned resource. References to a resource without a known id cannot be resolved.
+func (st *bulkState) created(operationResult *BulkOperationResult, response json.RawMessage) {
+	id := operationResult.Id()
+	if id == "" && len(response) > 0 {
+		var resource struct {
+			Id string `json:"id"`
+		}
+		if err := json.Unmarshal(response, &resource); err == nil {
+			id = resource.Id
+		}
+	}
+	if id == "" {
+		st.failed[operationResult.BulkId] = true
+		return
+	}
+	st.ids[operationResult.BulkId] = id
+}
+
+// resolve replaces references to operations of earlier chunks by the id of the created resource
+func (st *bulkState) resolve(operation types.BulkOperation) (types.BulkOperation, error) {
+	for bulkId := range st.failed {
+		ref := "bulkId:" + bulkId
+		if bytes.Contains(operation.Data, []byte(quote(ref))) || hasPathSegment(operation.Path, ref) {
+			return operation, fmt.Errorf("operation references %s which failed or was not processed: %w", ref, ErrBulkOperationSkipped)
+		}
+	}
+	for bulkId, id := range st.ids {
+		ref := "bulkId:" + bulkId
+		operation.Data = bytes.ReplaceAll(operation.Data, []byte(quote(ref)), []byte(quote(id)))
+		if hasPathSegment(operation.Path, ref) {
+			segments := strings.Split(operation.Path, "/")
+			for i := range segments {
+				if segments[i] == ref {
+					segments[i] = id
+				}
+			}
+			operation.Path = strings.Join(segments, "/")
+		}
+	}
+
+	return operation, nil
+}
+
+// record maps the operations of a bulk response back to the operations which were sent,
+// by bulkId and falling back to their position
+func (st *bulkState) record(result *BulkResult, operations []types.BulkOperation, sent []int, response types.BulkResponse) {
+	byBulkId := make(map[string]int, len(sent))
+	for _, i := range sent {
+		byBulkId[operations[i].BulkId] = i
+	}
+
+	for position, operationResponse := range response.Operations {
+		i, ok := byBulkId[operationResponse.BulkId]
+		if !ok {
+			if position >= len(sent) {
+				continue
+			}
+			i = sent[position]
+		}
+		status, _ := strconv.Atoi(operationResponse.Status.String())
+		operationResult := &result.Operations[i]
+		operationResult.Location = operationResponse.Location
+		operationResult.Version = operationResponse.Version
+		operationResult.Status = status
+		operationResult.Err = nil
+		if status >= 200 && status < 300 {
+			if operationResult.Method == http.MethodPost {
+				st.created(operationResult, operationResponse.Response)
+			}
+			continue
+		}
+
+		scimErr := &ScimError{StatusCode: status, Method: operationResult.Method, URL: operationResult.Path, Body: operationResponse.Response}
+		var errorResponse types.ErrorResponse
+		if err := json.Unmarshal(operationResponse.Response, &errorResponse); err == nil {
+			scimErr.ScimType = errorResponse.ScimType
+			scimErr.Detail = errorResponse.Detail
+		}
+		operationResult.Err = scimErr
+		st.fail(operationResult.BulkId)
+	}
+
+	// Operations missing from the response were not processed, references to them cannot be resolved
+	for _, i := range sent {
+		if errors.Is(result.Operations[i].Err, ErrBulkOperationSkipped) {
+			st.failed[operations[i].BulkId] = true
+		}
+	}
+}
+
+func hasPathSegment(path, segment string) bool {
+	for _, s := range strings.Split(path, "/") {
+		if s == segment {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,52 @@
+package cybr_pam_scim_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/scimtest"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
+)
+
+func TestBulkFailOnErrorsCountsRejectedOperations(t *testing.T) {
+	srv := scimtest.NewServer()
+	defer srv.Close()
+	config := srv.Config()
+	config.Bulk.MaxOperations = 2
+	srv.SetConfig(config)
+	if _, err := srv.Seed("Users", types.User{UserName: "john.smith"}); err != nil {
+		t.Fatal(err)
+	}
+	s := srv.NewService()
+
+	bulk := cybr_pam_scim.NewBulkRequest()
+	bulk.FailOnErrors = 2
+	// Rejected as a duplicate, the PATCH referencing it is not sent with the second chunk
+	duplicate := bulk.Post("/Users", types.User{UserName: "john.smith"})
+	bulk.Post("/Users", types.User{UserName: "jane.doe"})
+	patch := bulk.Patch("/Users/"+duplicate.String(), types.NewPatchRequest().Replace("active", true))
+	bulk.Post("/Users", types.User{UserName: "mary.major"})
+	last := bulk.Post("/Users", types.User{UserName: "richard.roe"})
+
+	result, err := s.Bulk(context.Background(), bulk)
+	var bulkErr *cybr_pam_scim.BulkError
+	if !errors.As(err, &bulkErr) {
+		t.Fatalf("error = %v, want a *BulkError", err)
+	}
+	if result.Failed != 1 || result.Skipped != 1 || bulkErr.Failed != 1 || bulkErr.Skipped != 1 {
+		t.Errorf("failed = %d and skipped = %d, want 1 rejected and 1 skipped operation", result.Failed, result.Skipped)
+	}
+	if status := result.Result(duplicate).Status; status != http.StatusConflict {
+		t.Errorf("duplicate status = %d, want %d", status, http.StatusConflict)
+	}
+	if err := result.Result(patch).Err; !errors.Is(err, cybr_pam_scim.ErrBulkOperationSkipped) {
+		t.Errorf("patch error = %v, want ErrBulkOperationSkipped", err)
+	}
+	// The skipped operation does not count toward FailOnErrors, the third chunk is sent
+	if op := result.Result(last); op.Err != nil || op.Id() == "" {
+		t.Errorf("last operation = %+v, want it to be processed", op)
+	}
+}
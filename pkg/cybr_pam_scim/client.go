@@ -4,35 +4,112 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
-	"net/http/httputil"
+	"sync"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
+	"golang.org/x/oauth2"
 )
 
 type Options struct {
 	ApiURL  string
 	Verbose bool
+	Retry   *RetryPolicy
+	// UserAgent is sent as User-Agent header when set
+	UserAgent string
+	// Logger receives the verbose output, StdLogger with the standard logger is used when nil
+	Logger Logger
+	// Redaction hides secrets from the verbose output, DefaultRedactionPolicy is used when nil
+	Redaction *RedactionPolicy
+	// TokenSource authenticates the requests via AuthMiddleware when set
+	TokenSource oauth2.TokenSource
+	// Middlewares wrap every request, the first middleware is the outermost
+	Middlewares []Middleware
 }
 
+// Client performs the HTTP requests of a Service. A Client is safe for concurrent use
+// by multiple goroutines, Use, SetRetryPolicy, and SetRedactionPolicy may be called while it
+// is in use and apply to the requests sent afterwards.
+//
+// Requests are sent through a chain of middlewares: the Middlewares of the Options,
+// RetryMiddleware with the Retry policy, LoggingMiddleware when Verbose is set, and
+// AuthMiddleware when a TokenSource is set, followed by the Do function of the http.Client.
 type Client struct {
 	httpClient *http.Client
-	options    *Options
-}
+	auth       Middleware
 
-var (
-	ErrUserAccessDenied = errors.New("you do not have access to the requested resource")
-	ErrNotFound         = errors.New("the requested resource not found")
-	ErrTooManyRequests  = errors.New("you have exceeded throttle")
-)
+	mu      sync.RWMutex // guards options and handler
+	options *Options
+	handler Handler
+}
 
 func NewClient(httpClient *http.Client, options Options) *Client {
-	return &Client{
+	c := &Client{
 		httpClient: httpClient,
 		options:    &options,
 	}
+	if options.TokenSource != nil {
+		c.auth = AuthMiddleware(options.TokenSource)
+	}
+	c.build()
+
+	return c
+}
+
+// Use appends middlewares to the chain of the Client, they are called after the middlewares
+// added before.
+//
+// Example Usage:
+//		client.Use(func(next cybr_pam_scim.Handler) cybr_pam_scim.Handler {
+//			return func(r *http.Request) (*http.Response, error) {
+//				start := time.Now()
+//				resp, err := next(r)
+//				requestDuration.Observe(time.Since(start).Seconds())
+//				return resp, err
+//			}
+//		})
+//
+func (c *Client) Use(middlewares ...Middleware) {
+	c.configure(func(options *Options) {
+		options.Middlewares = append(options.Middlewares, middlewares...)
+	})
+}
+
+// configure modifies the Options and rebuilds the middleware chain, requests in flight keep
+// the chain they started with
+func (c *Client) configure(fn func(options *Options)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fn(c.options)
+	c.build()
+}
+
+// redaction returns the redaction policy of the Options
+func (c *Client) redaction() *RedactionPolicy {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.options.Redaction == nil {
+		return &DefaultRedactionPolicy
+	}
+
+	return c.options.Redaction
+}
+
+// build composes the middleware chain from the Options, the caller must hold mu unless the
+// Client is not shared yet
+func (c *Client) build() {
+	middlewares := append([]Middleware{}, c.options.Middlewares...)
+	middlewares = append(middlewares, RetryMiddleware(c.options.Retry))
+	if c.options.Verbose {
+		middlewares = append(middlewares, LoggingMiddleware(c.options.Logger, c.options.Redaction))
+	}
+	middlewares = append(middlewares, c.auth)
+
+	c.handler = chain(c.httpClient.Do, middlewares...)
 }
 
 type HTTPClient interface {
@@ -110,7 +187,7 @@ func (c *Client) Delete(ctx context.Context, path string, v interface{}) error {
 	return nil
 }
 
-////////////// REQUEST PROCESSING - newRequest, doRequest, do ///////////////////////////////////////////////
+////////////// REQUEST PROCESSING - newRequest, doRequest, do, send ///////////////////////////////////////////////
 
 func (c *Client) newRequest(ctx context.Context, method, path string, payload interface{}) (*http.Request, error) {
 	var reqBody io.Reader
@@ -127,12 +204,14 @@ func (c *Client) newRequest(ctx context.Context, method, path string, payload in
 		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
 	}
 
-	if c.options.Verbose {
-		body, _ := httputil.DumpRequest(req, true)
-		log.Println(fmt.Sprintf("%s", string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if c.options.UserAgent != "" {
+		req.Header.Set("User-Agent", c.options.UserAgent)
 	}
 
 	req = req.WithContext(ctx)
+	setConditionalHeaders(req)
 	return req, nil
 }
 
@@ -147,30 +226,50 @@ func (c *Client) doRequest(r *http.Request, v interface{}) error {
 	}
 	defer resp.Body.Close()
 
-	if v == nil {
+	if v == nil || resp.StatusCode == http.StatusNoContent {
 		return nil
 	}
 
 	var buf bytes.Buffer
 	dec := json.NewDecoder(io.TeeReader(resp.Body, &buf))
-	if err := dec.Decode(v); err != nil {
-		return fmt.Errorf("could not parse response body: %w [%s:%s] %s", err, r.Method, r.URL.String(), buf.String())
+	if err := dec.Decode(v); err == io.EOF {
+		// Empty response body, e.g. a PATCH request which does not return the resource
+		return nil
+	} else if err != nil {
+		policy := c.redaction()
+		return fmt.Errorf("could not parse response body: %w [%s:%s] %s", err, r.Method, r.URL.String(), policy.body(r.URL.Path, resp.Header.Get("Content-Type"), buf.Bytes()))
 	}
+	clearWriteOnly(v)
 
 	return nil
 }
 
+// clearWriteOnly discards the write-only attributes echoed back by the SCIM API, so that
+// passwords never appear in decoded results. Every read path decodes through it, including
+// the pages of a Collection: a *types.ListResponse[types.User] is a *types.Users.
+func clearWriteOnly(v interface{}) {
+	switch v := v.(type) {
+	case *types.User:
+		v.Password = ""
+	case *types.Users:
+		for i := range v.Resources {
+			v.Resources[i].Password = ""
+		}
+	case *types.PrivilegedData:
+		v.UrnIetfParamsScimSchemasCyberark10PrivilegedData.Password = ""
+	case *types.PrivilegedDatas:
+		for i := range v.Resources {
+			v.Resources[i].UrnIetfParamsScimSchemasCyberark10PrivilegedData.Password = ""
+		}
+	}
+}
+
 func (c *Client) do(r *http.Request) (*http.Response, error) {
-	resp, err := c.httpClient.Do(r)
+	resp, err := c.send(r)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request [%s:%s]: %w", r.Method, r.URL.String(), err)
 	}
 
-	if c.options.Verbose {
-		body, _ := httputil.DumpResponse(resp, true)
-		log.Println(fmt.Sprintf("%s", string(body)))
-	}
-
 	switch resp.StatusCode {
 	case http.StatusOK,
 		http.StatusCreated,
@@ -180,15 +279,14 @@ func (c *Client) do(r *http.Request) (*http.Response, error) {
 
 	defer resp.Body.Close()
 
-	switch resp.StatusCode {
-	case http.StatusNotFound:
-		return nil, ErrNotFound
-	case http.StatusUnauthorized,
-		http.StatusForbidden:
-		return nil, ErrUserAccessDenied
-	case http.StatusTooManyRequests:
-		return nil, ErrTooManyRequests
-	}
+	return nil, newScimError(r, resp)
+}
+
+// send passes the request through the middleware chain
+func (c *Client) send(r *http.Request) (*http.Response, error) {
+	c.mu.RLock()
+	handler := c.handler
+	c.mu.RUnlock()
 
-	return nil, fmt.Errorf("failed to do request, %d status code received", resp.StatusCode)
+	return handler(r)
 }
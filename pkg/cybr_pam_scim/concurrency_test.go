@@ -0,0 +1,345 @@
+package cybr_pam_scim_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/filter"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/scimtest"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
+	"golang.org/x/oauth2"
+)
+
+// expiringTokenSource returns tokens which expire within the refresh margin, so that every
+// request refreshes the token
+type expiringTokenSource struct {
+	token   string
+	refresh int64
+}
+
+func (ts *expiringTokenSource) Token() (*oauth2.Token, error) {
+	atomic.AddInt64(&ts.refresh, 1)
+	return &oauth2.Token{AccessToken: ts.token, TokenType: "Bearer", Expiry: time.Now().Add(time.Second)}, nil
+}
+
+// TestServiceConcurrency runs the methods of every resource in parallel while the token is
+// refreshed and the retry and redaction policies are replaced. Run with -race.
+func TestServiceConcurrency(t *testing.T) {
+	srv := scimtest.NewServer()
+	defer srv.Close()
+
+	ts := &expiringTokenSource{token: srv.Token}
+	s := cybr_pam_scim.NewServiceWithClient(cybr_pam_scim.NewClient(srv.Server.Client(), cybr_pam_scim.Options{
+		ApiURL:      srv.URL,
+		Verbose:     true,
+		Logger:      cybr_pam_scim.StdLogger(log.New(io.Discard, "", 0)),
+		Retry:       &cybr_pam_scim.RetryPolicy{MaxAttempts: 4, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond},
+		TokenSource: ts,
+	}))
+
+	// Failed reads are retried by every policy set below
+	srv.InjectFault(scimtest.Fault{Method: http.MethodGet, Path: "/Users", Status: http.StatusServiceUnavailable, Times: 3})
+	srv.InjectFault(scimtest.Fault{Method: http.MethodGet, Path: "/Containers", Status: http.StatusServiceUnavailable, Times: 3})
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			s.SetRetryPolicy(&cybr_pam_scim.RetryPolicy{MaxAttempts: 4 + i%2, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+			s.SetRedactionPolicy(&cybr_pam_scim.RedactionPolicy{Headers: []string{"Authorization"}, Fields: []string{"password"}})
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	t.Run("group", func(t *testing.T) {
+		for i := 0; i < 8; i++ {
+			i := i
+			t.Run(fmt.Sprintf("discovery%d", i), func(t *testing.T) {
+				t.Parallel()
+				testDiscovery(t, s)
+			})
+			t.Run(fmt.Sprintf("users%d", i), func(t *testing.T) {
+				t.Parallel()
+				testUsers(t, s, i)
+			})
+			t.Run(fmt.Sprintf("groups%d", i), func(t *testing.T) {
+				t.Parallel()
+				testGroups(t, s, i)
+			})
+			t.Run(fmt.Sprintf("safes%d", i), func(t *testing.T) {
+				t.Parallel()
+				testSafes(t, s, i)
+			})
+			t.Run(fmt.Sprintf("bulk%d", i), func(t *testing.T) {
+				t.Parallel()
+				testBulk(t, s, i)
+			})
+		}
+	})
+	close(stop)
+	wg.Wait()
+
+	if atomic.LoadInt64(&ts.refresh) < 2 {
+		t.Errorf("token refreshes = %d, want a refresh per request", ts.refresh)
+	}
+}
+
+func check(t *testing.T, method string, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %v", method, err)
+	}
+}
+
+func testDiscovery(t *testing.T, s *cybr_pam_scim.Service) {
+	ctx := context.Background()
+	_, err := s.Capabilities(ctx)
+	check(t, "Capabilities", err)
+	_, err = s.RefreshCapabilities(ctx)
+	check(t, "RefreshCapabilities", err)
+	_, err = s.GetServiceProviderConfig(ctx)
+	check(t, "GetServiceProviderConfig", err)
+	_, err = s.GetResourceTypes(ctx)
+	check(t, "GetResourceTypes", err)
+	_, err = s.GetSchemas(ctx)
+	check(t, "GetSchemas", err)
+}
+
+func testUsers(t *testing.T, s *cybr_pam_scim.Service, i int) {
+	ctx := context.Background()
+	userName := fmt.Sprintf("user%d", i)
+	user, err := s.AddUser(ctx, types.User{UserName: userName, Password: "Cyberark1!", Active: true})
+	check(t, "AddUser", err)
+
+	_, err = s.GetUsers(ctx)
+	check(t, "GetUsers", err)
+	_, err = s.GetUsersIndex(ctx, 1, 5)
+	check(t, "GetUsersIndex", err)
+	_, err = s.GetUsersSort(ctx, "userName", "descending")
+	check(t, "GetUsersSort", err)
+	_, err = s.GetUserByFilter(ctx, "userName", userName)
+	check(t, "GetUserByFilter", err)
+	_, err = s.GetUsersByFilterExpr(ctx, filter.Attr("userName").Eq(userName))
+	check(t, "GetUsersByFilterExpr", err)
+	_, err = s.Users().List(ctx, &cybr_pam_scim.ListOptions{PageSize: 2})
+	check(t, "Users", err)
+
+	user, err = s.GetUserById(ctx, user.Id)
+	check(t, "GetUserById", err)
+	user.DisplayName = "User " + userName
+	user, err = s.UpdateUserIfMatch(ctx, *user)
+	check(t, "UpdateUserIfMatch", err)
+	_, err = s.UpdateUser(ctx, *user)
+	check(t, "UpdateUser", err)
+	_, err = s.EditUser(ctx, user.Id, func(user *types.User) error {
+		user.Title = "Engineer"
+		return nil
+	})
+	check(t, "EditUser", err)
+	_, err = s.PatchUser(ctx, user.Id, types.NewPatchRequest().Replace("nickName", userName))
+	check(t, "PatchUser", err)
+	check(t, "SetUserPassword", s.SetUserPassword(ctx, user.Id, "Cyberark2!"))
+	check(t, "DeleteUser", s.DeleteUser(ctx, user.Id))
+}
+
+func testGroups(t *testing.T, s *cybr_pam_scim.Service, i int) {
+	ctx := context.Background()
+	var userIds []string
+	for j := 0; j < 3; j++ {
+		user, err := s.AddUser(ctx, types.User{UserName: fmt.Sprintf("member%d.%d", i, j)})
+		check(t, "AddUser", err)
+		userIds = append(userIds, user.Id)
+	}
+	displayName := fmt.Sprintf("group%d", i)
+	group, err := s.AddGroup(ctx, types.Group{DisplayName: displayName})
+	check(t, "AddGroup", err)
+
+	_, err = s.GetGroups(ctx)
+	check(t, "GetGroups", err)
+	_, err = s.GetGroupsIndex(ctx, 1, 5)
+	check(t, "GetGroupsIndex", err)
+	_, err = s.GetGroupsSort(ctx, "displayName", "ascending")
+	check(t, "GetGroupsSort", err)
+	_, err = s.GetGroupByFilter(ctx, "displayName", displayName)
+	check(t, "GetGroupByFilter", err)
+	_, err = s.GetGroupsByFilterExpr(ctx, filter.Attr("displayName").Eq(displayName))
+	check(t, "GetGroupsByFilterExpr", err)
+	_, err = s.Groups().List(ctx, nil)
+	check(t, "Groups", err)
+
+	check(t, "AddGroupMembers", s.AddGroupMembers(ctx, group.Id, userIds...))
+	check(t, "RemoveGroupMembers", s.RemoveGroupMembers(ctx, group.Id, userIds[0]))
+	check(t, "ReplaceGroupMembers", s.ReplaceGroupMembers(ctx, group.Id, userIds[1:]...))
+	members, err := s.ListGroupMembers(ctx, group.Id)
+	check(t, "ListGroupMembers", err)
+	if len(members) != 2 {
+		t.Errorf("members = %d, want 2", len(members))
+	}
+
+	group, err = s.GetGroupById(ctx, group.Id)
+	check(t, "GetGroupById", err)
+	group, err = s.UpdateGroupIfMatch(ctx, *group)
+	check(t, "UpdateGroupIfMatch", err)
+	_, err = s.UpdateGroup(ctx, *group)
+	check(t, "UpdateGroup", err)
+	_, err = s.EditGroup(ctx, group.Id, func(group *types.Group) error {
+		group.DisplayName = displayName + ".edited"
+		return nil
+	})
+	check(t, "EditGroup", err)
+	_, err = s.PatchGroup(ctx, group.Id, types.NewPatchRequest().Replace("displayName", displayName))
+	check(t, "PatchGroup", err)
+	check(t, "DeleteGroup", s.DeleteGroup(ctx, group.Id))
+}
+
+func testSafes(t *testing.T, s *cybr_pam_scim.Service, i int) {
+	ctx := context.Background()
+	safeName := fmt.Sprintf("safe%d", i)
+	_, err := s.AddSafe(ctx, types.Container{Name: safeName})
+	check(t, "AddSafe", err)
+
+	_, err = s.GetSafes(ctx)
+	check(t, "GetSafes", err)
+	_, err = s.GetSafesIndex(ctx, 1, 5)
+	check(t, "GetSafesIndex", err)
+	_, err = s.GetSafesSort(ctx, "name", "ascending")
+	check(t, "GetSafesSort", err)
+	_, err = s.GetSafeByFilter(ctx, "name", safeName)
+	check(t, "GetSafeByFilter", err)
+	_, err = s.GetSafesByFilterExpr(ctx, filter.Attr("name").Eq(safeName))
+	check(t, "GetSafesByFilterExpr", err)
+	_, err = s.Safes().List(ctx, nil)
+	check(t, "Safes", err)
+
+	safe, err := s.GetSafeByName(ctx, safeName)
+	check(t, "GetSafeByName", err)
+	safe, err = s.UpdateSafeIfMatch(ctx, *safe)
+	check(t, "UpdateSafeIfMatch", err)
+	_, err = s.UpdateSafe(ctx, *safe)
+	check(t, "UpdateSafe", err)
+	_, err = s.EditSafe(ctx, safeName, func(safe *types.Container) error {
+		safe.Description = "Edited"
+		return nil
+	})
+	check(t, "EditSafe", err)
+	_, err = s.PatchSafe(ctx, safeName, types.NewPatchRequest().Replace("description", "Patched"))
+	check(t, "PatchSafe", err)
+
+	testSafePermissions(t, s, i, safeName)
+	testPrivilegedData(t, s, safeName)
+	check(t, "DeleteSafe", s.DeleteSafe(ctx, safeName))
+}
+
+func testSafePermissions(t *testing.T, s *cybr_pam_scim.Service, i int, safeName string) {
+	ctx := context.Background()
+	userName := fmt.Sprintf("safe.member%d", i)
+	_, err := s.AddUser(ctx, types.User{UserName: userName})
+	check(t, "AddUser", err)
+	_, err = s.AddSafePermissions(ctx, types.ContainerPermission{
+		Container: types.ContainerRef{Name: safeName},
+		User:      types.UserRef{Display: userName},
+		Rights:    types.ViewerRights,
+	})
+	check(t, "AddSafePermissions", err)
+
+	_, err = s.GetSafePermissions(ctx)
+	check(t, "GetSafePermissions", err)
+	_, err = s.GetSafePermissionsIndex(ctx, 1, 5)
+	check(t, "GetSafePermissionsIndex", err)
+	_, err = s.GetSafePermissionsSort(ctx, "id", "ascending")
+	check(t, "GetSafePermissionsSort", err)
+	_, err = s.GetSafePermissionByFilter(ctx, "container.name", safeName)
+	check(t, "GetSafePermissionByFilter", err)
+	_, err = s.GetSafePermissionsByFilterExpr(ctx, filter.Attr("container.name").Eq(safeName))
+	check(t, "GetSafePermissionsByFilterExpr", err)
+	_, err = s.SafePermissions().List(ctx, nil)
+	check(t, "SafePermissions", err)
+
+	permission, err := s.GetSafePermissionsByName(ctx, safeName, userName)
+	check(t, "GetSafePermissionsByName", err)
+	permission, err = s.UpdateSafePermissionsIfMatch(ctx, *permission)
+	check(t, "UpdateSafePermissionsIfMatch", err)
+	_, err = s.UpdateSafePermissions(ctx, *permission)
+	check(t, "UpdateSafePermissions", err)
+	_, err = s.EditSafePermission(ctx, safeName, userName, func(permission *types.ContainerPermission) error {
+		permission.Rights = types.EndUserRights
+		return nil
+	})
+	check(t, "EditSafePermission", err)
+	_, err = s.PatchSafePermission(ctx, safeName, userName, types.NewPatchRequest().Replace("rights", types.AuditorRights))
+	check(t, "PatchSafePermission", err)
+	check(t, "DeleteSafePermission", s.DeleteSafePermission(ctx, safeName, userName))
+}
+
+func testPrivilegedData(t *testing.T, s *cybr_pam_scim.Service, safeName string) {
+	ctx := context.Background()
+	privilegedData, err := s.AddPrivilegedData(ctx, types.PrivilegedData{
+		Name: safeName + ".account",
+		Type: "password",
+		UrnIetfParamsScimSchemasCyberark10PrivilegedData: types.UrnIetfParamsScimSchemasCyberark10PrivilegedData{Safe: safeName},
+	})
+	check(t, "AddPrivilegedData", err)
+
+	_, err = s.GetPrivilegedData(ctx)
+	check(t, "GetPrivilegedData", err)
+	_, err = s.GetPrivilegedDataIndex(ctx, 1, 5)
+	check(t, "GetPrivilegedDataIndex", err)
+	_, err = s.GetPrivilegedDataSort(ctx, "name", "ascending")
+	check(t, "GetPrivilegedDataSort", err)
+	_, err = s.GetPrivilegedDataByFilter(ctx, "name", privilegedData.Name)
+	check(t, "GetPrivilegedDataByFilter", err)
+	_, err = s.GetPrivilegedDataByFilterExpr(ctx, filter.Attr("name").Eq(privilegedData.Name))
+	check(t, "GetPrivilegedDataByFilterExpr", err)
+	_, err = s.PrivilegedData().List(ctx, nil)
+	check(t, "PrivilegedData", err)
+
+	privilegedData, err = s.GetPrivilegedDataById(ctx, privilegedData.Id)
+	check(t, "GetPrivilegedDataById", err)
+	privilegedData, err = s.UpdatePrivilegedDataIfMatch(ctx, *privilegedData)
+	check(t, "UpdatePrivilegedDataIfMatch", err)
+	_, err = s.UpdatePrivilegedData(ctx, *privilegedData)
+	check(t, "UpdatePrivilegedData", err)
+	_, err = s.EditPrivilegedData(ctx, privilegedData.Id, func(privilegedData *types.PrivilegedData) error {
+		privilegedData.Description = "Edited"
+		return nil
+	})
+	check(t, "EditPrivilegedData", err)
+	_, err = s.PatchPrivilegedData(ctx, privilegedData.Id, types.NewPatchRequest().Replace("description", "Patched"))
+	check(t, "PatchPrivilegedData", err)
+	_, err = s.ModifyPrivilegedData(ctx, types.PrivilegedData{
+		Id:      privilegedData.Id,
+		Schemas: []string{types.PatchOpSchema},
+		Operations: []types.Operations{
+			{Op: "replace", Path: "urn:ietf:params:scim:schemas:cyberark:1.0:PrivilegedData.properties", Value: []types.Value{{Key: "address", Value: "example.com"}}},
+		},
+	})
+	check(t, "ModifyPrivilegedData", err)
+	check(t, "DeletePrivilegedData", s.DeletePrivilegedData(ctx, privilegedData.Id))
+}
+
+func testBulk(t *testing.T, s *cybr_pam_scim.Service, i int) {
+	ctx := context.Background()
+	bulk := cybr_pam_scim.NewBulkRequest()
+	user := bulk.Post("/Users", types.User{UserName: fmt.Sprintf("bulk.user%d", i)})
+	group := bulk.Post("/Groups", types.Group{DisplayName: fmt.Sprintf("bulk.group%d", i)})
+	bulk.Patch("/Groups/"+group.String(), types.NewPatchRequest().Add("members", []types.Members{{Value: user.String()}}))
+	result, err := s.Bulk(ctx, bulk)
+	check(t, "Bulk", err)
+	check(t, "DeleteGroup", s.DeleteGroup(ctx, result.Result(group).Id()))
+	check(t, "DeleteUser", s.DeleteUser(ctx, result.Result(user).Id()))
+}
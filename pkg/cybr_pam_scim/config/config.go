@@ -0,0 +1,325 @@
+// Package config loads named connection profiles for the SCIM API and builds a Service from
+// them. Settings are layered with the precedence flags > environment > file > defaults.
+//
+// Example configuration file:
+//		default_profile: dev
+//		profiles:
+//		  dev:
+//		    url: dev.my.idaptive.app
+//		    app_id: exampleAppName
+//		    auth: client_credentials
+//		    client_id: identity-privilege-integration-user$@example.com
+//		    client_secret: ExampleSecret12!@
+//		  prod:
+//		    url: prod.my.idaptive.app
+//		    app_id: exampleAppName
+//		    auth: token
+//		    proxy: http://proxy.example.com:3128
+//		    tls:
+//		      ca_file: /etc/ssl/certs/corporate.pem
+//
+// Example Usage:
+//		p, err := config.Load(config.Options{Profile: "prod"})
+//		if err != nil {
+//			log.Fatal(err)
+//		}
+//		s, err := config.NewServiceFromProfile(p)
+//
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// AuthMethod is the way a profile obtains its Oauth2 tokens
+type AuthMethod string
+
+const (
+	// AuthClientCredentials requests tokens with client_id and client_secret (OauthCredClient)
+	AuthClientCredentials AuthMethod = "client_credentials"
+	// AuthResourceOwner requests tokens with client_id, client_secret, username, and password (OauthResourceOwner)
+	AuthResourceOwner AuthMethod = "resource_owner"
+	// AuthToken uses an existing bearer token
+	AuthToken AuthMethod = "token"
+)
+
+// DefaultEnvPrefix is the prefix of the environment variables overriding the settings of a profile
+const DefaultEnvPrefix = "CYBR_SCIM"
+
+// Profile holds the tenant, credentials, and connection settings used to create a Service
+type Profile struct {
+	// Name of the profile in the configuration file, empty when no profile was selected
+	Name string
+	// URL is the host name of the tenant (e.g. "example.my.idaptive.app")
+	URL string
+	// AppId is the ID of the SCIM Application
+	AppId string
+	// Auth is the authentication method
+	Auth AuthMethod
+
+	ClientId     string
+	ClientSecret string
+	Username     string
+	Password     string
+	Token        string
+
+	// Endpoint and Version form the path of the SCIM API (e.g. "scim" and "v2")
+	Endpoint string
+	Version  string
+
+	TLS TLS
+	// Proxy is the URL of the HTTP proxy, the proxy environment variables are used when empty
+	Proxy string
+	// Verbose logs the HTTP requests and responses
+	Verbose bool
+}
+
+// TLS holds the TLS settings of a profile
+type TLS struct {
+	// CAFile is a PEM file of certificates trusted in addition to the system certificates
+	CAFile string
+	// InsecureSkipVerify disables the verification of the server certificate
+	InsecureSkipVerify bool
+}
+
+// setting is a key of a profile with the flag and environment variable overriding it
+type setting struct {
+	key   string
+	flag  string
+	usage string
+}
+
+var settings = []setting{
+	{key: "url", flag: "url", usage: "host name of the tenant, e.g. example.my.idaptive.app"},
+	{key: "app_id", flag: "app-id", usage: "ID of the SCIM Application"},
+	{key: "auth", flag: "auth", usage: "authentication method: client_credentials, resource_owner, or token"},
+	{key: "client_id", flag: "client-id", usage: "username of the SCIM Application"},
+	{key: "client_secret", flag: "client-secret", usage: "password of the SCIM Application"},
+	{key: "username", flag: "username", usage: "username of the resource owner"},
+	{key: "password", flag: "password", usage: "password of the resource owner"},
+	{key: "token", flag: "token", usage: "existing bearer token"},
+	{key: "endpoint", flag: "api-endpoint", usage: "endpoint of the SCIM API"},
+	{key: "version", flag: "api-version", usage: "version of the SCIM API"},
+	{key: "tls.ca_file", flag: "tls-ca-file", usage: "PEM file of additional trusted certificates"},
+	{key: "tls.insecure_skip_verify", flag: "tls-insecure-skip-verify", usage: "do not verify the server certificate"},
+	{key: "proxy", flag: "proxy", usage: "URL of the HTTP proxy"},
+	{key: "verbose", flag: "verbose", usage: "log the HTTP requests and responses"},
+}
+
+var defaults = map[string]interface{}{
+	"endpoint": "scim",
+	"version":  "v2",
+}
+
+// RegisterFlags defines a flag for every setting of a profile (e.g. --url, --client-secret,
+// --tls-ca-file). Flags passed to Load via Options.Flags override the environment and file.
+func RegisterFlags(flags *pflag.FlagSet) {
+	for _, s := range settings {
+		switch s.key {
+		case "tls.insecure_skip_verify", "verbose":
+			flags.Bool(s.flag, false, s.usage)
+		default:
+			flags.String(s.flag, "", s.usage)
+		}
+	}
+}
+
+// Options controls where Load reads a profile from. All fields are optional.
+type Options struct {
+	// File is the configuration file. DefaultFile is read if it exists when empty.
+	File string
+	// Profile is the name of the profile, <EnvPrefix>_PROFILE or default_profile of the file is used when empty
+	Profile string
+	// EnvPrefix is the prefix of the environment variables, DefaultEnvPrefix when empty
+	EnvPrefix string
+	// Flags are the flags defined by RegisterFlags, only flags set on the command line apply
+	Flags *pflag.FlagSet
+}
+
+// DefaultFile returns the path of the default configuration file, $HOME/.cybr-scim.yaml
+func DefaultFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, ".cybr-scim.yaml")
+}
+
+// Load reads a profile and validates it. Every setting is taken from the first source
+// providing it: flags, the environment variable <EnvPrefix>_<SETTING> (e.g.
+// CYBR_SCIM_CLIENT_SECRET or CYBR_SCIM_TLS_CA_FILE), the profile in the configuration
+// file, and the defaults. The authentication method defaults to token when a token is set,
+// to resource_owner when a username is set, and to client_credentials otherwise.
+//
+// A *ValidationError lists every problem of an invalid profile.
+func Load(opts Options) (*Profile, error) {
+	prefix := opts.EnvPrefix
+	if prefix == "" {
+		prefix = DefaultEnvPrefix
+	}
+
+	file := viper.New()
+	file.SetConfigType("yaml")
+	switch {
+	case opts.File != "":
+		file.SetConfigFile(opts.File)
+		if err := file.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("failed to read configuration file %s: %w", opts.File, err)
+		}
+	case DefaultFile() != "":
+		file.SetConfigFile(DefaultFile())
+		if err := file.ReadInConfig(); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read configuration file %s: %w", DefaultFile(), err)
+		}
+	}
+
+	name := opts.Profile
+	if name == "" {
+		name = os.Getenv(prefix + "_PROFILE")
+	}
+	if name == "" {
+		name = file.GetString("default_profile")
+	}
+
+	v := viper.New()
+	v.SetEnvPrefix(prefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	for key, value := range defaults {
+		v.SetDefault(key, value)
+	}
+	if name != "" {
+		if !file.IsSet("profiles." + name) {
+			return nil, fmt.Errorf("profile %q is not defined in the configuration file %s", name, file.ConfigFileUsed())
+		}
+		if err := v.MergeConfigMap(file.GetStringMap("profiles." + name)); err != nil {
+			return nil, fmt.Errorf("failed to read profile %q: %w", name, err)
+		}
+	}
+	for _, s := range settings {
+		if err := v.BindEnv(s.key); err != nil {
+			return nil, err
+		}
+		if opts.Flags == nil {
+			continue
+		}
+		if flag := opts.Flags.Lookup(s.flag); flag != nil && flag.Changed {
+			if err := v.BindPFlag(s.key, flag); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	p := &Profile{
+		Name:         name,
+		URL:          strings.TrimSuffix(strings.TrimPrefix(v.GetString("url"), "https://"), "/"),
+		AppId:        v.GetString("app_id"),
+		Auth:         AuthMethod(strings.ToLower(v.GetString("auth"))),
+		ClientId:     v.GetString("client_id"),
+		ClientSecret: v.GetString("client_secret"),
+		Username:     v.GetString("username"),
+		Password:     v.GetString("password"),
+		Token:        v.GetString("token"),
+		Endpoint:     strings.Trim(v.GetString("endpoint"), "/"),
+		Version:      strings.Trim(v.GetString("version"), "/"),
+		TLS: TLS{
+			CAFile:             v.GetString("tls.ca_file"),
+			InsecureSkipVerify: v.GetBool("tls.insecure_skip_verify"),
+		},
+		Proxy:   v.GetString("proxy"),
+		Verbose: v.GetBool("verbose"),
+	}
+	if p.Auth == "" {
+		switch {
+		case p.Token != "":
+			p.Auth = AuthToken
+		case p.Username != "":
+			p.Auth = AuthResourceOwner
+		default:
+			p.Auth = AuthClientCredentials
+		}
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// ValidationError is returned for an invalid profile, it lists every problem found
+type ValidationError struct {
+	Profile  string
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Profile == "" {
+		return fmt.Sprintf("invalid profile: %s", strings.Join(e.Problems, "; "))
+	}
+
+	return fmt.Sprintf("invalid profile %q: %s", e.Profile, strings.Join(e.Problems, "; "))
+}
+
+// Validate reports the missing and invalid settings of the profile as a *ValidationError
+func (p *Profile) Validate() error {
+	var problems []string
+	require := func(value string, key string) {
+		if value == "" {
+			problems = append(problems, fmt.Sprintf("%s is required for %s authentication", key, p.Auth))
+		}
+	}
+
+	if p.URL == "" {
+		problems = append(problems, "url is required")
+	} else if strings.Contains(p.URL, "://") || strings.Contains(p.URL, "/") {
+		problems = append(problems, fmt.Sprintf("url %q must be a host name, e.g. example.my.idaptive.app", p.URL))
+	}
+	if p.Endpoint == "" {
+		problems = append(problems, "endpoint is required")
+	}
+	if p.Version == "" {
+		problems = append(problems, "version is required")
+	}
+
+	switch p.Auth {
+	case AuthToken:
+		require(p.Token, "token")
+	case AuthClientCredentials:
+		require(p.AppId, "app_id")
+		require(p.ClientId, "client_id")
+		require(p.ClientSecret, "client_secret")
+	case AuthResourceOwner:
+		require(p.AppId, "app_id")
+		require(p.ClientId, "client_id")
+		require(p.ClientSecret, "client_secret")
+		require(p.Username, "username")
+		require(p.Password, "password")
+	default:
+		problems = append(problems, fmt.Sprintf("unknown auth %q, accepted values are client_credentials, resource_owner, or token", p.Auth))
+	}
+
+	if p.TLS.CAFile != "" {
+		if _, err := os.Stat(p.TLS.CAFile); err != nil {
+			problems = append(problems, fmt.Sprintf("tls.ca_file is not readable: %v", err))
+		}
+	}
+	if p.Proxy != "" {
+		if proxy, err := url.Parse(p.Proxy); err != nil || proxy.Scheme == "" || proxy.Host == "" {
+			problems = append(problems, fmt.Sprintf("proxy %q must be a URL, e.g. http://proxy.example.com:3128", p.Proxy))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Profile: p.Name, Problems: problems}
+	}
+
+	return nil
+}
@@ -0,0 +1,353 @@
+package config_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/config"
+)
+
+const configFile = `default_profile: dev
+profiles:
+  dev:
+    url: https://dev.my.idaptive.app/
+    app_id: devApp
+    client_id: dev-user@example.com
+    client_secret: DevSecret
+  prod:
+    url: prod.my.idaptive.app
+    app_id: prodApp
+    auth: resource_owner
+    client_id: prod-user@example.com
+    client_secret: ProdSecret
+    username: john.smith
+    password: ExamplePass
+    endpoint: /custom/
+    proxy: http://proxy.example.com:3128
+  token:
+    url: token.my.idaptive.app
+    token: ExampleToken
+  incomplete:
+    url: incomplete.my.idaptive.app
+    auth: client_credentials
+`
+
+// setup writes the configuration file, isolates the test from the environment and the
+// default file, and returns the path of the file
+func setup(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	for _, env := range os.Environ() {
+		if name := env[:strings.Index(env, "=")]; strings.HasPrefix(name, config.DefaultEnvPrefix+"_") {
+			t.Setenv(name, "")
+			os.Unsetenv(name)
+		}
+	}
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte(configFile), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// parse returns the flags defined by RegisterFlags after parsing the arguments
+func parse(t *testing.T, args ...string) *pflag.FlagSet {
+	t.Helper()
+	flags := pflag.NewFlagSet("cybr-scim", pflag.ContinueOnError)
+	config.RegisterFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+
+	return flags
+}
+
+func TestLoadFile(t *testing.T) {
+	path := setup(t)
+
+	p, err := config.Load(config.Options{File: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// default_profile is selected, the scheme and trailing slash of the url are removed, and
+	// endpoint and version take their defaults
+	want := &config.Profile{
+		Name:         "dev",
+		URL:          "dev.my.idaptive.app",
+		AppId:        "devApp",
+		Auth:         config.AuthClientCredentials,
+		ClientId:     "dev-user@example.com",
+		ClientSecret: "DevSecret",
+		Endpoint:     "scim",
+		Version:      "v2",
+	}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("profile = %+v, want %+v", p, want)
+	}
+
+	p, err = config.Load(config.Options{File: path, Profile: "prod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = &config.Profile{
+		Name:         "prod",
+		URL:          "prod.my.idaptive.app",
+		AppId:        "prodApp",
+		Auth:         config.AuthResourceOwner,
+		ClientId:     "prod-user@example.com",
+		ClientSecret: "ProdSecret",
+		Username:     "john.smith",
+		Password:     "ExamplePass",
+		Endpoint:     "custom",
+		Version:      "v2",
+		Proxy:        "http://proxy.example.com:3128",
+	}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("profile = %+v, want %+v", p, want)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		opts    config.Options
+		args    []string
+		profile string
+		secret  string
+		verbose bool
+	}{
+		{
+			name:    "file",
+			profile: "dev", secret: "DevSecret",
+		},
+		{
+			name:    "profile from the environment",
+			env:     map[string]string{"CYBR_SCIM_PROFILE": "prod"},
+			profile: "prod", secret: "ProdSecret",
+		},
+		{
+			name:    "profile option over the environment",
+			env:     map[string]string{"CYBR_SCIM_PROFILE": "prod"},
+			opts:    config.Options{Profile: "dev"},
+			profile: "dev", secret: "DevSecret",
+		},
+		{
+			name:    "environment over the file",
+			env:     map[string]string{"CYBR_SCIM_CLIENT_SECRET": "EnvSecret", "CYBR_SCIM_VERBOSE": "true"},
+			profile: "dev", secret: "EnvSecret", verbose: true,
+		},
+		{
+			name:    "flags over the environment",
+			env:     map[string]string{"CYBR_SCIM_CLIENT_SECRET": "EnvSecret", "CYBR_SCIM_VERBOSE": "true"},
+			args:    []string{"--client-secret", "FlagSecret", "--verbose=false"},
+			profile: "dev", secret: "FlagSecret",
+		},
+		{
+			name:    "unset flags do not override",
+			env:     map[string]string{"CYBR_SCIM_CLIENT_SECRET": "EnvSecret"},
+			args:    []string{"--app-id", "devApp"},
+			profile: "dev", secret: "EnvSecret",
+		},
+		{
+			name:    "environment prefix",
+			env:     map[string]string{"CYBR_SCIM_CLIENT_SECRET": "EnvSecret", "SCIM_PROFILE": "prod", "SCIM_CLIENT_SECRET": "PrefixSecret"},
+			opts:    config.Options{EnvPrefix: "SCIM"},
+			profile: "prod", secret: "PrefixSecret",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			opts.File = setup(t)
+			opts.Flags = parse(t, tt.args...)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			p, err := config.Load(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Name != tt.profile || p.ClientSecret != tt.secret || p.Verbose != tt.verbose {
+				t.Errorf("profile %s with client_secret %s and verbose %v, want %s with %s and %v", p.Name, p.ClientSecret, p.Verbose, tt.profile, tt.secret, tt.verbose)
+			}
+		})
+	}
+}
+
+func TestLoadNestedSettings(t *testing.T) {
+	path := setup(t)
+	caFile := filepath.Join(filepath.Dir(path), "ca.pem")
+	if err := os.WriteFile(caFile, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CYBR_SCIM_TLS_CA_FILE", caFile)
+
+	p, err := config.Load(config.Options{File: path, Flags: parse(t, "--tls-insecure-skip-verify")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.TLS.CAFile != caFile || !p.TLS.InsecureSkipVerify {
+		t.Errorf("tls = %+v, want the CA file of the environment and InsecureSkipVerify of the flag", p.TLS)
+	}
+}
+
+func TestLoadAuthDefault(t *testing.T) {
+	path := setup(t)
+	tests := []struct {
+		profile string
+		args    []string
+		auth    config.AuthMethod
+	}{
+		{"dev", nil, config.AuthClientCredentials},
+		{"token", nil, config.AuthToken},
+		{"dev", []string{"--username", "john.smith", "--password", "ExamplePass"}, config.AuthResourceOwner},
+		{"token", []string{"--auth", "CLIENT_CREDENTIALS", "--app-id", "app", "--client-id", "user", "--client-secret", "secret"}, config.AuthClientCredentials},
+	}
+	for _, tt := range tests {
+		p, err := config.Load(config.Options{File: path, Profile: tt.profile, Flags: parse(t, tt.args...)})
+		if err != nil {
+			t.Errorf("%s %v: %v", tt.profile, tt.args, err)
+			continue
+		}
+		if p.Auth != tt.auth {
+			t.Errorf("%s %v: auth = %s, want %s", tt.profile, tt.args, p.Auth, tt.auth)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	path := setup(t)
+	dir := filepath.Dir(path)
+	invalid := filepath.Join(dir, "invalid.yaml")
+	if err := os.WriteFile(invalid, []byte("profiles: [dev"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		opts config.Options
+		want string
+	}{
+		{config.Options{File: filepath.Join(dir, "missing.yaml")}, "failed to read configuration file " + filepath.Join(dir, "missing.yaml")},
+		{config.Options{File: invalid}, "failed to read configuration file " + invalid},
+		{config.Options{File: path, Profile: "staging"}, `profile "staging" is not defined in the configuration file ` + path},
+		{config.Options{File: path, Profile: "incomplete"}, `invalid profile "incomplete": app_id is required for client_credentials authentication; client_id is required for client_credentials authentication; client_secret is required for client_credentials authentication`},
+		// Without a file or profile, only the defaults apply
+		{config.Options{}, `invalid profile: url is required; app_id is required for client_credentials authentication; client_id is required for client_credentials authentication; client_secret is required for client_credentials authentication`},
+	}
+	for _, tt := range tests {
+		_, err := config.Load(tt.opts)
+		if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+			t.Errorf("%+v: error = %v, want %s", tt.opts, err, tt.want)
+		}
+	}
+
+	// The default file is read when it exists
+	if err := os.Rename(path, config.DefaultFile()); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := config.Load(config.Options{}); err != nil || p.Name != "dev" {
+		t.Errorf("profile = %+v and error = %v, want dev from the default file", p, err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() config.Profile {
+		return config.Profile{
+			Name:         "dev",
+			URL:          "dev.my.idaptive.app",
+			AppId:        "app",
+			Auth:         config.AuthClientCredentials,
+			ClientId:     "user",
+			ClientSecret: "secret",
+			Endpoint:     "scim",
+			Version:      "v2",
+		}
+	}
+	tests := []struct {
+		name     string
+		modify   func(p *config.Profile)
+		problems []string
+	}{
+		{"valid", func(p *config.Profile) {}, nil},
+		{"url with a scheme", func(p *config.Profile) { p.URL = "https://dev.my.idaptive.app" }, []string{
+			`url "https://dev.my.idaptive.app" must be a host name, e.g. example.my.idaptive.app`,
+		}},
+		{"url with a path", func(p *config.Profile) { p.URL = "dev.my.idaptive.app/scim" }, []string{
+			`url "dev.my.idaptive.app/scim" must be a host name, e.g. example.my.idaptive.app`,
+		}},
+		{"api path", func(p *config.Profile) { p.Endpoint, p.Version = "", "" }, []string{
+			"endpoint is required",
+			"version is required",
+		}},
+		{"token", func(p *config.Profile) { p.Auth = config.AuthToken }, []string{
+			"token is required for token authentication",
+		}},
+		{"resource owner", func(p *config.Profile) { p.Auth, p.Username = config.AuthResourceOwner, "john.smith" }, []string{
+			"password is required for resource_owner authentication",
+		}},
+		{"unknown auth", func(p *config.Profile) { p.Auth = "saml" }, []string{
+			`unknown auth "saml", accepted values are client_credentials, resource_owner, or token`,
+		}},
+		{"proxy without a scheme", func(p *config.Profile) { p.Proxy = "proxy.example.com:3128" }, []string{
+			`proxy "proxy.example.com:3128" must be a URL, e.g. http://proxy.example.com:3128`,
+		}},
+		{"every problem", func(p *config.Profile) { p.URL, p.ClientSecret, p.Proxy = "", "", "http://" }, []string{
+			"url is required",
+			"client_secret is required for client_credentials authentication",
+			`proxy "http://" must be a URL, e.g. http://proxy.example.com:3128`,
+		}},
+	}
+	for _, tt := range tests {
+		p := valid()
+		tt.modify(&p)
+		err := p.Validate()
+		if tt.problems == nil {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			continue
+		}
+		var validationErr *config.ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Errorf("%s: error = %v, want a ValidationError", tt.name, err)
+			continue
+		}
+		if validationErr.Profile != "dev" || !reflect.DeepEqual(validationErr.Problems, tt.problems) {
+			t.Errorf("%s: problems of %s = %q, want %q", tt.name, validationErr.Profile, validationErr.Problems, tt.problems)
+		}
+		if want := `invalid profile "dev": ` + strings.Join(tt.problems, "; "); err.Error() != want {
+			t.Errorf("%s: message = %s, want %s", tt.name, err, want)
+		}
+	}
+
+	p := valid()
+	p.TLS.CAFile = filepath.Join(t.TempDir(), "missing.pem")
+	if err := p.Validate(); err == nil || !strings.Contains(err.Error(), "tls.ca_file is not readable: ") {
+		t.Errorf("error = %v, want the CA file rejected", err)
+	}
+}
+
+func TestRegisterFlags(t *testing.T) {
+	flags := pflag.NewFlagSet("cybr-scim", pflag.ContinueOnError)
+	config.RegisterFlags(flags)
+
+	for _, name := range []string{"url", "app-id", "auth", "client-id", "client-secret", "username", "password", "token", "api-endpoint", "api-version", "tls-ca-file", "proxy"} {
+		if flag := flags.Lookup(name); flag == nil || flag.Value.Type() != "string" || flag.Usage == "" {
+			t.Errorf("flag --%s = %+v, want a documented string flag", name, flag)
+		}
+	}
+	for _, name := range []string{"tls-insecure-skip-verify", "verbose"} {
+		if flag := flags.Lookup(name); flag == nil || flag.Value.Type() != "bool" || flag.DefValue != "false" {
+			t.Errorf("flag --%s = %+v, want a bool flag defaulting to false", name, flag)
+		}
+	}
+}
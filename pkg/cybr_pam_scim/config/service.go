@@ -0,0 +1,101 @@
+package config
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	cybr_pam_scim "github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim"
+	"golang.org/x/oauth2"
+)
+
+// NewServiceFromProfile validates the profile and returns a Service authenticated with its
+// authentication method. The proxy and TLS settings apply to the token requests as well as
+// to the requests of the Service. Resource Owner profiles request their first token before
+// returning, the other methods request tokens when the Service is first used.
+//
+// Example Usage:
+//		p, err := config.Load(config.Options{Profile: "staging"})
+//		if err != nil {
+//			log.Fatal(err)
+//		}
+//		s, err := config.NewServiceFromProfile(p)
+//		users, err := s.Users().List(context.Background(), nil)
+//
+func NewServiceFromProfile(p *Profile) (*cybr_pam_scim.Service, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	base, err := p.transport()
+	if err != nil {
+		return nil, err
+	}
+	// Token requests are logged with the same redaction as the requests of the Service
+	var tokenTransport http.RoundTripper = base
+	if p.Verbose {
+		tokenTransport = cybr_pam_scim.Transport(base, cybr_pam_scim.LoggingMiddleware(nil, nil))
+	}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: tokenTransport})
+
+	var ts oauth2.TokenSource
+	switch p.Auth {
+	case AuthToken:
+		ts = oauth2.StaticTokenSource(&oauth2.Token{AccessToken: p.Token, TokenType: "Bearer"})
+	case AuthClientCredentials:
+		ts = cybr_pam_scim.OauthCredClientTokenSourceContext(ctx, p.ClientId, p.ClientSecret, p.AppId, p.URL)
+	case AuthResourceOwner:
+		authToken, err := cybr_pam_scim.OauthResourceOwnerContext(ctx, p.ClientId, p.ClientSecret, p.AppId, p.URL, p.Username, p.Password)
+		if err != nil {
+			return nil, err
+		}
+		ts = cybr_pam_scim.OauthResourceOwnerTokenSourceContext(ctx, p.ClientId, p.ClientSecret, p.AppId, p.URL, authToken)
+	}
+
+	return cybr_pam_scim.New(
+		cybr_pam_scim.WithBaseURL(fmt.Sprintf("https://%s/%s/%s", p.URL, p.Endpoint, p.Version)),
+		cybr_pam_scim.WithTokenSource(ts),
+		cybr_pam_scim.WithTransport(base),
+		cybr_pam_scim.WithVerbose(p.Verbose),
+	)
+}
+
+// transport returns the http.Transport with the proxy and TLS settings of the profile
+func (p *Profile) transport() (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	if p.Proxy != "" {
+		proxy, err := url.Parse(p.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy provided: %w", err)
+		}
+		t.Proxy = http.ProxyURL(proxy)
+	}
+
+	if p.TLS.CAFile != "" || p.TLS.InsecureSkipVerify {
+		t.TLSClientConfig = &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: p.TLS.InsecureSkipVerify,
+		}
+	}
+	if p.TLS.CAFile != "" {
+		pem, err := os.ReadFile(p.TLS.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tls.ca_file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls.ca_file %s does not contain PEM certificates", p.TLS.CAFile)
+		}
+		t.TLSClientConfig.RootCAs = pool
+	}
+
+	return t, nil
+}
@@ -6,13 +6,12 @@ import (
 	"net/url"
 	"strconv"
 
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/filter"
 	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
 )
 
-var (
-	ContainerPermissions types.ContainerPermissions
-	ContainerPermission  types.ContainerPermission
-)
+// safePermissionSortBy contains the attributes Safe Permissions may be sorted by
+var safePermissionSortBy = []string{"id"}
 
 // GetSafePermissions retrieves all Safes via the SCIM API.
 // The response from the SCIM API is returned as the types.ContainerPermissions struct
@@ -20,12 +19,17 @@ var (
 // Example Usage:
 //		getSafePermissions, err := s.GetSafePermissions(context.Background)
 //
-func (s *Service) GetSafePermissions(ctx context.Context) (*types.ContainerPermissions, error) {
-	if err := s.client.Get(ctx, fmt.Sprintf("/%s", "ContainerPermissions"), &ContainerPermissions); err != nil {
+func (s *Service) GetSafePermissions(ctx context.Context, opts ...ReadOption) (*types.ContainerPermissions, error) {
+	var containerPermissions types.ContainerPermissions
+	path, err := s.readPath(ctx, "ContainerPermission", fmt.Sprintf("/%s", "ContainerPermissions"), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Safe Permissions: %w", err)
+	}
+	if err := s.client.Get(ctx, path, &containerPermissions); err != nil {
 		return nil, fmt.Errorf("failed to get Safe Permissions: %w", err)
 	}
 
-	return &ContainerPermissions, nil
+	return &containerPermissions, nil
 }
 
 // GetSafePermissionsIndex retrieves a limited subset of Safe Permissions based on a starting index and count.
@@ -36,13 +40,18 @@ func (s *Service) GetSafePermissions(ctx context.Context) (*types.ContainerPermi
 // Example Usage:
 //		getSafePermissionsIndex, err := s.GetSafePermissionsIndex(context.Background, 10, 5)
 //
-func (s *Service) GetSafePermissionsIndex(ctx context.Context, startIndex int, count int) (*types.ContainerPermissions, error) {
+func (s *Service) GetSafePermissionsIndex(ctx context.Context, startIndex int, count int, opts ...ReadOption) (*types.ContainerPermissions, error) {
+	var containerPermissions types.ContainerPermissions
 	pathEscapedQuery := url.PathEscape("startIndex=" + strconv.Itoa(startIndex) + "&count=" + strconv.Itoa(count))
-	if err := s.client.Get(ctx, fmt.Sprintf("/%s?%s", "ContainerPermissions", pathEscapedQuery), &ContainerPermissions); err != nil {
+	path, err := s.readPath(ctx, "ContainerPermission", fmt.Sprintf("/%s?%s", "ContainerPermissions", pathEscapedQuery), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Safe Permissions: %w", err)
+	}
+	if err := s.client.Get(ctx, path, &containerPermissions); err != nil {
 		return nil, fmt.Errorf("failed to get Safe Permissions: %w", err)
 	}
 
-	return &ContainerPermissions, nil
+	return &containerPermissions, nil
 }
 
 // GetSafePermissionsSort retrieves and sorts all Safes via the SCIM API based on provided
@@ -55,7 +64,12 @@ func (s *Service) GetSafePermissionsIndex(ctx context.Context, startIndex int, c
 // Example Usage:
 // 		getSafePermissionsSort, err := s.GetSafePermissionsSort(context.Background, "SafeName", "ascending")
 //
-func (s *Service) GetSafePermissionsSort(ctx context.Context, sortBy string, sortOrder string) (*types.ContainerPermissions, error) {
+func (s *Service) GetSafePermissionsSort(ctx context.Context, sortBy string, sortOrder string, opts ...ReadOption) (*types.ContainerPermissions, error) {
+	if !s.capabilities(ctx).SortSupported() {
+		return nil, ErrSortNotSupported
+	}
+
+	var containerPermissions types.ContainerPermissions
 	var pathEscapedQuery string
 	// Input validations:
 	if sortBy == "id" {
@@ -70,11 +84,15 @@ func (s *Service) GetSafePermissionsSort(ctx context.Context, sortBy string, sor
 		return nil, fmt.Errorf("invalid sortBy value provided, the only accepted value is id")
 	}
 
-	if err := s.client.Get(ctx, fmt.Sprintf("/%s?%s", "ContainerPermissions", pathEscapedQuery), &ContainerPermissions); err != nil {
+	path, err := s.readPath(ctx, "ContainerPermission", fmt.Sprintf("/%s?%s", "ContainerPermissions", pathEscapedQuery), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Safes: %w", err)
+	}
+	if err := s.client.Get(ctx, path, &containerPermissions); err != nil {
 		return nil, fmt.Errorf("failed to get Safes: %w", err)
 	}
 
-	return &ContainerPermissions, nil
+	return &containerPermissions, nil
 }
 
 // GetSafePermissionsByName retrieves a single Safe by Safe Name amd a User or Group Name via the SCIM API.
@@ -85,12 +103,17 @@ func (s *Service) GetSafePermissionsSort(ctx context.Context, sortBy string, sor
 // Example Usage:
 //		getSafePermissionsByName, err := s.GetSafePermissionsByName(context.Background, "VaultInternal", "EPMAgent")
 //
-func (s *Service) GetSafePermissionsByName(ctx context.Context, safeName string, userOrGroupName string) (*types.ContainerPermission, error) {
-	if err := s.client.Get(ctx, fmt.Sprintf("/%s/%s:%s", "ContainerPermissions", url.PathEscape(safeName), url.PathEscape(userOrGroupName)), &ContainerPermission); err != nil {
+func (s *Service) GetSafePermissionsByName(ctx context.Context, safeName string, userOrGroupName string, opts ...ReadOption) (*types.ContainerPermission, error) {
+	var containerPermission types.ContainerPermission
+	path, err := s.readPath(ctx, "ContainerPermission", fmt.Sprintf("/%s/%s:%s", "ContainerPermissions", url.PathEscape(safeName), url.PathEscape(userOrGroupName)), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get User (%s) permissions on Safe %s: %w", userOrGroupName, safeName, err)
+	}
+	if err := s.client.Get(ctx, path, &containerPermission); err != nil {
 		return nil, fmt.Errorf("failed to get User (%s) permissions on Safe %s: %w", userOrGroupName, safeName, err)
 	}
 
-	return &ContainerPermission, nil
+	return &containerPermission, nil
 }
 
 // GetSafePermissionsByFilter retrieves a single Safe based on a provided filter via the SCIM API.
@@ -110,13 +133,45 @@ func (s *Service) GetSafePermissionsByName(ctx context.Context, safeName string,
 //		// Return specific group permissions on all safes
 //		getSafePermissionsByFilter, err := s.GetSafePermissionsByFilter(context.Background, "group.value", "18")
 //
-func (s *Service) GetSafePermissionByFilter(ctx context.Context, filterType string, filterQuery string) (*types.ContainerPermissions, error) {
-	pathEscapedQuery := url.PathEscape("filter=" + filterType + " eq \"" + filterQuery + "\"")
-	if err := s.client.Get(ctx, fmt.Sprintf("/%s?%s", "ContainerPermissions", pathEscapedQuery), &ContainerPermissions); err != nil {
+func (s *Service) GetSafePermissionByFilter(ctx context.Context, filterType string, filterQuery string, opts ...ReadOption) (*types.ContainerPermissions, error) {
+	var containerPermissions types.ContainerPermissions
+	query := url.Values{"filter": {filter.Attr(filterType).Eq(filterQuery).String()}}
+	path, err := s.readPath(ctx, "ContainerPermission", fmt.Sprintf("/%s?%s", "ContainerPermissions", encodeQuery(query)), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Safe Permissions based on filter parameters - %s = %s: %w", filterType, filterQuery, err)
+	}
+	if err := s.client.Get(ctx, path, &containerPermissions); err != nil {
 		return nil, fmt.Errorf("failed to get Safe Permissions based on filter parameters - %s = %s: %w", filterType, filterQuery, err)
 	}
 
-	return &ContainerPermissions, nil
+	return &containerPermissions, nil
+}
+
+// GetSafePermissionsByFilterExpr retrieves all Safe Permissions matching a filter expression via the SCIM API.
+// The response from the SCIM API is returned as the types.ContainerPermissions struct.
+// The attributes referenced by the expression are validated against filter.ContainerPermissions.
+//
+// Example Usage:
+//		// Return all permissions of a user on safes starting with "App"
+//		expr := filter.And(filter.Attr("container.name").Sw("App"), filter.Attr("user.display").Eq("EPMAgent"))
+//		getSafePermissionsByFilterExpr, err := s.GetSafePermissionsByFilterExpr(context.Background, expr)
+//
+func (s *Service) GetSafePermissionsByFilterExpr(ctx context.Context, expr filter.Expression, opts ...ReadOption) (*types.ContainerPermissions, error) {
+	if err := filter.ContainerPermissions.Validate(expr); err != nil {
+		return nil, fmt.Errorf("invalid filter provided: %w", err)
+	}
+
+	var containerPermissions types.ContainerPermissions
+	query := url.Values{"filter": {expr.String()}}
+	path, err := s.readPath(ctx, "ContainerPermission", fmt.Sprintf("/%s?%s", "ContainerPermissions", encodeQuery(query)), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Safe Permissions based on filter %s: %w", expr, err)
+	}
+	if err := s.client.Get(ctx, path, &containerPermissions); err != nil {
+		return nil, fmt.Errorf("failed to get Safe Permissions based on filter %s: %w", expr, err)
+	}
+
+	return &containerPermissions, nil
 }
 
 // AddSafePermissions attempts a "POST" operation to addpermissions to a single Safe for a
@@ -129,16 +184,17 @@ func (s *Service) GetSafePermissionByFilter(ctx context.Context, filterType stri
 //    		Schemas: []string{"urn:ietf:params:scim:schemas:pam:1.0:ContainerPermission"},
 //			User.Display: "john.smith@example.com",
 //			Container.Name: "ExampleContainer",
-//			Rights: []string{"UseAccounts","RetrieveAccounts","ListAccounts"},
+//			Rights: types.EndUserRights,
 // 		}
 //		addSafePermissions, err := s.AddSafePermissions(context.Background, safePermission)
 //
 func (s *Service) AddSafePermissions(ctx context.Context, safePermission types.ContainerPermission) (*types.ContainerPermission, error) {
-	if err := s.client.Post(ctx, fmt.Sprintf("/%s", "ContainerPermissions"), safePermission, &ContainerPermission); err != nil {
+	var containerPermission types.ContainerPermission
+	if err := s.client.Post(ctx, fmt.Sprintf("/%s", "ContainerPermissions"), safePermission, &containerPermission); err != nil {
 		return nil, fmt.Errorf("failed to add permissions to safe: %w", err)
 	}
 
-	return &ContainerPermission, nil
+	return &containerPermission, nil
 }
 
 // UpdateSafePermissions attempts to perform a "PUT" operation against a single Safe and requires
@@ -152,16 +208,89 @@ func (s *Service) AddSafePermissions(ctx context.Context, safePermission types.C
 //    		Schemas: []string{"urn:ietf:params:scim:schemas:pam:1.0:ContainerPermission"},
 //			User.Display: "john.smith@example.com",
 //			Container.Name: "ExampleContainer",
-//			Rights: []string{"UseAccounts","RetrieveAccounts","ListAccounts","ManageSafe"},
+//			Rights: types.EndUserRights | types.RightManageSafe,
 // 		}
 //      updateSafePermissions, err := s.UpdateSafePermissions(context.Background, safePermissionUpdate)
 //
-func (s *Service) UpdateSafePermissions(ctx context.Context, safePermission types.ContainerPermission) (*types.Container, error) {
-	if err := s.client.Put(ctx, fmt.Sprintf("/%s/%s:%s", "ContainerPermissions", safePermission.Container.Name, safePermission.User.Display), ContainerPermission, &ContainerPermission); err != nil {
+func (s *Service) UpdateSafePermissions(ctx context.Context, safePermission types.ContainerPermission) (*types.ContainerPermission, error) {
+	// Group memberships are identified by the Group name
+	member := safePermission.User.Display
+	if member == "" {
+		member = safePermission.Group.Display
+	}
+
+	var containerPermission types.ContainerPermission
+	if err := s.client.Put(ctx, fmt.Sprintf("/%s/%s:%s", "ContainerPermissions", url.PathEscape(safePermission.Container.Name), url.PathEscape(member)), safePermission, &containerPermission); err != nil {
+		return nil, fmt.Errorf("failed to update Safe Permissions: %w", err)
+	}
+
+	return &containerPermission, nil
+}
+
+// UpdateSafePermissionsIfMatch performs the "PUT" operation of UpdateSafePermissions only if the
+// Safe Permission has not been modified since safePermission.Meta.Version was retrieved,
+// ErrPreconditionFailed is returned otherwise. ErrETagNotSupported is returned if the service
+// provider does not support ETags.
+//
+// Example Usage:
+//		safePermission, err := s.GetSafePermissionsByName(context.Background, "ExampleSafe", "ExampleUser")
+//		safePermission.Rights = safePermission.Rights.Union(types.RightRetrieveAccounts)
+//		update, err := s.UpdateSafePermissionsIfMatch(context.Background, *safePermission)
+//
+func (s *Service) UpdateSafePermissionsIfMatch(ctx context.Context, safePermission types.ContainerPermission) (*types.ContainerPermission, error) {
+	ctx, err := s.ifMatch(ctx, safePermission.Meta.Version)
+	if err != nil {
 		return nil, fmt.Errorf("failed to update Safe Permissions: %w", err)
 	}
 
-	return &Container, nil
+	return s.UpdateSafePermissions(ctx, safePermission)
+}
+
+// EditSafePermission retrieves a single Safe Permission by Safe Name and User or Group Name,
+// applies fn to it, and replaces it with UpdateSafePermissionsIfMatch. The Safe Permission is
+// retrieved and fn applied again when it has been modified concurrently, so fn must only modify
+// the provided Safe Permission. An error returned by fn aborts the edit.
+//
+// Example Usage:
+//		edit, err := s.EditSafePermission(context.Background, "ExampleSafe", "ExampleUser", func(safePermission *types.ContainerPermission) error {
+//			safePermission.Rights = safePermission.Rights.Union(types.RightRetrieveAccounts)
+//			return nil
+//		})
+//
+func (s *Service) EditSafePermission(ctx context.Context, safeName string, userOrGroupName string, fn func(safePermission *types.ContainerPermission) error) (*types.ContainerPermission, error) {
+	safePermission, err := edit(ctx, func(ctx context.Context) (*types.ContainerPermission, error) {
+		return s.GetSafePermissionsByName(ctx, safeName, userOrGroupName)
+	}, fn, func(ctx context.Context, safePermission *types.ContainerPermission) (*types.ContainerPermission, error) {
+		return s.UpdateSafePermissionsIfMatch(ctx, *safePermission)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to edit %s permissions on Safe %s: %w", userOrGroupName, safeName, err)
+	}
+
+	return safePermission, nil
+}
+
+// PatchSafePermission attempts to perform a "PATCH" operation against the permissions of a single
+// User or Group on a single Safe and requires a types.PatchRequest with the desired operations.
+// The response from the SCIM API is returned as the types.ContainerPermission struct, which is
+// empty if the SCIM API does not return the updated permissions.
+//
+// Example Usage:
+//		patch := types.NewPatchRequest().
+//			Add("rights", []string{"RetrieveAccounts"})
+//		patchSafePermission, err := s.PatchSafePermission(context.Background, "ExampleSafe", "ExampleUser", patch)
+//
+func (s *Service) PatchSafePermission(ctx context.Context, safeName string, userOrGroupName string, patch *types.PatchRequest) (*types.ContainerPermission, error) {
+	if err := s.validatePatch(ctx, patch); err != nil {
+		return nil, fmt.Errorf("failed to patch %s permissions on Safe %s: %w", userOrGroupName, safeName, err)
+	}
+
+	var containerPermission types.ContainerPermission
+	if err := s.client.Patch(ctx, fmt.Sprintf("/%s/%s:%s", "ContainerPermissions", url.PathEscape(safeName), url.PathEscape(userOrGroupName)), patch, &containerPermission); err != nil {
+		return nil, fmt.Errorf("failed to patch %s permissions on Safe %s: %w", userOrGroupName, safeName, err)
+	}
+
+	return &containerPermission, nil
 }
 
 // DeleteSafe attempts to perform a "DELETE" operation against a single Safe for a
@@ -174,8 +303,8 @@ func (s *Service) UpdateSafePermissions(ctx context.Context, safePermission type
 //        err := s.DeleteSafePermission(context.Background, "ExampleSafe", "ExampleUser")
 //
 func (s *Service) DeleteSafePermission(ctx context.Context, safeName string, userOrGroupName string) error {
-	if err := s.client.Delete(ctx, fmt.Sprintf("/%s/%s:%s", "ContainerPermissions", safeName, userOrGroupName), nil); err != nil {
-		return fmt.Errorf("failed to remove %s Permissiosn from Safe %s: %w", userOrGroupName, safeName, err)
+	if err := s.client.Delete(ctx, fmt.Sprintf("/%s/%s:%s", "ContainerPermissions", url.PathEscape(safeName), url.PathEscape(userOrGroupName)), nil); err != nil {
+		return fmt.Errorf("failed to remove %s permissions from Safe %s: %w", userOrGroupName, safeName, err)
 	}
 
 	return nil
@@ -0,0 +1,43 @@
+package cybr_pam_scim_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/scimtest"
+)
+
+func TestDeleteEscapesNames(t *testing.T) {
+	srv := scimtest.NewServer()
+	defer srv.Close()
+	client := cybr_pam_scim.NewClient(srv.Client(), cybr_pam_scim.Options{ApiURL: srv.URL})
+	var paths []string
+	client.Use(func(next cybr_pam_scim.Handler) cybr_pam_scim.Handler {
+		return func(r *http.Request) (*http.Response, error) {
+			paths = append(paths, r.URL.EscapedPath())
+			return &http.Response{StatusCode: http.StatusNoContent, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("")), Request: r}, nil
+		}
+	})
+	s := cybr_pam_scim.NewServiceWithClient(client)
+	ctx := context.Background()
+
+	if err := s.DeleteSafePermission(ctx, "Ops/Prod", "App Admins?"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteSafe(ctx, "Ops/Prod #1"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/ContainerPermissions/Ops%2FProd:App%20Admins%3F", "/Containers/Ops%2FProd%20%231"}
+	if len(paths) != len(want) {
+		t.Fatalf("paths = %v, want %v", paths, want)
+	}
+	for i, path := range paths {
+		if !strings.HasSuffix(path, want[i]) {
+			t.Errorf("path = %s, want the escaped names %s", path, want[i])
+		}
+	}
+}
@@ -6,14 +6,13 @@ import (
 	"net/url"
 	"strconv"
 
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/filter"
 	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
 	"golang.org/x/exp/slices"
 )
 
-var (
-	Containers types.Containers
-	Container  types.Container
-)
+// safeSortBy contains the attributes Safes may be sorted by
+var safeSortBy = []string{"name", "displayName", "description", "id", "meta.created", "meta.lastmodified", "meta.location"}
 
 // GetSafes retrieves all Safes via the SCIM API.
 // The response from the SCIM API is returned as the types.Containers struct
@@ -21,12 +20,17 @@ var (
 // Example Usage:
 //		getSafes, err := s.GetSafes(context.Background)
 //
-func (s *Service) GetSafes(ctx context.Context) (*types.Containers, error) {
-	if err := s.client.Get(ctx, fmt.Sprintf("/%s", "Containers"), &Containers); err != nil {
+func (s *Service) GetSafes(ctx context.Context, opts ...ReadOption) (*types.Containers, error) {
+	var containers types.Containers
+	path, err := s.readPath(ctx, "Container", fmt.Sprintf("/%s", "Containers"), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Safes: %w", err)
+	}
+	if err := s.client.Get(ctx, path, &containers); err != nil {
 		return nil, fmt.Errorf("failed to get Safes: %w", err)
 	}
 
-	return &Containers, nil
+	return &containers, nil
 }
 
 // GetSafesIndex retrieves a limited subset of Safes based on a starting index and count.
@@ -37,13 +41,18 @@ func (s *Service) GetSafes(ctx context.Context) (*types.Containers, error) {
 // Example Usage:
 //		getSafesIndex, err := s.GetSafesIndex(context.Background, 10, 5)
 //
-func (s *Service) GetSafesIndex(ctx context.Context, startIndex int, count int) (*types.Containers, error) {
+func (s *Service) GetSafesIndex(ctx context.Context, startIndex int, count int, opts ...ReadOption) (*types.Containers, error) {
+	var containers types.Containers
 	pathEscapedQuery := url.PathEscape("startIndex=" + strconv.Itoa(startIndex) + "&count=" + strconv.Itoa(count))
-	if err := s.client.Get(ctx, fmt.Sprintf("/%s?%s", "Containers", pathEscapedQuery), &Containers); err != nil {
+	path, err := s.readPath(ctx, "Container", fmt.Sprintf("/%s?%s", "Containers", pathEscapedQuery), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Safes: %w", err)
+	}
+	if err := s.client.Get(ctx, path, &containers); err != nil {
 		return nil, fmt.Errorf("failed to get Safes: %w", err)
 	}
 
-	return &Containers, nil
+	return &containers, nil
 }
 
 // GetSafesSort retrieves and sorts all Safes via the SCIM API based on provided
@@ -56,11 +65,15 @@ func (s *Service) GetSafesIndex(ctx context.Context, startIndex int, count int)
 // Example Usage:
 //		getSafesSort, err := s.GetSafesSort(context.Background, "SafeName", "ascending")
 //
-func (s *Service) GetSafesSort(ctx context.Context, sortBy string, sortOrder string) (*types.Containers, error) {
-	allowedSortBy := []string{"name", "displayName", "description", "id", "meta.created", "meta.lastmodified", "meta.location"}
+func (s *Service) GetSafesSort(ctx context.Context, sortBy string, sortOrder string, opts ...ReadOption) (*types.Containers, error) {
+	if !s.capabilities(ctx).SortSupported() {
+		return nil, ErrSortNotSupported
+	}
+
+	var containers types.Containers
 	var pathEscapedQuery string
 	// Input validations:
-	if slices.Contains(allowedSortBy, sortBy) {
+	if slices.Contains(safeSortBy, sortBy) {
 		if sortOrder == "ascending" || sortOrder == "descending" {
 			pathEscapedQuery = url.PathEscape("sortBy=" + sortBy + "&sortOrder=" + sortOrder)
 		} else if sortOrder == "" {
@@ -72,11 +85,15 @@ func (s *Service) GetSafesSort(ctx context.Context, sortBy string, sortOrder str
 		return nil, fmt.Errorf("invalid sortBy value provided, accepted values are name, displayName, description, id, meta.created, meta.lastModified, or meta.location")
 	}
 
-	if err := s.client.Get(ctx, fmt.Sprintf("/%s?%s", "Containers", pathEscapedQuery), &Containers); err != nil {
+	path, err := s.readPath(ctx, "Container", fmt.Sprintf("/%s?%s", "Containers", pathEscapedQuery), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Safes: %w", err)
+	}
+	if err := s.client.Get(ctx, path, &containers); err != nil {
 		return nil, fmt.Errorf("failed to get Safes: %w", err)
 	}
 
-	return &Containers, nil
+	return &containers, nil
 }
 
 // GetSafeByName retrieves a single Safe by Safe Name via the SCIM API.
@@ -87,12 +104,17 @@ func (s *Service) GetSafesSort(ctx context.Context, sortBy string, sortOrder str
 // Example Usage:
 //		getSafeByName, err := s.GetSafeByName(context.Background, "NotificationEngine")
 //
-func (s *Service) GetSafeByName(ctx context.Context, safeName string) (*types.Container, error) {
-	if err := s.client.Get(ctx, fmt.Sprintf("/%s/%s", "Containers", url.PathEscape(safeName)), &Container); err != nil {
+func (s *Service) GetSafeByName(ctx context.Context, safeName string, opts ...ReadOption) (*types.Container, error) {
+	var container types.Container
+	path, err := s.readPath(ctx, "Container", fmt.Sprintf("/%s/%s", "Containers", url.PathEscape(safeName)), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Safe %s: %w", safeName, err)
+	}
+	if err := s.client.Get(ctx, path, &container); err != nil {
 		return nil, fmt.Errorf("failed to get Safe %s: %w", safeName, err)
 	}
 
-	return &Container, nil
+	return &container, nil
 }
 
 // GetSafeByFilter retrieves a single Safe based on a provided filter via the SCIM API.
@@ -105,13 +127,48 @@ func (s *Service) GetSafeByName(ctx context.Context, safeName string) (*types.Co
 // Example Usage:
 //		getSafeByFilter, err := s.GetSafeByFilter(context.Background, "name", "PVWATicketingSystem")
 //
-func (s *Service) GetSafeByFilter(ctx context.Context, filterType string, filterQuery string) (*types.Container, error) {
-	pathEscapedQuery := url.PathEscape("filter=" + filterType + " eq \"" + filterQuery + "\"")
-	if err := s.client.Get(ctx, fmt.Sprintf("/%s?%s", "Containers", pathEscapedQuery), &Container); err != nil {
+func (s *Service) GetSafeByFilter(ctx context.Context, filterType string, filterQuery string, opts ...ReadOption) (*types.Container, error) {
+	var containers types.ListResponse[types.Container]
+	query := url.Values{"filter": {filter.Attr(filterType).Eq(filterQuery).String()}}
+	path, err := s.readPath(ctx, "Container", fmt.Sprintf("/%s?%s", "Containers", encodeQuery(query)), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Container based on filter parameters - %s = %s: %w", filterType, filterQuery, err)
+	}
+	if err := s.client.Get(ctx, path, &containers); err != nil {
 		return nil, fmt.Errorf("failed to get Container based on filter parameters - %s = %s: %w", filterType, filterQuery, err)
 	}
+	container, ok := containers.First()
+	if !ok {
+		return nil, fmt.Errorf("failed to get Container based on filter parameters - %s = %s: %w", filterType, filterQuery, ErrNotFound)
+	}
+
+	return container, nil
+}
+
+// GetSafesByFilterExpr retrieves all Safes matching a filter expression via the SCIM API.
+// The response from the SCIM API is returned as the types.Containers struct.
+// The attributes referenced by the expression are validated against filter.Containers.
+//
+// Example Usage:
+//		expr := filter.Or(filter.Attr("name").Sw("App"), filter.Attr("description").Co("application"))
+//		getSafesByFilterExpr, err := s.GetSafesByFilterExpr(context.Background, expr)
+//
+func (s *Service) GetSafesByFilterExpr(ctx context.Context, expr filter.Expression, opts ...ReadOption) (*types.Containers, error) {
+	if err := filter.Containers.Validate(expr); err != nil {
+		return nil, fmt.Errorf("invalid filter provided: %w", err)
+	}
+
+	var containers types.Containers
+	query := url.Values{"filter": {expr.String()}}
+	path, err := s.readPath(ctx, "Container", fmt.Sprintf("/%s?%s", "Containers", encodeQuery(query)), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Safes based on filter %s: %w", expr, err)
+	}
+	if err := s.client.Get(ctx, path, &containers); err != nil {
+		return nil, fmt.Errorf("failed to get Safes based on filter %s: %w", expr, err)
+	}
 
-	return &Container, nil
+	return &containers, nil
 }
 
 // AddSafe attempts add a single Safe and requires a types.Container struct with the
@@ -129,11 +186,12 @@ func (s *Service) GetSafeByFilter(ctx context.Context, filterType string, filter
 //      addSafe, err := s.AddSafe(context.Background, safe)
 //
 func (s *Service) AddSafe(ctx context.Context, safe types.Container) (*types.Container, error) {
-	if err := s.client.Post(ctx, fmt.Sprintf("/%s", "Containers"), safe, &Container); err != nil {
+	var container types.Container
+	if err := s.client.Post(ctx, fmt.Sprintf("/%s", "Containers"), safe, &container); err != nil {
 		return nil, fmt.Errorf("failed to add Container %s: %w", safe.Name, err)
 	}
 
-	return &Container, nil
+	return &container, nil
 }
 
 // UpdateSafe attempts to perform a "PUT" operation against a single Safe and requires
@@ -151,11 +209,78 @@ func (s *Service) AddSafe(ctx context.Context, safe types.Container) (*types.Con
 //      updateSafe, err := s.UpdateContainer(context.Background, safe)
 //
 func (s *Service) UpdateSafe(ctx context.Context, safe types.Container) (*types.Container, error) {
-	if err := s.client.Put(ctx, fmt.Sprintf("/%s/%s", "Containers", safe.Id), Container, &Container); err != nil {
+	var container types.Container
+	if err := s.client.Put(ctx, fmt.Sprintf("/%s/%s", "Containers", safe.Id), safe, &container); err != nil {
 		return nil, fmt.Errorf("failed to update Container %s: %w", safe.Id, err)
 	}
 
-	return &Container, nil
+	return &container, nil
+}
+
+// UpdateSafeIfMatch performs the "PUT" operation of UpdateSafe only if the Safe has not been
+// modified since safe.Meta.Version was retrieved, ErrPreconditionFailed is returned otherwise.
+// ErrETagNotSupported is returned if the service provider does not support ETags.
+//
+// Example Usage:
+//		safe, err := s.GetSafeByName(context.Background, "ExampleSafe")
+//		safe.Description = "Application accounts"
+//		update, err := s.UpdateSafeIfMatch(context.Background, *safe)
+//
+func (s *Service) UpdateSafeIfMatch(ctx context.Context, safe types.Container) (*types.Container, error) {
+	ctx, err := s.ifMatch(ctx, safe.Meta.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update Container %s: %w", safe.Id, err)
+	}
+
+	return s.UpdateSafe(ctx, safe)
+}
+
+// EditSafe retrieves a single Safe by Safe Name, applies fn to it, and replaces it with
+// UpdateSafeIfMatch. The Safe is retrieved and fn applied again when it has been modified
+// concurrently, so fn must only modify the provided Safe. An error returned by fn aborts the
+// edit.
+//
+// Example Usage:
+//		edit, err := s.EditSafe(context.Background, "ExampleSafe", func(safe *types.Container) error {
+//			safe.Description = "Application accounts"
+//			return nil
+//		})
+//
+func (s *Service) EditSafe(ctx context.Context, safeName string, fn func(safe *types.Container) error) (*types.Container, error) {
+	safe, err := edit(ctx, func(ctx context.Context) (*types.Container, error) {
+		return s.GetSafeByName(ctx, safeName)
+	}, fn, func(ctx context.Context, safe *types.Container) (*types.Container, error) {
+		return s.UpdateSafeIfMatch(ctx, *safe)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to edit Container %s: %w", safeName, err)
+	}
+
+	return safe, nil
+}
+
+// PatchSafe attempts to perform a "PATCH" operation against a single Safe by Safe Name and
+// requires a types.PatchRequest with the desired operations. Only the attributes referenced
+// by the operations are modified. The response from the SCIM API is returned as the types.Container struct,
+// which is empty if the SCIM API does not return the updated Safe.
+//
+// Example Usage:
+//		patch := types.NewPatchRequest().
+//			Replace("description", "Application accounts").
+//			Replace("urn:ietf:params:scim:schemas:cyberark:1.0:Safe:NumberOfDaysRetention", 30)
+//		patchSafe, err := s.PatchSafe(context.Background, "ExampleSafe", patch)
+//
+func (s *Service) PatchSafe(ctx context.Context, safeName string, patch *types.PatchRequest) (*types.Container, error) {
+	if err := s.validatePatch(ctx, patch); err != nil {
+		return nil, fmt.Errorf("failed to patch Container %s: %w", safeName, err)
+	}
+
+	var container types.Container
+	if err := s.client.Patch(ctx, fmt.Sprintf("/%s/%s", "Containers", url.PathEscape(safeName)), patch, &container); err != nil {
+		return nil, fmt.Errorf("failed to patch Container %s: %w", safeName, err)
+	}
+
+	return &container, nil
 }
 
 // DeleteSafe attempts to perform a "DELETE" operation against a single Safe by
@@ -167,7 +292,7 @@ func (s *Service) UpdateSafe(ctx context.Context, safe types.Container) (*types.
 //		err := s.DeleteSafe(context.Background, "ExampleSafe")
 //
 func (s *Service) DeleteSafe(ctx context.Context, name string) error {
-	if err := s.client.Delete(ctx, fmt.Sprintf("/%s/%s", "Containers", name), nil); err != nil {
+	if err := s.client.Delete(ctx, fmt.Sprintf("/%s/%s", "Containers", url.PathEscape(name)), nil); err != nil {
 		return fmt.Errorf("failed to delete Container %s: %w", name, err)
 	}
 
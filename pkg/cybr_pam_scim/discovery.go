@@ -0,0 +1,263 @@
+package cybr_pam_scim
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
+)
+
+// GetServiceProviderConfig retrieves the features supported by the SCIM service provider.
+// The response from the SCIM API is returned as the types.ScimConfig struct.
+//
+// Example Usage:
+//		getServiceProviderConfig, err := s.GetServiceProviderConfig(context.Background)
+//
+func (s *Service) GetServiceProviderConfig(ctx context.Context) (*types.ScimConfig, error) {
+	var scimConfig types.ScimConfig
+	if err := s.client.Get(ctx, fmt.Sprintf("/%s", "ServiceProviderConfig"), &scimConfig); err != nil {
+		return nil, fmt.Errorf("failed to get Service Provider Config: %w", err)
+	}
+
+	return &scimConfig, nil
+}
+
+// GetResourceTypes retrieves the resource types supported by the SCIM service provider.
+// The response from the SCIM API is returned as the types.ResourceTypes struct.
+//
+// Example Usage:
+//		getResourceTypes, err := s.GetResourceTypes(context.Background)
+//
+func (s *Service) GetResourceTypes(ctx context.Context) (*types.ResourceTypes, error) {
+	var resourceTypes types.ResourceTypes
+	if err := s.client.Get(ctx, fmt.Sprintf("/%s", "ResourceTypes"), &resourceTypes); err != nil {
+		return nil, fmt.Errorf("failed to get Resource Types: %w", err)
+	}
+
+	return &resourceTypes, nil
+}
+
+// GetSchemas retrieves the schemas supported by the SCIM service provider.
+// The response from the SCIM API is returned as the types.Schemas struct.
+//
+// Example Usage:
+//		getSchemas, err := s.GetSchemas(context.Background)
+//
+func (s *Service) GetSchemas(ctx context.Context) (*types.Schemas, error) {
+	var schemas types.Schemas
+	if err := s.client.Get(ctx, fmt.Sprintf("/%s", "Schemas"), &schemas); err != nil {
+		return nil, fmt.Errorf("failed to get Schemas: %w", err)
+	}
+
+	return &schemas, nil
+}
+
+// Capabilities is the view of the features, resource types, and schemas supported by the
+// SCIM service provider. All methods may be called on a nil Capabilities, in which case
+// nothing is assumed to be restricted.
+type Capabilities struct {
+	Config        types.ScimConfig
+	ResourceTypes []types.ResourceType
+	Schemas       []types.Schema
+}
+
+// SortSupported reports whether the service provider supports sorting
+func (c *Capabilities) SortSupported() bool {
+	return c == nil || c.Config.Sort.Supported
+}
+
+// PatchSupported reports whether the service provider supports PATCH operations
+func (c *Capabilities) PatchSupported() bool {
+	return c == nil || c.Config.Patch.Supported
+}
+
+// BulkSupported reports whether the service provider supports bulk operations
+func (c *Capabilities) BulkSupported() bool {
+	return c == nil || c.Config.Bulk.Supported
+}
+
+// ETagSupported reports whether the service provider supports ETags
+func (c *Capabilities) ETagSupported() bool {
+	return c == nil || c.Config.Etag.Supported
+}
+
+// ChangePasswordSupported reports whether the service provider supports changing passwords
+func (c *Capabilities) ChangePasswordSupported() bool {
+	return c == nil || c.Config.ChangePassword.Supported
+}
+
+// MaxResults returns the maximum number of resources returned in a response, zero if unknown
+func (c *Capabilities) MaxResults() int {
+	if c == nil {
+		return 0
+	}
+
+	return c.Config.Filter.MaxResults
+}
+
+// MaxBulkOperations returns the maximum number of operations in a bulk request, zero if unknown
+func (c *Capabilities) MaxBulkOperations() int {
+	if c == nil {
+		return 0
+	}
+
+	return c.Config.Bulk.MaxOperations
+}
+
+// MaxBulkPayloadSize returns the maximum payload size in bytes of a bulk request, zero if unknown
+func (c *Capabilities) MaxBulkPayloadSize() int {
+	if c == nil {
+		return 0
+	}
+
+	return c.Config.Bulk.MaxPayloadSize
+}
+
+// ResourceType returns the resource type with the provided name (e.g. User, Container)
+func (c *Capabilities) ResourceType(name string) (types.ResourceType, bool) {
+	if c != nil {
+		for _, resourceType := range c.ResourceTypes {
+			if strings.EqualFold(resourceType.Name, name) {
+				return resourceType, true
+			}
+		}
+	}
+
+	return types.ResourceType{}, false
+}
+
+// Schema returns the schema with the provided id (e.g. urn:ietf:params:scim:schemas:core:2.0:User)
+func (c *Capabilities) Schema(id string) (types.Schema, bool) {
+	if c != nil {
+		for _, schema := range c.Schemas {
+			if strings.EqualFold(schema.Id, id) {
+				return schema, true
+			}
+		}
+	}
+
+	return types.Schema{}, false
+}
+
+// Capabilities returns the capabilities of the service provider. They are requested once
+// via GetServiceProviderConfig, GetResourceTypes, and GetSchemas and cached by the Service
+// once the request succeeds, concurrent calls share a single request. Resource types and
+// schemas are left empty if the service provider does not provide them.
+//
+// The cached capabilities are consulted by other functions, e.g. sorting is refused when it is
+// not supported and page sizes of iterators are clamped to the maximum number of results.
+//
+// Example Usage:
+//		capabilities, err := s.Capabilities(context.Background)
+//		if capabilities.BulkSupported() {
+//			fmt.Println(capabilities.MaxBulkOperations())
+//		}
+//
+func (s *Service) Capabilities(ctx context.Context) (*Capabilities, error) {
+	s.capabilitiesMu.Lock()
+	capabilities := s.cachedCapabilities
+	s.capabilitiesMu.Unlock()
+	if capabilities != nil {
+		return capabilities, nil
+	}
+
+	return s.discoverShared(ctx)
+}
+
+// RefreshCapabilities requests the capabilities again and replaces the cached capabilities.
+// The cached capabilities are kept if the request fails.
+func (s *Service) RefreshCapabilities(ctx context.Context) (*Capabilities, error) {
+	return s.discoverShared(ctx)
+}
+
+// discoveryBackoff is the delay before a failed discovery is attempted again by functions
+// consulting the capabilities
+const discoveryBackoff = 30 * time.Second
+
+// capabilities returns the cached capabilities and requests them on first use. It returns
+// nil if they could not be requested, which other functions treat as unrestricted. A failed
+// discovery is attempted again after discoveryBackoff, or by Capabilities and RefreshCapabilities.
+func (s *Service) capabilities(ctx context.Context) *Capabilities {
+	s.capabilitiesMu.Lock()
+	capabilities, retryAt := s.cachedCapabilities, s.discoveryRetryAt
+	s.capabilitiesMu.Unlock()
+	if capabilities != nil || time.Now().Before(retryAt) {
+		return capabilities
+	}
+
+	capabilities, _ = s.discoverShared(ctx)
+
+	return capabilities
+}
+
+// discoveryCall is a discovery in flight, shared by the callers requesting the capabilities
+// in the meantime
+type discoveryCall struct {
+	done         chan struct{}
+	capabilities *Capabilities
+	err          error
+	// canceled reports that the context of the caller performing the discovery ended
+	canceled bool
+}
+
+// discoverShared requests the capabilities and caches them on success. Concurrent callers
+// share a single discovery, capabilitiesMu is not held while it is in flight.
+func (s *Service) discoverShared(ctx context.Context) (*Capabilities, error) {
+	for {
+		s.capabilitiesMu.Lock()
+		call := s.discovery
+		if call == nil {
+			call = &discoveryCall{done: make(chan struct{})}
+			s.discovery = call
+			s.capabilitiesMu.Unlock()
+
+			call.capabilities, call.err = s.discover(ctx)
+			call.canceled = call.err != nil && ctx.Err() != nil
+
+			s.capabilitiesMu.Lock()
+			s.discovery = nil
+			switch {
+			case call.err == nil:
+				s.cachedCapabilities = call.capabilities
+				s.discoveryRetryAt = time.Time{}
+			case !call.canceled:
+				s.discoveryRetryAt = time.Now().Add(discoveryBackoff)
+			}
+			s.capabilitiesMu.Unlock()
+			close(call.done)
+
+			return call.capabilities, call.err
+		}
+		s.capabilitiesMu.Unlock()
+
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		// The discovery ended with the context of another caller, attempt it again
+		if !call.canceled {
+			return call.capabilities, call.err
+		}
+	}
+}
+
+// discover requests the capabilities
+func (s *Service) discover(ctx context.Context) (*Capabilities, error) {
+	scimConfig, err := s.GetServiceProviderConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	capabilities := &Capabilities{Config: *scimConfig}
+	if resourceTypes, err := s.GetResourceTypes(ctx); err == nil {
+		capabilities.ResourceTypes = resourceTypes.Items()
+	}
+	if schemas, err := s.GetSchemas(ctx); err == nil {
+		capabilities.Schemas = schemas.Items()
+	}
+
+	return capabilities, nil
+}
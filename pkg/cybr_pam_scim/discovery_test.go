@@ -0,0 +1,82 @@
+package cybr_pam_scim_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/scimtest"
+)
+
+func TestCapabilitiesFailureNotCached(t *testing.T) {
+	srv := scimtest.NewServer()
+	defer srv.Close()
+	config := srv.Config()
+	config.Sort.Supported = false
+	srv.SetConfig(config)
+	s := srv.NewService()
+	ctx := context.Background()
+
+	srv.InjectFault(scimtest.Fault{Method: http.MethodGet, Path: "/ServiceProviderConfig", Status: http.StatusInternalServerError, Times: 1})
+	if _, err := s.Capabilities(ctx); err == nil {
+		t.Fatal("Capabilities succeeded with a failing service provider")
+	}
+	if _, err := s.Capabilities(ctx); err != nil {
+		t.Fatalf("Capabilities after a failure: %v", err)
+	}
+	if _, err := s.GetUsersSort(ctx, "userName", "ascending"); !errors.Is(err, cybr_pam_scim.ErrSortNotSupported) {
+		t.Errorf("GetUsersSort error = %v, want ErrSortNotSupported", err)
+	}
+}
+
+func TestCapabilitiesSharedDiscovery(t *testing.T) {
+	srv := scimtest.NewServer()
+	defer srv.Close()
+	s := srv.NewService()
+
+	srv.InjectFault(scimtest.Fault{Method: http.MethodGet, Path: "/ServiceProviderConfig", Latency: 100 * time.Millisecond, Times: 1})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.Capabilities(context.Background()); err != nil {
+				t.Errorf("Capabilities: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// ServiceProviderConfig, ResourceTypes, and Schemas
+	if requests := srv.Requests(); requests != 3 {
+		t.Errorf("requests = %d, want a single discovery of 3 requests", requests)
+	}
+}
+
+func TestCapabilitiesCanceledDiscovery(t *testing.T) {
+	srv := scimtest.NewServer()
+	defer srv.Close()
+	s := srv.NewService()
+
+	srv.InjectFault(scimtest.Fault{Method: http.MethodGet, Path: "/ServiceProviderConfig", Latency: time.Second, Times: 1})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		_, err := s.Capabilities(ctx)
+		done <- err
+	}()
+
+	// Joins the discovery in flight and attempts it again when the context of the first caller ends
+	time.Sleep(10 * time.Millisecond)
+	if _, err := s.Capabilities(context.Background()); err != nil {
+		t.Errorf("Capabilities: %v", err)
+	}
+	if err := <-done; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Capabilities with an ended context error = %v, want context.DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,114 @@
+package cybr_pam_scim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+var (
+	ErrUserAccessDenied           = errors.New("you do not have access to the requested resource")
+	ErrNotFound                   = errors.New("the requested resource not found")
+	ErrTooManyRequests            = errors.New("you have exceeded throttle")
+	ErrConflict                   = errors.New("the resource conflicts with an existing resource")
+	ErrInvalidFilter              = errors.New("the filter syntax is invalid or the filter is not supported")
+	ErrInvalidValue               = errors.New("a required value is missing or a provided value is invalid")
+	ErrPreconditionFailed         = errors.New("the resource has been modified since it was last retrieved")
+	ErrNotModified                = errors.New("the resource has not been modified since the provided version")
+	ErrSortNotSupported           = errors.New("sorting is not supported by the service provider")
+	ErrPatchNotSupported          = errors.New("patch operations are not supported by the service provider")
+	ErrETagNotSupported           = errors.New("ETags are not supported by the service provider")
+	ErrChangePasswordNotSupported = errors.New("changing passwords is not supported by the service provider")
+)
+
+// maxErrorBodySize limits how much of an error response body is kept in a ScimError
+const maxErrorBodySize = 1 << 20
+
+// ScimError is returned for every unsuccessful response of the SCIM API. The SCIM error
+// response (urn:ietf:params:scim:api:messages:2.0:Error) is decoded when present.
+//
+// ScimError satisfies errors.Is for the sentinel errors matching its status code and
+// scimType, e.g. a 409 response with scimType "uniqueness" matches ErrConflict.
+//
+// Example Usage:
+//		_, err := s.AddUser(context.Background, user)
+//		if errors.Is(err, cybr_pam_scim.ErrConflict) {
+//			// User already exists
+//		}
+//		var scimErr *cybr_pam_scim.ScimError
+//		if errors.As(err, &scimErr) {
+//			fmt.Println(scimErr.StatusCode, scimErr.ScimType, scimErr.Detail)
+//		}
+//
+type ScimError struct {
+	StatusCode int
+	ScimType   string
+	Detail     string
+	Method     string
+	URL        string
+	Body       []byte
+}
+
+func (e *ScimError) Error() string {
+	msg := fmt.Sprintf("failed to do request [%s:%s], %d status code received", e.Method, e.URL, e.StatusCode)
+	if e.ScimType != "" {
+		msg = fmt.Sprintf("%s (%s)", msg, e.ScimType)
+	}
+	if e.Detail != "" {
+		msg = fmt.Sprintf("%s: %s", msg, e.Detail)
+	}
+
+	return msg
+}
+
+// Is reports whether the ScimError corresponds to one of the package sentinel errors
+func (e *ScimError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrUserAccessDenied:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrTooManyRequests:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict || e.ScimType == "uniqueness"
+	case ErrInvalidFilter:
+		return e.ScimType == "invalidFilter"
+	case ErrInvalidValue:
+		return e.ScimType == "invalidValue"
+	case ErrPreconditionFailed:
+		return e.StatusCode == http.StatusPreconditionFailed
+	case ErrNotModified:
+		return e.StatusCode == http.StatusNotModified
+	}
+
+	return false
+}
+
+// newScimError builds a ScimError from an unsuccessful response and decodes the SCIM
+// error response from its body when possible.
+func newScimError(r *http.Request, resp *http.Response) *ScimError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+
+	scimErr := &ScimError{
+		StatusCode: resp.StatusCode,
+		Method:     r.Method,
+		URL:        r.URL.String(),
+		Body:       body,
+	}
+
+	// The status of the body is not decoded, service providers send it as a string, a number, or
+	// a reason phrase, and the status code of the response is authoritative
+	var errorResponse struct {
+		ScimType string `json:"scimType"`
+		Detail   string `json:"detail"`
+	}
+	if err := json.Unmarshal(body, &errorResponse); err == nil {
+		scimErr.ScimType = errorResponse.ScimType
+		scimErr.Detail = errorResponse.Detail
+	}
+
+	return scimErr
+}
@@ -0,0 +1,147 @@
+package cybr_pam_scim_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim"
+)
+
+// errorResponse requests a server responding with the status, content type, and body, and
+// returns the ScimError of the request
+func errorResponse(t *testing.T, status int, contentType string, body string) *cybr_pam_scim.ScimError {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	defer srv.Close()
+
+	client := cybr_pam_scim.NewClient(srv.Client(), cybr_pam_scim.Options{ApiURL: srv.URL})
+	err := client.Get(context.Background(), "/Users/8", nil)
+	var scimErr *cybr_pam_scim.ScimError
+	if !errors.As(err, &scimErr) {
+		t.Fatalf("error = %v, want a ScimError", err)
+	}
+
+	return scimErr
+}
+
+func TestScimErrorDecode(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+		scimType    string
+		detail      string
+		message     string
+	}{
+		{
+			"status as a string", http.StatusConflict, "application/scim+json",
+			`{"schemas":["urn:ietf:params:scim:api:messages:2.0:Error"],"scimType":"uniqueness","detail":"User already exists","status":"409"}`,
+			"uniqueness", "User already exists",
+			"failed to do request [GET:%s/Users/8], 409 status code received (uniqueness): User already exists",
+		},
+		{
+			"status as a number", http.StatusBadRequest, "application/scim+json",
+			`{"schemas":["urn:ietf:params:scim:api:messages:2.0:Error"],"scimType":"invalidFilter","detail":"Unsupported operator","status":400}`,
+			"invalidFilter", "Unsupported operator",
+			"failed to do request [GET:%s/Users/8], 400 status code received (invalidFilter): Unsupported operator",
+		},
+		{
+			"status which is not a number", http.StatusConflict, "application/scim+json",
+			`{"schemas":["urn:ietf:params:scim:api:messages:2.0:Error"],"scimType":"uniqueness","detail":"User already exists","status":"Conflict"}`,
+			"uniqueness", "User already exists",
+			"failed to do request [GET:%s/Users/8], 409 status code received (uniqueness): User already exists",
+		},
+		{
+			"without scimType", http.StatusNotFound, "application/json",
+			`{"schemas":["urn:ietf:params:scim:api:messages:2.0:Error"],"detail":"Resource 8 not found","status":"404"}`,
+			"", "Resource 8 not found",
+			"failed to do request [GET:%s/Users/8], 404 status code received: Resource 8 not found",
+		},
+		{
+			"non-JSON body", http.StatusBadGateway, "text/html",
+			`<html><body><h1>502 Bad Gateway</h1></body></html>`,
+			"", "",
+			"failed to do request [GET:%s/Users/8], 502 status code received",
+		},
+		{
+			"empty body", http.StatusServiceUnavailable, "",
+			``,
+			"", "",
+			"failed to do request [GET:%s/Users/8], 503 status code received",
+		},
+	}
+	for _, tt := range tests {
+		scimErr := errorResponse(t, tt.status, tt.contentType, tt.body)
+		if scimErr.StatusCode != tt.status || scimErr.ScimType != tt.scimType || scimErr.Detail != tt.detail {
+			t.Errorf("%s: error = %+v, want status %d, scimType %q, and detail %q", tt.name, scimErr, tt.status, tt.scimType, tt.detail)
+		}
+		if scimErr.Method != http.MethodGet || string(scimErr.Body) != tt.body {
+			t.Errorf("%s: method = %s and body = %q, want the request method and the response body", tt.name, scimErr.Method, scimErr.Body)
+		}
+		// The URL of the server is only known once it is started
+		base := scimErr.URL[:len(scimErr.URL)-len("/Users/8")]
+		if want := fmt.Sprintf(tt.message, base); scimErr.Error() != want {
+			t.Errorf("%s: message = %s, want %s", tt.name, scimErr.Error(), want)
+		}
+	}
+}
+
+func TestScimErrorIs(t *testing.T) {
+	sentinels := map[string]error{
+		"ErrUserAccessDenied":           cybr_pam_scim.ErrUserAccessDenied,
+		"ErrNotFound":                   cybr_pam_scim.ErrNotFound,
+		"ErrTooManyRequests":            cybr_pam_scim.ErrTooManyRequests,
+		"ErrConflict":                   cybr_pam_scim.ErrConflict,
+		"ErrInvalidFilter":              cybr_pam_scim.ErrInvalidFilter,
+		"ErrInvalidValue":               cybr_pam_scim.ErrInvalidValue,
+		"ErrPreconditionFailed":         cybr_pam_scim.ErrPreconditionFailed,
+		"ErrNotModified":                cybr_pam_scim.ErrNotModified,
+		"ErrSortNotSupported":           cybr_pam_scim.ErrSortNotSupported,
+		"ErrPatchNotSupported":          cybr_pam_scim.ErrPatchNotSupported,
+		"ErrETagNotSupported":           cybr_pam_scim.ErrETagNotSupported,
+		"ErrChangePasswordNotSupported": cybr_pam_scim.ErrChangePasswordNotSupported,
+	}
+	tests := []struct {
+		status   int
+		scimType string
+		matches  []string
+	}{
+		{http.StatusUnauthorized, "", []string{"ErrUserAccessDenied"}},
+		{http.StatusForbidden, "", []string{"ErrUserAccessDenied"}},
+		{http.StatusNotFound, "", []string{"ErrNotFound"}},
+		{http.StatusTooManyRequests, "", []string{"ErrTooManyRequests"}},
+		{http.StatusConflict, "", []string{"ErrConflict"}},
+		{http.StatusConflict, "uniqueness", []string{"ErrConflict"}},
+		{http.StatusBadRequest, "uniqueness", []string{"ErrConflict"}},
+		{http.StatusBadRequest, "invalidFilter", []string{"ErrInvalidFilter"}},
+		{http.StatusBadRequest, "invalidValue", []string{"ErrInvalidValue"}},
+		{http.StatusBadRequest, "tooMany", nil},
+		{http.StatusBadRequest, "", nil},
+		{http.StatusPreconditionFailed, "", []string{"ErrPreconditionFailed"}},
+		{http.StatusNotModified, "", []string{"ErrNotModified"}},
+		{http.StatusInternalServerError, "", nil},
+	}
+	for _, tt := range tests {
+		scimErr := &cybr_pam_scim.ScimError{StatusCode: tt.status, ScimType: tt.scimType, Method: http.MethodGet, URL: "https://example.com/scim/v2/Users"}
+		// Wrapped like the errors returned by the Service methods
+		err := fmt.Errorf("failed to get user 8: %w", scimErr)
+		want := map[string]bool{}
+		for _, name := range tt.matches {
+			want[name] = true
+		}
+		for name, sentinel := range sentinels {
+			if got := errors.Is(err, sentinel); got != want[name] {
+				t.Errorf("%d %s: errors.Is(%s) = %v, want %v", tt.status, tt.scimType, name, got, want[name])
+			}
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package cybr_pam_scim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// maxEditAttempts is the number of times an Edit function reads, modifies, and writes a
+// resource before giving up on concurrent modifications
+const maxEditAttempts = 5
+
+type ifMatchKey struct{}
+type ifNoneMatchKey struct{}
+
+// WithIfMatch returns a context sending the version (ETag) of a resource in the If-Match
+// header of the requests made with it. The SCIM API rejects a PUT, PATCH, or DELETE request
+// with ErrPreconditionFailed if the resource has been modified since the version was retrieved.
+//
+// Example Usage:
+//		user, err := s.GetUserById(context.Background(), "8")
+//		ctx := cybr_pam_scim.WithIfMatch(context.Background(), user.Meta.Version)
+//		_, err = s.PatchUser(ctx, "8", types.NewPatchRequest().Replace("active", false))
+//		if errors.Is(err, cybr_pam_scim.ErrPreconditionFailed) {
+//			// The User has been modified by someone else
+//		}
+//
+func WithIfMatch(ctx context.Context, version string) context.Context {
+	return context.WithValue(ctx, ifMatchKey{}, version)
+}
+
+// WithIfNoneMatch returns a context sending the version (ETag) of a resource in the If-None-Match
+// header of the requests made with it. The SCIM API answers a GET request with ErrNotModified if
+// the resource still has the version, so a cached copy can be reused.
+//
+// Example Usage:
+//		ctx := cybr_pam_scim.WithIfNoneMatch(context.Background(), cached.Meta.Version)
+//		safe, err := s.GetSafeByName(ctx, "ExampleSafe")
+//		if errors.Is(err, cybr_pam_scim.ErrNotModified) {
+//			safe = cached
+//		}
+//
+func WithIfNoneMatch(ctx context.Context, version string) context.Context {
+	return context.WithValue(ctx, ifNoneMatchKey{}, version)
+}
+
+// setConditionalHeaders sets the If-Match and If-None-Match headers from the request context
+func setConditionalHeaders(r *http.Request) {
+	if version, ok := r.Context().Value(ifMatchKey{}).(string); ok && version != "" {
+		r.Header.Set("If-Match", version)
+	}
+	if version, ok := r.Context().Value(ifNoneMatchKey{}).(string); ok && version != "" {
+		r.Header.Set("If-None-Match", version)
+	}
+}
+
+// ifMatch returns a context sending If-Match with the version of a resource, the update is
+// refused rather than silently made unconditional when the version or ETag support is missing
+func (s *Service) ifMatch(ctx context.Context, version string) (context.Context, error) {
+	if version == "" {
+		return nil, errors.New("meta.version is required for a conditional update, retrieve the resource first")
+	}
+	if !s.capabilities(ctx).ETagSupported() {
+		return nil, ErrETagNotSupported
+	}
+
+	return WithIfMatch(ctx, version), nil
+}
+
+// edit reads a resource, applies fn, and writes it back conditionally, starting over when the
+// resource has been modified concurrently
+func edit[T any](ctx context.Context, get func(ctx context.Context) (*T, error), fn func(*T) error, update func(ctx context.Context, resource *T) (*T, error)) (*T, error) {
+	var err error
+	for attempt := 1; attempt <= maxEditAttempts; attempt++ {
+		var resource *T
+		resource, err = get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := fn(resource); err != nil {
+			return nil, err
+		}
+
+		var updated *T
+		updated, err = update(ctx, resource)
+		if !errors.Is(err, ErrPreconditionFailed) {
+			return updated, err
+		}
+	}
+
+	return nil, fmt.Errorf("resource modified concurrently %d times: %w", maxEditAttempts, err)
+}
@@ -0,0 +1,120 @@
+package filter
+
+import (
+	"strings"
+)
+
+// AttributeBuilder creates expressions for a single attribute
+type AttributeBuilder struct {
+	path AttributePath
+}
+
+// Attr starts an expression for the provided attribute path. The path may be
+// qualified by a schema URI and may contain a sub-attribute.
+//
+// Example Usage:
+//		filter.Attr("userName").Eq("john.smith@example.com")
+//		filter.Attr("name.familyName").Sw("Sm")
+//		filter.Attr("urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department").Eq("IT")
+//
+func Attr(path string) AttributeBuilder {
+	return AttributeBuilder{path: ParsePath(path)}
+}
+
+func (a AttributeBuilder) compare(op Operator, value interface{}) Expression {
+	return &AttributeExpression{Path: a.path, Operator: op, Value: value}
+}
+
+// Eq matches attributes equal to the value
+func (a AttributeBuilder) Eq(value interface{}) Expression { return a.compare(Equal, value) }
+
+// Ne matches attributes not equal to the value
+func (a AttributeBuilder) Ne(value interface{}) Expression { return a.compare(NotEqual, value) }
+
+// Co matches attributes containing the value
+func (a AttributeBuilder) Co(value interface{}) Expression { return a.compare(Contains, value) }
+
+// Sw matches attributes starting with the value
+func (a AttributeBuilder) Sw(value interface{}) Expression { return a.compare(StartsWith, value) }
+
+// Ew matches attributes ending with the value
+func (a AttributeBuilder) Ew(value interface{}) Expression { return a.compare(EndsWith, value) }
+
+// Gt matches attributes greater than the value
+func (a AttributeBuilder) Gt(value interface{}) Expression { return a.compare(GreaterThan, value) }
+
+// Ge matches attributes greater than or equal to the value
+func (a AttributeBuilder) Ge(value interface{}) Expression { return a.compare(GreaterOrEqual, value) }
+
+// Lt matches attributes less than the value
+func (a AttributeBuilder) Lt(value interface{}) Expression { return a.compare(LessThan, value) }
+
+// Le matches attributes less than or equal to the value
+func (a AttributeBuilder) Le(value interface{}) Expression { return a.compare(LessOrEqual, value) }
+
+// Pr matches attributes which have a non-empty value
+func (a AttributeBuilder) Pr() Expression {
+	return &AttributeExpression{Path: a.path, Operator: Present}
+}
+
+// Where filters the values of a multi-valued attribute. Attribute paths in the
+// provided expression are relative to the attribute.
+//
+// Example Usage:
+//		filter.Attr("members").Where(filter.Attr("value").Eq("12")) // members[value eq "12"]
+//
+func (a AttributeBuilder) Where(expr Expression) Expression {
+	return &ValuePath{Path: a.path, Filter: expr}
+}
+
+// And joins the expressions with the "and" operator
+func And(exprs ...Expression) Expression {
+	return join(LogicalAnd, exprs)
+}
+
+// Or joins the expressions with the "or" operator
+func Or(exprs ...Expression) Expression {
+	return join(LogicalOr, exprs)
+}
+
+// Not negates the expression
+func Not(expr Expression) Expression {
+	return &NotExpression{Expression: expr}
+}
+
+func join(op LogicalOperator, exprs []Expression) Expression {
+	var result Expression
+	for _, expr := range exprs {
+		if expr == nil {
+			continue
+		}
+		if result == nil {
+			result = expr
+			continue
+		}
+		result = &LogicalExpression{Operator: op, Left: result, Right: expr}
+	}
+
+	return result
+}
+
+// ParsePath splits an attribute path into its schema URI, name, and sub-attribute.
+// Attribute paths qualified by a URI use the last colon as separator
+// (e.g. urn:ietf:params:scim:schemas:core:2.0:User:name.givenName).
+func ParsePath(path string) AttributePath {
+	var p AttributePath
+	if strings.HasPrefix(strings.ToLower(path), "urn:") {
+		if i := strings.LastIndex(path, ":"); i >= 0 {
+			p.URI = path[:i]
+			path = path[i+1:]
+		}
+	}
+	if i := strings.Index(path, "."); i >= 0 {
+		p.Name = path[:i]
+		p.SubAttribute = path[i+1:]
+	} else {
+		p.Name = path
+	}
+
+	return p
+}
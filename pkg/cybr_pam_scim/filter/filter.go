@@ -0,0 +1,172 @@
+// Package filter builds, parses, and validates SCIM filter expressions as
+// defined in RFC 7644 section 3.4.2.2.
+//
+// Example Usage:
+//		expr := filter.And(
+//			filter.Attr("userName").Sw("john"),
+//			filter.Attr("emails").Where(filter.Attr("type").Eq("work")),
+//		)
+//		expr.String() // userName sw "john" and emails[type eq "work"]
+//
+//		expr, err := filter.Parse(`displayName eq "Vault Admins" or not (members pr)`)
+//
+package filter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Expression is a node of a SCIM filter. The String method returns the canonical
+// form of the expression which can be passed to the SCIM API and parsed again.
+type Expression interface {
+	String() string
+	expression()
+}
+
+// Operator is an attribute operator
+type Operator string
+
+const (
+	Equal          Operator = "eq"
+	NotEqual       Operator = "ne"
+	Contains       Operator = "co"
+	StartsWith     Operator = "sw"
+	EndsWith       Operator = "ew"
+	GreaterThan    Operator = "gt"
+	GreaterOrEqual Operator = "ge"
+	LessThan       Operator = "lt"
+	LessOrEqual    Operator = "le"
+	Present        Operator = "pr"
+)
+
+// LogicalOperator joins two expressions
+type LogicalOperator string
+
+const (
+	LogicalAnd LogicalOperator = "and"
+	LogicalOr  LogicalOperator = "or"
+)
+
+// AttributePath references an attribute, optionally qualified by a schema URI
+// and followed by a sub-attribute (e.g. name.givenName).
+type AttributePath struct {
+	URI          string
+	Name         string
+	SubAttribute string
+}
+
+// AttributeExpression compares an attribute with a value. Value is nil for the
+// "pr" operator and for comparisons with null.
+type AttributeExpression struct {
+	Path     AttributePath
+	Operator Operator
+	Value    interface{}
+}
+
+// LogicalExpression joins two expressions with "and" or "or"
+type LogicalExpression struct {
+	Operator LogicalOperator
+	Left     Expression
+	Right    Expression
+}
+
+// NotExpression negates an expression
+type NotExpression struct {
+	Expression Expression
+}
+
+// ValuePath filters the values of a multi-valued attribute (e.g. emails[type eq "work"])
+type ValuePath struct {
+	Path   AttributePath
+	Filter Expression
+}
+
+func (*AttributeExpression) expression() {}
+func (*LogicalExpression) expression()   {}
+func (*NotExpression) expression()       {}
+func (*ValuePath) expression()           {}
+
+func (p AttributePath) String() string {
+	var sb strings.Builder
+	if p.URI != "" {
+		sb.WriteString(p.URI)
+		sb.WriteString(":")
+	}
+	sb.WriteString(p.Name)
+	if p.SubAttribute != "" {
+		sb.WriteString(".")
+		sb.WriteString(p.SubAttribute)
+	}
+
+	return sb.String()
+}
+
+func (e *AttributeExpression) String() string {
+	if e.Operator == Present {
+		return fmt.Sprintf("%s %s", e.Path, Present)
+	}
+
+	return fmt.Sprintf("%s %s %s", e.Path, e.Operator, formatValue(e.Value))
+}
+
+func (e *LogicalExpression) String() string {
+	return fmt.Sprintf("%s %s %s", e.operand(e.Left), e.Operator, e.operand(e.Right))
+}
+
+// operand wraps "or" expressions nested in an "and" expression with parentheses
+func (e *LogicalExpression) operand(operand Expression) string {
+	if nested, ok := operand.(*LogicalExpression); ok && e.Operator == LogicalAnd && nested.Operator == LogicalOr {
+		return "(" + nested.String() + ")"
+	}
+
+	return operand.String()
+}
+
+func (e *NotExpression) String() string {
+	return fmt.Sprintf("not (%s)", e.Expression)
+}
+
+func (e *ValuePath) String() string {
+	return fmt.Sprintf("%s[%s]", e.Path, e.Filter)
+}
+
+// formatValue returns the JSON representation of a comparison value
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return quote(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return v.String()
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", v)
+	case time.Time:
+		return quote(v.Format(time.RFC3339))
+	case fmt.Stringer:
+		return quote(v.String())
+	}
+
+	return quote(fmt.Sprintf("%v", value))
+}
+
+// quote returns a JSON string, escaping quotes, backslashes and control characters
+func quote(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
@@ -0,0 +1,307 @@
+package filter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ErrInvalidFilter is returned by Parse for filters which do not follow the RFC 7644 grammar
+var ErrInvalidFilter = errors.New("invalid filter")
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenString
+	tokenLeftParen
+	tokenRightParen
+	tokenLeftBracket
+	tokenRightBracket
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value string
+	pos   int
+}
+
+func (t token) describe() string {
+	if t.kind == tokenEOF {
+		return "end of filter"
+	}
+
+	return fmt.Sprintf("%q at position %d", t.text, t.pos+1)
+}
+
+// Parse parses a SCIM filter. Operators and logical keywords are case insensitive,
+// "and" takes precedence over "or", and "not" must be followed by a parenthesized filter.
+//
+// Example Usage:
+//		expr, err := filter.Parse(`userName eq "john.smith@example.com" and active eq true`)
+//
+func Parse(s string) (Expression, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if next := p.peek(); next.kind != tokenEOF {
+		return nil, p.unexpected(next)
+	}
+
+	return expr, nil
+}
+
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLeftParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRightParen, text: ")", pos: i})
+			i++
+		case c == '[':
+			tokens = append(tokens, token{kind: tokenLeftBracket, text: "[", pos: i})
+			i++
+		case c == ']':
+			tokens = append(tokens, token{kind: tokenRightBracket, text: "]", pos: i})
+			i++
+		case c == '"':
+			end := i + 1
+			for ; end < len(s) && s[end] != '"'; end++ {
+				if s[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(s) {
+				return nil, fmt.Errorf("%w: unterminated string at position %d", ErrInvalidFilter, i+1)
+			}
+			var value string
+			if err := json.Unmarshal([]byte(s[i:end+1]), &value); err != nil {
+				return nil, fmt.Errorf("%w: invalid string at position %d: %v", ErrInvalidFilter, i+1, err)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: s[i : end+1], value: value, pos: i})
+			i = end + 1
+		default:
+			end := i
+			for ; end < len(s) && !strings.ContainsRune(" \t\n\r()[]\"", rune(s[end])); end++ {
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: s[i:end], value: s[i:end], pos: i})
+			i = end
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(s)}), nil
+}
+
+type parser struct {
+	tokens    []token
+	pos       int
+	valuePath bool
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+
+	return t
+}
+
+func (p *parser) keyword(t token, keyword string) bool {
+	return t.kind == tokenWord && strings.EqualFold(t.text, keyword)
+}
+
+func (p *parser) expect(kind tokenKind) error {
+	if t := p.next(); t.kind != kind {
+		return p.unexpected(t)
+	}
+
+	return nil
+}
+
+func (p *parser) unexpected(t token) error {
+	return fmt.Errorf("%w: unexpected %s", ErrInvalidFilter, t.describe())
+}
+
+func (p *parser) parseOr() (Expression, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword(p.peek(), "or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &LogicalExpression{Operator: LogicalOr, Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expression, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword(p.peek(), "and") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &LogicalExpression{Operator: LogicalAnd, Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Expression, error) {
+	t := p.peek()
+	switch {
+	case p.keyword(t, "not"):
+		p.next()
+		if err := p.expect(tokenLeftParen); err != nil {
+			return nil, err
+		}
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenRightParen); err != nil {
+			return nil, err
+		}
+		return &NotExpression{Expression: expr}, nil
+	case t.kind == tokenLeftParen:
+		p.next()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenRightParen); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	case t.kind == tokenWord:
+		return p.parseAttribute()
+	}
+
+	return nil, p.unexpected(t)
+}
+
+func (p *parser) parseAttribute() (Expression, error) {
+	t := p.next()
+	path, err := parseAttributePath(t)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek().kind == tokenLeftBracket {
+		if p.valuePath {
+			return nil, p.unexpected(p.peek())
+		}
+		p.next()
+		p.valuePath = true
+		expr, err := p.parseOr()
+		p.valuePath = false
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenRightBracket); err != nil {
+			return nil, err
+		}
+		return &ValuePath{Path: path, Filter: expr}, nil
+	}
+
+	opToken := p.next()
+	if opToken.kind != tokenWord {
+		return nil, p.unexpected(opToken)
+	}
+	op := Operator(strings.ToLower(opToken.text))
+	switch op {
+	case Present:
+		return &AttributeExpression{Path: path, Operator: Present}, nil
+	case Equal, NotEqual, Contains, StartsWith, EndsWith, GreaterThan, GreaterOrEqual, LessThan, LessOrEqual:
+	default:
+		return nil, fmt.Errorf("%w: unknown operator %s", ErrInvalidFilter, opToken.describe())
+	}
+
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	return &AttributeExpression{Path: path, Operator: op, Value: value}, nil
+}
+
+func (p *parser) parseValue() (interface{}, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenString:
+		return t.value, nil
+	case tokenWord:
+		switch strings.ToLower(t.text) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		var number json.Number
+		if err := json.Unmarshal([]byte(t.text), &number); err == nil {
+			return number, nil
+		}
+		return nil, fmt.Errorf("%w: invalid value %s", ErrInvalidFilter, t.describe())
+	}
+
+	return nil, p.unexpected(t)
+}
+
+func parseAttributePath(t token) (AttributePath, error) {
+	path := ParsePath(t.text)
+	if !validName(path.Name) || (path.SubAttribute != "" && !validName(path.SubAttribute)) {
+		return path, fmt.Errorf("%w: invalid attribute path %s", ErrInvalidFilter, t.describe())
+	}
+
+	return path, nil
+}
+
+// validName reports whether the name is a valid ATTRNAME (ALPHA *(nameChar)), "$ref" is allowed as well
+func validName(name string) bool {
+	if name == "$ref" {
+		return true
+	}
+	for i, r := range name {
+		switch {
+		case r < unicode.MaxASCII && unicode.IsLetter(r):
+		case i > 0 && (r == '-' || r == '_' || (r >= '0' && r <= '9')):
+		default:
+			return false
+		}
+	}
+
+	return name != ""
+}
@@ -0,0 +1,215 @@
+package filter_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/filter"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+		want   string
+	}{
+		{"eq", `userName eq "john.smith"`, `userName eq "john.smith"`},
+		{"ne", `userName ne "john.smith"`, `userName ne "john.smith"`},
+		{"co", `userName co "smith"`, `userName co "smith"`},
+		{"sw", `userName sw "john"`, `userName sw "john"`},
+		{"ew", `userName ew "@example.com"`, `userName ew "@example.com"`},
+		{"gt", `meta.lastModified gt "2022-05-02T10:11:12Z"`, `meta.lastModified gt "2022-05-02T10:11:12Z"`},
+		{"ge", `urn:ietf:params:scim:schemas:cyberark:1.0:Safe:NumberOfDaysRetention ge 7`, `urn:ietf:params:scim:schemas:cyberark:1.0:Safe:NumberOfDaysRetention ge 7`},
+		{"lt", `urn:ietf:params:scim:schemas:cyberark:1.0:SafeMember:membershipExpirationDate lt 1767225600`, `urn:ietf:params:scim:schemas:cyberark:1.0:SafeMember:membershipExpirationDate lt 1767225600`},
+		{"le", `meta.created le "2022-05-02T10:11:12Z"`, `meta.created le "2022-05-02T10:11:12Z"`},
+		{"pr", `title pr`, `title pr`},
+		{"case insensitive operators", `userName EQ "john" AND active Eq TRUE`, `userName eq "john" and active eq true`},
+		{"boolean and null", `active eq false or nickName eq null`, `active eq false or nickName eq null`},
+		{"decimal", `x gt -1.5e3`, `x gt -1.5e3`},
+		{"and before or", `a pr or b pr and c pr`, `a pr or b pr and c pr`},
+		{"and before or on the left", `a pr and b pr or c pr`, `a pr and b pr or c pr`},
+		{"grouped or in and", `(a pr or b pr) and c pr`, `(a pr or b pr) and c pr`},
+		{"grouped or on the right of and", `a pr and (b pr or c pr)`, `a pr and (b pr or c pr)`},
+		{"redundant parentheses", `((a pr)) and (b pr)`, `a pr and b pr`},
+		{"not", `not (a pr) and b pr`, `not (a pr) and b pr`},
+		{"not of or", `NOT (a pr or b pr)`, `not (a pr or b pr)`},
+		{"value path", `emails[type eq "work" and value co "@example.com"]`, `emails[type eq "work" and value co "@example.com"]`},
+		{"value path in logical", `userName sw "j" and emails[type eq "work"]`, `userName sw "j" and emails[type eq "work"]`},
+		{"value path with URI", `urn:ietf:params:scim:schemas:cyberark:1.0:PrivilegedData:properties[key eq "Address"]`, `urn:ietf:params:scim:schemas:cyberark:1.0:PrivilegedData:properties[key eq "Address"]`},
+		{"escaped quotes", `displayName eq "Say \"hi\" \\ bye"`, `displayName eq "Say \"hi\" \\ bye"`},
+		{"unicode escape", `displayName eq "café"`, `displayName eq "café"`},
+		{"whitespace", "  userName\teq\n\"john\"  ", `userName eq "john"`},
+		{"$ref", `members.$ref pr`, `members.$ref pr`},
+	}
+	for _, tt := range tests {
+		expr, err := filter.Parse(tt.filter)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := expr.String(); got != tt.want {
+			t.Errorf("%s: String() = %s, want %s", tt.name, got, tt.want)
+		}
+
+		// The canonical form parses to the same expression
+		again, err := filter.Parse(expr.String())
+		if err != nil {
+			t.Errorf("%s: parsing %s again: %v", tt.name, expr, err)
+			continue
+		}
+		if again.String() != expr.String() {
+			t.Errorf("%s: round trip = %s, want %s", tt.name, again, expr)
+		}
+	}
+}
+
+func TestParseTree(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+		want   filter.Expression
+	}{
+		{"and before or", `a pr or b pr and c pr`, &filter.LogicalExpression{
+			Operator: filter.LogicalOr,
+			Left:     &filter.AttributeExpression{Path: filter.AttributePath{Name: "a"}, Operator: filter.Present},
+			Right: &filter.LogicalExpression{
+				Operator: filter.LogicalAnd,
+				Left:     &filter.AttributeExpression{Path: filter.AttributePath{Name: "b"}, Operator: filter.Present},
+				Right:    &filter.AttributeExpression{Path: filter.AttributePath{Name: "c"}, Operator: filter.Present},
+			},
+		}},
+		{"grouping", `(a pr or b pr) and c pr`, &filter.LogicalExpression{
+			Operator: filter.LogicalAnd,
+			Left: &filter.LogicalExpression{
+				Operator: filter.LogicalOr,
+				Left:     &filter.AttributeExpression{Path: filter.AttributePath{Name: "a"}, Operator: filter.Present},
+				Right:    &filter.AttributeExpression{Path: filter.AttributePath{Name: "b"}, Operator: filter.Present},
+			},
+			Right: &filter.AttributeExpression{Path: filter.AttributePath{Name: "c"}, Operator: filter.Present},
+		}},
+		{"not", `not (name.givenName eq "John")`, &filter.NotExpression{
+			Expression: &filter.AttributeExpression{Path: filter.AttributePath{Name: "name", SubAttribute: "givenName"}, Operator: filter.Equal, Value: "John"},
+		}},
+		{"value path", `emails[type eq "work"]`, &filter.ValuePath{
+			Path:   filter.AttributePath{Name: "emails"},
+			Filter: &filter.AttributeExpression{Path: filter.AttributePath{Name: "type"}, Operator: filter.Equal, Value: "work"},
+		}},
+		{"URI", `urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:manager.value eq "8"`, &filter.AttributeExpression{
+			Path:     filter.AttributePath{URI: "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User", Name: "manager", SubAttribute: "value"},
+			Operator: filter.Equal,
+			Value:    "8",
+		}},
+		{"values", `a eq 7 and b eq true and c eq null`, &filter.LogicalExpression{
+			Operator: filter.LogicalAnd,
+			Left: &filter.LogicalExpression{
+				Operator: filter.LogicalAnd,
+				Left:     &filter.AttributeExpression{Path: filter.AttributePath{Name: "a"}, Operator: filter.Equal, Value: json.Number("7")},
+				Right:    &filter.AttributeExpression{Path: filter.AttributePath{Name: "b"}, Operator: filter.Equal, Value: true},
+			},
+			Right: &filter.AttributeExpression{Path: filter.AttributePath{Name: "c"}, Operator: filter.Equal, Value: nil},
+		}},
+	}
+	for _, tt := range tests {
+		expr, err := filter.Parse(tt.filter)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(expr, tt.want) {
+			t.Errorf("%s: expression = %#v, want %#v", tt.name, expr, tt.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		filter string
+		err    string
+	}{
+		{``, `unexpected end of filter`},
+		{`userName`, `unexpected end of filter`},
+		{`userName eq`, `unexpected end of filter`},
+		{`userName is "john"`, `unknown operator "is" at position 10`},
+		{`userName eq "john`, `unterminated string at position 13`},
+		{`userName eq "a\q"`, `invalid string at position 13`},
+		{`userName eq john`, `invalid value "john" at position 13`},
+		{`userName eq "john" extra`, `unexpected "extra" at position 20`},
+		{`userName eq "john" and`, `unexpected end of filter`},
+		{`(userName pr`, `unexpected end of filter`},
+		{`userName pr)`, `unexpected ")" at position 12`},
+		{`not userName pr`, `unexpected "userName" at position 5`},
+		{`emails[type eq "work"`, `unexpected end of filter`},
+		{`emails[type[value pr]]`, `unexpected "[" at position 12`},
+		{`1userName pr`, `invalid attribute path "1userName" at position 1`},
+		{`name.given-Name pr`, ``},
+		{`user_name! pr`, `invalid attribute path "user_name!" at position 1`},
+		{`"userName" eq "john"`, `unexpected "\"userName\"" at position 1`},
+	}
+	for _, tt := range tests {
+		_, err := filter.Parse(tt.filter)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("Parse(%q) = %v, want no error", tt.filter, err)
+			}
+			continue
+		}
+		if !errors.Is(err, filter.ErrInvalidFilter) {
+			t.Errorf("Parse(%q) = %v, want ErrInvalidFilter", tt.filter, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Parse(%q) = %v, want %s", tt.filter, err, tt.err)
+		}
+	}
+}
+
+func TestResourceValidate(t *testing.T) {
+	tests := []struct {
+		resource filter.Resource
+		filter   string
+		unknown  string
+	}{
+		{filter.Users, `userName eq "john" and name.givenName sw "J"`, ""},
+		{filter.Users, `emails[type eq "work" and value ew "@example.com"]`, ""},
+		{filter.Users, `urn:ietf:params:scim:schemas:core:2.0:User:userName eq "john"`, ""},
+		{filter.Users, `urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:manager.value eq "8"`, ""},
+		{filter.Users, `URN:IETF:PARAMS:SCIM:SCHEMAS:CYBERARK:1.0:USER:directoryType eq "LDAP"`, ""},
+		{filter.Users, `USERNAME eq "john" and meta.lastModified gt "2022-05-02T10:11:12Z"`, ""},
+		{filter.Users, `username eq "john" or not (nickname pr)`, ""},
+		{filter.Users, `not (members pr)`, `"members" for Users`},
+		{filter.Users, `emails[kind eq "work"]`, `"emails.kind" for Users`},
+		{filter.Users, `urn:ietf:params:scim:schemas:core:2.0:Group:displayName pr`, `"urn:ietf:params:scim:schemas:core:2.0:Group:displayName" for Users`},
+		{filter.Groups, `displayName eq "Vault Admins" and members[value eq "8"]`, ""},
+		{filter.Groups, `urn:ietf:params:scim:schemas:cyberark:1.0:Group:directoryName eq "example.com"`, ""},
+		{filter.Groups, `userName eq "john"`, `"userName" for Groups`},
+		{filter.Containers, `name eq "AppSafe" and owner.display eq "Administrator"`, ""},
+		{filter.Containers, `urn:ietf:params:scim:schemas:cyberark:1.0:Safe:ManagingCPM eq "PasswordManager"`, ""},
+		{filter.Containers, `rights pr`, `"rights" for Containers`},
+		{filter.ContainerPermissions, `container.name eq "AppSafe" and (user.display eq "john" or group.display eq "App Admins")`, ""},
+		{filter.ContainerPermissions, `urn:ietf:params:scim:schemas:cyberark:1.0:SafeMember:memberType eq "User"`, ""},
+		{filter.ContainerPermissions, `container.owner pr`, `"container.owner" for ContainerPermissions`},
+		{filter.PrivilegedData, `name eq "svc-app" and urn:ietf:params:scim:schemas:cyberark:1.0:PrivilegedData:safe eq "AppSafe"`, ""},
+		{filter.PrivilegedData, `urn:ietf:params:scim:schemas:cyberark:1.0:PrivilegedData:properties[key eq "Address"]`, ""},
+		{filter.PrivilegedData, `urn:ietf:params:scim:schemas:cyberark:1.0:PrivilegedData:password pr`, `"urn:ietf:params:scim:schemas:cyberark:1.0:PrivilegedData:password" for PrivilegedData`},
+	}
+	for _, tt := range tests {
+		expr, err := filter.Parse(tt.filter)
+		if err != nil {
+			t.Errorf("%s: %v", tt.filter, err)
+			continue
+		}
+		err = tt.resource.Validate(expr)
+		if tt.unknown == "" {
+			if err != nil {
+				t.Errorf("%s.Validate(%s) = %v, want no error", tt.resource.Name, tt.filter, err)
+			}
+			continue
+		}
+		if !errors.Is(err, filter.ErrUnknownAttribute) || !strings.Contains(err.Error(), tt.unknown) {
+			t.Errorf("%s.Validate(%s) = %v, want unknown attribute %s", tt.resource.Name, tt.filter, err, tt.unknown)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnknownAttribute is returned by Validate for attributes a resource does not define
+var ErrUnknownAttribute = errors.New("unknown attribute")
+
+// Resource describes the attributes of a resource type which may be referenced in a filter.
+// Attribute names are case insensitive. Sub-attributes are listed as "parent.sub" and
+// extension attributes are qualified by their schema URI.
+type Resource struct {
+	Name       string
+	Schema     string
+	Attributes []string
+}
+
+// Validate reports an error wrapping ErrUnknownAttribute if the expression references an
+// attribute that is not defined by the resource. Attributes qualified by the core schema
+// of the resource are treated as unqualified attributes.
+//
+// Example Usage:
+//		expr := filter.Attr("userName").Eq("john.smith@example.com")
+//		err := filter.Users.Validate(expr)
+//
+func (r Resource) Validate(expr Expression) error {
+	known := make(map[string]bool, len(r.Attributes))
+	for _, attr := range r.Attributes {
+		known[strings.ToLower(attr)] = true
+	}
+
+	return r.validate(expr, nil, known)
+}
+
+// Has reports whether the resource defines the attribute path
+func (r Resource) Has(path string) bool {
+	for _, attr := range r.Attributes {
+		if strings.EqualFold(attr, r.normalize(ParsePath(path), nil)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r Resource) validate(expr Expression, parent *AttributePath, known map[string]bool) error {
+	switch e := expr.(type) {
+	case *AttributeExpression:
+		name := r.normalize(e.Path, parent)
+		if !known[strings.ToLower(name)] {
+			return fmt.Errorf("%w %q for %s", ErrUnknownAttribute, name, r.Name)
+		}
+	case *LogicalExpression:
+		if err := r.validate(e.Left, parent, known); err != nil {
+			return err
+		}
+		return r.validate(e.Right, parent, known)
+	case *NotExpression:
+		return r.validate(e.Expression, parent, known)
+	case *ValuePath:
+		name := r.normalize(e.Path, nil)
+		if !known[strings.ToLower(name)] {
+			return fmt.Errorf("%w %q for %s", ErrUnknownAttribute, name, r.Name)
+		}
+		return r.validate(e.Filter, &e.Path, known)
+	}
+
+	return nil
+}
+
+// normalize returns the full attribute name, relative to the parent of a value path
+func (r Resource) normalize(path AttributePath, parent *AttributePath) string {
+	if parent != nil {
+		path = AttributePath{
+			URI:          parent.URI,
+			Name:         parent.Name,
+			SubAttribute: path.Name,
+		}
+	}
+	if strings.EqualFold(path.URI, r.Schema) {
+		path.URI = ""
+	}
+
+	return path.String()
+}
+
+func withSubAttributes(parent string, subAttributes ...string) []string {
+	attributes := []string{parent}
+	for _, sub := range subAttributes {
+		attributes = append(attributes, parent+"."+sub)
+	}
+
+	return attributes
+}
+
+func withURI(uri string, attributes ...string) []string {
+	qualified := make([]string, 0, len(attributes))
+	for _, attr := range attributes {
+		qualified = append(qualified, uri+":"+attr)
+	}
+
+	return qualified
+}
+
+func concat(lists ...[]string) []string {
+	var result []string
+	for _, list := range lists {
+		result = append(result, list...)
+	}
+
+	return result
+}
+
+var commonAttributes = concat(
+	[]string{"id", "externalId"},
+	withSubAttributes("meta", "resourceType", "created", "lastModified", "location", "version"),
+)
+
+var multiValuedSubAttributes = []string{"type", "primary", "value", "display", "$ref"}
+
+// Users defines the filterable attributes of the Users resource
+var Users = Resource{
+	Name:   "Users",
+	Schema: "urn:ietf:params:scim:schemas:core:2.0:User",
+	Attributes: concat(
+		commonAttributes,
+		[]string{"userName", "displayName", "nickName", "profileUrl", "title", "userType", "preferredLanguage", "locale", "timezone", "active", "entitlements"},
+		withSubAttributes("name", "formatted", "givenName", "familyName", "middleName", "honorificPrefix", "honorificSuffix"),
+		withSubAttributes("emails", multiValuedSubAttributes...),
+		withSubAttributes("phoneNumbers", multiValuedSubAttributes...),
+		withSubAttributes("ims", multiValuedSubAttributes...),
+		withSubAttributes("photos", multiValuedSubAttributes...),
+		withSubAttributes("addresses", "formatted", "streetAddress", "locality", "region", "postalCode", "country", "type", "primary"),
+		withSubAttributes("groups", multiValuedSubAttributes...),
+		withSubAttributes("roles", multiValuedSubAttributes...),
+		withSubAttributes("x509Certificates", multiValuedSubAttributes...),
+		withURI("urn:ietf:params:scim:schemas:extension:enterprise:2.0:User",
+			concat([]string{"employeeNumber", "costCenter", "organization", "division", "department"},
+				withSubAttributes("manager", "value", "displayName", "$ref"))...),
+		withURI("urn:ietf:params:scim:schemas:cyberark:1.0:User",
+			"authenticationMethod", "expiryDate", "changePassOnNextLogon", "passwordNeverExpires", "distinguishedName", "directoryType"),
+		withURI("urn:ietf:params:scim:schemas:pam:1.0:LinkedObject", "source", "nativeIdentifier"),
+	),
+}
+
+// Groups defines the filterable attributes of the Groups resource
+var Groups = Resource{
+	Name:   "Groups",
+	Schema: "urn:ietf:params:scim:schemas:core:2.0:Group",
+	Attributes: concat(
+		commonAttributes,
+		[]string{"displayName"},
+		withSubAttributes("members", "value", "type", "display", "$ref"),
+		withURI("urn:ietf:params:scim:schemas:cyberark:1.0:Group", "directoryType", "directoryName"),
+	),
+}
+
+// Containers defines the filterable attributes of the Containers (Safes) resource
+var Containers = Resource{
+	Name:   "Containers",
+	Schema: "urn:ietf:params:scim:schemas:pam:1.0:Container",
+	Attributes: concat(
+		commonAttributes,
+		[]string{"name", "displayName", "description", "type"},
+		withSubAttributes("parent", "value", "display", "$ref"),
+		withSubAttributes("owner", "value", "display", "$ref"),
+		withSubAttributes("privilegedData", "value", "display", "type", "$ref"),
+		withURI("urn:ietf:params:scim:schemas:cyberark:1.0:Safe", "NumberOfDaysRetention", "ManagingCPM"),
+	),
+}
+
+// ContainerPermissions defines the filterable attributes of the ContainerPermissions (Safe Permissions) resource
+var ContainerPermissions = Resource{
+	Name:   "ContainerPermissions",
+	Schema: "urn:ietf:params:scim:schemas:pam:1.0:ContainerPermission",
+	Attributes: concat(
+		commonAttributes,
+		[]string{"rights"},
+		withSubAttributes("container", "value", "name", "display", "$ref"),
+		withSubAttributes("user", "value", "display", "$ref"),
+		withSubAttributes("group", "value", "display", "$ref"),
+		withURI("urn:ietf:params:scim:schemas:cyberark:1.0:SafeMember", "membershipExpirationDate", "memberType", "searchIn"),
+	),
+}
+
+// PrivilegedData defines the filterable attributes of the PrivilegedData (Accounts) resource
+var PrivilegedData = Resource{
+	Name:   "PrivilegedData",
+	Schema: "urn:ietf:params:scim:schemas:pam:1.0:PrivilegedData",
+	Attributes: concat(
+		commonAttributes,
+		[]string{"name", "description", "type"},
+		withURI("urn:ietf:params:scim:schemas:cyberark:1.0:PrivilegedData",
+			concat([]string{"safe", "folder"}, withSubAttributes("properties", "key", "value"))...),
+	),
+}
@@ -0,0 +1,303 @@
+package cybr_pam_scim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/filter"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
+)
+
+// DefaultMemberBatchSize is the maximum number of members sent in a single membership PATCH request
+const DefaultMemberBatchSize = 100
+
+// DefaultMemberPatchSize is the maximum size in bytes of a single membership PATCH request.
+// The payload size limit of the service provider only applies to bulk requests, it sizes the
+// batches ReplaceGroupMembers sends as bulk operations.
+const DefaultMemberPatchSize = 64 << 10
+
+// memberPatchOverhead is reserved for the PATCH request around the members of a batch
+const memberPatchOverhead = 256
+
+// MemberFailure is a member which could not be added or removed
+type MemberFailure struct {
+	Member string
+	Err    error
+}
+
+// MembershipError is returned by the group membership functions when some members could
+// not be added, removed, or replaced. Applied lists the members which were processed
+// successfully and Failures the members which were not, with the reason. errors.Is and
+// errors.As match the errors of the failed members.
+//
+// Example Usage:
+//		err := s.AddGroupMembers(context.Background, "3", "8", "9", "10")
+//		var membershipErr *cybr_pam_scim.MembershipError
+//		if errors.As(err, &membershipErr) {
+//			for _, failure := range membershipErr.Failures {
+//				fmt.Println(failure.Member, failure.Err)
+//			}
+//		}
+//
+type MembershipError struct {
+	GroupId  string
+	Op       types.PatchOp
+	Applied  []string
+	Failures []MemberFailure
+}
+
+func (e *MembershipError) Error() string {
+	members := make([]string, 0, len(e.Failures))
+	for _, failure := range e.Failures {
+		members = append(members, failure.Member)
+	}
+
+	return fmt.Sprintf("failed to %s %d member(s) of Group %s: %s: %v", e.Op, len(e.Failures), e.GroupId, strings.Join(members, ", "), e.Failures[0].Err)
+}
+
+// Is reports whether the error of any failed member matches target
+func (e *MembershipError) Is(target error) bool {
+	for _, failure := range e.Failures {
+		if errors.Is(failure.Err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// As finds the first error of the failed members matching target
+func (e *MembershipError) As(target interface{}) bool {
+	for _, failure := range e.Failures {
+		if errors.As(failure.Err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ListGroupMembers retrieves the members of a single Group by Group Id.
+//
+// Example Usage:
+//		members, err := s.ListGroupMembers(context.Background, "3")
+//
+func (s *Service) ListGroupMembers(ctx context.Context, groupId string) ([]types.Members, error) {
+	group, err := s.GetGroupById(ctx, groupId)
+	if err != nil {
+		return nil, err
+	}
+
+	return group.Members, nil
+}
+
+// AddGroupMembers adds Users by User Id to a single Group via PATCH requests. Members already
+// in the Group are left untouched. Large member lists are split into batches, if a batch is
+// rejected its members are added one at a time and the members which still fail are
+// reported by a *MembershipError.
+//
+// Example Usage:
+//		err := s.AddGroupMembers(context.Background, "3", "8", "9", "10")
+//
+func (s *Service) AddGroupMembers(ctx context.Context, groupId string, userIds ...string) error {
+	if !s.capabilities(ctx).PatchSupported() {
+		return fmt.Errorf("failed to add members to Group %s: %w", groupId, ErrPatchNotSupported)
+	}
+
+	return s.patchMembers(ctx, groupId, types.PatchOpAdd, userIds, addMembersPatch)
+}
+
+// RemoveGroupMembers removes Users by User Id from a single Group via PATCH requests. Large
+// member lists are split into batches, if a batch is rejected its members are removed one
+// at a time and the members which still fail are reported by a *MembershipError.
+//
+// Example Usage:
+//		err := s.RemoveGroupMembers(context.Background, "3", "8", "9")
+//
+func (s *Service) RemoveGroupMembers(ctx context.Context, groupId string, userIds ...string) error {
+	if !s.capabilities(ctx).PatchSupported() {
+		return fmt.Errorf("failed to remove members from Group %s: %w", groupId, ErrPatchNotSupported)
+	}
+
+	return s.patchMembers(ctx, groupId, types.PatchOpRemove, userIds, removeMembersPatch)
+}
+
+// ReplaceGroupMembers replaces the members of a single Group with the provided Users.
+// Providing no User Ids removes every member of the Group.
+//
+// Members fitting in a single PATCH request are replaced atomically. Larger member lists are
+// not replaced atomically: the first batch replaces the members and the remaining batches are
+// added, in a bulk request stopping at the first failure when the service provider supports
+// bulk operations and with AddGroupMembers otherwise. If a later batch fails, the Group keeps
+// the members listed as Applied by the returned *MembershipError.
+//
+// Example Usage:
+//		err := s.ReplaceGroupMembers(context.Background, "3", "8", "9")
+//
+func (s *Service) ReplaceGroupMembers(ctx context.Context, groupId string, userIds ...string) error {
+	if !s.capabilities(ctx).PatchSupported() {
+		return fmt.Errorf("failed to replace members of Group %s: %w", groupId, ErrPatchNotSupported)
+	}
+
+	batches := memberBatches(userIds, DefaultMemberPatchSize, addMembersPatch)
+	if len(batches) > 1 && s.capabilities(ctx).BulkSupported() {
+		// The batches are operations of a bulk request, limited by its payload size instead
+		maxSize := defaultMaxPayloadSize
+		if size := s.capabilities(ctx).MaxBulkPayloadSize(); size > 0 {
+			maxSize = size
+		}
+		return s.replaceMembersBulk(ctx, groupId, memberBatches(userIds, maxSize-bulkRequestOverhead, addMembersPatch))
+	}
+
+	first := []string{}
+	if len(batches) > 0 {
+		first = batches[0]
+	}
+	if _, err := s.PatchGroup(ctx, groupId, types.NewPatchRequest().Replace("members", members(first))); err != nil {
+		return fmt.Errorf("failed to replace members of Group %s: %w", groupId, err)
+	}
+	if len(batches) < 2 {
+		return nil
+	}
+
+	var remaining []string
+	for _, batch := range batches[1:] {
+		remaining = append(remaining, batch...)
+	}
+	err := s.patchMembers(ctx, groupId, types.PatchOpAdd, remaining, addMembersPatch)
+	var membershipErr *MembershipError
+	if errors.As(err, &membershipErr) {
+		membershipErr.Op = types.PatchOpReplace
+		membershipErr.Applied = append(append([]string{}, first...), membershipErr.Applied...)
+	}
+
+	return err
+}
+
+// replaceMembersBulk replaces the members with the first batch and adds the remaining batches
+// in a bulk request, the operations after a failed operation are not processed
+func (s *Service) replaceMembersBulk(ctx context.Context, groupId string, batches [][]string) error {
+	bulk := NewBulkRequest()
+	bulk.FailOnErrors = 1
+	path := fmt.Sprintf("/%s/%s", "Groups", groupId)
+	bulk.Patch(path, types.NewPatchRequest().Replace("members", members(batches[0])))
+	for _, batch := range batches[1:] {
+		bulk.Patch(path, addMembersPatch.build(batch))
+	}
+
+	result, err := s.Bulk(ctx, bulk)
+	if err == nil {
+		return nil
+	}
+	if result == nil || result.Operations[0].Err != nil {
+		return fmt.Errorf("failed to replace members of Group %s: %w", groupId, err)
+	}
+
+	membershipErr := &MembershipError{GroupId: groupId, Op: types.PatchOpReplace}
+	for i, operation := range result.Operations {
+		if operation.Err == nil {
+			membershipErr.Applied = append(membershipErr.Applied, batches[i]...)
+			continue
+		}
+		for _, userId := range batches[i] {
+			membershipErr.Failures = append(membershipErr.Failures, MemberFailure{Member: userId, Err: operation.Err})
+		}
+	}
+
+	return membershipErr
+}
+
+// memberPatch builds the PATCH request for a batch of members and reports the size a member adds to it
+type memberPatch struct {
+	build func(userIds []string) *types.PatchRequest
+	size  func(userId string) int
+}
+
+var addMembersPatch = memberPatch{
+	build: func(userIds []string) *types.PatchRequest {
+		return types.NewPatchRequest().Add("members", members(userIds))
+	},
+	size: func(userId string) int {
+		return len(`{"value":},`) + len(quote(userId))
+	},
+}
+
+var removeMembersPatch = memberPatch{
+	build: func(userIds []string) *types.PatchRequest {
+		exprs := make([]filter.Expression, 0, len(userIds))
+		for _, userId := range userIds {
+			exprs = append(exprs, filter.Attr("value").Eq(userId))
+		}
+		return types.NewPatchRequest().Remove(filter.Attr("members").Where(filter.Or(exprs...)).String())
+	},
+	size: func(userId string) int {
+		return len(` or value eq `) + len(quote(userId))
+	},
+}
+
+// memberBatches splits the members in batches of at most DefaultMemberBatchSize members whose
+// PATCH request does not exceed maxSize bytes
+func memberBatches(userIds []string, maxSize int, patch memberPatch) [][]string {
+	return splitBatches(userIds, DefaultMemberBatchSize, maxSize-memberPatchOverhead, patch.size)
+}
+
+// patchMembers sends the members in batches and retries the members of a rejected batch one at
+// a time. It stops early if the context ends or access is denied, as no other request would
+// succeed, and reports the members which were not processed as failed.
+func (s *Service) patchMembers(ctx context.Context, groupId string, op types.PatchOp, userIds []string, patch memberPatch) error {
+	membershipErr := &MembershipError{GroupId: groupId, Op: op}
+	processed := 0
+	stop := func(err error) error {
+		for _, userId := range userIds[processed:] {
+			membershipErr.Failures = append(membershipErr.Failures, MemberFailure{Member: userId, Err: err})
+		}
+		return membershipErr
+	}
+	for _, batch := range memberBatches(userIds, DefaultMemberPatchSize, patch) {
+		_, err := s.PatchGroup(ctx, groupId, patch.build(batch))
+		switch {
+		case err == nil:
+			membershipErr.Applied = append(membershipErr.Applied, batch...)
+		case ctx.Err() != nil || errors.Is(err, ErrUserAccessDenied):
+			return stop(err)
+		case len(batch) == 1:
+			membershipErr.Failures = append(membershipErr.Failures, MemberFailure{Member: batch[0], Err: err})
+		default:
+			for _, userId := range batch {
+				if _, err := s.PatchGroup(ctx, groupId, patch.build([]string{userId})); err != nil {
+					if ctx.Err() != nil {
+						return stop(err)
+					}
+					membershipErr.Failures = append(membershipErr.Failures, MemberFailure{Member: userId, Err: err})
+				} else {
+					membershipErr.Applied = append(membershipErr.Applied, userId)
+				}
+				processed++
+			}
+			continue
+		}
+		processed += len(batch)
+	}
+	if len(membershipErr.Failures) > 0 {
+		return membershipErr
+	}
+
+	return nil
+}
+
+func members(userIds []string) []types.Members {
+	members := make([]types.Members, 0, len(userIds))
+	for _, userId := range userIds {
+		members = append(members, types.Members{Value: userId})
+	}
+
+	return members
+}
+
+func quote(s string) string {
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
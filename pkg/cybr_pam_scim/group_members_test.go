@@ -0,0 +1,342 @@
+package cybr_pam_scim_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/scimtest"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
+)
+
+// memberIds returns n User Ids, more than fit in a single membership PATCH request when n
+// exceeds DefaultMemberBatchSize
+func memberIds(n int) []string {
+	userIds := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		userIds = append(userIds, fmt.Sprint(100+i))
+	}
+
+	return userIds
+}
+
+func newGroup(t *testing.T, s *cybr_pam_scim.Service) string {
+	t.Helper()
+	group, err := s.AddGroup(context.Background(), types.Group{DisplayName: "Admins", Members: []types.Members{{Value: "99"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return group.Id
+}
+
+func groupMembers(t *testing.T, s *cybr_pam_scim.Service, groupId string) []string {
+	t.Helper()
+	members, err := s.ListGroupMembers(context.Background(), groupId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make([]string, 0, len(members))
+	for _, member := range members {
+		values = append(values, member.Value)
+	}
+
+	return values
+}
+
+// respond returns a middleware answering the requests accepted by match with a SCIM error of
+// the status instead of sending them
+func respond(status int, match func(r *http.Request) bool) cybr_pam_scim.Middleware {
+	return func(next cybr_pam_scim.Handler) cybr_pam_scim.Handler {
+		return func(r *http.Request) (*http.Response, error) {
+			if !match(r) {
+				return next(r)
+			}
+			body := fmt.Sprintf(`{"schemas":["urn:ietf:params:scim:api:messages:2.0:Error"],"status":"%d","detail":"injected"}`, status)
+			return &http.Response{StatusCode: status, Header: http.Header{"Content-Type": {"application/scim+json"}}, Body: io.NopCloser(strings.NewReader(body)), Request: r}, nil
+		}
+	}
+}
+
+func TestReplaceGroupMembersBulk(t *testing.T) {
+	srv := scimtest.NewServer()
+	defer srv.Close()
+	s := srv.NewService()
+	groupId := newGroup(t, s)
+
+	userIds := memberIds(2*cybr_pam_scim.DefaultMemberBatchSize + 50)
+	requests := srv.Requests()
+	if err := s.ReplaceGroupMembers(context.Background(), groupId, userIds...); err != nil {
+		t.Fatal(err)
+	}
+	// ServiceProviderConfig, ResourceTypes, Schemas, and a single bulk request
+	if n := srv.Requests() - requests; n != 4 {
+		t.Errorf("requests = %d, want discovery and a single bulk request", n)
+	}
+	if members := groupMembers(t, s, groupId); strings.Join(members, ",") != strings.Join(userIds, ",") {
+		t.Errorf("members = %d, want the %d replacing members", len(members), len(userIds))
+	}
+}
+
+func TestReplaceGroupMembersBulkFailure(t *testing.T) {
+	srv := scimtest.NewServer()
+	defer srv.Close()
+	client := cybr_pam_scim.NewClient(srv.Client(), cybr_pam_scim.Options{ApiURL: srv.URL})
+	// Only the first operation is processed, the second is rejected and the third is not processed
+	client.Use(func(next cybr_pam_scim.Handler) cybr_pam_scim.Handler {
+		return func(r *http.Request) (*http.Response, error) {
+			if !strings.HasSuffix(r.URL.Path, "/Bulk") {
+				return next(r)
+			}
+			var request types.BulkRequest
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				return nil, err
+			}
+			rejected := request.Operations[1]
+			request.Operations = request.Operations[:1]
+			body, _ := json.Marshal(request)
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			r.ContentLength = int64(len(body))
+
+			resp, err := next(r)
+			if err != nil {
+				return nil, err
+			}
+			var response types.BulkResponse
+			if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+				return nil, err
+			}
+			response.Operations = append(response.Operations, types.BulkOperationResponse{
+				Method:   rejected.Method,
+				BulkId:   rejected.BulkId,
+				Status:   "403",
+				Response: json.RawMessage(`{"status":"403","detail":"injected"}`),
+			})
+			body, _ = json.Marshal(response)
+			resp.Body = io.NopCloser(bytes.NewReader(body))
+			return resp, nil
+		}
+	})
+	s := cybr_pam_scim.NewServiceWithClient(client)
+	groupId := newGroup(t, s)
+
+	userIds := memberIds(2*cybr_pam_scim.DefaultMemberBatchSize + 50)
+	err := s.ReplaceGroupMembers(context.Background(), groupId, userIds...)
+	var membershipErr *cybr_pam_scim.MembershipError
+	if !errors.As(err, &membershipErr) {
+		t.Fatalf("error = %v, want a *MembershipError", err)
+	}
+	first := userIds[:cybr_pam_scim.DefaultMemberBatchSize]
+	if membershipErr.Op != types.PatchOpReplace || strings.Join(membershipErr.Applied, ",") != strings.Join(first, ",") {
+		t.Errorf("op = %s and applied = %d member(s), want replace and the first batch", membershipErr.Op, len(membershipErr.Applied))
+	}
+	if len(membershipErr.Failures) != len(userIds)-len(first) {
+		t.Errorf("failures = %d, want %d", len(membershipErr.Failures), len(userIds)-len(first))
+	}
+	if !errors.Is(err, cybr_pam_scim.ErrUserAccessDenied) || !errors.Is(err, cybr_pam_scim.ErrBulkOperationSkipped) {
+		t.Errorf("error = %v, want it to match the rejected and the skipped batch", err)
+	}
+	if members := groupMembers(t, s, groupId); strings.Join(members, ",") != strings.Join(first, ",") {
+		t.Errorf("members = %d, want the %d applied members", len(members), len(first))
+	}
+}
+
+func TestReplaceGroupMembersWithoutBulk(t *testing.T) {
+	srv := scimtest.NewServer()
+	defer srv.Close()
+	config := srv.Config()
+	config.Bulk.Supported = false
+	srv.SetConfig(config)
+	client := cybr_pam_scim.NewClient(srv.Client(), cybr_pam_scim.Options{ApiURL: srv.URL})
+	patches := 0
+	// The replacing batch is applied and the batches added afterwards are denied
+	client.Use(respond(http.StatusForbidden, func(r *http.Request) bool {
+		if r.Method != http.MethodPatch {
+			return false
+		}
+		patches++
+		return patches > 1
+	}))
+	s := cybr_pam_scim.NewServiceWithClient(client)
+	groupId := newGroup(t, s)
+
+	userIds := memberIds(2*cybr_pam_scim.DefaultMemberBatchSize + 50)
+	err := s.ReplaceGroupMembers(context.Background(), groupId, userIds...)
+	var membershipErr *cybr_pam_scim.MembershipError
+	if !errors.As(err, &membershipErr) {
+		t.Fatalf("error = %v, want a *MembershipError", err)
+	}
+	first := userIds[:cybr_pam_scim.DefaultMemberBatchSize]
+	if membershipErr.Op != types.PatchOpReplace || strings.Join(membershipErr.Applied, ",") != strings.Join(first, ",") {
+		t.Errorf("op = %s and applied = %d member(s), want replace and the first batch", membershipErr.Op, len(membershipErr.Applied))
+	}
+	if len(membershipErr.Failures) != len(userIds)-len(first) {
+		t.Errorf("failures = %d, want the %d members which were not processed", len(membershipErr.Failures), len(userIds)-len(first))
+	}
+	var scimErr *cybr_pam_scim.ScimError
+	if !errors.Is(err, cybr_pam_scim.ErrUserAccessDenied) || !errors.As(err, &scimErr) || scimErr.StatusCode != http.StatusForbidden {
+		t.Errorf("error = %v, want it to match the *ScimError of the denied batch", err)
+	}
+	if patches != 2 {
+		t.Errorf("PATCH requests = %d, want to stop after access is denied", patches)
+	}
+}
+
+func TestAddGroupMembersPartialFailure(t *testing.T) {
+	srv := scimtest.NewServer()
+	defer srv.Close()
+	client := cybr_pam_scim.NewClient(srv.Client(), cybr_pam_scim.Options{ApiURL: srv.URL})
+	// The batch and the member 101 are rejected
+	client.Use(respond(http.StatusBadRequest, func(r *http.Request) bool {
+		if r.Method != http.MethodPatch {
+			return false
+		}
+		body, _ := r.GetBody()
+		data, _ := io.ReadAll(body)
+		return bytes.Contains(data, []byte(`"101"`))
+	}))
+	s := cybr_pam_scim.NewServiceWithClient(client)
+	groupId := newGroup(t, s)
+
+	err := s.AddGroupMembers(context.Background(), groupId, "100", "101", "102")
+	var membershipErr *cybr_pam_scim.MembershipError
+	if !errors.As(err, &membershipErr) {
+		t.Fatalf("error = %v, want a *MembershipError", err)
+	}
+	if strings.Join(membershipErr.Applied, ",") != "100,102" || len(membershipErr.Failures) != 1 || membershipErr.Failures[0].Member != "101" {
+		t.Errorf("applied = %v and failures = %v, want 100 and 102 applied and 101 failed", membershipErr.Applied, membershipErr.Failures)
+	}
+	var scimErr *cybr_pam_scim.ScimError
+	if !errors.As(err, &scimErr) || scimErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("error = %v, want it to match the *ScimError of the member", err)
+	}
+	if members := groupMembers(t, s, groupId); strings.Join(members, ",") != "99,100,102" {
+		t.Errorf("members = %v, want 99, 100, and 102", members)
+	}
+}
+
+// membershipRequests counts the PATCH requests and the operations of the bulk requests
+type membershipRequests struct {
+	patches    int
+	operations []int
+	bulkSizes  []int
+}
+
+func (m *membershipRequests) middleware(next cybr_pam_scim.Handler) cybr_pam_scim.Handler {
+	return func(r *http.Request) (*http.Response, error) {
+		switch {
+		case r.Method == http.MethodPatch:
+			m.patches++
+		case strings.HasSuffix(r.URL.Path, "/Bulk"):
+			body, _ := r.GetBody()
+			data, _ := io.ReadAll(body)
+			var request types.BulkRequest
+			if err := json.Unmarshal(data, &request); err != nil {
+				return nil, err
+			}
+			m.operations = append(m.operations, len(request.Operations))
+			m.bulkSizes = append(m.bulkSizes, len(data))
+		}
+		return next(r)
+	}
+}
+
+// longMemberIds returns n User Ids of size bytes
+func longMemberIds(n int, size int) []string {
+	userIds := memberIds(n)
+	for i := range userIds {
+		userIds[i] += strings.Repeat("0", size-len(userIds[i]))
+	}
+
+	return userIds
+}
+
+func TestGroupMembersPatchSize(t *testing.T) {
+	srv := scimtest.NewServer()
+	defer srv.Close()
+	config := srv.Config()
+	config.Bulk.MaxPayloadSize = 1024
+	srv.SetConfig(config)
+	m := &membershipRequests{}
+	client := cybr_pam_scim.NewClient(srv.Client(), cybr_pam_scim.Options{ApiURL: srv.URL})
+	client.Use(m.middleware)
+	s := cybr_pam_scim.NewServiceWithClient(client)
+	groupId := newGroup(t, s)
+	ctx := context.Background()
+
+	// The bulk payload size does not limit PATCH requests
+	userIds := memberIds(cybr_pam_scim.DefaultMemberBatchSize)
+	if err := s.AddGroupMembers(ctx, groupId, userIds...); err != nil {
+		t.Fatal(err)
+	}
+	if m.patches != 1 {
+		t.Errorf("PATCH requests = %d, want a single request of %d members", m.patches, len(userIds))
+	}
+
+	// DefaultMemberPatchSize does
+	m.patches = 0
+	userIds = longMemberIds(50, 2000)
+	if err := s.RemoveGroupMembers(ctx, groupId, userIds...); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddGroupMembers(ctx, groupId, userIds...); err != nil {
+		t.Fatal(err)
+	}
+	if m.patches != 4 {
+		t.Errorf("PATCH requests = %d, want two batches of %d byte members removed and added", m.patches, 2000)
+	}
+	if members := groupMembers(t, s, groupId); len(members) != 1+cybr_pam_scim.DefaultMemberBatchSize+len(userIds) {
+		t.Errorf("members = %d, want %d", len(members), 1+cybr_pam_scim.DefaultMemberBatchSize+len(userIds))
+	}
+}
+
+func TestReplaceGroupMembersBulkPayloadSize(t *testing.T) {
+	tests := []struct {
+		name           string
+		maxPayloadSize int
+		userIds        []string
+		operations     []int
+	}{
+		// A smaller bulk limit splits the batches below DefaultMemberBatchSize
+		{"bulk limit below the PATCH limit", 1024, memberIds(cybr_pam_scim.DefaultMemberBatchSize + 50), []int{1, 1, 1, 1, 1}},
+		// Members exceeding a single PATCH request fit in a single bulk operation
+		{"bulk limit above the PATCH limit", 1 << 20, longMemberIds(50, 2000), []int{1}},
+	}
+	for _, tt := range tests {
+		srv := scimtest.NewServer()
+		config := srv.Config()
+		config.Bulk.MaxPayloadSize = tt.maxPayloadSize
+		srv.SetConfig(config)
+		m := &membershipRequests{}
+		client := cybr_pam_scim.NewClient(srv.Client(), cybr_pam_scim.Options{ApiURL: srv.URL})
+		client.Use(m.middleware)
+		s := cybr_pam_scim.NewServiceWithClient(client)
+		groupId := newGroup(t, s)
+
+		err := s.ReplaceGroupMembers(context.Background(), groupId, tt.userIds...)
+		members := groupMembers(t, s, groupId)
+		srv.Close()
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if fmt.Sprint(m.operations) != fmt.Sprint(tt.operations) || m.patches != 0 {
+			t.Errorf("%s: bulk operations = %v and PATCH requests = %d, want %v", tt.name, m.operations, m.patches, tt.operations)
+		}
+		for _, size := range m.bulkSizes {
+			if size > tt.maxPayloadSize {
+				t.Errorf("%s: bulk request of %d bytes, want at most %d", tt.name, size, tt.maxPayloadSize)
+			}
+		}
+		if strings.Join(members, ",") != strings.Join(tt.userIds, ",") {
+			t.Errorf("%s: members = %d, want the %d replacing members", tt.name, len(members), len(tt.userIds))
+		}
+	}
+}
@@ -6,13 +6,12 @@ import (
 	"net/url"
 	"strconv"
 
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/filter"
 	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
 )
 
-var (
-	Groups types.Groups
-	Group  types.Group
-)
+// groupSortBy contains the attributes Groups may be sorted by
+var groupSortBy = []string{"displayName"}
 
 // GetGroups retrieves all groups via the SCIM API and returns them in the form of the
 // The response from the SCIM API is returned as the types.Groups struct.
@@ -20,12 +19,17 @@ var (
 // Example Usage:
 //		getGroups, err := s.GetGroups(context.Background)
 //
-func (s *Service) GetGroups(ctx context.Context) (*types.Groups, error) {
-	if err := s.client.Get(ctx, fmt.Sprintf("/%s", "groups"), &Groups); err != nil {
+func (s *Service) GetGroups(ctx context.Context, opts ...ReadOption) (*types.Groups, error) {
+	var groups types.Groups
+	path, err := s.readPath(ctx, "Group", fmt.Sprintf("/%s", "groups"), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get groups: %w", err)
+	}
+	if err := s.client.Get(ctx, path, &groups); err != nil {
 		return nil, fmt.Errorf("failed to get groups: %w", err)
 	}
 
-	return &Groups, nil
+	return &groups, nil
 }
 
 // GetGroupsIndex retrieves a limited subset of groups based on a starting and count.
@@ -36,13 +40,18 @@ func (s *Service) GetGroups(ctx context.Context) (*types.Groups, error) {
 // Example Usage:
 //		getGroupsIndex, err := s.GetGroupsIndex(context.Background, 1, 5)
 //
-func (s *Service) GetGroupsIndex(ctx context.Context, startIndex int, count int) (*types.Groups, error) {
+func (s *Service) GetGroupsIndex(ctx context.Context, startIndex int, count int, opts ...ReadOption) (*types.Groups, error) {
+	var groups types.Groups
 	pathEscapedQuery := url.PathEscape("startIndex=" + strconv.Itoa(startIndex) + "&count=" + strconv.Itoa(count))
-	if err := s.client.Get(ctx, fmt.Sprintf("/%s?%s", "Groups", pathEscapedQuery), &Groups); err != nil {
+	path, err := s.readPath(ctx, "Group", fmt.Sprintf("/%s?%s", "Groups", pathEscapedQuery), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Groups: %w", err)
+	}
+	if err := s.client.Get(ctx, path, &groups); err != nil {
 		return nil, fmt.Errorf("failed to get Groups: %w", err)
 	}
 
-	return &Groups, nil
+	return &groups, nil
 }
 
 // GetGroupsSort retrieves and sorts all Groups via the SCIM API.
@@ -55,7 +64,12 @@ func (s *Service) GetGroupsIndex(ctx context.Context, startIndex int, count int)
 // Example Usage:
 //		getGroupsSort, err := s.GetGroupsSort(context.Background, "displayName", "ascending")
 //
-func (s *Service) GetGroupsSort(ctx context.Context, sortBy string, sortOrder string) (*types.Groups, error) {
+func (s *Service) GetGroupsSort(ctx context.Context, sortBy string, sortOrder string, opts ...ReadOption) (*types.Groups, error) {
+	if !s.capabilities(ctx).SortSupported() {
+		return nil, ErrSortNotSupported
+	}
+
+	var groups types.Groups
 	var pathEscapedQuery string
 	// Input validations:
 	if sortBy == "displayName" {
@@ -70,11 +84,15 @@ func (s *Service) GetGroupsSort(ctx context.Context, sortBy string, sortOrder st
 		return nil, fmt.Errorf("invalid sortBy value provide, accepted value is displayName")
 	}
 
-	if err := s.client.Get(ctx, fmt.Sprintf("/%s?%s", "Groups", pathEscapedQuery), &Groups); err != nil {
+	path, err := s.readPath(ctx, "Group", fmt.Sprintf("/%s?%s", "Groups", pathEscapedQuery), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Groups: %w", err)
+	}
+	if err := s.client.Get(ctx, path, &groups); err != nil {
 		return nil, fmt.Errorf("failed to get Groups: %w", err)
 	}
 
-	return &Groups, nil
+	return &groups, nil
 }
 
 // GetGroupById retrieves a single Group by Group Id via the SCIM API.
@@ -85,12 +103,17 @@ func (s *Service) GetGroupsSort(ctx context.Context, sortBy string, sortOrder st
 // Example Usage:
 //		getGroupById, err := s.GetGroupById(context.Background, "8")
 //
-func (s *Service) GetGroupById(ctx context.Context, id string) (*types.Group, error) {
-	if err := s.client.Get(ctx, fmt.Sprintf("/%s/%s", "Groups", id), &Group); err != nil {
+func (s *Service) GetGroupById(ctx context.Context, id string, opts ...ReadOption) (*types.Group, error) {
+	var group types.Group
+	path, err := s.readPath(ctx, "Group", fmt.Sprintf("/%s/%s", "Groups", id), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Group %s: %w", id, err)
+	}
+	if err := s.client.Get(ctx, path, &group); err != nil {
 		return nil, fmt.Errorf("failed to get Group %s: %w", id, err)
 	}
 
-	return &Group, nil
+	return &group, nil
 }
 
 // GetGroupByFilter retrieves a single Group based on a provided filter.
@@ -102,18 +125,51 @@ func (s *Service) GetGroupById(ctx context.Context, id string) (*types.Group, er
 // Example Usage:
 //		getGroupByFilter, err := s.GetGroupByFilter(context.Background, "displayName", "Auditors")
 //
-func (s *Service) GetGroupByFilter(ctx context.Context, filterType string, filterQuery string) (*types.Group, error) {
-	var pathEscapedQuery string
-	if filterType == "id" || filterType == "displayName" {
-		pathEscapedQuery = url.PathEscape("filter=" + filterType + " eq \"" + filterQuery + "\"")
-	} else {
+func (s *Service) GetGroupByFilter(ctx context.Context, filterType string, filterQuery string, opts ...ReadOption) (*types.Group, error) {
+	var groups types.ListResponse[types.Group]
+	if filterType != "id" && filterType != "displayName" {
 		return nil, fmt.Errorf("invalid filterType provided, accepted types are id or displayName")
 	}
-	if err := s.client.Get(ctx, fmt.Sprintf("/%s?%s", "Groups", pathEscapedQuery), &Group); err != nil {
+	query := url.Values{"filter": {filter.Attr(filterType).Eq(filterQuery).String()}}
+	path, err := s.readPath(ctx, "Group", fmt.Sprintf("/%s?%s", "Groups", encodeQuery(query)), opts)
+	if err != nil {
 		return nil, fmt.Errorf("failed to get Group based on filter parameters - %s = %s: %w", filterType, filterQuery, err)
 	}
+	if err := s.client.Get(ctx, path, &groups); err != nil {
+		return nil, fmt.Errorf("failed to get Group based on filter parameters - %s = %s: %w", filterType, filterQuery, err)
+	}
+	group, ok := groups.First()
+	if !ok {
+		return nil, fmt.Errorf("failed to get Group based on filter parameters - %s = %s: %w", filterType, filterQuery, ErrNotFound)
+	}
 
-	return &Group, nil
+	return group, nil
+}
+
+// GetGroupsByFilterExpr retrieves all Groups matching a filter expression via the SCIM API.
+// The response from the SCIM API is returned as the types.Groups struct.
+// The attributes referenced by the expression are validated against filter.Groups.
+//
+// Example Usage:
+//		expr := filter.Attr("members").Where(filter.Attr("value").Eq("12"))
+//		getGroupsByFilterExpr, err := s.GetGroupsByFilterExpr(context.Background, expr)
+//
+func (s *Service) GetGroupsByFilterExpr(ctx context.Context, expr filter.Expression, opts ...ReadOption) (*types.Groups, error) {
+	if err := filter.Groups.Validate(expr); err != nil {
+		return nil, fmt.Errorf("invalid filter provided: %w", err)
+	}
+
+	var groups types.Groups
+	query := url.Values{"filter": {expr.String()}}
+	path, err := s.readPath(ctx, "Group", fmt.Sprintf("/%s?%s", "Groups", encodeQuery(query)), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Groups based on filter %s: %w", expr, err)
+	}
+	if err := s.client.Get(ctx, path, &groups); err != nil {
+		return nil, fmt.Errorf("failed to get Groups based on filter %s: %w", expr, err)
+	}
+
+	return &groups, nil
 }
 
 // AddGroup attempts add a single Group and requires a passed object in the form of
@@ -130,11 +186,12 @@ func (s *Service) GetGroupByFilter(ctx context.Context, filterType string, filte
 //		addGroup, err := s.AddGroup(context.Background, Group)
 //
 func (s *Service) AddGroup(ctx context.Context, group types.Group) (*types.Group, error) {
-	if err := s.client.Post(ctx, fmt.Sprintf("/%s", "Groups"), group, &Group); err != nil {
+	var groupResponse types.Group
+	if err := s.client.Post(ctx, fmt.Sprintf("/%s", "Groups"), group, &groupResponse); err != nil {
 		return nil, fmt.Errorf("failed to add Group %s: %w", group.DisplayName, err)
 	}
 
-	return &Group, nil
+	return &groupResponse, nil
 }
 
 // UpdateGroup attempts to perform a "PUT" operation against a single Group and requires
@@ -149,11 +206,79 @@ func (s *Service) AddGroup(ctx context.Context, group types.Group) (*types.Group
 //		addGroup, err := s.UpdateGroup(context.Background, Group)
 //
 func (s *Service) UpdateGroup(ctx context.Context, group types.Group) (*types.Group, error) {
-	if err := s.client.Put(ctx, fmt.Sprintf("/%s/%s", "Groups", group.Id), group, &Group); err != nil {
+	var groupResponse types.Group
+	if err := s.client.Put(ctx, fmt.Sprintf("/%s/%s", "Groups", group.Id), group, &groupResponse); err != nil {
 		return nil, fmt.Errorf("failed to update Group %s: %w", group.Id, err)
 	}
 
-	return &Group, nil
+	return &groupResponse, nil
+}
+
+// UpdateGroupIfMatch performs the "PUT" operation of UpdateGroup only if the Group has not been
+// modified since group.Meta.Version was retrieved, ErrPreconditionFailed is returned otherwise.
+// ErrETagNotSupported is returned if the service provider does not support ETags.
+//
+// Example Usage:
+//		group, err := s.GetGroupById(context.Background, "8")
+//		group.DisplayName = "ExampleGroup"
+//		update, err := s.UpdateGroupIfMatch(context.Background, *group)
+//
+func (s *Service) UpdateGroupIfMatch(ctx context.Context, group types.Group) (*types.Group, error) {
+	ctx, err := s.ifMatch(ctx, group.Meta.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update Group %s: %w", group.Id, err)
+	}
+
+	return s.UpdateGroup(ctx, group)
+}
+
+// EditGroup retrieves a single Group by Group Id, applies fn to it, and replaces it with
+// UpdateGroupIfMatch. The Group is retrieved and fn applied again when it has been modified
+// concurrently, so fn must only modify the provided Group. An error returned by fn aborts the
+// edit.
+//
+// Example Usage:
+//		edit, err := s.EditGroup(context.Background, "8", func(group *types.Group) error {
+//			group.DisplayName = "ExampleGroup"
+//			return nil
+//		})
+//
+func (s *Service) EditGroup(ctx context.Context, id string, fn func(group *types.Group) error) (*types.Group, error) {
+	group, err := edit(ctx, func(ctx context.Context) (*types.Group, error) {
+		return s.GetGroupById(ctx, id)
+	}, fn, func(ctx context.Context, group *types.Group) (*types.Group, error) {
+		return s.UpdateGroupIfMatch(ctx, *group)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to edit Group %s: %w", id, err)
+	}
+
+	return group, nil
+}
+
+// PatchGroup attempts to perform a "PATCH" operation against a single Group by Group Id and
+// requires a types.PatchRequest with the desired operations. Only the attributes referenced
+// by the operations are modified. The response from the SCIM API is returned as the types.Group struct,
+// which is empty if the SCIM API does not return the updated Group.
+//
+// Example Usage:
+//		// Add one member and remove another
+//		patch := types.NewPatchRequest().
+//			Add("members", []types.Members{{Value: "12"}}).
+//			Remove(filter.Attr("members").Where(filter.Attr("value").Eq("15")).String())
+//		patchGroup, err := s.PatchGroup(context.Background, "8", patch)
+//
+func (s *Service) PatchGroup(ctx context.Context, id string, patch *types.PatchRequest) (*types.Group, error) {
+	if err := s.validatePatch(ctx, patch); err != nil {
+		return nil, fmt.Errorf("failed to patch Group %s: %w", id, err)
+	}
+
+	var group types.Group
+	if err := s.client.Patch(ctx, fmt.Sprintf("/%s/%s", "Groups", id), patch, &group); err != nil {
+		return nil, fmt.Errorf("failed to patch Group %s: %w", id, err)
+	}
+
+	return &group, nil
 }
 
 // DeleteGroup attempts to perform a "DELETE" operation against a single Group by
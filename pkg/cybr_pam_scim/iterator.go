@@ -0,0 +1,243 @@
+package cybr_pam_scim
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/filter"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
+	"golang.org/x/exp/slices"
+)
+
+// DefaultPageSize is the number of resources requested per page when ListOptions.PageSize is not set.
+// Page sizes are clamped to the maximum number of results supported by the service provider.
+const DefaultPageSize = 100
+
+// ListOptions configures the requests made by an Iterator. All fields are optional.
+type ListOptions struct {
+	// Filter restricts the resources to those matching the expression
+	Filter filter.Expression
+	// SortBy is the attribute used to sort the resources
+	SortBy string
+	// SortOrder is either ascending or descending
+	SortOrder string
+	// PageSize is the number of resources requested per page
+	PageSize int
+	// Attributes restricts the returned attributes to those listed, see the Attributes ReadOption
+	Attributes []string
+	// ExcludedAttributes omits the listed attributes, see the ExcludedAttributes ReadOption
+	ExcludedAttributes []string
+}
+
+// Collection provides paginated access to a SCIM resource endpoint
+type Collection[T any] struct {
+	service  *Service
+	endpoint string
+	// name is the name of the resource type, e.g. User
+	name     string
+	resource filter.Resource
+	sortBy   []string
+}
+
+// Users returns the Collection of the Users endpoint
+func (s *Service) Users() *Collection[types.User] {
+	return &Collection[types.User]{service: s, endpoint: "users", name: "User", resource: filter.Users, sortBy: userSortBy}
+}
+
+// Groups returns the Collection of the Groups endpoint
+func (s *Service) Groups() *Collection[types.Group] {
+	return &Collection[types.Group]{service: s, endpoint: "Groups", name: "Group", resource: filter.Groups, sortBy: groupSortBy}
+}
+
+// Safes returns the Collection of the Containers endpoint
+func (s *Service) Safes() *Collection[types.Container] {
+	return &Collection[types.Container]{service: s, endpoint: "Containers", name: "Container", resource: filter.Containers, sortBy: safeSortBy}
+}
+
+// SafePermissions returns the Collection of the ContainerPermissions endpoint
+func (s *Service) SafePermissions() *Collection[types.ContainerPermission] {
+	return &Collection[types.ContainerPermission]{service: s, endpoint: "ContainerPermissions", name: "ContainerPermission", resource: filter.ContainerPermissions, sortBy: safePermissionSortBy}
+}
+
+// PrivilegedData returns the Collection of the PrivilegedData endpoint
+func (s *Service) PrivilegedData() *Collection[types.PrivilegedData] {
+	return &Collection[types.PrivilegedData]{service: s, endpoint: "PrivilegedData", name: "PrivilegedData", resource: filter.PrivilegedData, sortBy: privilegedDataSortBy}
+}
+
+// All returns an Iterator over every resource matching the options. Pages are requested
+// lazily while iterating, the first request is made by the first call to Next.
+//
+// Requires PVWA 12.2+
+//
+// Example Usage:
+//		it := s.PrivilegedData().All(context.Background, &cybr_pam_scim.ListOptions{
+//			Filter:   filter.Attr("name").Sw("svc_"),
+//			SortBy:   "name",
+//			PageSize: 500,
+//		})
+//		for it.Next() {
+//			account := it.Value()
+//			fmt.Println(account.Id, account.Name)
+//		}
+//		if err := it.Err(); err != nil {
+//			log.Fatal(err)
+//		}
+//
+func (c *Collection[T]) All(ctx context.Context, opts *ListOptions) *Iterator[T] {
+	it := &Iterator[T]{
+		ctx:        ctx,
+		collection: c,
+		startIndex: 1,
+	}
+	if opts != nil {
+		it.opts = *opts
+	}
+	if it.opts.PageSize <= 0 {
+		it.opts.PageSize = DefaultPageSize
+	}
+	it.err = c.validate(it.opts)
+
+	return it
+}
+
+// List returns every resource matching the options by draining an Iterator
+//
+// Example Usage:
+//		users, err := s.Users().List(context.Background, &cybr_pam_scim.ListOptions{SortBy: "userName"})
+//
+func (c *Collection[T]) List(ctx context.Context, opts *ListOptions) ([]T, error) {
+	var resources []T
+	it := c.All(ctx, opts)
+	for it.Next() {
+		resources = append(resources, it.Value())
+	}
+
+	return resources, it.Err()
+}
+
+func (c *Collection[T]) validate(opts ListOptions) error {
+	if opts.Filter != nil {
+		if err := c.resource.Validate(opts.Filter); err != nil {
+			return fmt.Errorf("invalid filter provided: %w", err)
+		}
+	}
+	if opts.SortBy != "" && !slices.Contains(c.sortBy, opts.SortBy) {
+		return fmt.Errorf("invalid sortBy value provided for %s, accepted values are %v", c.endpoint, c.sortBy)
+	}
+	if opts.SortOrder != "" && opts.SortOrder != "ascending" && opts.SortOrder != "descending" {
+		return fmt.Errorf("invalid sortOrder provided, accepted values are ascending, descending, or no input")
+	}
+
+	return nil
+}
+
+// Iterator pages through the resources of a Collection. An Iterator is not safe for
+// concurrent use.
+type Iterator[T any] struct {
+	ctx        context.Context
+	collection *Collection[T]
+	opts       ListOptions
+	page       []T
+	index      int
+	startIndex int
+	prepared   bool
+	done       bool
+	current    T
+	err        error
+}
+
+// Next advances to the next resource, requesting the next page when needed. It returns
+// false when all resources have been returned or an error occurred.
+func (it *Iterator[T]) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	for it.index >= len(it.page) {
+		if it.done {
+			return false
+		}
+		if err := it.fetch(); err != nil {
+			it.err = err
+			return false
+		}
+	}
+
+	it.current = it.page[it.index]
+	it.index++
+
+	return true
+}
+
+// Value returns the current resource
+func (it *Iterator[T]) Value() T {
+	return it.current
+}
+
+// Err returns the error which stopped the iteration, if any
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// prepare adapts the options to the capabilities of the service provider before the first page
+func (it *Iterator[T]) prepare() error {
+	capabilities := it.collection.service.capabilities(it.ctx)
+	if it.opts.SortBy != "" && !capabilities.SortSupported() {
+		return ErrSortNotSupported
+	}
+	if maxResults := capabilities.MaxResults(); maxResults > 0 && it.opts.PageSize > maxResults {
+		it.opts.PageSize = maxResults
+	}
+	it.prepared = true
+
+	return nil
+}
+
+// fetch requests the page starting at startIndex. Iteration stops on an empty page or
+// once startIndex passes totalResults as reported by the latest page, which handles
+// servers returning short pages and totalResults changing during the scan.
+func (it *Iterator[T]) fetch() error {
+	if !it.prepared {
+		if err := it.prepare(); err != nil {
+			return err
+		}
+	}
+
+	query := url.Values{}
+	query.Set("startIndex", strconv.Itoa(it.startIndex))
+	query.Set("count", strconv.Itoa(it.opts.PageSize))
+	if it.opts.Filter != nil {
+		query.Set("filter", it.opts.Filter.String())
+	}
+	if it.opts.SortBy != "" {
+		query.Set("sortBy", it.opts.SortBy)
+	}
+	if it.opts.SortOrder != "" {
+		query.Set("sortOrder", it.opts.SortOrder)
+	}
+	if err := it.collection.service.attributeQuery(it.ctx, it.collection.name, query, it.opts.Attributes, it.opts.ExcludedAttributes); err != nil {
+		return err
+	}
+
+	var page types.ListResponse[T]
+	if err := it.collection.service.client.Get(it.ctx, fmt.Sprintf("/%s?%s", it.collection.endpoint, encodeQuery(query)), &page); err != nil {
+		return fmt.Errorf("failed to get %s starting at index %d: %w", it.collection.endpoint, it.startIndex, err)
+	}
+
+	it.page = page.Items()
+	it.index = 0
+	it.startIndex += page.Len()
+
+	switch {
+	case page.Len() == 0:
+		it.done = true
+	case page.TotalResults > 0 && it.startIndex > page.TotalResults:
+		it.done = true
+	case page.TotalResults == 0 && page.Len() < it.opts.PageSize:
+		// totalResults was not provided, a short page is the last page
+		it.done = true
+	}
+
+	return nil
+}
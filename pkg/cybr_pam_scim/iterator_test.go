@@ -0,0 +1,269 @@
+package cybr_pam_scim_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/scimtest"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
+)
+
+// seedUsers adds n users named user-1 to user-n and returns their ids
+func seedUsers(t *testing.T, srv *scimtest.Server, from int, n int) []string {
+	t.Helper()
+	var ids []string
+	for i := from; i < from+n; i++ {
+		id, err := srv.Seed("Users", types.User{UserName: fmt.Sprintf("user-%d", i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+// pages is a middleware calling fn after every page of Users, with the number of the page
+// starting at 1 and the decoded page, which fn may modify
+type pages struct {
+	n  int
+	fn func(n int, page map[string]interface{})
+}
+
+func (p *pages) middleware(next cybr_pam_scim.Handler) cybr_pam_scim.Handler {
+	return func(r *http.Request) (*http.Response, error) {
+		resp, err := next(r)
+		if err != nil || r.Method != http.MethodGet || !strings.HasSuffix(r.URL.Path, "/users") {
+			return resp, err
+		}
+		p.n++
+		if p.fn == nil {
+			return resp, nil
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		var page map[string]interface{}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, err
+		}
+		p.fn(p.n, page)
+		body, _ = json.Marshal(page)
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+
+		return resp, nil
+	}
+}
+
+// listUsers lists the users with the page size through the pages middleware
+func listUsers(t *testing.T, srv *scimtest.Server, p *pages, pageSize int) ([]string, error) {
+	t.Helper()
+	client := cybr_pam_scim.NewClient(srv.Client(), cybr_pam_scim.Options{ApiURL: srv.URL})
+	client.Use(p.middleware)
+	s := cybr_pam_scim.NewServiceWithClient(client)
+
+	users, err := s.Users().List(context.Background(), &cybr_pam_scim.ListOptions{PageSize: pageSize})
+	names := make([]string, 0, len(users))
+	for _, user := range users {
+		names = append(names, user.UserName)
+	}
+
+	return names, err
+}
+
+func TestIteratorPageBoundaries(t *testing.T) {
+	tests := []struct {
+		users    int
+		pageSize int
+		requests int
+	}{
+		{0, 3, 1},
+		{1, 3, 1},
+		{3, 3, 1},
+		{6, 3, 2},
+		{7, 3, 3},
+		{5, 1, 5},
+	}
+	for _, tt := range tests {
+		srv := scimtest.NewServer()
+		seedUsers(t, srv, 1, tt.users)
+		p := &pages{}
+		names, err := listUsers(t, srv, p, tt.pageSize)
+		srv.Close()
+		if err != nil {
+			t.Errorf("%d users by %d: %v", tt.users, tt.pageSize, err)
+			continue
+		}
+		if len(names) != tt.users || p.n != tt.requests {
+			t.Errorf("%d users by %d: users = %d and requests = %d, want %d and %d", tt.users, tt.pageSize, len(names), p.n, tt.users, tt.requests)
+		}
+	}
+}
+
+func TestIteratorTotalResultsChanges(t *testing.T) {
+	t.Run("shrinking", func(t *testing.T) {
+		srv := scimtest.NewServer()
+		defer srv.Close()
+		ids := seedUsers(t, srv, 1, 6)
+		admin := srv.NewService()
+		p := &pages{fn: func(n int, page map[string]interface{}) {
+			// The last two users are deleted after the first page
+			if n == 1 {
+				for _, id := range ids[4:] {
+					if err := admin.DeleteUser(context.Background(), id); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+		}}
+		names, err := listUsers(t, srv, p, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "user-1,user-2,user-3,user-4"
+		if got := strings.Join(names, ","); got != want || p.n != 2 {
+			t.Errorf("users = %s after %d requests, want %s after 2", got, p.n, want)
+		}
+	})
+
+	t.Run("growing", func(t *testing.T) {
+		srv := scimtest.NewServer()
+		defer srv.Close()
+		seedUsers(t, srv, 1, 4)
+		p := &pages{fn: func(n int, page map[string]interface{}) {
+			// Two users are added after the first page
+			if n == 1 {
+				seedUsers(t, srv, 5, 2)
+			}
+		}}
+		names, err := listUsers(t, srv, p, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "user-1,user-2,user-3,user-4,user-5,user-6"
+		if got := strings.Join(names, ","); got != want || p.n != 3 {
+			t.Errorf("users = %s after %d requests, want %s after 3", got, p.n, want)
+		}
+	})
+
+	t.Run("drops to zero", func(t *testing.T) {
+		srv := scimtest.NewServer()
+		defer srv.Close()
+		ids := seedUsers(t, srv, 1, 4)
+		admin := srv.NewService()
+		p := &pages{fn: func(n int, page map[string]interface{}) {
+			if n == 1 {
+				for _, id := range ids {
+					if err := admin.DeleteUser(context.Background(), id); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+		}}
+		names, err := listUsers(t, srv, p, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The second page is empty
+		if len(names) != 2 || p.n != 2 {
+			t.Errorf("users = %v after %d requests, want the first page after 2", names, p.n)
+		}
+	})
+}
+
+func TestIteratorShortPages(t *testing.T) {
+	t.Run("without totalResults", func(t *testing.T) {
+		srv := scimtest.NewServer()
+		defer srv.Close()
+		seedUsers(t, srv, 1, 5)
+		p := &pages{fn: func(n int, page map[string]interface{}) {
+			page["totalResults"] = 0
+		}}
+		names, err := listUsers(t, srv, p, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Pages of 2, 2, and 1 users, the short page is the last one
+		if len(names) != 5 || p.n != 3 {
+			t.Errorf("users = %v after %d requests, want 5 after 3", names, p.n)
+		}
+	})
+
+	t.Run("without totalResults on a full last page", func(t *testing.T) {
+		srv := scimtest.NewServer()
+		defer srv.Close()
+		seedUsers(t, srv, 1, 4)
+		p := &pages{fn: func(n int, page map[string]interface{}) {
+			delete(page, "totalResults")
+		}}
+		names, err := listUsers(t, srv, p, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// An empty page ends the iteration
+		if len(names) != 4 || p.n != 3 {
+			t.Errorf("users = %v after %d requests, want 4 after 3", names, p.n)
+		}
+	})
+
+	t.Run("shorter than requested", func(t *testing.T) {
+		srv := scimtest.NewServer()
+		defer srv.Close()
+		seedUsers(t, srv, 1, 5)
+		p := &pages{fn: func(n int, page map[string]interface{}) {
+			// The server returns a single user per page whatever the count
+			resources := page["Resources"].([]interface{})
+			page["Resources"] = resources[:1]
+			page["itemsPerPage"] = 1
+		}}
+		names, err := listUsers(t, srv, p, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "user-1,user-2,user-3,user-4,user-5"
+		if got := strings.Join(names, ","); got != want || p.n != 5 {
+			t.Errorf("users = %s after %d requests, want %s after 5", got, p.n, want)
+		}
+	})
+}
+
+func TestIteratorContextCanceled(t *testing.T) {
+	srv := scimtest.NewServer()
+	defer srv.Close()
+	seedUsers(t, srv, 1, 6)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := &pages{}
+	client := cybr_pam_scim.NewClient(srv.Client(), cybr_pam_scim.Options{ApiURL: srv.URL})
+	client.Use(p.middleware)
+	s := cybr_pam_scim.NewServiceWithClient(client)
+
+	it := s.Users().All(ctx, &cybr_pam_scim.ListOptions{PageSize: 2})
+	var names []string
+	for it.Next() {
+		names = append(names, it.Value().UserName)
+		// Canceled while iterating the second page
+		if len(names) == 3 {
+			cancel()
+		}
+	}
+	if !errors.Is(it.Err(), context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", it.Err())
+	}
+	if len(names) != 4 || p.n != 2 {
+		t.Errorf("users = %v after %d requests, want the first two pages", names, p.n)
+	}
+	if it.Next() {
+		t.Error("Next returned true after an error")
+	}
+}
@@ -0,0 +1,346 @@
+package cybr_pam_scim
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Logger receives the structured verbose output of a Client. Its methods take a message
+// followed by alternating keys and values, e.g. "method", "GET", "status", 200. A *slog.Logger
+// of the log/slog package implements it, StdLogger adapts a *log.Logger.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// StdLogger returns a Logger writing a line per message to the provided *log.Logger,
+// formatted like the text handler of log/slog. A nil logger uses the standard logger.
+//
+// Example Output:
+//		2022/05/02 10:11:12 INFO scim response method=GET path=/scim/v2/Users status=200 latency=152.4ms
+//
+func StdLogger(logger *log.Logger) Logger {
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	return stdLogger{logger: logger}
+}
+
+type stdLogger struct {
+	logger *log.Logger
+}
+
+func (l stdLogger) Debug(msg string, args ...interface{}) { l.log("DEBUG", msg, args) }
+func (l stdLogger) Info(msg string, args ...interface{})  { l.log("INFO", msg, args) }
+func (l stdLogger) Warn(msg string, args ...interface{})  { l.log("WARN", msg, args) }
+func (l stdLogger) Error(msg string, args ...interface{}) { l.log("ERROR", msg, args) }
+
+func (l stdLogger) log(level string, msg string, args []interface{}) {
+	var b strings.Builder
+	b.WriteString(level)
+	b.WriteString(" ")
+	b.WriteString(msg)
+	for i := 0; i < len(args); i += 2 {
+		key, value := fmt.Sprint(args[i]), interface{}(nil)
+		if i+1 < len(args) {
+			value = args[i+1]
+		} else {
+			key, value = "!BADKEY", args[i]
+		}
+		b.WriteString(" ")
+		b.WriteString(key)
+		b.WriteString("=")
+		b.WriteString(logValue(fmt.Sprint(value)))
+	}
+	l.logger.Println(b.String())
+}
+
+// logValue quotes values which would otherwise be ambiguous in a key=value line
+func logValue(s string) string {
+	if s == "" || strings.ContainsAny(s, " =\"\t\r\n") || !strconv.CanBackquote(s) {
+		return strconv.Quote(s)
+	}
+
+	return s
+}
+
+// Redacted replaces the values hidden by a RedactionPolicy
+const Redacted = "[REDACTED]"
+
+// RedactionPolicy controls which parts of the requests and responses are hidden from the
+// verbose output. Redaction cannot be disabled by a nil policy, DefaultRedactionPolicy is
+// used instead. An empty RedactionPolicy logs everything.
+type RedactionPolicy struct {
+	// Headers are the names of the headers whose values are replaced
+	Headers []string
+	// Fields are the names of the JSON attributes and form fields whose values are replaced,
+	// at any depth of the body. They also match the target of SCIM PATCH operations, so the
+	// value of an operation with the path "password" is replaced as well. Names are case
+	// insensitive and match the last segment of a path or URN (e.g. "password" matches
+	// "urn:ietf:params:scim:schemas:cyberark:1.0:PrivilegedData:password"). When Fields is
+	// set, bodies which are neither JSON nor form encoded are replaced as a whole.
+	Fields []string
+	// Paths are URL path prefixes or substrings (e.g. token endpoints) whose request and
+	// response bodies are replaced as a whole
+	Paths []string
+}
+
+// DefaultRedactionPolicy hides the Authorization headers, passwords, client secrets, Oauth2
+// tokens, and the bodies of the Oauth2 token endpoints
+var DefaultRedactionPolicy = RedactionPolicy{
+	Headers: []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"},
+	Fields:  []string{"password", "client_secret", "access_token", "refresh_token", "id_token"},
+	Paths:   []string{"/oauth2/token", "/oauth2/platformtoken"},
+}
+
+// SetRedactionPolicy replaces the redaction policy of the verbose output of the Service,
+// nil restores DefaultRedactionPolicy
+func (s *Service) SetRedactionPolicy(policy *RedactionPolicy) {
+	s.client.configure(func(options *Options) {
+		options.Redaction = policy
+	})
+}
+
+// header returns a copy of the header with the values of the redacted headers replaced
+func (p *RedactionPolicy) header(header http.Header) http.Header {
+	redacted := header.Clone()
+	for _, name := range p.Headers {
+		if _, ok := redacted[http.CanonicalHeaderKey(name)]; ok {
+			redacted.Set(name, Redacted)
+		}
+	}
+
+	return redacted
+}
+
+// body returns the body of a request or response to the URL path with the redacted values
+// replaced. JSON and form bodies are redacted field by field. Other bodies, and JSON or form
+// bodies which cannot be parsed, may echo secrets in any format (e.g. an HTML error page
+// repeating the submitted credentials) and are dropped unless the policy is empty.
+func (p *RedactionPolicy) body(path string, contentType string, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	for _, prefix := range p.Paths {
+		if strings.Contains(path, prefix) {
+			return Redacted
+		}
+	}
+	if len(p.Fields) == 0 {
+		return string(body)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "application/x-www-form-urlencoded" {
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return dropped(mediaType, body)
+		}
+		for key := range values {
+			if p.field(key) {
+				values[key] = []string{Redacted}
+			}
+		}
+		return values.Encode()
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err == nil && !dec.More() {
+		if redacted, err := json.Marshal(p.value(v)); err == nil {
+			return string(redacted)
+		}
+	}
+
+	return dropped(mediaType, body)
+}
+
+// dropped replaces a body which cannot be redacted field by field
+func dropped(mediaType string, body []byte) string {
+	if mediaType == "" {
+		mediaType = "unknown content type"
+	}
+
+	return fmt.Sprintf("%s (%d bytes of %s)", Redacted, len(body), mediaType)
+}
+
+// value replaces the redacted fields of a decoded JSON value
+func (p *RedactionPolicy) value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		// SCIM PATCH operation targeting a redacted attribute
+		if path, ok := v["path"].(string); ok && p.field(path) {
+			if _, ok := v["value"]; ok {
+				v["value"] = Redacted
+			}
+		}
+		for key, value := range v {
+			if p.field(key) {
+				v[key] = Redacted
+			} else {
+				v[key] = p.value(value)
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = p.value(v[i])
+		}
+	}
+
+	return v
+}
+
+// field reports whether the attribute name or path is redacted
+func (p *RedactionPolicy) field(name string) bool {
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	for _, field := range p.Fields {
+		if strings.EqualFold(name, field) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// requestIDHeaders are the headers a request ID is read from, request headers first
+var requestIDHeaders = []string{"X-Request-Id", "X-Correlation-Id"}
+
+func requestID(r *http.Request, resp *http.Response) string {
+	for _, name := range requestIDHeaders {
+		if id := r.Header.Get(name); id != "" {
+			return id
+		}
+	}
+	if resp != nil {
+		for _, name := range requestIDHeaders {
+			if id := resp.Header.Get(name); id != "" {
+				return id
+			}
+		}
+	}
+
+	return ""
+}
+
+// LoggingMiddleware logs every request and response with the method, path, status, latency,
+// and request ID at the Info level, Warn for error responses and Error for failed requests.
+// The headers and bodies are logged at the Debug level after applying the redaction policy.
+// A nil logger uses StdLogger with the standard logger, a nil policy DefaultRedactionPolicy.
+//
+// Example Usage:
+//		logger := slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+//		s, err := cybr_pam_scim.New(
+//			cybr_pam_scim.WithBaseURL(baseURL),
+//			cybr_pam_scim.WithTokenSource(ts),
+//			cybr_pam_scim.WithLogger(logger),
+//			cybr_pam_scim.WithVerbose(true),
+//		)
+//
+func LoggingMiddleware(logger Logger, policy *RedactionPolicy) Middleware {
+	if logger == nil {
+		logger = StdLogger(nil)
+	}
+	if policy == nil {
+		policy = &DefaultRedactionPolicy
+	}
+
+	return func(next Handler) Handler {
+		return func(r *http.Request) (*http.Response, error) {
+			body, err := readRequestBody(r)
+			if err != nil {
+				return nil, err
+			}
+			logger.Debug("scim request",
+				"method", r.Method,
+				"url", r.URL.Redacted(),
+				"headers", policy.header(r.Header),
+				"body", policy.body(r.URL.Path, r.Header.Get("Content-Type"), body),
+			)
+
+			start := time.Now()
+			resp, err := next(r)
+			latency := time.Since(start)
+			if err != nil {
+				logger.Error("scim request failed",
+					"method", r.Method,
+					"path", r.URL.Path,
+					"latency", latency,
+					"error", err,
+				)
+				return resp, err
+			}
+
+			attrs := []interface{}{
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", resp.StatusCode,
+				"latency", latency,
+			}
+			if id := requestID(r, resp); id != "" {
+				attrs = append(attrs, "request_id", id)
+			}
+			if resp.StatusCode >= http.StatusBadRequest {
+				logger.Warn("scim response", attrs...)
+			} else {
+				logger.Info("scim response", attrs...)
+			}
+
+			body, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read response body: %w", err)
+			}
+			resp.Body = io.NopCloser(bytes.NewReader(body))
+			logger.Debug("scim response body",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"headers", policy.header(resp.Header),
+				"body", policy.body(r.URL.Path, resp.Header.Get("Content-Type"), body),
+			)
+
+			return resp, nil
+		}
+	}
+}
+
+// readRequestBody returns the body of the request and leaves the request with an unread body
+func readRequestBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	if r.GetBody != nil {
+		body, err := r.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		defer body.Close()
+		return io.ReadAll(body)
+	}
+
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	return body, nil
+}
@@ -0,0 +1,56 @@
+package manifest
+
+import (
+	"strings"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
+)
+
+// DefaultBundles are the rights bundles available in every manifest. Manifests may declare
+// additional bundles, which may reference these. The bundles authorize requests of the first
+// confirmation level at most, RequestsAuthorizationLevel2 must be granted explicitly.
+var DefaultBundles = map[string][]string{
+	"viewer":   types.ViewerRights.Names(),
+	"user":     types.EndUserRights.Names(),
+	"auditor":  types.AuditorRights.Names(),
+	"approver": types.ApproverRights.Names(),
+	"manager":  types.SafeManagerRights.Names(),
+}
+
+// right returns an individual right
+func right(name string) (types.Rights, bool) {
+	r, err := types.ParseRights(name)
+	return r, err == nil
+}
+
+// expand resolves bundle names and individual rights to the set of individual rights. It
+// returns the first name which is neither a bundle nor a right, or the first bundle which
+// includes itself.
+func expand(names []string, bundles map[string][]string, visiting map[string]bool) (types.Rights, string) {
+	var granted types.Rights
+	for _, name := range names {
+		if r, ok := right(name); ok {
+			granted |= r
+			continue
+		}
+		bundle, ok := bundles[strings.ToLower(name)]
+		if !ok || visiting[strings.ToLower(name)] {
+			return 0, name
+		}
+		visiting[strings.ToLower(name)] = true
+		rights, unknown := expand(bundle, bundles, visiting)
+		delete(visiting, strings.ToLower(name))
+		if unknown != "" {
+			return 0, unknown
+		}
+		granted |= rights
+	}
+
+	return granted, ""
+}
+
+// isBundle reports whether the name is a bundle
+func isBundle(bundles map[string][]string, name string) bool {
+	_, ok := bundles[strings.ToLower(name)]
+	return ok
+}
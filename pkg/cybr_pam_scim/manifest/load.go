@@ -0,0 +1,476 @@
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/reconcile"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
+	"gopkg.in/yaml.v3"
+)
+
+// document is the content of a single manifest file
+type document struct {
+	Version   string                `yaml:"version"`
+	Variables map[string]string     `yaml:"variables"`
+	Include   []string              `yaml:"include"`
+	Bundles   map[string]stringList `yaml:"bundles"`
+	Groups    []Group               `yaml:"groups"`
+	Safes     []Safe                `yaml:"safes"`
+	Prune     []string              `yaml:"prune"`
+}
+
+// file is a parsed manifest file
+type file struct {
+	path string
+	root *yaml.Node
+	doc  document
+}
+
+type loader struct {
+	files   []*file
+	loaded  map[string]bool
+	reading map[string]bool
+	errs    ErrorList
+	vars    map[string]string
+	varsPos map[string]Position
+}
+
+func (l *loader) errorf(pos Position, format string, args ...interface{}) {
+	l.errs = append(l.errs, &Error{Position: pos, Message: fmt.Sprintf(format, args...)})
+}
+
+// Load reads a manifest and the manifests it includes. YAML and JSON files are accepted.
+// Variables provided by the caller take precedence over the variables declared in manifests.
+// Problems are reported as an ErrorList with the file and line of each problem.
+func Load(path string, vars map[string]string) (*Manifest, error) {
+	l := &loader{loaded: map[string]bool{}, reading: map[string]bool{}, vars: map[string]string{}, varsPos: map[string]Position{}}
+	l.read(path, Position{File: path}, true)
+	if len(l.errs) > 0 {
+		return nil, l.errs
+	}
+
+	for _, f := range l.files {
+		l.collectVariables(f)
+	}
+	for name, value := range vars {
+		l.vars[name] = value
+	}
+	for _, f := range l.files {
+		l.substitute(f, f.root)
+		l.checkFields(f, f.root, reflect.TypeOf(document{}))
+	}
+	if len(l.errs) > 0 {
+		return nil, l.errs
+	}
+
+	for _, f := range l.files {
+		l.decode(f)
+	}
+	if len(l.errs) > 0 {
+		return nil, l.errs
+	}
+
+	m := l.merge()
+	if len(l.errs) > 0 {
+		return nil, l.errs
+	}
+
+	return m, nil
+}
+
+// read parses a file and, recursively, the files it includes. A file included by several
+// manifests is read once, a file including itself directly or indirectly is an error.
+func (l *loader) read(path string, from Position, root bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	if l.reading[abs] {
+		l.errorf(from, "include cycle, %s includes itself", path)
+		return
+	}
+	if l.loaded[abs] {
+		return
+	}
+	l.loaded[abs] = true
+	l.reading[abs] = true
+	defer delete(l.reading, abs)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		l.errorf(from, "failed to read manifest: %v", err)
+		return
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		l.yamlError(path, err)
+		return
+	}
+	if len(node.Content) == 0 {
+		l.errorf(Position{File: path}, "manifest is empty")
+		return
+	}
+	f := &file{path: path, root: node.Content[0]}
+	if f.root.Kind != yaml.MappingNode {
+		l.errorf(position(path, f.root), "manifest must be a mapping")
+		return
+	}
+	l.files = append(l.files, f)
+
+	version := value(f.root, "version")
+	switch {
+	case version == nil && root:
+		l.errorf(Position{File: path, Line: 1, Column: 1}, "version is required")
+	case version != nil && version.Value != Version:
+		l.errorf(position(path, version), "unsupported manifest version %q, expected %q", version.Value, Version)
+	}
+
+	include := value(f.root, "include")
+	if include == nil {
+		return
+	}
+	if include.Kind != yaml.SequenceNode {
+		l.errorf(position(path, include), "include must be a list of files")
+		return
+	}
+	for _, pattern := range include.Content {
+		pos := position(path, pattern)
+		matches, err := filepath.Glob(filepath.Join(filepath.Dir(path), pattern.Value))
+		if err != nil {
+			l.errorf(pos, "invalid include pattern %q: %v", pattern.Value, err)
+			continue
+		}
+		if len(matches) == 0 {
+			l.errorf(pos, "include %q does not match any file", pattern.Value)
+			continue
+		}
+		sort.Strings(matches)
+		for _, match := range matches {
+			l.read(match, pos, false)
+		}
+	}
+}
+
+// collectVariables merges the variables of a file, conflicting declarations are reported
+func (l *loader) collectVariables(f *file) {
+	variables := value(f.root, "variables")
+	if variables == nil {
+		return
+	}
+	if variables.Kind != yaml.MappingNode {
+		l.errorf(position(f.path, variables), "variables must be a mapping")
+		return
+	}
+	for i := 0; i+1 < len(variables.Content); i += 2 {
+		name, val := variables.Content[i], variables.Content[i+1]
+		pos := position(f.path, name)
+		if existing, ok := l.vars[name.Value]; ok && existing != val.Value {
+			l.errorf(pos, "variable %q is already declared with a different value at %s", name.Value, l.varsPos[name.Value])
+			continue
+		}
+		l.vars[name.Value] = val.Value
+		l.varsPos[name.Value] = pos
+	}
+}
+
+var variablePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_.-]*)\}`)
+
+// substitute replaces ${name} in every scalar value except the variable declarations
+func (l *loader) substitute(f *file, node *yaml.Node) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node == f.root && node.Content[i].Value == "variables" {
+				continue
+			}
+			l.substitute(f, node.Content[i+1])
+		}
+	case yaml.SequenceNode:
+		for _, child := range node.Content {
+			l.substitute(f, child)
+		}
+	case yaml.ScalarNode:
+		node.Value = variablePattern.ReplaceAllStringFunc(node.Value, func(ref string) string {
+			name := variablePattern.FindStringSubmatch(ref)[1]
+			val, ok := l.vars[name]
+			if !ok {
+				l.errorf(position(f.path, node), "undefined variable %q", name)
+				return ref
+			}
+			return val
+		})
+	}
+}
+
+// checkFields reports mapping keys which do not match a field of the decoded type
+func (l *loader) checkFields(f *file, node *yaml.Node, t reflect.Type) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Struct:
+		fields := map[string]reflect.Type{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if name != "" && name != "-" {
+				fields[name] = field.Type
+			}
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			fieldType, ok := fields[key.Value]
+			if !ok {
+				l.errorf(position(f.path, key), "unknown field %q", key.Value)
+				continue
+			}
+			l.checkFields(f, node.Content[i+1], fieldType)
+		}
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Map:
+		for i := 1; i < len(node.Content); i += 2 {
+			l.checkFields(f, node.Content[i], t.Elem())
+		}
+	case node.Kind == yaml.SequenceNode && t.Kind() == reflect.Slice:
+		for _, child := range node.Content {
+			l.checkFields(f, child, t.Elem())
+		}
+	}
+}
+
+var yamlLinePattern = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// yamlError converts the errors of the yaml package to Errors with the file and line
+func (l *loader) yamlError(path string, err error) {
+	var messages []string
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		messages = typeErr.Errors
+	} else {
+		messages = []string{err.Error()}
+	}
+	for _, message := range messages {
+		pos := Position{File: path}
+		if match := yamlLinePattern.FindStringSubmatch(message); match != nil {
+			fmt.Sscan(match[1], &pos.Line)
+			message = match[2]
+		}
+		l.errs = append(l.errs, &Error{Position: pos, Message: message})
+	}
+}
+
+func (l *loader) decode(f *file) {
+	if err := f.root.Decode(&f.doc); err != nil {
+		l.yamlError(f.path, err)
+		return
+	}
+	for i := range f.doc.Groups {
+		f.doc.Groups[i].Position.File = f.path
+	}
+	for i := range f.doc.Safes {
+		safe := &f.doc.Safes[i]
+		safe.Position.File = f.path
+		for j := range safe.Members {
+			safe.Members[j].Position.File = f.path
+			safe.Members[j].rightsPosition.File = f.path
+		}
+		for j := range safe.Accounts {
+			safe.Accounts[j].Position.File = f.path
+		}
+	}
+}
+
+// merge combines the files into a Manifest, validates it, and expands rights bundles
+func (l *loader) merge() *Manifest {
+	m := &Manifest{Version: Version, Bundles: map[string][]string{}}
+	for name, rights := range DefaultBundles {
+		m.Bundles[name] = rights
+	}
+
+	declared := map[string]Position{}
+	bundles := map[string][]string{}
+	for name, rights := range DefaultBundles {
+		bundles[name] = rights
+	}
+	for _, f := range l.files {
+		bundleNodes := value(f.root, "bundles")
+		for name, rights := range f.doc.Bundles {
+			pos := Position{File: f.path}
+			if bundleNodes != nil {
+				if key := key(bundleNodes, name); key != nil {
+					pos = position(f.path, key)
+				}
+			}
+			key := strings.ToLower(name)
+			if _, ok := DefaultBundles[key]; ok {
+				l.errorf(pos, "bundle %q redefines a default bundle", name)
+				continue
+			}
+			if existing, ok := declared[key]; ok {
+				l.errorf(pos, "bundle %q is already declared at %s", name, existing)
+				continue
+			}
+			if _, ok := right(name); ok {
+				l.errorf(pos, "bundle %q has the name of a right", name)
+				continue
+			}
+			declared[key] = pos
+			bundles[key] = rights
+		}
+	}
+	names := make([]string, 0, len(declared))
+	for key := range declared {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	for _, key := range names {
+		pos := declared[key]
+		rights, unknown := expand(bundles[key], bundles, map[string]bool{key: true})
+		switch {
+		case unknown == "":
+		case strings.EqualFold(unknown, key):
+			l.errorf(pos, "bundle %q includes itself", key)
+			continue
+		case isBundle(bundles, unknown):
+			l.errorf(pos, "bundle %q includes %q, which includes itself", key, unknown)
+			continue
+		default:
+			l.errorf(pos, "bundle %q references an unknown right or bundle %q", key, unknown)
+			continue
+		}
+		m.Bundles[key] = rights.Names()
+	}
+
+	groups := map[string]Position{}
+	safes := map[string]Position{}
+	prune := map[reconcile.Kind]bool{}
+	for _, f := range l.files {
+		for _, group := range f.doc.Groups {
+			if group.Name == "" {
+				l.errorf(group.Position, "group name is required")
+				continue
+			}
+			if existing, ok := groups[strings.ToLower(group.Name)]; ok {
+				l.errorf(group.Position, "group %q is already declared at %s", group.Name, existing)
+				continue
+			}
+			groups[strings.ToLower(group.Name)] = group.Position
+			m.Groups = append(m.Groups, group)
+		}
+
+		for _, safe := range f.doc.Safes {
+			if safe.Name == "" {
+				l.errorf(safe.Position, "safe name is required")
+				continue
+			}
+			if existing, ok := safes[strings.ToLower(safe.Name)]; ok {
+				l.errorf(safe.Position, "safe %q is already declared at %s", safe.Name, existing)
+				continue
+			}
+			safes[strings.ToLower(safe.Name)] = safe.Position
+			l.validateSafe(&safe, bundles)
+			m.Safes = append(m.Safes, safe)
+		}
+
+		pruneNodes := value(f.root, "prune")
+		for i, name := range f.doc.Prune {
+			kind, ok := pruneKinds[strings.ToLower(name)]
+			if !ok {
+				l.errorf(position(f.path, pruneNodes.Content[i]), "unknown prune value %q, accepted values are groups, safes, members, or accounts", name)
+				continue
+			}
+			if !prune[kind] {
+				prune[kind] = true
+				m.Prune = append(m.Prune, kind)
+			}
+		}
+	}
+
+	return m
+}
+
+func (l *loader) validateSafe(safe *Safe, bundles map[string][]string) {
+	members := map[string]Position{}
+	for i := range safe.Members {
+		member := &safe.Members[i]
+		name := member.User
+		switch {
+		case member.User != "" && member.Group != "":
+			l.errorf(member.Position, "member of safe %q must be either a user or a group", safe.Name)
+			continue
+		case member.User == "" && member.Group == "":
+			l.errorf(member.Position, "member of safe %q requires a user or a group", safe.Name)
+			continue
+		case member.Group != "":
+			name = member.Group
+		}
+		if existing, ok := members[strings.ToLower(name)]; ok {
+			l.errorf(member.Position, "%q is already a member of safe %q at %s", name, safe.Name, existing)
+			continue
+		}
+		members[strings.ToLower(name)] = member.Position
+		if len(member.Rights) == 0 {
+			l.errorf(member.Position, "member %q of safe %q requires rights", name, safe.Name)
+			continue
+		}
+		rights, unknown := expand(member.Rights, bundles, map[string]bool{})
+		if unknown != "" {
+			if isBundle(bundles, unknown) {
+				l.errorf(member.rightsPosition, "bundle %q includes itself", unknown)
+			} else {
+				l.errorf(member.rightsPosition, "unknown right or bundle %q", unknown)
+			}
+			continue
+		}
+		if rights.Has(types.RightRequestsAuthorizationLevel1 | types.RightRequestsAuthorizationLevel2) {
+			l.errorf(member.rightsPosition, "rights of member %q of safe %q grant both authorization levels, RequestsAuthorizationLevel1 and RequestsAuthorizationLevel2 are mutually exclusive", name, safe.Name)
+			continue
+		}
+		member.Rights = rights.Names()
+	}
+
+	accounts := map[string]Position{}
+	for _, account := range safe.Accounts {
+		if account.Name == "" {
+			l.errorf(account.Position, "account name is required")
+			continue
+		}
+		if existing, ok := accounts[strings.ToLower(account.Name)]; ok {
+			l.errorf(account.Position, "account %q is already declared in safe %q at %s", account.Name, safe.Name, existing)
+			continue
+		}
+		accounts[strings.ToLower(account.Name)] = account.Position
+	}
+}
+
+// value returns the value of a key of a mapping node
+func value(node *yaml.Node, name string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == name {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+// key returns the key node of a mapping node
+func key(node *yaml.Node, name string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == name {
+			return node.Content[i]
+		}
+	}
+
+	return nil
+}
+
+func position(path string, node *yaml.Node) Position {
+	return Position{File: path, Line: node.Line, Column: node.Column}
+}
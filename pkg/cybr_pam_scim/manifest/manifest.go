@@ -0,0 +1,324 @@
+// Package manifest loads the desired vault access from YAML or JSON manifests and maps it
+// onto a reconcile.Spec. Manifests declare groups and safes with their members and accounts,
+// grant rights through named bundles, and may use variables and include other manifests.
+//
+// Example manifest:
+//		version: 1
+//		variables:
+//		  env: prod
+//		include:
+//		  - teams/*.yaml
+//		bundles:
+//		  operator: [viewer, UseAccounts]
+//		groups:
+//		  - name: App Admins
+//		    members: [john.smith@example.com]
+//		safes:
+//		  - name: App-${env}
+//		    description: Application accounts
+//		    managingCPM: PasswordManager
+//		    retentionDays: 7
+//		    members:
+//		      - group: App Admins
+//		        rights: operator
+//		      - user: auditor@example.com
+//		        rights: [auditor]
+//		    accounts:
+//		      - name: svc-app
+//		        type: password
+//		        properties:
+//		          address: app.example.com
+//		          username: svc-app
+//		prune: [members]
+//
+// Example Usage:
+//		m, err := manifest.Load("vault.yaml", map[string]string{"env": "staging"})
+//		if err != nil {
+//			log.Fatal(err) // vault.yaml:18:17: unknown right or bundle "operatr"
+//		}
+//		plan, err := reconcile.New(s).Plan(context.Background(), m.Spec())
+//
+package manifest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/reconcile"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
+	"gopkg.in/yaml.v3"
+)
+
+// Version is the manifest schema version supported by Load
+const Version = "1"
+
+// Manifest is the merged content of a manifest and the manifests it includes. Variables
+// have been substituted and the rights of safe members have been expanded from their bundles.
+type Manifest struct {
+	Version string
+	Bundles map[string][]string
+	Groups  []Group
+	Safes   []Safe
+	Prune   []reconcile.Kind
+}
+
+// Position is the location of a declaration in a manifest file
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	if p.Column == 0 {
+		return fmt.Sprintf("%s:%d", p.File, p.Line)
+	}
+
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// Group is a group and the userNames of its members
+type Group struct {
+	Name    string   `yaml:"name"`
+	Members []string `yaml:"members"`
+
+	Position Position `yaml:"-"`
+	managed  []string
+}
+
+// Safe is a safe with its members and accounts
+type Safe struct {
+	Name          string    `yaml:"name"`
+	Description   string    `yaml:"description"`
+	ManagingCPM   string    `yaml:"managingCPM"`
+	RetentionDays int       `yaml:"retentionDays"`
+	Members       []Member  `yaml:"members"`
+	Accounts      []Account `yaml:"accounts"`
+
+	Position Position `yaml:"-"`
+	managed  []string
+}
+
+// Member grants rights on a safe to either a user or a group. Rights may list bundle
+// names and individual rights, after loading it holds the expanded individual rights.
+type Member struct {
+	User   string     `yaml:"user"`
+	Group  string     `yaml:"group"`
+	Rights stringList `yaml:"rights"`
+
+	Position       Position `yaml:"-"`
+	rightsPosition Position
+}
+
+// Account is a privileged account stored in a safe
+type Account struct {
+	Name        string            `yaml:"name"`
+	Type        string            `yaml:"type"`
+	Description string            `yaml:"description"`
+	Folder      string            `yaml:"folder"`
+	Properties  map[string]string `yaml:"properties"`
+
+	Position Position `yaml:"-"`
+	managed  []string
+}
+
+// The attributes managed by the fields of groups, safes, and accounts. Fields present in a
+// manifest are applied even when they are empty, e.g. to clear a description.
+var (
+	groupAttributes = map[string]string{"members": "members"}
+	safeAttributes  = map[string]string{
+		"description":   "description",
+		"managingCPM":   "urn:ietf:params:scim:schemas:cyberark:1.0:Safe.ManagingCPM",
+		"retentionDays": "urn:ietf:params:scim:schemas:cyberark:1.0:Safe.NumberOfDaysRetention",
+	}
+	accountAttributes = map[string]string{
+		"description": "description",
+		"folder":      "urn:ietf:params:scim:schemas:cyberark:1.0:PrivilegedData.folder",
+	}
+)
+
+// managedAttributes returns the attributes managed by the fields present in a mapping node
+func managedAttributes(node *yaml.Node, attributes map[string]string) []string {
+	var managed []string
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if attribute, ok := attributes[node.Content[i].Value]; ok {
+			managed = append(managed, attribute)
+		}
+	}
+
+	return managed
+}
+
+// stringList accepts a single string or a list of strings
+type stringList []string
+
+func (l *stringList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*l = stringList{node.Value}
+		return nil
+	}
+	var values []string
+	if err := node.Decode(&values); err != nil {
+		return err
+	}
+	*l = values
+
+	return nil
+}
+
+func (g *Group) UnmarshalYAML(node *yaml.Node) error {
+	type plain Group
+	if err := node.Decode((*plain)(g)); err != nil {
+		return err
+	}
+	g.Position = Position{Line: node.Line, Column: node.Column}
+	g.managed = managedAttributes(node, groupAttributes)
+
+	return nil
+}
+
+func (s *Safe) UnmarshalYAML(node *yaml.Node) error {
+	type plain Safe
+	if err := node.Decode((*plain)(s)); err != nil {
+		return err
+	}
+	s.Position = Position{Line: node.Line, Column: node.Column}
+	s.managed = managedAttributes(node, safeAttributes)
+
+	return nil
+}
+
+func (m *Member) UnmarshalYAML(node *yaml.Node) error {
+	type plain Member
+	if err := node.Decode((*plain)(m)); err != nil {
+		return err
+	}
+	m.Position = Position{Line: node.Line, Column: node.Column}
+	m.rightsPosition = m.Position
+	if rights := value(node, "rights"); rights != nil {
+		m.rightsPosition = Position{Line: rights.Line, Column: rights.Column}
+	}
+
+	return nil
+}
+
+func (a *Account) UnmarshalYAML(node *yaml.Node) error {
+	type plain Account
+	if err := node.Decode((*plain)(a)); err != nil {
+		return err
+	}
+	a.Position = Position{Line: node.Line, Column: node.Column}
+	a.managed = managedAttributes(node, accountAttributes)
+
+	return nil
+}
+
+// pruneKinds maps the values of the prune list to the kinds of resources they delete
+var pruneKinds = map[string]reconcile.Kind{
+	"groups":   reconcile.KindGroup,
+	"safes":    reconcile.KindSafe,
+	"members":  reconcile.KindSafePermission,
+	"accounts": reconcile.KindPrivilegedData,
+}
+
+// Spec maps the manifest onto a reconcile.Spec. The fields present in the manifest are managed
+// even when they are empty.
+func (m *Manifest) Spec() *reconcile.Spec {
+	spec := &reconcile.Spec{Prune: m.Prune, Managed: map[reconcile.Kind]map[string][]string{}}
+	manage := func(kind reconcile.Kind, key string, attributes []string) {
+		if len(attributes) == 0 {
+			return
+		}
+		if spec.Managed[kind] == nil {
+			spec.Managed[kind] = map[string][]string{}
+		}
+		spec.Managed[kind][key] = attributes
+	}
+	for _, group := range m.Groups {
+		manage(reconcile.KindGroup, group.Name, group.managed)
+		g := types.Group{DisplayName: group.Name}
+		for _, member := range group.Members {
+			g.Members = append(g.Members, types.Members{Display: member})
+		}
+		spec.Groups = append(spec.Groups, g)
+	}
+
+	for _, safe := range m.Safes {
+		manage(reconcile.KindSafe, safe.Name, safe.managed)
+		spec.Safes = append(spec.Safes, types.Container{
+			Name:        safe.Name,
+			Description: safe.Description,
+			UrnIetfParamsScimSchemasCyberark10Safe: types.UrnIetfParamsScimSchemasCyberark10Safe{
+				NumberOfDaysRetention: safe.RetentionDays,
+				ManagingCPM:           safe.ManagingCPM,
+			},
+		})
+		for _, member := range safe.Members {
+			// The rights have been expanded and validated by Load
+			rights, _ := types.ParseRights(member.Rights...)
+			permission := types.ContainerPermission{
+				Container: types.ContainerRef{Name: safe.Name},
+				Rights:    rights,
+			}
+			if member.User != "" {
+				permission.User = types.UserRef{Display: member.User}
+			} else {
+				permission.Group = types.GroupRef{Display: member.Group}
+			}
+			spec.SafePermissions = append(spec.SafePermissions, permission)
+		}
+		for _, account := range safe.Accounts {
+			manage(reconcile.KindPrivilegedData, safe.Name+"/"+account.Name, account.managed)
+			privilegedData := types.PrivilegedData{
+				Name:        account.Name,
+				Type:        account.Type,
+				Description: account.Description,
+				UrnIetfParamsScimSchemasCyberark10PrivilegedData: types.UrnIetfParamsScimSchemasCyberark10PrivilegedData{
+					Safe:   safe.Name,
+					Folder: account.Folder,
+				},
+			}
+			keys := make([]string, 0, len(account.Properties))
+			for key := range account.Properties {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				privilegedData.UrnIetfParamsScimSchemasCyberark10PrivilegedData.Properties = append(
+					privilegedData.UrnIetfParamsScimSchemasCyberark10PrivilegedData.Properties,
+					types.Properties{Key: key, Value: account.Properties[key]},
+				)
+			}
+			spec.PrivilegedData = append(spec.PrivilegedData, privilegedData)
+		}
+	}
+
+	return spec
+}
+
+// Error is a problem found in a manifest
+type Error struct {
+	Position Position
+	Message  string
+}
+
+func (e *Error) Error() string {
+	if e.Position.Line == 0 {
+		return fmt.Sprintf("%s: %s", e.Position.File, e.Message)
+	}
+
+	return fmt.Sprintf("%s: %s", e.Position, e.Message)
+}
+
+// ErrorList is returned by Load when a manifest has problems, it lists every problem found
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	messages := make([]string, 0, len(l))
+	for _, err := range l {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "\n")
+}
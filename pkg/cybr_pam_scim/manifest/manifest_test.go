@@ -0,0 +1,199 @@
+package manifest_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/manifest"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/reconcile"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
+)
+
+func TestSpecManagedFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.yaml")
+	err := os.WriteFile(path, []byte(`version: 1
+groups:
+  - name: App Admins
+    members: []
+  - name: App Users
+safes:
+  - name: AppSafe
+    description: ""
+    retentionDays: 7
+    accounts:
+      - name: svc-app
+        folder: ""
+  - name: OtherSafe
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := manifest.Load(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[reconcile.Kind]map[string][]string{
+		reconcile.KindGroup: {"App Admins": {"members"}},
+		reconcile.KindSafe: {"AppSafe": {
+			"description",
+			"urn:ietf:params:scim:schemas:cyberark:1.0:Safe.NumberOfDaysRetention",
+		}},
+		reconcile.KindPrivilegedData: {"AppSafe/svc-app": {"urn:ietf:params:scim:schemas:cyberark:1.0:PrivilegedData.folder"}},
+	}
+	if got := m.Spec().Managed; !reflect.DeepEqual(got, want) {
+		t.Errorf("managed = %v, want %v", got, want)
+	}
+}
+
+func TestAuthorizationLevels(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.yaml")
+	err := os.WriteFile(path, []byte(`version: 1
+safes:
+  - name: AppSafe
+    members:
+      - group: App Admins
+        rights: manager
+      - user: approver@example.com
+        rights: [manager, RequestsAuthorizationLevel2]
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = manifest.Load(path, nil)
+	var errs manifest.ErrorList
+	if !errors.As(err, &errs) || len(errs) != 1 || !strings.Contains(errs[0].Message, "mutually exclusive") {
+		t.Fatalf("error = %v, want the approver granted both authorization levels", err)
+	}
+	if errs[0].Position.Line != 8 {
+		t.Errorf("line = %d, want the rights of the approver", errs[0].Position.Line)
+	}
+
+	err = os.WriteFile(path, []byte(`version: 1
+safes:
+  - name: AppSafe
+    members:
+      - group: App Admins
+        rights: manager
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := manifest.Load(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if permissions := m.Spec().SafePermissions; len(permissions) != 1 || permissions[0].Rights != types.SafeManagerRights {
+		t.Errorf("permissions = %+v, want the manager bundle", permissions)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		dir  string
+		want []string
+	}{
+		{"unknown_field", []string{
+			`testdata/unknown_field/vault.yaml:4:5: unknown field "member"`,
+			`testdata/unknown_field/vault.yaml:7:5: unknown field "retention"`,
+			`testdata/unknown_field/vault.yaml:10:9: unknown field "password"`,
+			`testdata/unknown_field/vault.yaml:11:1: unknown field "owner"`,
+		}},
+		{"undefined_variable", []string{
+			`testdata/undefined_variable/vault.yaml:6:11: undefined variable "region"`,
+			`testdata/undefined_variable/vault.yaml:9:18: undefined variable "zone"`,
+		}},
+		{"conflicting_variables", []string{
+			`testdata/conflicting_variables/common.yaml:3:3: variable "env" is already declared with a different value at testdata/conflicting_variables/vault.yaml:4:3`,
+		}},
+		{"include_cycle", []string{
+			`testdata/include_cycle/common.yaml:2:5: include cycle, testdata/include_cycle/vault.yaml includes itself`,
+		}},
+		{"include_missing", []string{
+			`testdata/include_missing/vault.yaml:2:11: include "groups/*.yaml" does not match any file`,
+			`testdata/include_missing/vault.yaml:2:26: include "safes.yaml" does not match any file`,
+		}},
+		{"version", []string{
+			`testdata/version/vault.yaml:1:10: unsupported manifest version "2", expected "1"`,
+			`testdata/version/common.yaml:1:10: unsupported manifest version "1.1", expected "1"`,
+		}},
+		{"version_missing", []string{
+			`testdata/version_missing/vault.yaml:1:1: version is required`,
+		}},
+		{"duplicates", []string{
+			`testdata/duplicates/vault.yaml:10:9: "app admins" is already a member of safe "AppSafe" at testdata/duplicates/vault.yaml:8:9`,
+			`testdata/duplicates/vault.yaml:14:9: account "SVC-APP" is already declared in safe "AppSafe" at testdata/duplicates/vault.yaml:13:9`,
+			`testdata/duplicates/other.yaml:2:5: group "app admins" is already declared at testdata/duplicates/vault.yaml:4:5`,
+			`testdata/duplicates/other.yaml:4:5: safe "appsafe" is already declared at testdata/duplicates/vault.yaml:6:5`,
+		}},
+		{"rights", []string{
+			`testdata/rights/vault.yaml:4:3: bundle "loop-a" includes itself`,
+			`testdata/rights/vault.yaml:5:3: bundle "loop-b" includes itself`,
+			`testdata/rights/vault.yaml:6:3: bundle "loop-user" includes "loop-a", which includes itself`,
+			`testdata/rights/vault.yaml:7:3: bundle "viewer" redefines a default bundle`,
+			`testdata/rights/vault.yaml:8:3: bundle "ListAccounts" has the name of a right`,
+			`testdata/rights/vault.yaml:9:3: bundle "broken" references an unknown right or bundle "RetreiveAccounts"`,
+			`testdata/rights/vault.yaml:16:17: unknown right or bundle "Retrieve"`,
+			`testdata/rights/vault.yaml:18:17: bundle "loop-b" includes itself`,
+		}},
+		{"prune", []string{
+			`testdata/prune/vault.yaml:2:17: unknown prune value "users", accepted values are groups, safes, members, or accounts`,
+		}},
+	}
+	for _, tt := range tests {
+		_, err := manifest.Load(filepath.Join("testdata", tt.dir, "vault.yaml"), nil)
+		var errs manifest.ErrorList
+		if !errors.As(err, &errs) {
+			t.Errorf("%s: error = %v, want an ErrorList", tt.dir, err)
+			continue
+		}
+		got := make([]string, 0, len(errs))
+		for _, err := range errs {
+			got = append(got, filepath.ToSlash(err.Error()))
+		}
+		// Bundles are declared in a map, their errors are not ordered
+		sort.Strings(got)
+		want := append([]string(nil), tt.want...)
+		sort.Strings(want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: errors =\n%s\nwant:\n%s", tt.dir, strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	}
+}
+
+func TestLoadVariables(t *testing.T) {
+	path := filepath.Join("testdata", "variables", "vault.yaml")
+	tests := []struct {
+		vars   map[string]string
+		safe   string
+		groups []string
+		owner  string
+	}{
+		{nil, "App-dev", []string{"App-dev-Admins", "App-dev-Users"}, "Platform accounts"},
+		{map[string]string{"env": "prod"}, "App-prod", []string{"App-prod-Admins", "App-prod-Users"}, "Platform accounts"},
+		{map[string]string{"owner": "Security"}, "App-dev", []string{"App-dev-Admins", "App-dev-Users"}, "Security accounts"},
+	}
+	for _, tt := range tests {
+		m, err := manifest.Load(path, tt.vars)
+		if err != nil {
+			t.Errorf("%v: %v", tt.vars, err)
+			continue
+		}
+		var groups []string
+		for _, group := range m.Groups {
+			groups = append(groups, group.Name)
+		}
+		if !reflect.DeepEqual(groups, tt.groups) {
+			t.Errorf("%v: groups = %v, want %v", tt.vars, groups, tt.groups)
+		}
+		if len(m.Safes) != 1 || m.Safes[0].Name != tt.safe || m.Safes[0].Description != tt.owner {
+			t.Errorf("%v: safes = %+v, want %s with the description %q", tt.vars, m.Safes, tt.safe, tt.owner)
+		}
+	}
+}
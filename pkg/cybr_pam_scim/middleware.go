@@ -0,0 +1,180 @@
+package cybr_pam_scim
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// Handler sends a request and returns its response, the innermost Handler of a Client is
+// the Do function of its http.Client
+type Handler func(r *http.Request) (*http.Response, error)
+
+// Middleware wraps the Handler sending a request, e.g. to add headers, record metrics, or
+// inject faults. A Middleware may modify the request before calling next, inspect or replace
+// the response, or answer without calling next at all.
+//
+// Example Usage:
+//		correlation := func(next cybr_pam_scim.Handler) cybr_pam_scim.Handler {
+//			return func(r *http.Request) (*http.Response, error) {
+//				r.Header.Set("X-Correlation-Id", uuid.NewString())
+//				return next(r)
+//			}
+//		}
+//		s, err := cybr_pam_scim.New(cybr_pam_scim.WithBaseURL(baseURL), cybr_pam_scim.WithMiddleware(correlation))
+//
+type Middleware func(next Handler) Handler
+
+// chain wraps the handler with the middlewares, the first middleware is the outermost
+func chain(handler Handler, middlewares ...Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		if middlewares[i] != nil {
+			handler = middlewares[i](handler)
+		}
+	}
+
+	return handler
+}
+
+// RetryMiddleware retries throttled (429) and failed (5xx) requests according to the policy.
+// Retries stop early when the policy budget or the context deadline would be exceeded.
+// A nil policy disables retries.
+func RetryMiddleware(policy *RetryPolicy) Middleware {
+	return func(next Handler) Handler {
+		if policy == nil {
+			return next
+		}
+		j := newJitter()
+
+		return func(r *http.Request) (*http.Response, error) {
+			ctx := r.Context()
+			var waited time.Duration
+
+			for attempt := 1; ; attempt++ {
+				// Every attempt sends a clone with a fresh body, the request of the caller is left untouched
+				attemptRequest, err := cloneRequest(r)
+				if err != nil {
+					return nil, err
+				}
+				resp, err := next(attemptRequest)
+				if attempt >= policy.MaxAttempts || !policy.retryable(r) || !retryableResponse(ctx, resp, err) {
+					return resp, err
+				}
+				// The request body has already been consumed and cannot be sent again
+				if r.Body != nil && r.GetBody == nil {
+					return resp, err
+				}
+
+				delay := policy.backoff(attempt, resp, j)
+				if policy.Budget > 0 && waited+delay > policy.Budget {
+					return resp, err
+				}
+				if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+					return resp, err
+				}
+
+				if resp != nil {
+					io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+				}
+				if err := sleep(ctx, delay); err != nil {
+					return nil, err
+				}
+				waited += delay
+			}
+		}
+	}
+}
+
+// cloneRequest returns a clone of the request with a new body when the body can be replayed
+func cloneRequest(r *http.Request) (*http.Request, error) {
+	clone := r.Clone(r.Context())
+	if r.GetBody != nil {
+		body, err := r.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("failed to reset request body: %w", err)
+		}
+		clone.Body = body
+	}
+
+	return clone, nil
+}
+
+// AuthMiddleware authenticates requests with the Oauth2 tokens of the provided oauth2.TokenSource.
+// A new token is requested shortly before the current token expires, and once more if the SCIM
+// API rejects a request with a 401 status code, in which case the request is sent again if its
+// body can be replayed. Every Handler wrapped by the returned Middleware shares the cached token.
+func AuthMiddleware(tokenSource oauth2.TokenSource) Middleware {
+	source := newRefreshingTokenSource(tokenSource)
+
+	return func(next Handler) Handler {
+		return func(r *http.Request) (*http.Response, error) {
+			token, err := source.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			resp, err := next(authorize(r, token))
+			if err != nil || resp.StatusCode != http.StatusUnauthorized {
+				return resp, err
+			}
+
+			// The token was rejected before its expiry (e.g. revoked), force a refresh and
+			// retry the request once if its body can be replayed.
+			if r.Body != nil && r.GetBody == nil {
+				return resp, nil
+			}
+			source.invalidate(token)
+			refreshed, err := source.Token()
+			if err != nil || refreshed.AccessToken == token.AccessToken {
+				return resp, nil
+			}
+
+			retry := authorize(r, refreshed)
+			if r.GetBody != nil {
+				body, err := r.GetBody()
+				if err != nil {
+					return resp, nil
+				}
+				retry.Body = body
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+
+			return next(retry)
+		}
+	}
+}
+
+func authorize(r *http.Request, token *oauth2.Token) *http.Request {
+	authorized := r.Clone(r.Context())
+	authorized.Header.Set("Authorization", "Bearer "+token.AccessToken)
+
+	return authorized
+}
+
+// Transport returns an http.RoundTripper sending requests through the middlewares before the
+// base http.RoundTripper, http.DefaultTransport when nil. It applies middlewares to clients
+// created outside of a Service, e.g. the http.Client requesting Oauth2 tokens.
+//
+// Example Usage:
+//		logging := cybr_pam_scim.Transport(nil, cybr_pam_scim.LoggingMiddleware(logger, nil))
+//		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: logging})
+//		ts := cybr_pam_scim.OauthCredClientTokenSourceContext(ctx, clientId, clientSecret, appId, url)
+//
+func Transport(base http.RoundTripper, middlewares ...Middleware) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return roundTripperFunc(chain(base.RoundTrip, middlewares...))
+}
+
+type roundTripperFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
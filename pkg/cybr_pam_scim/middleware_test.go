@@ -0,0 +1,182 @@
+package cybr_pam_scim
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRetryMiddlewareClonesRequest(t *testing.T) {
+	var bodies []string
+	var requests []*http.Request
+	next := func(r *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		requests = append(requests, r)
+		status := http.StatusServiceUnavailable
+		if len(bodies) == 3 {
+			status = http.StatusOK
+		}
+		return &http.Response{StatusCode: status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}
+
+	policy := &RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	r, _ := http.NewRequest(http.MethodPut, "https://example.com/scim/v2/Users/8", bytes.NewReader([]byte(`{"userName":"john"}`)))
+	resp, err := RetryMiddleware(policy)(next)(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	if len(bodies) != 3 {
+		t.Fatalf("attempts = %d, want 3", len(bodies))
+	}
+	for i, body := range bodies {
+		if body != `{"userName":"john"}` {
+			t.Errorf("attempt %d body = %q", i+1, body)
+		}
+		if requests[i] == r {
+			t.Errorf("attempt %d sent the request of the caller instead of a clone", i+1)
+		}
+	}
+	// The body of the caller has not been read by any attempt
+	body, _ := io.ReadAll(r.Body)
+	if string(body) != `{"userName":"john"}` {
+		t.Errorf("body of the caller = %q, want it unread", body)
+	}
+}
+
+func TestRetryBackoffJitter(t *testing.T) {
+	policy := &RetryPolicy{MinBackoff: time.Second, MaxBackoff: 4 * time.Second}
+	delays := func(j *jitter) []time.Duration {
+		var result []time.Duration
+		for i := 0; i < 20; i++ {
+			result = append(result, policy.backoff(3, nil, j))
+		}
+		return result
+	}
+
+	first, second := delays(newJitter()), delays(newJitter())
+	same := true
+	for i := range first {
+		if first[i] < 2*time.Second || first[i] > 4*time.Second {
+			t.Errorf("delay = %v, want between 2s and 4s", first[i])
+		}
+		same = same && first[i] == second[i]
+	}
+	if same {
+		t.Error("policies created separately waited for the same delays, want their own seed")
+	}
+
+	resp := &http.Response{Header: http.Header{"Retry-After": {"7"}}}
+	if delay := policy.backoff(1, resp, newJitter()); delay != 7*time.Second {
+		t.Errorf("delay = %v, want the Retry-After delay", delay)
+	}
+}
+
+func TestRetryBackoffConcurrent(t *testing.T) {
+	policy := &RetryPolicy{MinBackoff: time.Millisecond, MaxBackoff: time.Second}
+	j := newJitter()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for retry := 1; retry < 100; retry++ {
+				policy.backoff(retry, nil, j)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRedactionPolicyBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		want        string
+	}{
+		{"json", "/scim/v2/Users", "application/scim+json", `{"userName":"john","password":"secret"}`, `{"password":"[REDACTED]","userName":"john"}`},
+		{"json without content type", "/scim/v2/Users", "", `{"password":"secret"}`, `{"password":"[REDACTED]"}`},
+		{"patch operation", "/scim/v2/Users/8", "application/json", `{"Operations":[{"op":"replace","path":"password","value":"secret"}]}`, `{"Operations":[{"op":"replace","path":"password","value":"[REDACTED]"}]}`},
+		{"form", "/scim/v2/Users", "application/x-www-form-urlencoded", "grant_type=client_credentials&client_secret=secret&Password=p", "Password=%5BREDACTED%5D&client_secret=%5BREDACTED%5D&grant_type=client_credentials"},
+		{"form with parameters", "/scim/v2/Users", "Application/X-WWW-Form-Urlencoded; charset=utf-8", "password=secret", "password=%5BREDACTED%5D"},
+		{"invalid form", "/scim/v2/Users", "application/x-www-form-urlencoded", "password=%zz", "[REDACTED] (12 bytes of application/x-www-form-urlencoded)"},
+		{"html error page", "/scim/v2/Users", "text/html", "<p>invalid password secret</p>", "[REDACTED] (30 bytes of text/html)"},
+		{"form without content type", "/scim/v2/Users", "", "password=secret", "[REDACTED] (15 bytes of unknown content type)"},
+		{"invalid json", "/scim/v2/Users", "application/json", `{"password":"secret"`, "[REDACTED] (20 bytes of application/json)"},
+		{"concatenated json", "/scim/v2/Users", "application/json", `{} password=secret`, "[REDACTED] (18 bytes of application/json)"},
+		{"redacted path", "/oauth2/token", "application/json", `{"token_type":"Bearer"}`, Redacted},
+		{"redacted path text", "/oauth2/token", "text/plain", "secret", Redacted},
+		{"empty body", "/scim/v2/Users", "text/plain", "", ""},
+	}
+	for _, tt := range tests {
+		if got := DefaultRedactionPolicy.body(tt.path, tt.contentType, []byte(tt.body)); got != tt.want {
+			t.Errorf("%s: body = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	// An empty policy logs everything
+	if got := (&RedactionPolicy{}).body("/oauth2/token", "text/html", []byte("secret")); got != "secret" {
+		t.Errorf("empty policy body = %s, want it unchanged", got)
+	}
+}
+
+// recordingLogger keeps the values logged for a key
+type recordingLogger struct {
+	key    string
+	values []string
+}
+
+func (l *recordingLogger) record(args []interface{}) {
+	for i := 0; i+1 < len(args); i += 2 {
+		if args[i] == l.key {
+			l.values = append(l.values, fmt.Sprint(args[i+1]))
+		}
+	}
+}
+
+func (l *recordingLogger) Debug(msg string, args ...interface{}) { l.record(args) }
+func (l *recordingLogger) Info(msg string, args ...interface{})  { l.record(args) }
+func (l *recordingLogger) Warn(msg string, args ...interface{})  { l.record(args) }
+func (l *recordingLogger) Error(msg string, args ...interface{}) { l.record(args) }
+
+func TestLoggingMiddlewareRedactsBodies(t *testing.T) {
+	next := func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusUnauthorized,
+			Header:     http.Header{"Content-Type": {"text/html"}},
+			Body:       io.NopCloser(strings.NewReader("<p>client_secret=secret was rejected</p>")),
+		}, nil
+	}
+	logger := &recordingLogger{key: "body"}
+	r, _ := http.NewRequest(http.MethodPost, "https://example.com/scim/v2/Users", strings.NewReader("client_id=app&client_secret=secret"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := LoggingMiddleware(logger, nil)(next)(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, body := range logger.values {
+		if strings.Contains(body, "secret=secret") || strings.Contains(body, "secret was") {
+			t.Errorf("logged body %s, want the secret redacted", body)
+		}
+	}
+	if len(logger.values) != 2 || logger.values[0] != "client_id=app&client_secret=%5BREDACTED%5D" {
+		t.Errorf("logged bodies = %v, want the redacted form and the dropped response", logger.values)
+	}
+	// The caller still receives the response body
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "rejected") {
+		t.Errorf("response body = %s, want it unchanged", body)
+	}
+}
@@ -0,0 +1,194 @@
+package cybr_pam_scim_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/scimtest"
+)
+
+// countingTransport counts the requests it sends
+type countingTransport struct {
+	base http.RoundTripper
+	n    int32
+}
+
+func (t *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.n, 1)
+	return t.base.RoundTrip(r)
+}
+
+func (t *countingTransport) count() int {
+	return int(atomic.LoadInt32(&t.n))
+}
+
+func TestNewInvalid(t *testing.T) {
+	tests := []struct {
+		opts []cybr_pam_scim.ServiceOption
+		want string
+	}{
+		{nil, "a base URL is required, use WithBaseURL"},
+		{[]cybr_pam_scim.ServiceOption{cybr_pam_scim.WithBaseURL("/")}, "a base URL is required, use WithBaseURL"},
+		{[]cybr_pam_scim.ServiceOption{cybr_pam_scim.WithBaseURL("example.my.idaptive.app/scim/v2")}, `invalid base URL provided, "example.my.idaptive.app/scim/v2" is not an absolute http or https URL`},
+		{[]cybr_pam_scim.ServiceOption{cybr_pam_scim.WithBaseURL("/scim/v2")}, `invalid base URL provided, "/scim/v2" is not an absolute http or https URL`},
+		{[]cybr_pam_scim.ServiceOption{cybr_pam_scim.WithBaseURL("ftp://example.my.idaptive.app/scim/v2")}, `invalid base URL provided, "ftp://example.my.idaptive.app/scim/v2" is not an absolute http or https URL`},
+		{[]cybr_pam_scim.ServiceOption{cybr_pam_scim.WithBaseURL("https:///scim/v2")}, `invalid base URL provided, "https:///scim/v2" is not an absolute http or https URL`},
+		{[]cybr_pam_scim.ServiceOption{cybr_pam_scim.WithBaseURL("https://[::1/scim/v2")}, "invalid base URL provided: parse "},
+		{[]cybr_pam_scim.ServiceOption{cybr_pam_scim.WithBaseURL("https://example.my.idaptive.app/scim/v2"), cybr_pam_scim.WithTimeout(-time.Second)}, "invalid timeout provided, -1s is negative"},
+	}
+	for _, tt := range tests {
+		s, err := cybr_pam_scim.New(tt.opts...)
+		if s != nil || err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+			t.Errorf("New = %v, %v, want error %s", s, err, tt.want)
+		}
+	}
+}
+
+func TestNewBaseURL(t *testing.T) {
+	srv := scimtest.NewServer()
+	defer srv.Close()
+
+	// The trailing slash is removed
+	s, err := cybr_pam_scim.New(cybr_pam_scim.WithBaseURL(srv.URL+"/"), cybr_pam_scim.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetUsers(context.Background()); err != nil {
+		t.Errorf("GetUsers: %v", err)
+	}
+}
+
+func TestNewTransportPrecedence(t *testing.T) {
+	srv := scimtest.NewServer()
+	defer srv.Close()
+	ctx := context.Background()
+
+	httpClient := srv.Client()
+	client := &countingTransport{base: httpClient.Transport}
+	httpClient.Transport = client
+	httpClient.Timeout = time.Minute
+	s, err := cybr_pam_scim.New(cybr_pam_scim.WithBaseURL(srv.URL), cybr_pam_scim.WithHTTPClient(httpClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetUsers(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if client.count() == 0 {
+		t.Error("the Transport of the http.Client was not used")
+	}
+
+	// WithTransport wins whatever the order of the options, the http.Client is not modified
+	for _, order := range []string{"client first", "transport first"} {
+		transport := &countingTransport{base: srv.Client().Transport}
+		opts := []cybr_pam_scim.ServiceOption{cybr_pam_scim.WithHTTPClient(httpClient), cybr_pam_scim.WithTransport(transport), cybr_pam_scim.WithTimeout(time.Second)}
+		if order == "transport first" {
+			opts[0], opts[1] = opts[1], opts[0]
+		}
+		s, err := cybr_pam_scim.New(append(opts, cybr_pam_scim.WithBaseURL(srv.URL))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		before := client.count()
+		if _, err := s.GetUsers(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if transport.count() == 0 || client.count() != before {
+			t.Errorf("%s: requests sent with WithTransport = %d and the http.Client = %d, want only WithTransport", order, transport.count(), client.count()-before)
+		}
+		if httpClient.Transport != client || httpClient.Timeout != time.Minute {
+			t.Errorf("%s: the http.Client was modified", order)
+		}
+	}
+}
+
+func TestNewTimeout(t *testing.T) {
+	srv := scimtest.NewServer()
+	defer srv.Close()
+	srv.InjectFault(scimtest.Fault{Method: http.MethodGet, Path: "/Users", Latency: 200 * time.Millisecond})
+	ctx := context.Background()
+
+	s, err := cybr_pam_scim.New(
+		cybr_pam_scim.WithBaseURL(srv.URL),
+		cybr_pam_scim.WithHTTPClient(srv.Client()),
+		cybr_pam_scim.WithTimeout(50*time.Millisecond),
+		cybr_pam_scim.WithRetryPolicy(nil),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var netErr net.Error
+	if _, err := s.GetUsers(ctx); !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("error = %v, want a timeout", err)
+	}
+
+	// A timeout longer than the latency
+	s, err = cybr_pam_scim.New(
+		cybr_pam_scim.WithBaseURL(srv.URL),
+		cybr_pam_scim.WithHTTPClient(srv.Client()),
+		cybr_pam_scim.WithTimeout(5*time.Second),
+		cybr_pam_scim.WithRetryPolicy(nil),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetUsers(ctx); err != nil {
+		t.Errorf("GetUsers: %v", err)
+	}
+}
+
+func TestNewRetryPolicy(t *testing.T) {
+	srv := scimtest.NewServer()
+	defer srv.Close()
+	ctx := context.Background()
+	defaults := cybr_pam_scim.DefaultRetryPolicy
+	t.Cleanup(func() { cybr_pam_scim.DefaultRetryPolicy = defaults })
+	cybr_pam_scim.DefaultRetryPolicy.MinBackoff = time.Millisecond
+
+	newService := func(opts ...cybr_pam_scim.ServiceOption) (*cybr_pam_scim.Service, *countingTransport) {
+		transport := &countingTransport{base: srv.Client().Transport}
+		s, err := cybr_pam_scim.New(append([]cybr_pam_scim.ServiceOption{cybr_pam_scim.WithBaseURL(srv.URL), cybr_pam_scim.WithTransport(transport)}, opts...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s, transport
+	}
+	unavailable := scimtest.Fault{Method: http.MethodGet, Path: "/Users", Status: http.StatusServiceUnavailable, Times: 1}
+
+	// DefaultRetryPolicy is copied by New, later changes do not apply to the Service
+	s, transport := newService()
+	cybr_pam_scim.DefaultRetryPolicy.MaxAttempts = 1
+	srv.InjectFault(unavailable)
+	if _, err := s.GetUsers(ctx); err != nil || transport.count() != 2 {
+		t.Errorf("error = %v after %d requests, want a retry with the policy at the time of New", err, transport.count())
+	}
+
+	// A Service created afterwards uses the modified policy
+	s, transport = newService()
+	srv.InjectFault(unavailable)
+	if _, err := s.GetUsers(ctx); err == nil || transport.count() != 1 {
+		t.Errorf("error = %v after %d requests, want no retry", err, transport.count())
+	}
+	cybr_pam_scim.DefaultRetryPolicy = defaults
+	cybr_pam_scim.DefaultRetryPolicy.MinBackoff = time.Millisecond
+
+	// WithRetryPolicy replaces the default, nil disables retries
+	policy := cybr_pam_scim.RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	s, transport = newService(cybr_pam_scim.WithRetryPolicy(&policy))
+	srv.InjectFault(scimtest.Fault{Method: http.MethodGet, Path: "/Users", Status: http.StatusServiceUnavailable, Times: 2})
+	if _, err := s.GetUsers(ctx); err != nil || transport.count() != 3 {
+		t.Errorf("error = %v after %d requests, want 3 attempts", err, transport.count())
+	}
+	s, transport = newService(cybr_pam_scim.WithRetryPolicy(nil))
+	srv.InjectFault(unavailable)
+	if _, err := s.GetUsers(ctx); err == nil || transport.count() != 1 {
+		t.Errorf("error = %v after %d requests, want the 503 without a retry", err, transport.count())
+	}
+}
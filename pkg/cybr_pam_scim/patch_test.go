@@ -0,0 +1,54 @@
+package cybr_pam_scim_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/scimtest"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
+)
+
+func TestPatchNilRequest(t *testing.T) {
+	srv := scimtest.NewServer()
+	defer srv.Close()
+	s := srv.NewService()
+	ctx := context.Background()
+
+	patches := map[string]func(patch *types.PatchRequest) error{
+		"PatchUser": func(patch *types.PatchRequest) error {
+			_, err := s.PatchUser(ctx, "8", patch)
+			return err
+		},
+		"PatchGroup": func(patch *types.PatchRequest) error {
+			_, err := s.PatchGroup(ctx, "8", patch)
+			return err
+		},
+		"PatchSafe": func(patch *types.PatchRequest) error {
+			_, err := s.PatchSafe(ctx, "Safe", patch)
+			return err
+		},
+		"PatchSafePermission": func(patch *types.PatchRequest) error {
+			_, err := s.PatchSafePermission(ctx, "Safe", "john", patch)
+			return err
+		},
+		"PatchPrivilegedData": func(patch *types.PatchRequest) error {
+			_, err := s.PatchPrivilegedData(ctx, "2_1", patch)
+			return err
+		},
+	}
+	for name, patch := range patches {
+		if err := patch(nil); err == nil {
+			t.Errorf("%s accepted a nil patch request", name)
+		}
+	}
+	if srv.Requests() != 0 {
+		t.Errorf("requests = %d, want nil patch requests to be refused before discovery", srv.Requests())
+	}
+
+	bulk := cybr_pam_scim.NewBulkRequest()
+	bulk.Patch("/Users/8", nil)
+	if _, err := s.Bulk(ctx, bulk); err == nil {
+		t.Error("Bulk accepted a nil patch request")
+	}
+}
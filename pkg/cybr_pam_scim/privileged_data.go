@@ -6,14 +6,13 @@ import (
 	"net/url"
 	"strconv"
 
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/filter"
 	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
 	"golang.org/x/exp/slices"
 )
 
-var (
-	PrivilegedDatas types.PrivilegedDatas
-	PrivilegedData  types.PrivilegedData
-)
+// privilegedDataSortBy contains the attributes Privileged Data may be sorted by
+var privilegedDataSortBy = []string{"name", "id", "type", "meta.created", "meta.lastmodified", "meta.location"}
 
 // GetPrivilegedData retrieves all Privileged Data (Accounts, SSHKeys, etc...) via the SCIM API.
 // The response from the SCIM API is returned as the types.PrivilegedDatas struct
@@ -21,12 +20,17 @@ var (
 // Example Usage:
 //		getPrivilegedData, err := s.GetPrivilegedData(context.Background)
 //
-func (s *Service) GetPrivilegedData(ctx context.Context) (*types.PrivilegedDatas, error) {
-	if err := s.client.Get(ctx, fmt.Sprintf("/%s", "PrivilegedData"), &PrivilegedDatas); err != nil {
+func (s *Service) GetPrivilegedData(ctx context.Context, opts ...ReadOption) (*types.PrivilegedDatas, error) {
+	var privilegedDatas types.PrivilegedDatas
+	path, err := s.readPath(ctx, "PrivilegedData", fmt.Sprintf("/%s", "PrivilegedData"), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Privielged Data: %w", err)
+	}
+	if err := s.client.Get(ctx, path, &privilegedDatas); err != nil {
 		return nil, fmt.Errorf("failed to get Privielged Data: %w", err)
 	}
 
-	return &PrivilegedDatas, nil
+	return &privilegedDatas, nil
 }
 
 // GetPrivilegedDataIndex retrieves a limited subset of Privileged Data based on a starting index and count.
@@ -37,13 +41,18 @@ func (s *Service) GetPrivilegedData(ctx context.Context) (*types.PrivilegedDatas
 // Example Usage:
 //		getPrivielegedDataIndex, err := s.GetPrivilegedDataIndex(context.Background, 10, 5)
 //
-func (s *Service) GetPrivilegedDataIndex(ctx context.Context, startIndex int, count int) (*types.PrivilegedDatas, error) {
+func (s *Service) GetPrivilegedDataIndex(ctx context.Context, startIndex int, count int, opts ...ReadOption) (*types.PrivilegedDatas, error) {
+	var privilegedDatas types.PrivilegedDatas
 	pathEscapedQuery := url.PathEscape("startIndex=" + strconv.Itoa(startIndex) + "&count=" + strconv.Itoa(count))
-	if err := s.client.Get(ctx, fmt.Sprintf("/%s?%s", "PrivilegedData", pathEscapedQuery), &PrivilegedDatas); err != nil {
+	path, err := s.readPath(ctx, "PrivilegedData", fmt.Sprintf("/%s?%s", "PrivilegedData", pathEscapedQuery), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Privileged Data: %w", err)
+	}
+	if err := s.client.Get(ctx, path, &privilegedDatas); err != nil {
 		return nil, fmt.Errorf("failed to get Privileged Data: %w", err)
 	}
 
-	return &PrivilegedDatas, nil
+	return &privilegedDatas, nil
 }
 
 // GetPrivilegedDataSort retrieves and sorts all Safes via the SCIM API based on provided
@@ -56,11 +65,15 @@ func (s *Service) GetPrivilegedDataIndex(ctx context.Context, startIndex int, co
 // Example Usage:
 //		getPrivilegedDataSort, err := s.GetPrivilegedDataSort(context.Background, "name", "ascending")
 //
-func (s *Service) GetPrivilegedDataSort(ctx context.Context, sortBy string, sortOrder string) (*types.PrivilegedDatas, error) {
+func (s *Service) GetPrivilegedDataSort(ctx context.Context, sortBy string, sortOrder string, opts ...ReadOption) (*types.PrivilegedDatas, error) {
+	if !s.capabilities(ctx).SortSupported() {
+		return nil, ErrSortNotSupported
+	}
+
+	var privilegedDatas types.PrivilegedDatas
 	var pathEscapedQuery string
-	allowedSortBy := []string{"name", "id", "type", "meta.created", "meta.lastmodified", "meta.location"}
 	// Input validations:
-	if slices.Contains(allowedSortBy, sortBy) {
+	if slices.Contains(privilegedDataSortBy, sortBy) {
 		if sortOrder == "ascending" || sortOrder == "descending" {
 			pathEscapedQuery = url.PathEscape("sortBy=" + sortBy + "&sortOrder=" + sortOrder)
 		} else if sortOrder == "" {
@@ -72,11 +85,15 @@ func (s *Service) GetPrivilegedDataSort(ctx context.Context, sortBy string, sort
 		return nil, fmt.Errorf("invalid sortBy value provided, the only accepted value is name, id, meta.created, meta.lastmodified, or meta.location")
 	}
 
-	if err := s.client.Get(ctx, fmt.Sprintf("/%s?%s", "PrivilegedData", pathEscapedQuery), &PrivilegedDatas); err != nil {
+	path, err := s.readPath(ctx, "PrivilegedData", fmt.Sprintf("/%s?%s", "PrivilegedData", pathEscapedQuery), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Privileged Data: %w", err)
+	}
+	if err := s.client.Get(ctx, path, &privilegedDatas); err != nil {
 		return nil, fmt.Errorf("failed to get Privileged Data: %w", err)
 	}
 
-	return &PrivilegedDatas, nil
+	return &privilegedDatas, nil
 }
 
 // GetPrivilegedDataById retrieves a data point based on Id via the SCIM API.
@@ -87,12 +104,17 @@ func (s *Service) GetPrivilegedDataSort(ctx context.Context, sortBy string, sort
 // Example Usage:
 //		getPrivilegedDataById, err := s.GetPrivilegedDataById(context.Background, "92_2")
 //
-func (s *Service) GetPrivilegedDataById(ctx context.Context, id string) (*types.PrivilegedData, error) {
-	if err := s.client.Get(ctx, fmt.Sprintf("/%s/%s", "PrivilegedData", id), &PrivilegedData); err != nil {
+func (s *Service) GetPrivilegedDataById(ctx context.Context, id string, opts ...ReadOption) (*types.PrivilegedData, error) {
+	var privilegedData types.PrivilegedData
+	path, err := s.readPath(ctx, "PrivilegedData", fmt.Sprintf("/%s/%s", "PrivilegedData", id), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Privileged data %s: %w", id, err)
+	}
+	if err := s.client.Get(ctx, path, &privilegedData); err != nil {
 		return nil, fmt.Errorf("failed to get Privileged data %s: %w", id, err)
 	}
 
-	return &PrivilegedData, nil
+	return &privilegedData, nil
 }
 
 // GetPrivilegedDataByFilter retrieves Privileged Data based on a provided filter via the SCIM API.
@@ -106,13 +128,47 @@ func (s *Service) GetPrivilegedDataById(ctx context.Context, id string) (*types.
 //      getPrivilegedDataByFilter, err := s.GetPrivilegedDataByFilter(context.Background, "name", "exampleadmin")
 //      getPrivilegedDataByFilter, err := s.GetPrivilegedDataByFilter(context.Background, "id", "92_3")
 //
-func (s *Service) GetPrivilegedDataByFilter(ctx context.Context, filterType string, filterQuery string) (*types.PrivilegedDatas, error) {
-	pathEscapedQuery := url.PathEscape("filter=" + filterType + " eq \"" + filterQuery + "\"")
-	if err := s.client.Get(ctx, fmt.Sprintf("/%s?%s", "PrivilegedDatas", pathEscapedQuery), &PrivilegedDatas); err != nil {
-		return nil, fmt.Errorf("failed to get Safe Permissions based on filter parameters - %s = %s: %w", filterType, filterQuery, err)
+func (s *Service) GetPrivilegedDataByFilter(ctx context.Context, filterType string, filterQuery string, opts ...ReadOption) (*types.PrivilegedDatas, error) {
+	var privilegedDatas types.PrivilegedDatas
+	query := url.Values{"filter": {filter.Attr(filterType).Eq(filterQuery).String()}}
+	path, err := s.readPath(ctx, "PrivilegedData", fmt.Sprintf("/%s?%s", "PrivilegedData", encodeQuery(query)), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Privileged Data based on filter parameters - %s = %s: %w", filterType, filterQuery, err)
+	}
+	if err := s.client.Get(ctx, path, &privilegedDatas); err != nil {
+		return nil, fmt.Errorf("failed to get Privileged Data based on filter parameters - %s = %s: %w", filterType, filterQuery, err)
+	}
+
+	return &privilegedDatas, nil
+}
+
+// GetPrivilegedDataByFilterExpr retrieves all Privileged Data matching a filter expression via the SCIM API.
+// The response from the SCIM API is returned as the types.PrivilegedDatas struct.
+// The attributes referenced by the expression are validated against filter.PrivilegedData.
+//
+// Example Usage:
+//		expr := filter.And(
+//			filter.Attr("urn:ietf:params:scim:schemas:cyberark:1.0:PrivilegedData:safe").Eq("AppSafe"),
+//			filter.Attr("name").Sw("svc_"),
+//		)
+//		getPrivilegedDataByFilterExpr, err := s.GetPrivilegedDataByFilterExpr(context.Background, expr)
+//
+func (s *Service) GetPrivilegedDataByFilterExpr(ctx context.Context, expr filter.Expression, opts ...ReadOption) (*types.PrivilegedDatas, error) {
+	if err := filter.PrivilegedData.Validate(expr); err != nil {
+		return nil, fmt.Errorf("invalid filter provided: %w", err)
+	}
+
+	var privilegedDatas types.PrivilegedDatas
+	query := url.Values{"filter": {expr.String()}}
+	path, err := s.readPath(ctx, "PrivilegedData", fmt.Sprintf("/%s?%s", "PrivilegedData", encodeQuery(query)), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Privileged Data based on filter %s: %w", expr, err)
+	}
+	if err := s.client.Get(ctx, path, &privilegedDatas); err != nil {
+		return nil, fmt.Errorf("failed to get Privileged Data based on filter %s: %w", expr, err)
 	}
 
-	return &PrivilegedDatas, nil
+	return &privilegedDatas, nil
 }
 
 // AddPrivilegedData attempts a "POST" operation to add Privileged Data to the Vault
@@ -149,11 +205,12 @@ func (s *Service) GetPrivilegedDataByFilter(ctx context.Context, filterType stri
 //      addPrivilegedData, err := s.AddPrivilegedData(context.Background, PrivilegedData)
 //
 func (s *Service) AddPrivilegedData(ctx context.Context, privilegedData types.PrivilegedData) (*types.PrivilegedData, error) {
-	if err := s.client.Post(ctx, fmt.Sprintf("/%s", "PrivilegedData"), privilegedData, &PrivilegedData); err != nil {
+	var privilegedDataResponse types.PrivilegedData
+	if err := s.client.Post(ctx, fmt.Sprintf("/%s", "PrivilegedData"), privilegedData, &privilegedDataResponse); err != nil {
 		return nil, fmt.Errorf("failed to add permissions to safe: %w", err)
 	}
 
-	return &PrivilegedData, nil
+	return &privilegedDataResponse, nil
 }
 
 // UpdatePrivilegedData attempts to perform a "PUT" operation against Privileged Data and requires
@@ -187,11 +244,55 @@ func (s *Service) AddPrivilegedData(ctx context.Context, privilegedData types.Pr
 //      addPrivilegedData, err := s.AddPrivilegedData(context.Background, PrivilegedData)
 //
 func (s *Service) UpdatePrivilegedData(ctx context.Context, privilegedData types.PrivilegedData) (*types.PrivilegedData, error) {
-	if err := s.client.Put(ctx, fmt.Sprintf("/%s/%s", "PrivilegedData", privilegedData.Id), PrivilegedData, &PrivilegedData); err != nil {
+	var privilegedDataResponse types.PrivilegedData
+	if err := s.client.Put(ctx, fmt.Sprintf("/%s/%s", "PrivilegedData", privilegedData.Id), privilegedData, &privilegedDataResponse); err != nil {
 		return nil, fmt.Errorf("failed to update Privileged Data: %w", err)
 	}
 
-	return &PrivilegedData, nil
+	return &privilegedDataResponse, nil
+}
+
+// UpdatePrivilegedDataIfMatch performs the "PUT" operation of UpdatePrivilegedData only if the
+// Privileged Data has not been modified since privilegedData.Meta.Version was retrieved,
+// ErrPreconditionFailed is returned otherwise. ErrETagNotSupported is returned if the service
+// provider does not support ETags.
+//
+// Example Usage:
+//		privilegedData, err := s.GetPrivilegedDataById(context.Background, "62_3")
+//		privilegedData.Description = "Application account"
+//		update, err := s.UpdatePrivilegedDataIfMatch(context.Background, *privilegedData)
+//
+func (s *Service) UpdatePrivilegedDataIfMatch(ctx context.Context, privilegedData types.PrivilegedData) (*types.PrivilegedData, error) {
+	ctx, err := s.ifMatch(ctx, privilegedData.Meta.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update Privileged Data: %w", err)
+	}
+
+	return s.UpdatePrivilegedData(ctx, privilegedData)
+}
+
+// EditPrivilegedData retrieves a single Privileged Data by Id, applies fn to it, and replaces it
+// with UpdatePrivilegedDataIfMatch. The Privileged Data is retrieved and fn applied again when it
+// has been modified concurrently, so fn must only modify the provided Privileged Data. An error
+// returned by fn aborts the edit.
+//
+// Example Usage:
+//		edit, err := s.EditPrivilegedData(context.Background, "62_3", func(privilegedData *types.PrivilegedData) error {
+//			privilegedData.Description = "Application account"
+//			return nil
+//		})
+//
+func (s *Service) EditPrivilegedData(ctx context.Context, id string, fn func(privilegedData *types.PrivilegedData) error) (*types.PrivilegedData, error) {
+	privilegedData, err := edit(ctx, func(ctx context.Context) (*types.PrivilegedData, error) {
+		return s.GetPrivilegedDataById(ctx, id)
+	}, fn, func(ctx context.Context, privilegedData *types.PrivilegedData) (*types.PrivilegedData, error) {
+		return s.UpdatePrivilegedDataIfMatch(ctx, *privilegedData)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to edit Privileged Data %s: %w", id, err)
+	}
+
+	return privilegedData, nil
 }
 
 // ModifyPrivilegedData attempts to perform a "PATCH" operation against Privileged Data and requires
@@ -218,11 +319,37 @@ func (s *Service) UpdatePrivilegedData(ctx context.Context, privilegedData types
 //      modifyPrivilegedData, err := s.ModifyPrivilegedData(context.Background, PrivilegedDataModify)
 //
 func (s *Service) ModifyPrivilegedData(ctx context.Context, privilegedData types.PrivilegedData) (*types.PrivilegedData, error) {
-	if err := s.client.Patch(ctx, fmt.Sprintf("/%s/%s", "PrivilegedData", privilegedData.Id), PrivilegedData, &PrivilegedData); err != nil {
+	var privilegedDataResponse types.PrivilegedData
+	if err := s.client.Patch(ctx, fmt.Sprintf("/%s/%s", "PrivilegedData", privilegedData.Id), privilegedData, &privilegedDataResponse); err != nil {
 		return nil, fmt.Errorf("failed to update Privileged Data: %w", err)
 	}
 
-	return &PrivilegedData, nil
+	return &privilegedDataResponse, nil
+}
+
+// PatchPrivilegedData attempts to perform a "PATCH" operation against Privileged Data by Id and
+// requires a types.PatchRequest with the desired operations. The response from the SCIM API is
+// returned as the types.PrivilegedData struct, which is empty if the SCIM API does not return the
+// updated Privileged Data.
+//
+// Example Usage:
+//		patch := types.NewPatchRequest().
+//			Replace("urn:ietf:params:scim:schemas:cyberark:1.0:PrivilegedData:properties", []types.Properties{
+//				{Key: "address", Value: "newAddress"},
+//			})
+//		patchPrivilegedData, err := s.PatchPrivilegedData(context.Background, "62_3", patch)
+//
+func (s *Service) PatchPrivilegedData(ctx context.Context, id string, patch *types.PatchRequest) (*types.PrivilegedData, error) {
+	if err := s.validatePatch(ctx, patch); err != nil {
+		return nil, fmt.Errorf("failed to patch Privileged Data %s: %w", id, err)
+	}
+
+	var privilegedData types.PrivilegedData
+	if err := s.client.Patch(ctx, fmt.Sprintf("/%s/%s", "PrivilegedData", id), patch, &privilegedData); err != nil {
+		return nil, fmt.Errorf("failed to patch Privileged Data %s: %w", id, err)
+	}
+
+	return &privilegedData, nil
 }
 
 // DeletePrivilegedData attempts to perform a "DELETE" operation against Privileged Data
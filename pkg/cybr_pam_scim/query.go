@@ -0,0 +1,11 @@
+package cybr_pam_scim
+
+import (
+	"net/url"
+	"strings"
+)
+
+// encodeQuery encodes query parameters, spaces are encoded as %20 instead of "+"
+func encodeQuery(query url.Values) string {
+	return strings.ReplaceAll(query.Encode(), "+", "%20")
+}
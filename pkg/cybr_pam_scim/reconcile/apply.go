@@ -0,0 +1,133 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
+)
+
+// Result lists the changes applied successfully
+type Result struct {
+	Applied []Change
+}
+
+// ApplyError is returned by Apply when a change fails. The changes following it are not applied.
+type ApplyError struct {
+	Change Change
+	Err    error
+}
+
+func (e *ApplyError) Error() string {
+	return fmt.Sprintf("failed to %s %s %s: %v", e.Change.Action, e.Change.Kind, e.Change.Key, e.Err)
+}
+
+func (e *ApplyError) Unwrap() error {
+	return e.Err
+}
+
+// Apply executes the changes of a Plan in order and stops at the first failure, which is
+// returned as an *ApplyError along with the changes applied so far. Group members referencing
+// users by userName are resolved to the ids of live users and users created by the Plan.
+func (r *Reconciler) Apply(ctx context.Context, plan *Plan) (*Result, error) {
+	userIds := make(map[string]string, len(plan.userIds))
+	for userName, id := range plan.userIds {
+		userIds[userName] = id
+	}
+
+	result := &Result{}
+	for _, change := range plan.Changes {
+		if err := r.apply(ctx, change, userIds); err != nil {
+			return result, &ApplyError{Change: change, Err: err}
+		}
+		result.Applied = append(result.Applied, change)
+	}
+
+	return result, nil
+}
+
+func (r *Reconciler) apply(ctx context.Context, change Change, userIds map[string]string) error {
+	s := r.service
+	switch resource := change.Resource.(type) {
+	case types.User:
+		switch change.Action {
+		case ActionCreate:
+			created, err := s.AddUser(ctx, resource)
+			if err != nil {
+				return err
+			}
+			userIds[strings.ToLower(resource.UserName)] = created.Id
+			return nil
+		case ActionUpdate:
+			_, err := s.UpdateUser(ctx, resource)
+			return err
+		}
+		return s.DeleteUser(ctx, change.Id)
+	case types.Group:
+		switch change.Action {
+		case ActionCreate:
+			if err := resolveMembers(&resource, userIds); err != nil {
+				return err
+			}
+			_, err := s.AddGroup(ctx, resource)
+			return err
+		case ActionUpdate:
+			if err := resolveMembers(&resource, userIds); err != nil {
+				return err
+			}
+			_, err := s.UpdateGroup(ctx, resource)
+			return err
+		}
+		return s.DeleteGroup(ctx, change.Id)
+	case types.Container:
+		switch change.Action {
+		case ActionCreate:
+			_, err := s.AddSafe(ctx, resource)
+			return err
+		case ActionUpdate:
+			_, err := s.UpdateSafe(ctx, resource)
+			return err
+		}
+		return s.DeleteSafe(ctx, resource.Name)
+	case types.ContainerPermission:
+		switch change.Action {
+		case ActionCreate:
+			_, err := s.AddSafePermissions(ctx, resource)
+			return err
+		case ActionUpdate:
+			_, err := s.UpdateSafePermissions(ctx, resource)
+			return err
+		}
+		return s.DeleteSafePermission(ctx, permissionSafe(resource), permissionMember(resource))
+	case types.PrivilegedData:
+		switch change.Action {
+		case ActionCreate:
+			_, err := s.AddPrivilegedData(ctx, resource)
+			return err
+		case ActionUpdate:
+			_, err := s.UpdatePrivilegedData(ctx, resource)
+			return err
+		}
+		return s.DeletePrivilegedData(ctx, change.Id)
+	}
+
+	return fmt.Errorf("unsupported resource %T", change.Resource)
+}
+
+// resolveMembers sets the id of members referencing a user by userName
+func resolveMembers(group *types.Group, userIds map[string]string) error {
+	group.Members = append([]types.Members(nil), group.Members...)
+	for i, member := range group.Members {
+		if member.Value != "" {
+			continue
+		}
+		id, ok := userIds[strings.ToLower(member.Display)]
+		if !ok {
+			return fmt.Errorf("member %q of Group %s is not a known user", member.Display, group.DisplayName)
+		}
+		group.Members[i].Value = id
+	}
+
+	return nil
+}
@@ -0,0 +1,354 @@
+package reconcile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// FieldDiff is the difference of a single attribute, Old is nil for attributes which are not set
+type FieldDiff struct {
+	Path string
+	Old  interface{}
+	New  interface{}
+}
+
+func (d FieldDiff) String() string {
+	return fmt.Sprintf("%s: %s -> %s", d.Path, formatValue(d.Old), formatValue(d.New))
+}
+
+func formatValue(v interface{}) string {
+	if v == nil {
+		return "(unset)"
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(encoded)
+}
+
+// unmanaged lists the attributes which are never compared: server assigned attributes and
+// secrets the SCIM API does not return
+var unmanaged = []string{"id", "meta", "schemas", "password", "urn:ietf:params:scim:schemas:cyberark:1.0:PrivilegedData.password"}
+
+// toMap encodes a resource as a JSON object
+func toMap(v interface{}) (map[string]interface{}, error) {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var obj map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(encoded))
+	dec.UseNumber()
+	if err := dec.Decode(&obj); err != nil {
+		return nil, err
+	}
+
+	return obj, nil
+}
+
+// fromMap decodes a JSON object into a resource
+func fromMap(obj map[string]interface{}, v interface{}) error {
+	encoded, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(encoded, v)
+}
+
+// managed returns the attributes set in a desired resource of type t. Zero values are removed
+// unless their path is listed in set, listed attributes omitted from obj are added with the
+// zero value of their type.
+func managed(obj map[string]interface{}, t reflect.Type, set []string) (map[string]interface{}, error) {
+	result, _ := pruneZero(obj, "", set).(map[string]interface{})
+	if result == nil {
+		result = map[string]interface{}{}
+	}
+	for _, path := range set {
+		names, attrType, ok := splitPath(t, path)
+		if !ok {
+			return nil, fmt.Errorf("unknown attribute %s", path)
+		}
+		if isUnmanaged(strings.Join(names, ".")) {
+			continue
+		}
+		setDefault(result, names, zeroValue(attrType))
+	}
+
+	return result, nil
+}
+
+func isUnmanaged(path string) bool {
+	for _, attr := range unmanaged {
+		if strings.EqualFold(path, attr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func pruneZero(v interface{}, path string, set []string) interface{} {
+	if isUnmanaged(path) {
+		return nil
+	}
+	keep := false
+	for _, attr := range set {
+		if path != "" && strings.EqualFold(path, attr) {
+			keep = true
+		}
+	}
+
+	switch value := v.(type) {
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+		for key, elem := range value {
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+			if pruned := pruneZero(elem, childPath, set); pruned != nil {
+				result[key] = pruned
+			}
+		}
+		if len(result) == 0 && path != "" && !keep {
+			return nil
+		}
+		return result
+	case []interface{}:
+		result := []interface{}{}
+		for _, elem := range value {
+			// Elements of multi-valued attributes are not matched against the unmanaged attributes
+			if pruned := pruneZero(elem, "[]", nil); pruned != nil {
+				result = append(result, pruned)
+			}
+		}
+		if len(result) == 0 && !keep {
+			return nil
+		}
+		return result
+	case string:
+		if value == "" && !keep {
+			return nil
+		}
+	case bool:
+		if !value && !keep {
+			return nil
+		}
+	case json.Number:
+		if value == "0" && !keep {
+			return nil
+		}
+	case nil:
+		return nil
+	}
+
+	return v
+}
+
+// splitPath splits an attribute path of the resource type t into the JSON names of the
+// attribute and its parents, and returns the type of the attribute. Names are matched case
+// insensitively, extension namespaces may contain dots.
+func splitPath(t reflect.Type, path string) ([]string, reflect.Type, bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, nil, false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" || len(path) < len(name) || !strings.EqualFold(path[:len(name)], name) {
+			continue
+		}
+		if len(path) == len(name) {
+			return []string{name}, field.Type, true
+		}
+		if path[len(name)] != '.' {
+			continue
+		}
+		if names, attrType, ok := splitPath(field.Type, path[len(name)+1:]); ok {
+			return append([]string{name}, names...), attrType, true
+		}
+	}
+
+	return nil, nil, false
+}
+
+// zeroValue returns the zero value of a type as decoded by toMap, lists and objects are empty
+func zeroValue(t reflect.Type) interface{} {
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return []interface{}{}
+	case reflect.Map:
+		return map[string]interface{}{}
+	}
+	encoded, err := json.Marshal(reflect.Zero(t).Interface())
+	if err != nil {
+		return nil
+	}
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(encoded))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil
+	}
+	if v == nil {
+		return []interface{}{}
+	}
+
+	return v
+}
+
+// setDefault sets the attribute at the path of names when obj does not have it
+func setDefault(obj map[string]interface{}, names []string, value interface{}) {
+	key := lookupKey(obj, names[0])
+	if len(names) == 1 {
+		if _, ok := obj[key]; !ok {
+			obj[key] = value
+		}
+		return
+	}
+	child, ok := obj[key].(map[string]interface{})
+	if !ok {
+		child = map[string]interface{}{}
+		obj[key] = child
+	}
+	setDefault(child, names[1:], value)
+}
+
+// lookupKey returns the key of obj matching name case insensitively, or name if there is none
+func lookupKey(obj map[string]interface{}, name string) string {
+	if _, ok := obj[name]; ok {
+		return name
+	}
+	for key := range obj {
+		if strings.EqualFold(key, name) {
+			return key
+		}
+	}
+
+	return name
+}
+
+// diff compares the desired attributes with the live resource
+func diff(desired, live map[string]interface{}, prefix string) []FieldDiff {
+	keys := make([]string, 0, len(desired))
+	for key := range desired {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var diffs []FieldDiff
+	for _, key := range keys {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		want := desired[key]
+		have, ok := live[lookupKey(live, key)]
+		if !ok {
+			have = nil
+		}
+
+		wantMap, wantIsMap := want.(map[string]interface{})
+		haveMap, haveIsMap := have.(map[string]interface{})
+		switch {
+		case wantIsMap && haveIsMap:
+			diffs = append(diffs, diff(wantMap, haveMap, path)...)
+		case wantIsMap:
+			diffs = append(diffs, diff(wantMap, map[string]interface{}{}, path)...)
+		case !contains(have, want):
+			diffs = append(diffs, FieldDiff{Path: path, Old: have, New: want})
+		}
+	}
+
+	return diffs
+}
+
+// contains reports whether the live value has every desired attribute. Multi-valued attributes
+// match if they have the same number of values and each desired value has a live counterpart.
+// Attributes the live resource omits match zero values.
+func contains(have, want interface{}) bool {
+	if have == nil && isZero(want) {
+		return true
+	}
+
+	switch w := want.(type) {
+	case map[string]interface{}:
+		h, ok := have.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, value := range w {
+			if !contains(h[lookupKey(h, key)], value) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		h, ok := have.([]interface{})
+		if !ok || len(h) != len(w) {
+			return false
+		}
+		used := make([]bool, len(h))
+		for _, value := range w {
+			found := false
+			for i, candidate := range h {
+				if !used[i] && contains(candidate, value) {
+					used[i], found = true, true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		h, ok := have.(json.Number)
+		return ok && h.String() == w.String()
+	}
+
+	return have == want
+}
+
+func isZero(v interface{}) bool {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		return len(value) == 0
+	case []interface{}:
+		return len(value) == 0
+	case string:
+		return value == ""
+	case bool:
+		return !value
+	case json.Number:
+		return value == "0"
+	}
+
+	return v == nil
+}
+
+// merge overlays the desired attributes on the live resource, multi-valued attributes are replaced
+func merge(live, desired map[string]interface{}) map[string]interface{} {
+	for key, want := range desired {
+		liveKey := lookupKey(live, key)
+		wantMap, wantIsMap := want.(map[string]interface{})
+		haveMap, haveIsMap := live[liveKey].(map[string]interface{})
+		if wantIsMap && haveIsMap {
+			live[liveKey] = merge(haveMap, wantMap)
+			continue
+		}
+		live[liveKey] = want
+	}
+
+	return live
+}
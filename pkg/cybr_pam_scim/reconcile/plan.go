@@ -0,0 +1,353 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/filter"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
+)
+
+// Action is the operation performed by a Change
+type Action string
+
+const (
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+)
+
+// Change is a single resource to create, update, or delete
+type Change struct {
+	Kind   Kind
+	Action Action
+	// Key identifies the resource, e.g. the userName of a User or "<safe>:<member>" of a safe permission.
+	// Keys are compared case insensitively.
+	Key string
+	// Id is the id of the live resource for updates and deletions
+	Id string
+	// Diffs lists the attributes modified by an update
+	Diffs []FieldDiff
+	// Resource is the resource sent on creation or update, e.g. a types.Container.
+	// Updates send the live resource with the desired attributes applied.
+	Resource interface{}
+}
+
+func (c Change) String() string {
+	switch c.Action {
+	case ActionCreate:
+		return fmt.Sprintf("+ %s %s", c.Kind, c.Key)
+	case ActionDelete:
+		return fmt.Sprintf("- %s %s", c.Kind, c.Key)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "~ %s %s", c.Kind, c.Key)
+	for _, d := range c.Diffs {
+		fmt.Fprintf(&b, "\n    %s", d)
+	}
+
+	return b.String()
+}
+
+// Plan lists the changes converging the vault to a Spec in the order they are applied:
+// creations and updates of users, groups, safes, safe permissions, and privileged data,
+// followed by deletions in the reverse order.
+type Plan struct {
+	Changes []Change
+
+	// userIds maps the userName of live users to their id
+	userIds map[string]string
+}
+
+// Empty reports whether the vault already matches the Spec
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// String returns a line per change, updates are followed by their field differences
+func (p *Plan) String() string {
+	if p.Empty() {
+		return "No changes\n"
+	}
+
+	var b strings.Builder
+	for _, change := range p.Changes {
+		b.WriteString(change.String())
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// Reconciler computes and applies Plans with a Service
+type Reconciler struct {
+	service *cybr_pam_scim.Service
+}
+
+// New returns a Reconciler using the provided Service
+func New(service *cybr_pam_scim.Service) *Reconciler {
+	return &Reconciler{service: service}
+}
+
+// resourceChanges collects the changes of a single kind
+type resourceChanges struct {
+	upserts []Change
+	deletes []Change
+}
+
+// Plan reads the live state of the resources referenced by the Spec and computes the changes
+// required to converge the vault to it. Nothing is modified.
+func (r *Reconciler) Plan(ctx context.Context, spec *Spec) (*Plan, error) {
+	plan := &Plan{userIds: map[string]string{}}
+	byKind := map[Kind]*resourceChanges{}
+	safes := spec.safes()
+
+	if len(spec.Users) > 0 || len(spec.Groups) > 0 || spec.prunes(KindUser) {
+		live, err := r.service.Users().List(ctx, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list users: %w", err)
+		}
+		for _, user := range live {
+			plan.userIds[strings.ToLower(user.UserName)] = user.Id
+		}
+		if byKind[KindUser], err = planKind(KindUser, spec, spec.Users, live, userKey, func(user types.User) string { return user.Id }, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(spec.Groups) > 0 || spec.prunes(KindGroup) {
+		live, err := r.service.Groups().List(ctx, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list groups: %w", err)
+		}
+		normalize := func(obj map[string]interface{}) {
+			normalizeMembers(obj, plan.userIds)
+		}
+		if byKind[KindGroup], err = planKind(KindGroup, spec, spec.Groups, live, groupKey, func(group types.Group) string { return group.Id }, normalize); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(spec.Safes) > 0 || spec.prunes(KindSafe) {
+		live, err := r.service.Safes().List(ctx, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list safes: %w", err)
+		}
+		if byKind[KindSafe], err = planKind(KindSafe, spec, spec.Safes, live, safeKey, func(safe types.Container) string { return safe.Name }, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(spec.SafePermissions) > 0 || spec.prunes(KindSafePermission) {
+		var live []types.ContainerPermission
+		for _, safe := range safes {
+			permissions, err := r.service.SafePermissions().List(ctx, &cybr_pam_scim.ListOptions{Filter: filter.Attr("container.name").Eq(safe)})
+			if err != nil {
+				return nil, fmt.Errorf("failed to list permissions of Safe %s: %w", safe, err)
+			}
+			live = append(live, permissions...)
+		}
+		var err error
+		if byKind[KindSafePermission], err = planKind(KindSafePermission, spec, spec.SafePermissions, live, permissionKey, func(permission types.ContainerPermission) string { return permission.Id }, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(spec.PrivilegedData) > 0 || spec.prunes(KindPrivilegedData) {
+		var live []types.PrivilegedData
+		for _, safe := range safes {
+			accounts, err := r.service.PrivilegedData().List(ctx, &cybr_pam_scim.ListOptions{Filter: filter.Attr("urn:ietf:params:scim:schemas:cyberark:1.0:PrivilegedData:safe").Eq(safe)})
+			if err != nil {
+				return nil, fmt.Errorf("failed to list privileged data of Safe %s: %w", safe, err)
+			}
+			live = append(live, accounts...)
+		}
+		var err error
+		if byKind[KindPrivilegedData], err = planKind(KindPrivilegedData, spec, spec.PrivilegedData, live, privilegedDataKey, func(privilegedData types.PrivilegedData) string { return privilegedData.Id }, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, kind := range kinds {
+		if changes := byKind[kind]; changes != nil {
+			plan.Changes = append(plan.Changes, changes.upserts...)
+		}
+	}
+	for i := len(kinds) - 1; i >= 0; i-- {
+		if changes := byKind[kinds[i]]; changes != nil {
+			plan.Changes = append(plan.Changes, changes.deletes...)
+		}
+	}
+
+	return plan, nil
+}
+
+// planKind compares the desired and live resources of a kind. normalize, if set, is applied to
+// the desired and live attributes before they are compared.
+func planKind[T any](kind Kind, spec *Spec, desired []T, live []T, key func(T) string, id func(T) string, normalize func(map[string]interface{})) (*resourceChanges, error) {
+	changes := &resourceChanges{}
+	liveByKey := make(map[string]T, len(live))
+	for _, resource := range live {
+		liveByKey[strings.ToLower(key(resource))] = resource
+	}
+
+	wanted := make(map[string]bool, len(desired))
+	for _, resource := range desired {
+		k := key(resource)
+		if k == "" {
+			return nil, fmt.Errorf("%s is missing its identifying attributes", kind)
+		}
+		if wanted[strings.ToLower(k)] {
+			return nil, fmt.Errorf("%s %s is declared more than once", kind, k)
+		}
+		wanted[strings.ToLower(k)] = true
+
+		desiredMap, err := toMap(resource)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s %s: %w", kind, k, err)
+		}
+		set := spec.managedAttributes(kind, k)
+		if desiredMap, err = managed(desiredMap, reflect.TypeOf(resource), set); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", kind, k, err)
+		}
+
+		existing, ok := liveByKey[strings.ToLower(k)]
+		if !ok {
+			changes.upserts = append(changes.upserts, Change{Kind: kind, Action: ActionCreate, Key: k, Resource: withSchemas(kind, withZeroValues(resource, desiredMap, set))})
+			continue
+		}
+
+		liveMap, err := toMap(existing)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s %s: %w", kind, k, err)
+		}
+		compared, comparedLive := desiredMap, liveMap
+		if normalize != nil {
+			compared, comparedLive = copyMap(desiredMap), copyMap(liveMap)
+			normalize(compared)
+			normalize(comparedLive)
+		}
+		diffs := diff(compared, comparedLive, "")
+		if len(diffs) == 0 {
+			continue
+		}
+
+		var merged T
+		if err := fromMap(merge(liveMap, desiredMap), &merged); err != nil {
+			return nil, fmt.Errorf("failed to merge %s %s: %w", kind, k, err)
+		}
+		changes.upserts = append(changes.upserts, Change{Kind: kind, Action: ActionUpdate, Key: k, Id: id(existing), Diffs: diffs, Resource: withZeroValues(merged, desiredMap, set)})
+	}
+
+	if spec.prunes(kind) {
+		for _, resource := range live {
+			if k := key(resource); !wanted[strings.ToLower(k)] {
+				changes.deletes = append(changes.deletes, Change{Kind: kind, Action: ActionDelete, Key: k, Id: id(resource), Resource: resource})
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+// withSchemas sets the core schema of resources which do not list their schemas
+func withSchemas[T any](kind Kind, resource T) interface{} {
+	switch r := interface{}(&resource).(type) {
+	case *types.User:
+		if len(r.Schemas) == 0 {
+			r.Schemas = defaultSchemas(kind)
+		}
+	case *types.Group:
+		if len(r.Schemas) == 0 {
+			r.Schemas = defaultSchemas(kind)
+		}
+	case *types.Container:
+		if len(r.Schemas) == 0 {
+			r.Schemas = defaultSchemas(kind)
+		}
+	case *types.ContainerPermission:
+		if len(r.Schemas) == 0 {
+			r.Schemas = defaultSchemas(kind)
+		}
+	case *types.PrivilegedData:
+		if len(r.Schemas) == 0 {
+			r.Schemas = defaultSchemas(kind)
+		}
+	}
+
+	return resource
+}
+
+// withZeroValues keeps the managed attributes of a resource which are left at their zero value
+// in its Extra attributes, so that they are sent instead of being omitted. Only attributes of the
+// resource are kept, sub-attributes left at their zero value are omitted.
+func withZeroValues[T any](resource T, desired map[string]interface{}, set []string) T {
+	var extra *types.RawAttributes
+	switch r := interface{}(&resource).(type) {
+	case *types.User:
+		extra = &r.Extra
+	case *types.Group:
+		extra = &r.Extra
+	case *types.Container:
+		extra = &r.Extra
+	case *types.ContainerPermission:
+		extra = &r.Extra
+	case *types.PrivilegedData:
+		extra = &r.Extra
+	default:
+		return resource
+	}
+
+	// The Extra attributes of the Spec are not modified
+	attributes := make(types.RawAttributes, len(*extra)+len(set))
+	for name, value := range *extra {
+		attributes[name] = value
+	}
+	for _, path := range set {
+		key := lookupKey(desired, path)
+		if value, ok := desired[key]; ok && isZero(value) {
+			attributes.Set(key, value)
+		}
+	}
+	*extra = attributes
+
+	return resource
+}
+
+// normalizeMembers compares group members by user id, members referencing a user by
+// userName are resolved when the user exists
+func normalizeMembers(obj map[string]interface{}, userIds map[string]string) {
+	key := lookupKey(obj, "members")
+	members, ok := obj[key].([]interface{})
+	if !ok {
+		return
+	}
+	normalized := make([]interface{}, 0, len(members))
+	for _, member := range members {
+		m, _ := member.(map[string]interface{})
+		value, _ := m["value"].(string)
+		display, _ := m["display"].(string)
+		if value == "" {
+			if id, ok := userIds[strings.ToLower(display)]; ok {
+				value = id
+			} else {
+				value = "userName:" + display
+			}
+		}
+		normalized = append(normalized, value)
+	}
+	obj[key] = normalized
+}
+
+func copyMap(obj map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(obj))
+	for key, value := range obj {
+		copied[key] = value
+	}
+
+	return copied
+}
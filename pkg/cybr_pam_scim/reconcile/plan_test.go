@@ -0,0 +1,121 @@
+package reconcile_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/reconcile"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/scimtest"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
+)
+
+// converge applies the plan of a Spec and checks that planning it again finds no changes
+func converge(t *testing.T, r *reconcile.Reconciler, spec *reconcile.Spec) *reconcile.Plan {
+	t.Helper()
+	ctx := context.Background()
+	plan, err := r.Plan(ctx, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Apply(ctx, plan); err != nil {
+		t.Fatal(err)
+	}
+	again, err := r.Plan(ctx, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.Empty() {
+		t.Errorf("plan after apply:\n%s\nwant no changes", again)
+	}
+
+	return plan
+}
+
+func TestPlanManagedZeroValues(t *testing.T) {
+	srv := scimtest.NewServer()
+	defer srv.Close()
+	s := srv.NewService()
+	ctx := context.Background()
+	user, err := s.AddUser(ctx, types.User{UserName: "john.smith", Active: true, NickName: "Johnny", Name: types.Name{GivenName: "John", MiddleName: "J"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddSafe(ctx, types.Container{Name: "AppSafe", Description: "Application accounts"}); err != nil {
+		t.Fatal(err)
+	}
+	r := reconcile.New(s)
+
+	spec := &reconcile.Spec{
+		Users: []types.User{{UserName: "john.smith", Title: "Engineer"}},
+		Safes: []types.Container{{Name: "AppSafe"}},
+	}
+	// Attributes left at their zero value keep their live value
+	plan := converge(t, r, spec)
+	if len(plan.Changes) != 1 || len(plan.Changes[0].Diffs) != 1 || plan.Changes[0].Diffs[0].Path != "title" {
+		t.Errorf("plan:\n%s\nwant the title of the user", plan)
+	}
+
+	spec.Managed = map[reconcile.Kind]map[string][]string{
+		reconcile.KindUser: {"JOHN.SMITH": {"active", "nickName", "name.middleName"}},
+		reconcile.KindSafe: {"AppSafe": {"description"}},
+	}
+	plan = converge(t, r, spec)
+	want := "~ User john.smith\n" +
+		"    active: true -> false\n" +
+		"    name.middleName: \"J\" -> \"\"\n" +
+		"    nickName: \"Johnny\" -> \"\"\n" +
+		"~ Safe AppSafe\n" +
+		"    description: \"Application accounts\" -> \"\"\n"
+	if plan.String() != want {
+		t.Errorf("plan:\n%s\nwant:\n%s", plan, want)
+	}
+
+	var stored map[string]interface{}
+	if _, err := srv.Resource("Users", user.Id, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if active, ok := stored["active"]; !ok || active != false {
+		t.Errorf("active = %v, want false to be sent", active)
+	}
+	if stored["title"] != "Engineer" || stored["nickName"] != "" {
+		t.Errorf("user = %v, want the title kept and the nickName cleared", stored)
+	}
+}
+
+func TestPlanManagedCreate(t *testing.T) {
+	srv := scimtest.NewServer()
+	defer srv.Close()
+	s := srv.NewService()
+	r := reconcile.New(s)
+
+	converge(t, r, &reconcile.Spec{
+		Users:   []types.User{{UserName: "jane.doe"}},
+		Managed: map[reconcile.Kind]map[string][]string{reconcile.KindUser: {"jane.doe": {"active"}}},
+	})
+	users, err := s.Users().List(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored map[string]interface{}
+	if _, err := srv.Resource("Users", users[0].Id, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if active, ok := stored["active"]; !ok || active != false {
+		t.Errorf("active = %v, want false to be sent on creation", active)
+	}
+}
+
+func TestPlanManagedUnknownAttribute(t *testing.T) {
+	srv := scimtest.NewServer()
+	defer srv.Close()
+	r := reconcile.New(srv.NewService())
+
+	_, err := r.Plan(context.Background(), &reconcile.Spec{
+		Safes:   []types.Container{{Name: "AppSafe"}},
+		Managed: map[reconcile.Kind]map[string][]string{reconcile.KindSafe: {"AppSafe": {"retention"}}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "unknown attribute retention") {
+		t.Errorf("error = %v, want the unknown attribute", err)
+	}
+}
@@ -0,0 +1,173 @@
+// Package reconcile converges the vault to a declarative Spec of users, groups, safes,
+// safe permissions, and privileged accounts. Plan compares the Spec with the live state
+// of the SCIM API and lists the resources to create, update, and delete along with
+// field level differences. Apply executes a Plan through the Service in dependency order.
+//
+// Example Usage:
+//		r := reconcile.New(s)
+//		plan, err := r.Plan(context.Background(), &reconcile.Spec{
+//			Safes: []types.Container{{Name: "AppSafe", Description: "Application accounts"}},
+//			SafePermissions: []types.ContainerPermission{{
+//				Container: types.ContainerRef{Name: "AppSafe"},
+//				Group:     types.GroupRef{Display: "App Admins"},
+//				Rights:    types.ViewerRights,
+//			}},
+//			Prune: []reconcile.Kind{reconcile.KindSafePermission},
+//		})
+//		fmt.Print(plan)
+//		result, err := r.Apply(context.Background(), plan)
+//
+package reconcile
+
+import (
+	"strings"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/filter"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
+	"golang.org/x/exp/slices"
+)
+
+// Kind is the type of a resource managed by a Spec
+type Kind string
+
+const (
+	KindUser           Kind = "User"
+	KindGroup          Kind = "Group"
+	KindSafe           Kind = "Safe"
+	KindSafePermission Kind = "SafePermission"
+	KindPrivilegedData Kind = "PrivilegedData"
+)
+
+// kinds lists the kinds in the order they are created, deletions happen in reverse order
+var kinds = []Kind{KindUser, KindGroup, KindSafe, KindSafePermission, KindPrivilegedData}
+
+// Spec is the desired state of the vault. Only the attributes set in a resource are managed,
+// attributes left at their zero value keep their live value unless they are listed in Managed.
+//
+// Resources are identified by a key instead of their id:
+//   - Users by userName
+//   - Groups by displayName
+//   - Safes by name
+//   - Safe permissions by Safe name and user or group display name
+//   - Privileged data by Safe and name
+//
+// Group members may reference users by userName in Display instead of their id in Value,
+// which allows adding users created by the same Apply.
+type Spec struct {
+	Users           []types.User
+	Groups          []types.Group
+	Safes           []types.Container
+	SafePermissions []types.ContainerPermission
+	PrivilegedData  []types.PrivilegedData
+
+	// Managed lists, by kind and key of the resource, the attributes managed even when the
+	// resource leaves them at their zero value, e.g. "active" to deactivate a user or
+	// "description" to clear the description of a Safe. Sub-attributes and extension
+	// attributes are separated from their parent by a dot, e.g. "name.middleName" or
+	// "urn:ietf:params:scim:schemas:cyberark:1.0:Safe.NumberOfDaysRetention".
+	Managed map[Kind]map[string][]string
+
+	// Prune lists the kinds of which live resources missing from the Spec are deleted.
+	// Safe permissions and privileged data are only pruned in Safes referenced by the Spec.
+	Prune []Kind
+}
+
+func (s *Spec) prunes(kind Kind) bool {
+	return slices.Contains(s.Prune, kind)
+}
+
+// managedAttributes returns the attributes managed at their zero value of a resource
+func (s *Spec) managedAttributes(kind Kind, key string) []string {
+	for k, attributes := range s.Managed[kind] {
+		if strings.EqualFold(k, key) {
+			return attributes
+		}
+	}
+
+	return nil
+}
+
+// safes returns the names of every Safe referenced by the Spec
+func (s *Spec) safes() []string {
+	var names []string
+	seen := map[string]bool{}
+	add := func(name string) {
+		if name != "" && !seen[strings.ToLower(name)] {
+			seen[strings.ToLower(name)] = true
+			names = append(names, name)
+		}
+	}
+	for _, safe := range s.Safes {
+		add(safe.Name)
+	}
+	for _, permission := range s.SafePermissions {
+		add(permissionSafe(permission))
+	}
+	for _, privilegedData := range s.PrivilegedData {
+		add(privilegedData.UrnIetfParamsScimSchemasCyberark10PrivilegedData.Safe)
+	}
+
+	return names
+}
+
+func userKey(user types.User) string {
+	return user.UserName
+}
+
+func groupKey(group types.Group) string {
+	return group.DisplayName
+}
+
+func safeKey(safe types.Container) string {
+	return safe.Name
+}
+
+func permissionKey(permission types.ContainerPermission) string {
+	safe, member := permissionSafe(permission), permissionMember(permission)
+	if safe == "" || member == "" {
+		return ""
+	}
+
+	return safe + ":" + member
+}
+
+func privilegedDataKey(privilegedData types.PrivilegedData) string {
+	safe := privilegedData.UrnIetfParamsScimSchemasCyberark10PrivilegedData.Safe
+	if safe == "" || privilegedData.Name == "" {
+		return ""
+	}
+
+	return safe + "/" + privilegedData.Name
+}
+
+func permissionSafe(permission types.ContainerPermission) string {
+	if permission.Container.Name != "" {
+		return permission.Container.Name
+	}
+
+	return permission.Container.Value
+}
+
+func permissionMember(permission types.ContainerPermission) string {
+	if permission.User.Display != "" {
+		return permission.User.Display
+	}
+
+	return permission.Group.Display
+}
+
+// defaultSchemas returns the core schema of a kind, sent when a resource does not list its schemas
+func defaultSchemas(kind Kind) []string {
+	switch kind {
+	case KindUser:
+		return []string{filter.Users.Schema}
+	case KindGroup:
+		return []string{filter.Groups.Schema}
+	case KindSafe:
+		return []string{filter.Containers.Schema}
+	case KindSafePermission:
+		return []string{filter.ContainerPermissions.Schema}
+	}
+
+	return []string{filter.PrivilegedData.Schema}
+}
@@ -0,0 +1,159 @@
+package cybr_pam_scim
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RetryPolicy controls how the Client retries requests which were throttled (429)
+// or failed on the server side (5xx). A nil policy disables retries.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts including the first request.
+	MaxAttempts int
+	// MinBackoff is the delay before the first retry, it doubles with every attempt.
+	MinBackoff time.Duration
+	// MaxBackoff caps the exponential backoff of a single retry.
+	MaxBackoff time.Duration
+	// Budget caps the total time spent waiting between attempts. Zero means no limit.
+	Budget time.Duration
+	// RetryNonIdempotent allows POST and PATCH requests to be retried as well.
+	RetryNonIdempotent bool
+}
+
+// DefaultRetryPolicy is used by services created with NewService and NewServiceWithTokenSource.
+// Only idempotent requests (GET, PUT, DELETE) are retried.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	MinBackoff:  500 * time.Millisecond,
+	MaxBackoff:  30 * time.Second,
+	Budget:      2 * time.Minute,
+}
+
+// SetRetryPolicy replaces the retry policy used by the Service. Passing nil disables retries.
+// It may be called while the Service is in use, requests in flight keep their policy.
+//
+// Example Usage:
+//		policy := cybr_pam_scim.DefaultRetryPolicy
+//		policy.MaxAttempts = 10
+//		policy.RetryNonIdempotent = true
+//		s.SetRetryPolicy(&policy)
+//
+func (s *Service) SetRetryPolicy(policy *RetryPolicy) {
+	s.client.configure(func(options *Options) {
+		options.Retry = policy
+	})
+}
+
+// retryable reports whether the method of the request may be sent more than once
+func (p *RetryPolicy) retryable(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	case http.MethodPost, http.MethodPatch:
+		return p.RetryNonIdempotent
+	}
+
+	return false
+}
+
+// retryableResponse reports whether a response or transport error warrants another attempt
+func retryableResponse(ctx context.Context, resp *http.Response, err error) bool {
+	if err != nil {
+		return ctx.Err() == nil
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+// jitter is the random source of the backoff delays of a policy, safe for concurrent use.
+// Each policy has its own source seeded on creation, so that clients retrying at the same
+// time do not wait for the same delays.
+type jitter struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func newJitter() *jitter {
+	return &jitter{rnd: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// int63n returns a random number in [0, n)
+func (j *jitter) int63n(n int64) int64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.rnd.Int63n(n)
+}
+
+// backoff returns the jittered exponential delay before the given retry (1 based),
+// a Retry-After header on the response takes precedence.
+func (p *RetryPolicy) backoff(retry int, resp *http.Response, j *jitter) time.Duration {
+	if resp != nil {
+		if delay, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			return delay
+		}
+	}
+
+	delay := p.MinBackoff
+	for i := 1; i < retry && (p.MaxBackoff <= 0 || delay < p.MaxBackoff); i++ {
+		delay *= 2
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	// Equal jitter, half of the delay is fixed and the other half is random
+	half := delay / 2
+	return half + time.Duration(j.int63n(int64(delay-half)+1))
+}
+
+// retryAfter parses a Retry-After header provided either in seconds or as an HTTP date
+func retryAfter(header string) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+
+	return 0, false
+}
+
+// sleep waits for the delay or until the context is done
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,160 @@
+package scimtest
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/filter"
+)
+
+// alwaysReturned are the attributes returned regardless of attributes and excludedAttributes
+var alwaysReturned = []string{"id", "schemas"}
+
+// projection returns a function applying the attributes or excludedAttributes query
+// parameters to a resource, nil if neither is present
+func projection(rt *resourceType, query url.Values) (func(obj map[string]interface{}) map[string]interface{}, *response) {
+	attributes, excluded := splitList(query.Get("attributes")), splitList(query.Get("excludedAttributes"))
+	switch {
+	case len(attributes) > 0 && len(excluded) > 0:
+		return nil, errorResponse(http.StatusBadRequest, "invalidValue", "attributes and excludedAttributes cannot be combined")
+	case len(attributes) > 0:
+		return func(obj map[string]interface{}) map[string]interface{} {
+			return include(obj, attributes, rt.filter.Schema)
+		}, nil
+	case len(excluded) > 0:
+		return func(obj map[string]interface{}) map[string]interface{} {
+			return exclude(obj, excluded, rt.filter.Schema)
+		}, nil
+	}
+
+	return nil, nil
+}
+
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
+
+// include returns a copy of the resource with only the listed attributes
+func include(obj map[string]interface{}, attributes []string, schema string) map[string]interface{} {
+	result := map[string]interface{}{}
+	for _, name := range alwaysReturned {
+		if value, ok := obj[name]; ok {
+			result[name] = value
+		}
+	}
+
+	for _, attr := range attributes {
+		// A schema URI selects the whole extension
+		if strings.HasPrefix(strings.ToLower(attr), "urn:") {
+			if key := lookupKey(obj, attr); obj[key] != nil {
+				result[key] = deepCopy(obj[key])
+				continue
+			}
+		}
+
+		path := filter.ParsePath(attr)
+		source := container(obj, path, schema, false)
+		if source == nil {
+			continue
+		}
+		key := lookupKey(source, path.Name)
+		value, ok := source[key]
+		if !ok {
+			continue
+		}
+		target := container(result, path, schema, true)
+		if path.SubAttribute == "" {
+			target[key] = deepCopy(value)
+			continue
+		}
+		target[key] = mergeSubAttribute(target[key], value, path.SubAttribute)
+	}
+
+	return result
+}
+
+// mergeSubAttribute adds a sub-attribute of a complex or multi-valued attribute to the
+// projection built so far
+func mergeSubAttribute(projected interface{}, value interface{}, subAttribute string) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		target, ok := projected.(map[string]interface{})
+		if !ok {
+			target = map[string]interface{}{}
+		}
+		if sub, ok := value[lookupKey(value, subAttribute)]; ok {
+			target[lookupKey(value, subAttribute)] = deepCopy(sub)
+		}
+		return target
+	case []interface{}:
+		target, ok := projected.([]interface{})
+		if !ok {
+			target = make([]interface{}, len(value))
+		}
+		for i, element := range value {
+			target[i] = mergeSubAttribute(target[i], element, subAttribute)
+		}
+		return target
+	}
+
+	return projected
+}
+
+// exclude returns a copy of the resource without the listed attributes
+func exclude(obj map[string]interface{}, attributes []string, schema string) map[string]interface{} {
+	result := deepCopy(obj).(map[string]interface{})
+	for _, attr := range attributes {
+		if strings.HasPrefix(strings.ToLower(attr), "urn:") {
+			if key := lookupKey(result, attr); result[key] != nil {
+				delete(result, key)
+				continue
+			}
+		}
+
+		path := filter.ParsePath(attr)
+		target := container(result, path, schema, false)
+		if target == nil {
+			continue
+		}
+		key := lookupKey(target, path.Name)
+		if (path.URI == "" || strings.EqualFold(path.URI, schema)) && isAlwaysReturned(key) {
+			continue
+		}
+		if path.SubAttribute == "" {
+			delete(target, key)
+			continue
+		}
+		removeSubAttribute(target[key], path.SubAttribute)
+	}
+
+	return result
+}
+
+func removeSubAttribute(value interface{}, subAttribute string) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		delete(value, lookupKey(value, subAttribute))
+	case []interface{}:
+		for _, element := range value {
+			removeSubAttribute(element, subAttribute)
+		}
+	}
+}
+
+func isAlwaysReturned(name string) bool {
+	for _, always := range alwaysReturned {
+		if strings.EqualFold(name, always) {
+			return true
+		}
+	}
+
+	return false
+}
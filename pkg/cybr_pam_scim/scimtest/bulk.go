@@ -0,0 +1,79 @@
+package scimtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
+)
+
+// bulk processes the operations of a bulk request in order, the caller must hold mu. References
+// to resources created by earlier operations (bulkId:<id>) are replaced by their id.
+func (s *Server) bulk(body []byte) *response {
+	if !s.config.Bulk.Supported {
+		return errorResponse(http.StatusNotImplemented, "", "bulk operations are not supported")
+	}
+	if maxSize := s.config.Bulk.MaxPayloadSize; maxSize > 0 && len(body) > maxSize {
+		return errorResponse(http.StatusRequestEntityTooLarge, "tooLarge", "the payload exceeds %d bytes", maxSize)
+	}
+	var request types.BulkRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		return errorResponse(http.StatusBadRequest, "invalidSyntax", "invalid bulk request: %v", err)
+	}
+	if maxOperations := s.config.Bulk.MaxOperations; maxOperations > 0 && len(request.Operations) > maxOperations {
+		return errorResponse(http.StatusRequestEntityTooLarge, "tooLarge", "the request exceeds %d operations", maxOperations)
+	}
+
+	ids := map[string]string{}
+	result := types.BulkResponse{Schemas: []string{types.BulkResponseSchema}}
+	errors := 0
+	for _, operation := range request.Operations {
+		if request.FailOnErrors > 0 && errors >= request.FailOnErrors {
+			break
+		}
+
+		for bulkId, id := range ids {
+			ref := "bulkId:" + bulkId
+			operation.Data = bytes.ReplaceAll(operation.Data, []byte(strconv.Quote(ref)), []byte(strconv.Quote(id)))
+			operation.Path = strings.ReplaceAll(operation.Path, ref, id)
+		}
+
+		var resp *response
+		switch {
+		case bytes.Contains(operation.Data, []byte(`"bulkId:`)) || strings.Contains(operation.Path, "bulkId:"):
+			resp = errorResponse(http.StatusConflict, "invalidValue", "unresolved bulkId reference")
+		case lookupResourceType(strings.Split(strings.Trim(operation.Path, "/"), "/")[0]) == nil:
+			resp = errorResponse(http.StatusBadRequest, "invalidPath", "invalid bulk operation path %q", operation.Path)
+		default:
+			header := http.Header{}
+			if operation.Version != "" {
+				header.Set("If-Match", operation.Version)
+			}
+			resp = s.handle(operation.Method, operation.Path, nil, header, operation.Data)
+		}
+
+		operationResponse := types.BulkOperationResponse{
+			Method: operation.Method,
+			BulkId: operation.BulkId,
+			Status: json.Number(strconv.Itoa(resp.status)),
+		}
+		if obj, ok := resp.body.(map[string]interface{}); ok && resp.status < 300 {
+			meta, _ := obj["meta"].(map[string]interface{})
+			operationResponse.Location, _ = meta["location"].(string)
+			operationResponse.Version, _ = meta["version"].(string)
+			if operation.BulkId != "" && operation.Method == http.MethodPost {
+				ids[operation.BulkId], _ = obj["id"].(string)
+			}
+		}
+		if resp.status >= 300 {
+			errors++
+			operationResponse.Response, _ = json.Marshal(resp.body)
+		}
+		result.Operations = append(result.Operations, operationResponse)
+	}
+
+	return &response{status: http.StatusOK, body: result}
+}
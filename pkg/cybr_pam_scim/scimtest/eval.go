@@ -0,0 +1,241 @@
+package scimtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/filter"
+)
+
+// lookupKey returns the key of obj matching name case insensitively, or name if there is none
+func lookupKey(obj map[string]interface{}, name string) string {
+	if _, ok := obj[name]; ok {
+		return name
+	}
+	for key := range obj {
+		if strings.EqualFold(key, name) {
+			return key
+		}
+	}
+
+	return name
+}
+
+// container returns the object holding the attributes of a path, i.e. the extension object
+// for paths qualified by an extension schema URI. It returns nil if the extension is missing.
+func container(obj map[string]interface{}, path filter.AttributePath, schema string, create bool) map[string]interface{} {
+	if path.URI == "" || strings.EqualFold(path.URI, schema) {
+		return obj
+	}
+	key := lookupKey(obj, path.URI)
+	extension, ok := obj[key].(map[string]interface{})
+	if !ok && create {
+		extension = map[string]interface{}{}
+		obj[key] = extension
+	}
+
+	return extension
+}
+
+// values returns the values of an attribute path. Multi-valued attributes are flattened and
+// complex values without a sub-attribute are represented by their "value" sub-attribute.
+func values(obj map[string]interface{}, path filter.AttributePath, schema string) []interface{} {
+	parent := container(obj, path, schema, false)
+	if parent == nil {
+		return nil
+	}
+	attr, ok := parent[lookupKey(parent, path.Name)]
+	if !ok || attr == nil {
+		return nil
+	}
+
+	var result []interface{}
+	elements, multiValued := attr.([]interface{})
+	if !multiValued {
+		elements = []interface{}{attr}
+	}
+	for _, element := range elements {
+		complexValue, isComplex := element.(map[string]interface{})
+		switch {
+		case path.SubAttribute != "" && isComplex:
+			if value, ok := complexValue[lookupKey(complexValue, path.SubAttribute)]; ok && value != nil {
+				result = append(result, value)
+			}
+		case path.SubAttribute != "":
+		case isComplex && multiValued:
+			if value, ok := complexValue["value"]; ok && value != nil {
+				result = append(result, value)
+			}
+		default:
+			result = append(result, element)
+		}
+	}
+
+	return result
+}
+
+func firstValue(obj map[string]interface{}, path filter.AttributePath, schema string) interface{} {
+	if v := values(obj, path, schema); len(v) > 0 {
+		return v[0]
+	}
+
+	return nil
+}
+
+// stringAttr returns the first value of an attribute path as a string
+func stringAttr(obj map[string]interface{}, path string) string {
+	value := firstValue(obj, filter.ParsePath(path), "")
+	if value == nil {
+		return ""
+	}
+
+	return fmt.Sprint(value)
+}
+
+// matches evaluates a filter expression against a resource or the element of a multi-valued attribute
+func matches(expr filter.Expression, obj map[string]interface{}, schema string) bool {
+	switch e := expr.(type) {
+	case *filter.LogicalExpression:
+		if e.Operator == filter.LogicalAnd {
+			return matches(e.Left, obj, schema) && matches(e.Right, obj, schema)
+		}
+		return matches(e.Left, obj, schema) || matches(e.Right, obj, schema)
+	case *filter.NotExpression:
+		return !matches(e.Expression, obj, schema)
+	case *filter.ValuePath:
+		parent := container(obj, e.Path, schema, false)
+		if parent == nil {
+			return false
+		}
+		elements, _ := parent[lookupKey(parent, e.Path.Name)].([]interface{})
+		for _, element := range elements {
+			if complexValue, ok := element.(map[string]interface{}); ok && matches(e.Filter, complexValue, "") {
+				return true
+			}
+		}
+		return false
+	case *filter.AttributeExpression:
+		actual := values(obj, e.Path, schema)
+		switch {
+		case e.Operator == filter.Present:
+			for _, value := range actual {
+				if value != "" {
+					return true
+				}
+			}
+			return false
+		case e.Value == nil && e.Operator == filter.Equal:
+			return len(actual) == 0
+		case e.Value == nil && e.Operator == filter.NotEqual:
+			return len(actual) > 0
+		case e.Operator == filter.NotEqual:
+			for _, value := range actual {
+				if compare(filter.Equal, value, e.Value) {
+					return false
+				}
+			}
+			return true
+		}
+		for _, value := range actual {
+			if compare(e.Operator, value, e.Value) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// compare applies an attribute operator, strings are compared case insensitively
+func compare(op filter.Operator, actual, expected interface{}) bool {
+	if b, ok := expected.(bool); ok {
+		actualBool, isBool := actual.(bool)
+		return isBool && op == filter.Equal && actualBool == b
+	}
+
+	if expectedNumber, ok := number(expected); ok {
+		actualNumber, isNumber := number(actual)
+		if !isNumber {
+			return false
+		}
+		switch op {
+		case filter.Equal:
+			return actualNumber == expectedNumber
+		case filter.GreaterThan:
+			return actualNumber > expectedNumber
+		case filter.GreaterOrEqual:
+			return actualNumber >= expectedNumber
+		case filter.LessThan:
+			return actualNumber < expectedNumber
+		case filter.LessOrEqual:
+			return actualNumber <= expectedNumber
+		}
+		return false
+	}
+
+	a, e := strings.ToLower(fmt.Sprint(actual)), strings.ToLower(fmt.Sprint(expected))
+	switch op {
+	case filter.Equal:
+		return a == e
+	case filter.Contains:
+		return strings.Contains(a, e)
+	case filter.StartsWith:
+		return strings.HasPrefix(a, e)
+	case filter.EndsWith:
+		return strings.HasSuffix(a, e)
+	case filter.GreaterThan:
+		return a > e
+	case filter.GreaterOrEqual:
+		return a >= e
+	case filter.LessThan:
+		return a < e
+	case filter.LessOrEqual:
+		return a <= e
+	}
+
+	return false
+}
+
+// compareValues orders values for sorting, missing values are sorted last
+func compareValues(a, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	if x, ok := number(a); ok {
+		if y, ok := number(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+
+	return strings.Compare(strings.ToLower(fmt.Sprint(a)), strings.ToLower(fmt.Sprint(b)))
+}
+
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case string:
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(fmt.Sprint(v), 64)
+
+	return f, err == nil && v != nil
+}
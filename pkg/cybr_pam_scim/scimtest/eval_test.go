@@ -0,0 +1,107 @@
+package scimtest
+
+import (
+	"testing"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/filter"
+)
+
+const userSchema = "urn:ietf:params:scim:schemas:core:2.0:User"
+
+var testUser = `{
+	"id": "8",
+	"userName": "John.Smith",
+	"name": {"givenName": "John", "familyName": "Smith"},
+	"displayName": "John Smith",
+	"nickName": "",
+	"active": true,
+	"loginCount": 10,
+	"emails": [
+		{"type": "work", "value": "john.smith@example.com", "primary": true},
+		{"type": "home", "value": "john@example.org"}
+	],
+	"groups": [{"value": "3", "display": "Admins"}],
+	"meta": {"created": "2023-04-01T10:00:00Z"},
+	"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": {"department": "IT"}
+}`
+
+func TestMatches(t *testing.T) {
+	user, errResp := decodeBody([]byte(testUser))
+	if errResp != nil {
+		t.Fatal(errResp.body)
+	}
+
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{`userName eq "john.smith"`, true},
+		{`userName eq "jane.doe"`, false},
+		{`userName ne "john.smith"`, false},
+		{`userName ne "jane.doe"`, true},
+		{`userName co "SMITH"`, true},
+		{`userName sw "john"`, true},
+		{`userName ew "doe"`, false},
+		{`name.familyName eq "Smith"`, true},
+		{`urn:ietf:params:scim:schemas:core:2.0:User:name.givenName eq "John"`, true},
+		{`displayName pr`, true},
+		{`nickName pr`, false},
+		{`title pr`, false},
+		{`title eq null`, true},
+		{`displayName ne null`, true},
+		{`active eq true`, true},
+		{`active eq false`, false},
+		{`loginCount gt 5`, true},
+		{`loginCount ge 10`, true},
+		{`loginCount lt 10`, false},
+		{`loginCount le 9`, false},
+		{`loginCount eq "10"`, true},
+		{`meta.created gt "2023-01-01"`, true},
+		{`meta.created lt "2023-01-01"`, false},
+		{`emails.value ew "@example.org"`, true},
+		{`emails.type eq "other"`, false},
+		{`emails eq "john.smith@example.com"`, true},
+		{`emails[type eq "work" and primary eq true]`, true},
+		{`emails[type eq "home" and primary eq true]`, false},
+		{`emails[value co "example.org"]`, true},
+		{`groups[display eq "admins"]`, true},
+		{`urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department eq "it"`, true},
+		{`urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department eq "HR"`, false},
+		{`urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:manager.value pr`, false},
+		{`userName eq "jane.doe" or active eq true`, true},
+		{`userName eq "john.smith" and active eq false`, false},
+		{`not (userName eq "jane.doe")`, true},
+		{`not (userName eq "john.smith") or (emails[type eq "home"] and loginCount gt 1)`, true},
+	}
+	for _, tt := range tests {
+		expr, err := filter.Parse(tt.filter)
+		if err != nil {
+			t.Errorf("Parse(%s): %v", tt.filter, err)
+			continue
+		}
+		if got := matches(expr, user, userSchema); got != tt.want {
+			t.Errorf("matches(%s) = %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestCompareValues(t *testing.T) {
+	tests := []struct {
+		a, b interface{}
+		want int
+	}{
+		{"abc", "ABD", -1},
+		{"b", "A", 1},
+		{"Same", "same", 0},
+		{2, 10, -1},
+		{10.5, 2, 1},
+		{"x", nil, -1},
+		{nil, "x", 1},
+		{nil, nil, 0},
+	}
+	for _, tt := range tests {
+		if got := compareValues(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareValues(%v, %v) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
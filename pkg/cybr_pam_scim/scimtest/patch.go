@@ -0,0 +1,168 @@
+package scimtest
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/filter"
+)
+
+type patchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// applyPatch applies a single PATCH operation as described by RFC 7644 section 3.5.2
+func applyPatch(obj map[string]interface{}, operation patchOperation, schema string) *response {
+	op := strings.ToLower(operation.Op)
+	switch op {
+	case "add", "replace":
+		if operation.Value == nil {
+			return errorResponse(http.StatusBadRequest, "invalidValue", "%s operation requires a value", op)
+		}
+	case "remove":
+		if operation.Path == "" {
+			return errorResponse(http.StatusBadRequest, "noTarget", "remove operation requires a path")
+		}
+	default:
+		return errorResponse(http.StatusBadRequest, "invalidSyntax", "invalid op %q", operation.Op)
+	}
+
+	if operation.Path == "" {
+		values, ok := operation.Value.(map[string]interface{})
+		if !ok {
+			return errorResponse(http.StatusBadRequest, "invalidValue", "%s operation without a path requires an object value", op)
+		}
+		for key, value := range values {
+			if errResp := applyPatch(obj, patchOperation{Op: op, Path: key, Value: value}, schema); errResp != nil {
+				return errResp
+			}
+		}
+		return nil
+	}
+
+	attrPath, valueFilter, subAttribute, err := splitPatchPath(operation.Path)
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, "invalidPath", "invalid path %q: %v", operation.Path, err)
+	}
+	path := filter.ParsePath(attrPath)
+	parent := container(obj, path, schema, op != "remove")
+	if parent == nil {
+		return nil
+	}
+	key := lookupKey(parent, path.Name)
+
+	if valueFilter != nil {
+		return patchValues(parent, key, op, valueFilter, subAttribute, operation)
+	}
+
+	if path.SubAttribute != "" {
+		complexValue, ok := parent[key].(map[string]interface{})
+		if !ok {
+			if op == "remove" {
+				return nil
+			}
+			complexValue = map[string]interface{}{}
+			parent[key] = complexValue
+		}
+		parent, key = complexValue, lookupKey(complexValue, path.SubAttribute)
+	}
+
+	switch op {
+	case "remove":
+		delete(parent, key)
+	case "add":
+		existing, multiValued := parent[key].([]interface{})
+		added, isList := operation.Value.([]interface{})
+		if !multiValued || !isList {
+			parent[key] = operation.Value
+			return nil
+		}
+		for _, value := range added {
+			if !containsValue(existing, value) {
+				existing = append(existing, value)
+			}
+		}
+		parent[key] = existing
+	case "replace":
+		parent[key] = operation.Value
+	}
+
+	return nil
+}
+
+// patchValues applies an operation to the elements of a multi-valued attribute matching a value filter
+func patchValues(parent map[string]interface{}, key, op string, valueFilter filter.Expression, subAttribute string, operation patchOperation) *response {
+	elements, _ := parent[key].([]interface{})
+	result := make([]interface{}, 0, len(elements))
+	matched := false
+	for _, element := range elements {
+		complexValue, ok := element.(map[string]interface{})
+		if !ok || !matches(valueFilter, complexValue, "") {
+			result = append(result, element)
+			continue
+		}
+		matched = true
+		switch {
+		case op == "remove" && subAttribute == "":
+			continue
+		case op == "remove":
+			delete(complexValue, lookupKey(complexValue, subAttribute))
+		case subAttribute != "":
+			complexValue[lookupKey(complexValue, subAttribute)] = operation.Value
+		default:
+			replacement, ok := operation.Value.(map[string]interface{})
+			if !ok {
+				return errorResponse(http.StatusBadRequest, "invalidValue", "%s operation on %q requires an object value", op, operation.Path)
+			}
+			if op == "add" {
+				for k, v := range replacement {
+					complexValue[k] = v
+				}
+				replacement = complexValue
+			}
+			element = replacement
+		}
+		result = append(result, element)
+	}
+	if !matched {
+		if op == "remove" {
+			return nil
+		}
+		return errorResponse(http.StatusBadRequest, "noTarget", "no values match %q", operation.Path)
+	}
+	parent[key] = result
+
+	return nil
+}
+
+// splitPatchPath splits a path such as emails[type eq "work"].value into its attribute path,
+// value filter, and sub-attribute
+func splitPatchPath(path string) (string, filter.Expression, string, error) {
+	open := strings.Index(path, "[")
+	if open < 0 {
+		return path, nil, "", nil
+	}
+	end := strings.LastIndex(path, "]")
+	if end < open {
+		return "", nil, "", filter.ErrInvalidFilter
+	}
+	expr, err := filter.Parse(path[open+1 : end])
+	if err != nil {
+		return "", nil, "", err
+	}
+
+	return path[:open], expr, strings.TrimPrefix(path[end+1:], "."), nil
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, existing := range values {
+		if reflect.DeepEqual(existing, value) {
+			return true
+		}
+	}
+
+	return false
+}
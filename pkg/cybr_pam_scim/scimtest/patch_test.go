@@ -0,0 +1,174 @@
+package scimtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestApplyPatch(t *testing.T) {
+	const base = `{
+		"userName": "john.smith",
+		"name": {"givenName": "John"},
+		"emails": [
+			{"type": "work", "value": "john.smith@example.com", "primary": true},
+			{"type": "home", "value": "john@example.org"}
+		]
+	}`
+	const enterprise = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+
+	tests := []struct {
+		name      string
+		operation string
+		want      string
+		status    int
+	}{
+		{
+			name:      "replace attribute",
+			operation: `{"op": "replace", "path": "userName", "value": "john"}`,
+			want:      `{"emails":[{"primary":true,"type":"work","value":"john.smith@example.com"},{"type":"home","value":"john@example.org"}],"name":{"givenName":"John"},"userName":"john"}`,
+		},
+		{
+			name:      "replace attribute in any case",
+			operation: `{"op": "Replace", "path": "USERNAME", "value": "john"}`,
+			want:      `{"emails":[{"primary":true,"type":"work","value":"john.smith@example.com"},{"type":"home","value":"john@example.org"}],"name":{"givenName":"John"},"userName":"john"}`,
+		},
+		{
+			name:      "add sub-attribute",
+			operation: `{"op": "add", "path": "name.familyName", "value": "Smith"}`,
+			want:      `{"emails":[{"primary":true,"type":"work","value":"john.smith@example.com"},{"type":"home","value":"john@example.org"}],"name":{"familyName":"Smith","givenName":"John"},"userName":"john.smith"}`,
+		},
+		{
+			name:      "add values to multi-valued attribute",
+			operation: `{"op": "add", "path": "emails", "value": [{"type": "home", "value": "john@example.org"}, {"type": "other", "value": "js@example.net"}]}`,
+			want:      `{"emails":[{"primary":true,"type":"work","value":"john.smith@example.com"},{"type":"home","value":"john@example.org"},{"type":"other","value":"js@example.net"}],"name":{"givenName":"John"},"userName":"john.smith"}`,
+		},
+		{
+			name:      "replace multi-valued attribute",
+			operation: `{"op": "replace", "path": "emails", "value": [{"type": "work", "value": "john@example.com"}]}`,
+			want:      `{"emails":[{"type":"work","value":"john@example.com"}],"name":{"givenName":"John"},"userName":"john.smith"}`,
+		},
+		{
+			name:      "add without path",
+			operation: `{"op": "add", "value": {"nickName": "Johnny", "name.familyName": "Smith"}}`,
+			want:      `{"emails":[{"primary":true,"type":"work","value":"john.smith@example.com"},{"type":"home","value":"john@example.org"}],"name":{"familyName":"Smith","givenName":"John"},"nickName":"Johnny","userName":"john.smith"}`,
+		},
+		{
+			name:      "add extension attribute",
+			operation: `{"op": "add", "path": "` + enterprise + `:department", "value": "IT"}`,
+			want:      `{"emails":[{"primary":true,"type":"work","value":"john.smith@example.com"},{"type":"home","value":"john@example.org"}],"name":{"givenName":"John"},"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User":{"department":"IT"},"userName":"john.smith"}`,
+		},
+		{
+			name:      "core schema qualified path",
+			operation: `{"op": "replace", "path": "urn:ietf:params:scim:schemas:core:2.0:User:name.givenName", "value": "Johnny"}`,
+			want:      `{"emails":[{"primary":true,"type":"work","value":"john.smith@example.com"},{"type":"home","value":"john@example.org"}],"name":{"givenName":"Johnny"},"userName":"john.smith"}`,
+		},
+		{
+			name:      "remove attribute",
+			operation: `{"op": "remove", "path": "name"}`,
+			want:      `{"emails":[{"primary":true,"type":"work","value":"john.smith@example.com"},{"type":"home","value":"john@example.org"}],"userName":"john.smith"}`,
+		},
+		{
+			name:      "remove missing attribute",
+			operation: `{"op": "remove", "path": "title"}`,
+			want:      `{"emails":[{"primary":true,"type":"work","value":"john.smith@example.com"},{"type":"home","value":"john@example.org"}],"name":{"givenName":"John"},"userName":"john.smith"}`,
+		},
+		{
+			name:      "remove missing extension",
+			operation: `{"op": "remove", "path": "` + enterprise + `:department"}`,
+			want:      `{"emails":[{"primary":true,"type":"work","value":"john.smith@example.com"},{"type":"home","value":"john@example.org"}],"name":{"givenName":"John"},"userName":"john.smith"}`,
+		},
+		{
+			name:      "remove filtered values",
+			operation: `{"op": "remove", "path": "emails[type eq \"home\"]"}`,
+			want:      `{"emails":[{"primary":true,"type":"work","value":"john.smith@example.com"}],"name":{"givenName":"John"},"userName":"john.smith"}`,
+		},
+		{
+			name:      "remove sub-attribute of filtered values",
+			operation: `{"op": "remove", "path": "emails[type eq \"work\"].primary"}`,
+			want:      `{"emails":[{"type":"work","value":"john.smith@example.com"},{"type":"home","value":"john@example.org"}],"name":{"givenName":"John"},"userName":"john.smith"}`,
+		},
+		{
+			name:      "remove filtered values without match",
+			operation: `{"op": "remove", "path": "emails[type eq \"other\"]"}`,
+			want:      `{"emails":[{"primary":true,"type":"work","value":"john.smith@example.com"},{"type":"home","value":"john@example.org"}],"name":{"givenName":"John"},"userName":"john.smith"}`,
+		},
+		{
+			name:      "replace sub-attribute of filtered values",
+			operation: `{"op": "replace", "path": "emails[type eq \"work\"].value", "value": "john@example.com"}`,
+			want:      `{"emails":[{"primary":true,"type":"work","value":"john@example.com"},{"type":"home","value":"john@example.org"}],"name":{"givenName":"John"},"userName":"john.smith"}`,
+		},
+		{
+			name:      "replace filtered values",
+			operation: `{"op": "replace", "path": "emails[type eq \"home\"]", "value": {"type": "home", "value": "js@example.org"}}`,
+			want:      `{"emails":[{"primary":true,"type":"work","value":"john.smith@example.com"},{"type":"home","value":"js@example.org"}],"name":{"givenName":"John"},"userName":"john.smith"}`,
+		},
+		{
+			name:      "add to filtered values",
+			operation: `{"op": "add", "path": "emails[type eq \"home\"]", "value": {"display": "Home"}}`,
+			want:      `{"emails":[{"primary":true,"type":"work","value":"john.smith@example.com"},{"display":"Home","type":"home","value":"john@example.org"}],"name":{"givenName":"John"},"userName":"john.smith"}`,
+		},
+		{
+			name:      "replace filtered values without match",
+			operation: `{"op": "replace", "path": "emails[type eq \"other\"].value", "value": "js@example.net"}`,
+			status:    http.StatusBadRequest,
+		},
+		{
+			name:      "replace filtered values with a simple value",
+			operation: `{"op": "replace", "path": "emails[type eq \"home\"]", "value": "js@example.org"}`,
+			status:    http.StatusBadRequest,
+		},
+		{
+			name:      "invalid value filter",
+			operation: `{"op": "remove", "path": "emails[type eq]"}`,
+			status:    http.StatusBadRequest,
+		},
+		{
+			name:      "add without value",
+			operation: `{"op": "add", "path": "nickName"}`,
+			status:    http.StatusBadRequest,
+		},
+		{
+			name:      "add without path and object value",
+			operation: `{"op": "add", "value": "Johnny"}`,
+			status:    http.StatusBadRequest,
+		},
+		{
+			name:      "remove without path",
+			operation: `{"op": "remove"}`,
+			status:    http.StatusBadRequest,
+		},
+		{
+			name:      "invalid op",
+			operation: `{"op": "move", "path": "nickName", "value": "Johnny"}`,
+			status:    http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj, errResp := decodeBody([]byte(base))
+			if errResp != nil {
+				t.Fatal(errResp.body)
+			}
+			var operation patchOperation
+			if err := json.Unmarshal([]byte(tt.operation), &operation); err != nil {
+				t.Fatal(err)
+			}
+
+			errResp = applyPatch(obj, operation, userSchema)
+			if tt.status != 0 {
+				if errResp == nil || errResp.status != tt.status {
+					t.Fatalf("response = %+v, want status %d", errResp, tt.status)
+				}
+				return
+			}
+			if errResp != nil {
+				t.Fatalf("unexpected error response %d: %v", errResp.status, errResp.body)
+			}
+			got, _ := json.Marshal(obj)
+			if string(got) != tt.want {
+				t.Errorf("patched resource = %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
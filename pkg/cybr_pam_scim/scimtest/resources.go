@@ -0,0 +1,705 @@
+package scimtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/filter"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
+)
+
+const listResponseSchema = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+
+// resourceType describes an endpoint and the CyberArk semantics of its resources
+type resourceType struct {
+	endpoint string
+	name     string
+	filter   filter.Resource
+	// unique is the attribute which must be unique among the resources, if any
+	unique string
+	// writeOnly are the attributes which are stored but never returned, like passwords
+	writeOnly []string
+	// identify validates a new or modified resource and returns its id, the caller holds mu
+	identify func(s *Server, obj map[string]interface{}, id string) (string, *response)
+}
+
+var resourceTypes = []*resourceType{
+	{
+		endpoint:  "Users",
+		name:      "User",
+		filter:    filter.Users,
+		unique:    "userName",
+		writeOnly: []string{"password"},
+		identify: func(s *Server, obj map[string]interface{}, id string) (string, *response) {
+			if stringAttr(obj, "userName") == "" {
+				return "", errorResponse(http.StatusBadRequest, "invalidValue", "userName is required")
+			}
+			if id == "" {
+				id = s.nextId("Users")
+			}
+			return id, nil
+		},
+	},
+	{
+		endpoint: "Groups",
+		name:     "Group",
+		filter:   filter.Groups,
+		unique:   "displayName",
+		identify: func(s *Server, obj map[string]interface{}, id string) (string, *response) {
+			if stringAttr(obj, "displayName") == "" {
+				return "", errorResponse(http.StatusBadRequest, "invalidValue", "displayName is required")
+			}
+			if id == "" {
+				id = s.nextId("Groups")
+			}
+			return id, nil
+		},
+	},
+	{
+		// Safes are identified by their name
+		endpoint: "Containers",
+		name:     "Container",
+		filter:   filter.Containers,
+		unique:   "name",
+		identify: func(s *Server, obj map[string]interface{}, id string) (string, *response) {
+			name := stringAttr(obj, "name")
+			if name == "" {
+				return "", errorResponse(http.StatusBadRequest, "invalidValue", "name is required")
+			}
+			if id != "" && !strings.EqualFold(id, name) {
+				return "", errorResponse(http.StatusBadRequest, "mutability", "the name of a Safe cannot be modified")
+			}
+			if _, ok := s.safeIds[strings.ToLower(name)]; !ok {
+				s.safeIds[strings.ToLower(name)] = len(s.safeIds) + 2
+			}
+			return name, nil
+		},
+	},
+	{
+		// Safe members are identified by "<safe name>:<user or group name>"
+		endpoint: "ContainerPermissions",
+		name:     "ContainerPermission",
+		filter:   filter.ContainerPermissions,
+		identify: func(s *Server, obj map[string]interface{}, id string) (string, *response) {
+			safe := stringAttr(obj, "container.name")
+			if safe == "" {
+				safe = stringAttr(obj, "container.value")
+			}
+			if _, ok := s.stores["Containers"].get(safe); !ok {
+				return "", errorResponse(http.StatusNotFound, "invalidValue", "Safe %q does not exist", safe)
+			}
+			member := stringAttr(obj, "user.display")
+			for _, attr := range []string{"user.value", "group.display", "group.value"} {
+				if member == "" {
+					member = stringAttr(obj, attr)
+				}
+			}
+			if member == "" {
+				return "", errorResponse(http.StatusBadRequest, "invalidValue", "a user or group is required")
+			}
+			memberId := safe + ":" + member
+			if id != "" && !strings.EqualFold(id, memberId) {
+				return "", errorResponse(http.StatusBadRequest, "mutability", "the Safe and member of a permission cannot be modified")
+			}
+			return memberId, nil
+		},
+	},
+	{
+		// Accounts are identified by "<safe id>_<account number>", e.g. 92_3
+		endpoint: "PrivilegedData",
+		name:     "PrivilegedData",
+		filter:   filter.PrivilegedData,
+		identify: func(s *Server, obj map[string]interface{}, id string) (string, *response) {
+			if stringAttr(obj, "name") == "" {
+				return "", errorResponse(http.StatusBadRequest, "invalidValue", "name is required")
+			}
+			safe := stringAttr(obj, "urn:ietf:params:scim:schemas:cyberark:1.0:PrivilegedData:safe")
+			safeId, ok := s.safeIds[strings.ToLower(safe)]
+			if _, exists := s.stores["Containers"].get(safe); !ok || !exists {
+				return "", errorResponse(http.StatusNotFound, "invalidValue", "Safe %q does not exist", safe)
+			}
+			if id != "" {
+				if !strings.HasPrefix(id, strconv.Itoa(safeId)+"_") {
+					return "", errorResponse(http.StatusBadRequest, "mutability", "the Safe of an account cannot be modified")
+				}
+				return id, nil
+			}
+			key := "PrivilegedData:" + strconv.Itoa(safeId)
+			s.counters[key]++
+			return fmt.Sprintf("%d_%d", safeId, s.counters[key]), nil
+		},
+	},
+}
+
+func lookupResourceType(endpoint string) *resourceType {
+	for _, rt := range resourceTypes {
+		if strings.EqualFold(rt.endpoint, endpoint) {
+			return rt
+		}
+	}
+
+	return nil
+}
+
+// nextId returns the next numeric id of an endpoint, the caller must hold mu
+func (s *Server) nextId(endpoint string) string {
+	s.counters[endpoint]++
+	return strconv.Itoa(s.counters[endpoint])
+}
+
+// store keeps the resources of an endpoint in insertion order, ids are case insensitive
+type store struct {
+	ids       []string
+	resources map[string]map[string]interface{}
+}
+
+func newStore() *store {
+	return &store{resources: map[string]map[string]interface{}{}}
+}
+
+func (st *store) get(id string) (map[string]interface{}, bool) {
+	obj, ok := st.resources[strings.ToLower(id)]
+	return obj, ok
+}
+
+func (st *store) put(id string, obj map[string]interface{}) {
+	key := strings.ToLower(id)
+	if _, ok := st.resources[key]; !ok {
+		st.ids = append(st.ids, key)
+	}
+	st.resources[key] = obj
+}
+
+func (st *store) delete(id string) {
+	key := strings.ToLower(id)
+	delete(st.resources, key)
+	for i, existing := range st.ids {
+		if existing == key {
+			st.ids = append(st.ids[:i:i], st.ids[i+1:]...)
+			break
+		}
+	}
+}
+
+func (st *store) list() []map[string]interface{} {
+	resources := make([]map[string]interface{}, 0, len(st.ids))
+	for _, id := range st.ids {
+		resources = append(resources, st.resources[id])
+	}
+
+	return resources
+}
+
+// route handles a request for a path relative to the server (e.g. /scim/v2/Users/12)
+func (s *Server) route(method, path string, query url.Values, header http.Header, body []byte) *response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.handle(method, path, query, header, body)
+}
+
+// handle handles a request, the caller must hold mu
+func (s *Server) handle(method, path string, query url.Values, header http.Header, body []byte) *response {
+	segments := strings.Split(strings.Trim(trimPrefix(path), "/"), "/")
+	endpoint, id := segments[0], strings.Join(segments[1:], "/")
+
+	switch {
+	case strings.EqualFold(endpoint, "ServiceProviderConfig") && method == http.MethodGet:
+		return &response{status: http.StatusOK, body: s.config}
+	case strings.EqualFold(endpoint, "ResourceTypes") && method == http.MethodGet:
+		return &response{status: http.StatusOK, body: resourceTypesResponse()}
+	case strings.EqualFold(endpoint, "Schemas") && method == http.MethodGet:
+		return &response{status: http.StatusOK, body: schemasResponse()}
+	case strings.EqualFold(endpoint, "Bulk") && method == http.MethodPost:
+		return s.bulk(body)
+	}
+
+	rt := lookupResourceType(endpoint)
+	if rt == nil {
+		return errorResponse(http.StatusNotFound, "", "unknown endpoint %s", path)
+	}
+
+	switch {
+	case method == http.MethodGet && id == "":
+		return s.list(rt, query)
+	case method == http.MethodGet:
+		return s.get(rt, id, query, header)
+	case method == http.MethodPost && id == "":
+		obj, errResp := decodeBody(body)
+		if errResp != nil {
+			return errResp
+		}
+		created, errResp := s.create(rt, obj)
+		if errResp != nil {
+			return errResp
+		}
+		return resourceResponse(rt, http.StatusCreated, created)
+	case method == http.MethodPut && id != "":
+		return s.modify(rt, id, header, body, s.replace)
+	case method == http.MethodPatch && id != "":
+		return s.modify(rt, id, header, body, s.patch)
+	case method == http.MethodDelete && id != "":
+		obj, errResp := s.lookup(rt, id, header)
+		if errResp != nil {
+			return errResp
+		}
+		s.stores[rt.endpoint].delete(obj["id"].(string))
+		return &response{status: http.StatusNoContent}
+	}
+
+	return errorResponse(http.StatusMethodNotAllowed, "", "%s is not supported for %s", method, path)
+}
+
+func resourceResponse(rt *resourceType, status int, obj map[string]interface{}) *response {
+	obj = returned(rt, obj)
+	meta, _ := obj["meta"].(map[string]interface{})
+	header := http.Header{}
+	if version, ok := meta["version"].(string); ok {
+		header.Set("ETag", version)
+	}
+	if location, ok := meta["location"].(string); ok && status == http.StatusCreated {
+		header.Set("Location", location)
+	}
+
+	return &response{status: status, header: header, body: obj}
+}
+
+// returned returns the resource without its write-only attributes
+func returned(rt *resourceType, obj map[string]interface{}) map[string]interface{} {
+	if len(rt.writeOnly) == 0 {
+		return obj
+	}
+	result := make(map[string]interface{}, len(obj))
+	for key, value := range obj {
+		result[key] = value
+	}
+	for _, name := range rt.writeOnly {
+		delete(result, lookupKey(result, name))
+	}
+
+	return result
+}
+
+// lookup returns an existing resource after checking If-Match, the caller must hold mu
+func (s *Server) lookup(rt *resourceType, id string, header http.Header) (map[string]interface{}, *response) {
+	obj, ok := s.stores[rt.endpoint].get(id)
+	if !ok {
+		return nil, errorResponse(http.StatusNotFound, "", "%s %s not found", rt.name, id)
+	}
+	if ifMatch := header.Get("If-Match"); ifMatch != "" && ifMatch != "*" {
+		if !s.config.Etag.Supported {
+			return nil, errorResponse(http.StatusBadRequest, "", "ETags are not supported")
+		}
+		if !matchesETag(ifMatch, version(obj)) {
+			return nil, errorResponse(http.StatusPreconditionFailed, "", "%s %s has been modified, version %s does not match", rt.name, id, ifMatch)
+		}
+	}
+
+	return obj, nil
+}
+
+func (s *Server) get(rt *resourceType, id string, query url.Values, header http.Header) *response {
+	project, errResp := projection(rt, query)
+	if errResp != nil {
+		return errResp
+	}
+	obj, errResp := s.lookup(rt, id, header)
+	if errResp != nil {
+		return errResp
+	}
+	if ifNoneMatch := header.Get("If-None-Match"); ifNoneMatch != "" && s.config.Etag.Supported && matchesETag(ifNoneMatch, version(obj)) {
+		return &response{status: http.StatusNotModified, header: http.Header{"Etag": {version(obj)}}}
+	}
+
+	resp := resourceResponse(rt, http.StatusOK, obj)
+	if project != nil {
+		resp.body = project(obj)
+	}
+
+	return resp
+}
+
+func (s *Server) list(rt *resourceType, query url.Values) *response {
+	project, errResp := projection(rt, query)
+	if errResp != nil {
+		return errResp
+	}
+	resources := s.stores[rt.endpoint].list()
+
+	if f := query.Get("filter"); f != "" {
+		if !s.config.Filter.Supported {
+			return errorResponse(http.StatusBadRequest, "invalidFilter", "filtering is not supported")
+		}
+		expr, err := filter.Parse(f)
+		if err != nil {
+			return errorResponse(http.StatusBadRequest, "invalidFilter", "%v", err)
+		}
+		var matched []map[string]interface{}
+		for _, obj := range resources {
+			if matches(expr, obj, rt.filter.Schema) {
+				matched = append(matched, obj)
+			}
+		}
+		resources = matched
+	}
+
+	if sortBy := query.Get("sortBy"); sortBy != "" {
+		if !s.config.Sort.Supported {
+			return errorResponse(http.StatusBadRequest, "", "sorting is not supported")
+		}
+		path := filter.ParsePath(sortBy)
+		descending := strings.EqualFold(query.Get("sortOrder"), "descending")
+		sorted := append([]map[string]interface{}{}, resources...)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			less := compareValues(firstValue(sorted[i], path, rt.filter.Schema), firstValue(sorted[j], path, rt.filter.Schema))
+			if descending {
+				return less > 0
+			}
+			return less < 0
+		})
+		resources = sorted
+	}
+
+	startIndex, count := 1, len(resources)
+	if value := query.Get("startIndex"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return errorResponse(http.StatusBadRequest, "invalidValue", "invalid startIndex %q", value)
+		}
+		if n > 1 {
+			startIndex = n
+		}
+	}
+	if value := query.Get("count"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return errorResponse(http.StatusBadRequest, "invalidValue", "invalid count %q", value)
+		}
+		if n < 0 {
+			n = 0
+		}
+		count = n
+	}
+	if maxResults := s.config.Filter.MaxResults; maxResults > 0 && count > maxResults {
+		count = maxResults
+	}
+
+	page := []map[string]interface{}{}
+	if startIndex <= len(resources) {
+		end := startIndex - 1 + count
+		if end > len(resources) {
+			end = len(resources)
+		}
+		page = resources[startIndex-1 : end]
+	}
+	returnedPage := make([]map[string]interface{}, 0, len(page))
+	for _, obj := range page {
+		obj = returned(rt, obj)
+		if project != nil {
+			obj = project(obj)
+		}
+		returnedPage = append(returnedPage, obj)
+	}
+	page = returnedPage
+
+	return &response{
+		status: http.StatusOK,
+		body: map[string]interface{}{
+			"schemas":      []string{listResponseSchema},
+			"totalResults": len(resources),
+			"itemsPerPage": len(page),
+			"startIndex":   startIndex,
+			"Resources":    page,
+		},
+	}
+}
+
+// create stores a new resource, the caller must hold mu
+func (s *Server) create(rt *resourceType, obj map[string]interface{}) (map[string]interface{}, *response) {
+	delete(obj, "id")
+	id, errResp := rt.identify(s, obj, "")
+	if errResp != nil {
+		return nil, errResp
+	}
+	if _, exists := s.stores[rt.endpoint].get(id); exists {
+		return nil, errorResponse(http.StatusConflict, "uniqueness", "%s %s already exists", rt.name, id)
+	}
+	if errResp := s.checkUnique(rt, obj, ""); errResp != nil {
+		return nil, errResp
+	}
+
+	now := time.Now().UTC()
+	s.store(rt, id, obj, now, now)
+
+	return obj, nil
+}
+
+type modifier func(rt *resourceType, existing map[string]interface{}, body []byte) (map[string]interface{}, *response)
+
+// modify replaces or patches an existing resource, the caller must hold mu
+func (s *Server) modify(rt *resourceType, id string, header http.Header, body []byte, modify modifier) *response {
+	existing, errResp := s.lookup(rt, id, header)
+	if errResp != nil {
+		return errResp
+	}
+	id = existing["id"].(string)
+
+	obj, errResp := modify(rt, existing, body)
+	if errResp != nil {
+		return errResp
+	}
+	newId, errResp := rt.identify(s, obj, id)
+	if errResp != nil {
+		return errResp
+	}
+	if errResp := s.checkUnique(rt, obj, newId); errResp != nil {
+		return errResp
+	}
+
+	created := time.Now().UTC()
+	if meta, ok := existing["meta"].(map[string]interface{}); ok {
+		if t, err := time.Parse(time.RFC3339Nano, fmt.Sprint(meta["created"])); err == nil {
+			created = t
+		}
+	}
+	s.store(rt, newId, obj, created, time.Now().UTC())
+
+	return resourceResponse(rt, http.StatusOK, obj)
+}
+
+func (s *Server) replace(rt *resourceType, existing map[string]interface{}, body []byte) (map[string]interface{}, *response) {
+	obj, errResp := decodeBody(body)
+	if errResp != nil {
+		return nil, errResp
+	}
+	// Write-only attributes are never returned, so a replacement omitting them keeps them
+	for _, name := range rt.writeOnly {
+		if key := lookupKey(existing, name); existing[key] != nil && obj[lookupKey(obj, name)] == nil {
+			obj[key] = existing[key]
+		}
+	}
+
+	return obj, nil
+}
+
+func (s *Server) patch(rt *resourceType, existing map[string]interface{}, body []byte) (map[string]interface{}, *response) {
+	if !s.config.Patch.Supported {
+		return nil, errorResponse(http.StatusNotImplemented, "", "PATCH is not supported")
+	}
+	var request struct {
+		Schemas    []string          `json:"schemas"`
+		Operations []json.RawMessage `json:"Operations"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, errorResponse(http.StatusBadRequest, "invalidSyntax", "invalid PATCH request: %v", err)
+	}
+	if len(request.Operations) == 0 {
+		return nil, errorResponse(http.StatusBadRequest, "invalidValue", "a PATCH request requires operations")
+	}
+
+	obj := deepCopy(existing).(map[string]interface{})
+	for _, raw := range request.Operations {
+		var operation patchOperation
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		if err := dec.Decode(&operation); err != nil {
+			return nil, errorResponse(http.StatusBadRequest, "invalidSyntax", "invalid PATCH operation: %v", err)
+		}
+		if errResp := applyPatch(obj, operation, rt.filter.Schema); errResp != nil {
+			return nil, errResp
+		}
+	}
+
+	return obj, nil
+}
+
+// checkUnique reports a conflict if another resource has the same unique attribute, the caller must hold mu
+func (s *Server) checkUnique(rt *resourceType, obj map[string]interface{}, id string) *response {
+	if rt.unique == "" {
+		return nil
+	}
+	value := stringAttr(obj, rt.unique)
+	for _, other := range s.stores[rt.endpoint].list() {
+		if !strings.EqualFold(fmt.Sprint(other["id"]), id) && strings.EqualFold(stringAttr(other, rt.unique), value) {
+			return errorResponse(http.StatusConflict, "uniqueness", "%s with %s %q already exists", rt.name, rt.unique, value)
+		}
+	}
+
+	return nil
+}
+
+// store sets the id, schemas, and meta of a resource and stores it, the caller must hold mu
+func (s *Server) store(rt *resourceType, id string, obj map[string]interface{}, created, lastModified time.Time) {
+	s.version++
+	obj["id"] = id
+	if schemas, ok := obj["schemas"].([]interface{}); !ok || len(schemas) == 0 {
+		obj["schemas"] = []interface{}{rt.filter.Schema}
+	}
+	obj["meta"] = map[string]interface{}{
+		"resourceType": rt.name,
+		"created":      created.Format(time.RFC3339Nano),
+		"lastModified": lastModified.Format(time.RFC3339Nano),
+		"location":     fmt.Sprintf("%s/%s/%s", s.baseURL(), rt.endpoint, url.PathEscape(id)),
+		"version":      fmt.Sprintf(`W/"%d"`, s.version),
+	}
+	s.stores[rt.endpoint].put(id, obj)
+}
+
+func (s *Server) baseURL() string {
+	if s.Server == nil {
+		return ""
+	}
+
+	return s.URL
+}
+
+func version(obj map[string]interface{}) string {
+	meta, _ := obj["meta"].(map[string]interface{})
+	v, _ := meta["version"].(string)
+
+	return v
+}
+
+// matchesETag reports whether a If-Match or If-None-Match header matches the version,
+// weak and strong validators are compared equally
+func matchesETag(header, version string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(version, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func decodeBody(body []byte) (map[string]interface{}, *response) {
+	var obj map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&obj); err != nil || obj == nil {
+		return nil, errorResponse(http.StatusBadRequest, "invalidSyntax", "invalid request body: %v", err)
+	}
+
+	return obj, nil
+}
+
+func decodeResource(resource interface{}) (map[string]interface{}, *response) {
+	encoded, err := json.Marshal(resource)
+	if err != nil {
+		return nil, errorResponse(http.StatusBadRequest, "invalidSyntax", "%v", err)
+	}
+
+	return decodeBody(encoded)
+}
+
+func deepCopy(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(value))
+		for k, elem := range value {
+			copied[k] = deepCopy(elem)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(value))
+		for i, elem := range value {
+			copied[i] = deepCopy(elem)
+		}
+		return copied
+	}
+
+	return v
+}
+
+func resourceTypesResponse() types.ResourceTypes {
+	resources := make([]types.ResourceType, 0, len(resourceTypes))
+	for _, rt := range resourceTypes {
+		resourceType := types.ResourceType{
+			Schemas:  []string{"urn:ietf:params:scim:schemas:core:2.0:ResourceType"},
+			Id:       rt.name,
+			Name:     rt.name,
+			Endpoint: "/" + rt.endpoint,
+			Schema:   rt.filter.Schema,
+			Meta:     types.Meta{ResourceType: "ResourceType"},
+		}
+		for _, schema := range schemas(rt) {
+			if schema.Id != rt.filter.Schema {
+				resourceType.SchemaExtensions = append(resourceType.SchemaExtensions, types.SchemaExtensions{Schema: schema.Id})
+			}
+		}
+		resources = append(resources, resourceType)
+	}
+
+	return types.ResourceTypes{
+		Schemas:      []string{listResponseSchema},
+		TotalResults: len(resources),
+		ItemsPerPage: len(resources),
+		StartIndex:   1,
+		Resources:    resources,
+	}
+}
+
+func schemasResponse() types.Schemas {
+	var resources []types.Schema
+	for _, rt := range resourceTypes {
+		resources = append(resources, schemas(rt)...)
+	}
+
+	return types.Schemas{
+		Schemas:      []string{listResponseSchema},
+		TotalResults: len(resources),
+		ItemsPerPage: len(resources),
+		StartIndex:   1,
+		Resources:    resources,
+	}
+}
+
+// schemas derives the core and extension schemas of a resource type from its filterable attributes
+func schemas(rt *resourceType) []types.Schema {
+	var ids []string
+	byId := map[string]*types.Schema{}
+	for _, attr := range rt.filter.Attributes {
+		path := filter.ParsePath(attr)
+		id := path.URI
+		if id == "" {
+			id = rt.filter.Schema
+		}
+		schema, ok := byId[id]
+		if !ok {
+			name := id[strings.LastIndex(id, ":")+1:]
+			schema = &types.Schema{Id: id, Name: name, Meta: types.Meta{ResourceType: "Schema"}}
+			byId[id] = schema
+			ids = append(ids, id)
+		}
+
+		index := -1
+		for i := range schema.Attributes {
+			if schema.Attributes[i].Name == path.Name {
+				index = i
+			}
+		}
+		if index < 0 {
+			schema.Attributes = append(schema.Attributes, types.Attributes{Name: path.Name, Type: "string"})
+			index = len(schema.Attributes) - 1
+		}
+		if path.SubAttribute != "" {
+			schema.Attributes[index].Type = "complex"
+			schema.Attributes[index].SubAttributes = append(schema.Attributes[index].SubAttributes, types.SubAttributes{Name: path.SubAttribute, Type: "string"})
+		}
+	}
+
+	result := make([]types.Schema, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, *byId[id])
+	}
+
+	return result
+}
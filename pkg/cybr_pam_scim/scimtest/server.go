@@ -0,0 +1,336 @@
+// Package scimtest provides an in-memory CyberArk SCIM server for tests. It serves the
+// Users, Groups, Containers, ContainerPermissions, and PrivilegedData endpoints with
+// filtering, sorting, pagination, attributes and excludedAttributes, PATCH, bulk requests, ETags,
+// and SCIM error responses, as well as the discovery endpoints. Faults such as throttling,
+// server errors, and latency may be injected to exercise retries and error handling.
+// User passwords are stored but never returned, Resource reads the stored password.
+//
+// Example Usage:
+//		srv := scimtest.NewServer()
+//		defer srv.Close()
+//
+//		s := srv.NewService()
+//		user, err := s.AddUser(context.Background(), types.User{UserName: "john.smith"})
+//
+//		srv.InjectFault(scimtest.Fault{Method: http.MethodGet, Path: "/Users", Status: http.StatusTooManyRequests, RetryAfter: time.Second, Times: 1})
+//
+package scimtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
+)
+
+// DefaultToken is the bearer token accepted by a Server
+const DefaultToken = "scimtest-token"
+
+const errorSchema = "urn:ietf:params:scim:api:messages:2.0:Error"
+
+// Server is an in-memory SCIM server backed by an httptest.Server using TLS.
+// Requests must carry the bearer token in Token. A Server is safe for concurrent use.
+type Server struct {
+	*httptest.Server
+
+	// Token is the bearer token expected by the server
+	Token string
+
+	mu       sync.Mutex
+	config   types.ScimConfig
+	stores   map[string]*store
+	safeIds  map[string]int
+	counters map[string]int
+	version  int
+	faults   []*Fault
+	requests int
+}
+
+// Fault makes the server fail or delay matching requests. Method and Path are optional,
+// Path matches requests whose path starts with it after the endpoint prefix (e.g. /Users).
+// Status zero only applies the latency. Times limits the number of affected requests,
+// zero affects every matching request until ClearFaults is called.
+type Fault struct {
+	Method     string
+	Path       string
+	Status     int
+	RetryAfter time.Duration
+	Latency    time.Duration
+	Times      int
+}
+
+// NewServer starts a Server supporting every SCIM feature. The caller must call Close.
+func NewServer() *Server {
+	s := &Server{
+		Token: DefaultToken,
+		config: types.ScimConfig{
+			Schemas:        []string{"urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"},
+			Patch:          types.Patch{Supported: true},
+			Bulk:           types.Bulk{Supported: true, MaxOperations: 1000, MaxPayloadSize: 1 << 20},
+			Filter:         types.Filter{Supported: true, MaxResults: 1000},
+			ChangePassword: types.ChangePassword{Supported: true},
+			Sort:           types.Sort{Supported: true},
+			Etag:           types.Etag{Supported: true},
+			AuthenticationSchemes: []types.AuthenticationSchemes{
+				{Type: "oauthbearertoken", Name: "OAuth Bearer Token", Description: "Authentication scheme using the OAuth Bearer Token Standard"},
+			},
+		},
+	}
+	s.Reset()
+	s.Server = httptest.NewTLSServer(s)
+
+	return s
+}
+
+// NewService returns a Service sending its requests to the server. Retries are disabled,
+// use SetRetryPolicy to exercise them.
+func (s *Server) NewService() *cybr_pam_scim.Service {
+	return cybr_pam_scim.NewServiceWithClient(cybr_pam_scim.NewClient(s.Client(), cybr_pam_scim.Options{ApiURL: s.URL}))
+}
+
+// Client returns an http.Client which trusts the server certificate and authenticates with Token
+func (s *Server) Client() *http.Client {
+	client := *s.Server.Client()
+	client.Transport = &bearerTransport{token: s.Token, base: client.Transport}
+
+	return &client
+}
+
+type bearerTransport struct {
+	token string
+	base  http.RoundTripper
+}
+
+func (t *bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := req.Clone(req.Context())
+	r.Header.Set("Authorization", "Bearer "+t.token)
+	r.Header.Set("Content-Type", "application/json")
+
+	return t.base.RoundTrip(r)
+}
+
+// Config returns the service provider configuration served by the server
+func (s *Server) Config() types.ScimConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.config
+}
+
+// SetConfig replaces the service provider configuration. Sorting, PATCH, bulk, and ETags are
+// refused when they are not supported and page sizes are limited to Filter.MaxResults.
+func (s *Server) SetConfig(config types.ScimConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.config = config
+}
+
+// InjectFault adds a fault, faults are matched in the order they were added
+func (s *Server) InjectFault(fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = append(s.faults, &fault)
+}
+
+// ClearFaults removes every fault
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = nil
+}
+
+// Requests returns the number of requests received, including rejected requests
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests
+}
+
+// Reset removes every resource
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stores = make(map[string]*store, len(resourceTypes))
+	for _, rt := range resourceTypes {
+		s.stores[rt.endpoint] = newStore()
+	}
+	s.safeIds = map[string]int{}
+	s.counters = map[string]int{}
+}
+
+// Seed stores a resource without a request, e.g. a types.User, and returns its id. The
+// endpoint is the name of the resource endpoint (e.g. Users, Containers).
+func (s *Server) Seed(endpoint string, resource interface{}) (string, error) {
+	rt := lookupResourceType(endpoint)
+	if rt == nil {
+		return "", fmt.Errorf("unknown endpoint %s", endpoint)
+	}
+	obj, errResp := decodeResource(resource)
+	if errResp != nil {
+		return "", fmt.Errorf("invalid resource: %v", errResp.body)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	created, errResp := s.create(rt, obj)
+	if errResp != nil {
+		return "", fmt.Errorf("failed to seed %s: %v", endpoint, errResp.body)
+	}
+
+	return created["id"].(string), nil
+}
+
+// Resource decodes the stored resource into v and reports whether it exists
+func (s *Server) Resource(endpoint string, id string, v interface{}) (bool, error) {
+	rt := lookupResourceType(endpoint)
+	if rt == nil {
+		return false, fmt.Errorf("unknown endpoint %s", endpoint)
+	}
+
+	s.mu.Lock()
+	obj, ok := s.stores[rt.endpoint].get(id)
+	var encoded []byte
+	if ok {
+		encoded, _ = json.Marshal(obj)
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		return false, nil
+	}
+
+	return true, json.Unmarshal(encoded, v)
+}
+
+// response is the result of handling a request, shared by regular and bulk requests
+type response struct {
+	status int
+	header http.Header
+	body   interface{}
+}
+
+func errorResponse(status int, scimType string, format string, args ...interface{}) *response {
+	return &response{
+		status: status,
+		body: types.ErrorResponse{
+			Schemas:  []string{errorSchema},
+			ScimType: scimType,
+			Detail:   fmt.Sprintf(format, args...),
+			Status:   json.Number(strconv.Itoa(status)),
+		},
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests++
+	fault := s.matchFault(r)
+	s.mu.Unlock()
+
+	if fault != nil {
+		if fault.Latency > 0 {
+			select {
+			case <-time.After(fault.Latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if fault.Status != 0 {
+			if fault.RetryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int((fault.RetryAfter+time.Second-1)/time.Second)))
+			}
+			s.write(w, errorResponse(fault.Status, "", "injected fault"))
+			return
+		}
+	}
+
+	if r.Header.Get("Authorization") != "Bearer "+s.Token {
+		s.write(w, errorResponse(http.StatusUnauthorized, "", "missing or invalid bearer token"))
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.write(w, errorResponse(http.StatusBadRequest, "", "failed to read request body: %v", err))
+		return
+	}
+
+	s.write(w, s.route(r.Method, r.URL.Path, r.URL.Query(), r.Header, body))
+}
+
+// matchFault returns the first fault matching the request, the caller must hold mu
+func (s *Server) matchFault(r *http.Request) *Fault {
+	for i, fault := range s.faults {
+		if fault.Method != "" && !strings.EqualFold(fault.Method, r.Method) {
+			continue
+		}
+		if fault.Path != "" && !strings.HasPrefix(strings.ToLower(trimPrefix(r.URL.Path)), strings.ToLower(fault.Path)) {
+			continue
+		}
+		if fault.Times > 0 {
+			fault.Times--
+			if fault.Times == 0 {
+				s.faults = append(s.faults[:i:i], s.faults[i+1:]...)
+			}
+		}
+		return fault
+	}
+
+	return nil
+}
+
+func (s *Server) write(w http.ResponseWriter, resp *response) {
+	for key, values := range resp.header {
+		w.Header()[key] = values
+	}
+	if resp.body == nil {
+		w.WriteHeader(resp.status)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(resp.body); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/scim+json")
+	w.WriteHeader(resp.status)
+	w.Write(buf.Bytes())
+}
+
+// trimPrefix removes everything before the endpoint name, e.g. /scim/v2/Users/12 becomes /Users/12
+func trimPrefix(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range segments {
+		if lookupResourceType(segment) != nil || isServiceEndpoint(segment) {
+			return "/" + strings.Join(segments[i:], "/")
+		}
+	}
+
+	return path
+}
+
+func isServiceEndpoint(segment string) bool {
+	for _, endpoint := range []string{"ServiceProviderConfig", "ResourceTypes", "Schemas", "Bulk"} {
+		if strings.EqualFold(segment, endpoint) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,283 @@
+package scimtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
+)
+
+// do sends a request to the server and decodes the response body into v when it is not nil
+func do(t *testing.T, s *Server, method, path string, header http.Header, body string, v interface{}) *http.Response {
+	t.Helper()
+	r, err := http.NewRequest(method, s.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, values := range header {
+		r.Header[key] = values
+	}
+	resp, err := s.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if v != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, v); err != nil {
+			t.Fatalf("%s %s: %v: %s", method, path, err, data)
+		}
+	}
+
+	return resp
+}
+
+func TestBulkOrdering(t *testing.T) {
+	tests := []struct {
+		name         string
+		failOnErrors int
+		operations   string
+		// statuses of the operations in the response, in order
+		statuses []string
+	}{
+		{
+			name: "references to earlier operations",
+			operations: `[
+				{"method": "POST", "bulkId": "user", "path": "/Users", "data": {"userName": "john.smith"}},
+				{"method": "POST", "bulkId": "group", "path": "/Groups", "data": {"displayName": "Admins", "members": [{"value": "bulkId:user"}]}},
+				{"method": "PATCH", "path": "/Users/bulkId:user", "data": {"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "replace", "path": "active", "value": true}]}},
+				{"method": "DELETE", "path": "/Groups/bulkId:group"}
+			]`,
+			statuses: []string{"201", "201", "200", "204"},
+		},
+		{
+			name: "reference to a later operation",
+			operations: `[
+				{"method": "POST", "bulkId": "group", "path": "/Groups", "data": {"displayName": "Admins", "members": [{"value": "bulkId:user"}]}},
+				{"method": "POST", "bulkId": "user", "path": "/Users", "data": {"userName": "john.smith"}}
+			]`,
+			statuses: []string{"409", "201"},
+		},
+		{
+			name: "failed operations are reported in order",
+			operations: `[
+				{"method": "POST", "bulkId": "a", "path": "/Users", "data": {"userName": "john.smith"}},
+				{"method": "POST", "bulkId": "b", "path": "/Users", "data": {"userName": "john.smith"}},
+				{"method": "DELETE", "path": "/Users/404"},
+				{"method": "POST", "path": "/Unknown", "data": {}},
+				{"method": "POST", "bulkId": "c", "path": "/Users", "data": {"userName": "jane.doe"}}
+			]`,
+			statuses: []string{"201", "409", "404", "400", "201"},
+		},
+		{
+			name:         "failOnErrors stops processing",
+			failOnErrors: 2,
+			operations: `[
+				{"method": "POST", "bulkId": "a", "path": "/Users", "data": {"userName": "john.smith"}},
+				{"method": "POST", "bulkId": "b", "path": "/Users", "data": {"userName": "john.smith"}},
+				{"method": "POST", "bulkId": "c", "path": "/Users", "data": {"userName": "jane.doe"}},
+				{"method": "DELETE", "path": "/Users/404"},
+				{"method": "POST", "bulkId": "d", "path": "/Users", "data": {"userName": "mary.major"}}
+			]`,
+			statuses: []string{"201", "409", "201", "404"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer()
+			defer s.Close()
+
+			request := fmt.Sprintf(`{"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"], "failOnErrors": %d, "Operations": %s}`, tt.failOnErrors, tt.operations)
+			var response types.BulkResponse
+			if resp := do(t, s, http.MethodPost, "/Bulk", nil, request, &response); resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+			}
+			var statuses []string
+			for _, operation := range response.Operations {
+				statuses = append(statuses, operation.Status.String())
+			}
+			if strings.Join(statuses, ",") != strings.Join(tt.statuses, ",") {
+				t.Errorf("statuses = %v, want %v", statuses, tt.statuses)
+			}
+		})
+	}
+}
+
+func TestBulkResolvesReferences(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	request := `{"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"], "Operations": [
+		{"method": "POST", "bulkId": "user", "path": "/Users", "data": {"userName": "john.smith"}},
+		{"method": "POST", "bulkId": "group", "path": "/Groups", "data": {"displayName": "Admins", "members": [{"value": "bulkId:user"}]}}
+	]}`
+	var response types.BulkResponse
+	do(t, s, http.MethodPost, "/Bulk", nil, request, &response)
+	if len(response.Operations) != 2 || response.Operations[0].BulkId != "user" || response.Operations[1].BulkId != "group" {
+		t.Fatalf("operations = %+v, want the user and the group in order", response.Operations)
+	}
+
+	userId := response.Operations[0].Location[strings.LastIndex(response.Operations[0].Location, "/")+1:]
+	groupId := response.Operations[1].Location[strings.LastIndex(response.Operations[1].Location, "/")+1:]
+	var group types.Group
+	if ok, err := s.Resource("Groups", groupId, &group); !ok || err != nil {
+		t.Fatalf("group %s not found: %v", groupId, err)
+	}
+	if len(group.Members) != 1 || group.Members[0].Value != userId {
+		t.Errorf("members = %+v, want the user %s", group.Members, userId)
+	}
+}
+
+func TestFaultTimes(t *testing.T) {
+	tests := []struct {
+		name  string
+		fault Fault
+		// requests sent in order, as "<method> <path>"
+		requests []string
+		statuses []int
+	}{
+		{
+			name:     "limited number of requests",
+			fault:    Fault{Method: http.MethodGet, Path: "/Users", Status: http.StatusServiceUnavailable, Times: 2},
+			requests: []string{"GET /Users", "GET /Users", "GET /Users"},
+			statuses: []int{503, 503, 200},
+		},
+		{
+			name:     "every request",
+			fault:    Fault{Status: http.StatusTooManyRequests},
+			requests: []string{"GET /Users", "GET /Groups", "GET /Users"},
+			statuses: []int{429, 429, 429},
+		},
+		{
+			name:     "method mismatch does not count",
+			fault:    Fault{Method: http.MethodPost, Status: http.StatusInternalServerError, Times: 1},
+			requests: []string{"GET /Users", "GET /Users", "POST /Groups", "POST /Groups"},
+			statuses: []int{200, 200, 500, 400},
+		},
+		{
+			name:     "path prefix",
+			fault:    Fault{Path: "/groups", Status: http.StatusBadGateway, Times: 1},
+			requests: []string{"GET /Users", "GET /Groups/3", "GET /Groups/3"},
+			statuses: []int{200, 502, 404},
+		},
+		{
+			name:     "latency only",
+			fault:    Fault{Path: "/Users", Latency: time.Millisecond, Times: 1},
+			requests: []string{"GET /Users", "GET /Users"},
+			statuses: []int{200, 200},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer()
+			defer s.Close()
+			s.InjectFault(tt.fault)
+
+			for i, request := range tt.requests {
+				method, path, _ := strings.Cut(request, " ")
+				if resp := do(t, s, method, path, nil, "{}", nil); resp.StatusCode != tt.statuses[i] {
+					t.Errorf("request %d (%s) status = %d, want %d", i+1, request, resp.StatusCode, tt.statuses[i])
+				}
+			}
+			if got := s.Requests(); got != len(tt.requests) {
+				t.Errorf("requests = %d, want %d", got, len(tt.requests))
+			}
+		})
+	}
+}
+
+func TestFaultRetryAfter(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.InjectFault(Fault{Status: http.StatusTooManyRequests, RetryAfter: 1500 * time.Millisecond, Times: 1})
+
+	resp := do(t, s, http.MethodGet, "/Users", nil, "", nil)
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "2" {
+		t.Errorf("status = %d and Retry-After = %q, want 429 and 2", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+
+	s.InjectFault(Fault{Status: http.StatusServiceUnavailable})
+	s.ClearFaults()
+	if resp := do(t, s, http.MethodGet, "/Users", nil, "", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("status after ClearFaults = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestETagPreconditions(t *testing.T) {
+	const patch = `{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "replace", "path": "nickName", "value": "Johnny"}]}`
+	const stale = `W/"999"`
+
+	tests := []struct {
+		name   string
+		method string
+		header string
+		// current builds the header value from the current version of the resource
+		current func(version string) string
+		body    string
+		status  int
+	}{
+		{"GET with stale If-Match", http.MethodGet, "If-Match", func(string) string { return stale }, "", http.StatusPreconditionFailed},
+		{"GET with If-None-Match", http.MethodGet, "If-None-Match", func(v string) string { return v }, "", http.StatusNotModified},
+		{"GET with stale If-None-Match", http.MethodGet, "If-None-Match", func(string) string { return stale }, "", http.StatusOK},
+		{"PUT with stale If-Match", http.MethodPut, "If-Match", func(string) string { return stale }, `{"userName": "john"}`, http.StatusPreconditionFailed},
+		{"PUT with strong If-Match", http.MethodPut, "If-Match", func(v string) string { return strings.TrimPrefix(v, "W/") }, `{"userName": "john"}`, http.StatusOK},
+		{"PATCH with stale If-Match", http.MethodPatch, "If-Match", func(string) string { return stale }, patch, http.StatusPreconditionFailed},
+		{"PATCH with a list of If-Match", http.MethodPatch, "If-Match", func(v string) string { return stale + ", " + v }, patch, http.StatusOK},
+		{"PATCH with wildcard If-Match", http.MethodPatch, "If-Match", func(string) string { return "*" }, patch, http.StatusOK},
+		{"DELETE with stale If-Match", http.MethodDelete, "If-Match", func(string) string { return stale }, "", http.StatusPreconditionFailed},
+		{"DELETE with If-Match", http.MethodDelete, "If-Match", func(v string) string { return v }, "", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer()
+			defer s.Close()
+			var user types.User
+			do(t, s, http.MethodPost, "/Users", nil, `{"userName": "john.smith"}`, &user)
+			// Advance the version counter so the previous version of the resource is also stale
+			do(t, s, http.MethodPost, "/Users", nil, `{"userName": "jane.doe"}`, nil)
+
+			var errResp types.ErrorResponse
+			resp := do(t, s, tt.method, "/Users/"+user.Id, http.Header{tt.header: {tt.current(user.Meta.Version)}}, tt.body, &errResp)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			switch tt.status {
+			case http.StatusPreconditionFailed:
+				if errResp.Status != "412" || errResp.Detail == "" {
+					t.Errorf("response = %+v, want a SCIM error", errResp)
+				}
+			case http.StatusNotModified:
+				if resp.Header.Get("ETag") != user.Meta.Version {
+					t.Errorf("ETag = %q, want %q", resp.Header.Get("ETag"), user.Meta.Version)
+				}
+			}
+			if tt.method != http.MethodGet && tt.status == http.StatusOK && resp.Header.Get("ETag") == user.Meta.Version {
+				t.Errorf("ETag = %q, want a new version", resp.Header.Get("ETag"))
+			}
+		})
+	}
+}
+
+func TestETagUnsupported(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	config := s.Config()
+	config.Etag.Supported = false
+	s.SetConfig(config)
+	id, err := s.Seed("Users", types.User{UserName: "john.smith"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp := do(t, s, http.MethodGet, "/Users/"+id, http.Header{"If-Match": {`W/"1"`}}, "", nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("If-Match status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+	if resp := do(t, s, http.MethodGet, "/Users/"+id, http.Header{"If-None-Match": {"*"}}, "", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("If-None-Match status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
@@ -1,41 +1,87 @@
 package cybr_pam_scim
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"sync"
+	"time"
 
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
 	"golang.org/x/oauth2"
 )
 
+// Service provides the User, Group, Container, Container Permission, and Privileged Data
+// functions of the SCIM API. A Service is safe for concurrent use by multiple goroutines,
+// every function allocates and returns its own result.
 type Service struct {
 	client *Client
-}
 
-type transport struct {
-	token string
+	capabilitiesMu     sync.Mutex // guards the fields below
+	cachedCapabilities *Capabilities
+	discovery          *discoveryCall
+	discoveryRetryAt   time.Time
 }
 
+// NewService returns a Service authenticated with a fixed Oauth2 token. The token is
+// not refreshed, use NewServiceWithTokenSource for long running processes. Use New to
+// configure the HTTP client, base URL, or logging.
 func NewService(clientURL string, clientApiEndpoint string, clientApiVersion string, verbose bool, authToken *oauth2.Token) *Service {
-	t := transport{
-		token: authToken.AccessToken,
-	}
+	return NewServiceWithTokenSource(clientURL, clientApiEndpoint, clientApiVersion, verbose, oauth2.StaticTokenSource(authToken))
+}
+
+// NewServiceWithTokenSource returns a Service which obtains its Oauth2 tokens from the
+// provided oauth2.TokenSource. A new token is requested shortly before the current token
+// expires, and once more if the SCIM API rejects a request with a 401 status code.
+//
+// Example Usage:
+//		ts := cybr_pam_scim.OauthCredClientTokenSource(clientId, clientSecret, clientAppId, clientUrl)
+//		s := cybr_pam_scim.NewServiceWithTokenSource(clientUrl, "scim", "v2", false, ts)
+//
+func NewServiceWithTokenSource(clientURL string, clientApiEndpoint string, clientApiVersion string, verbose bool, tokenSource oauth2.TokenSource) *Service {
+	return NewServiceWithTransport(clientURL, clientApiEndpoint, clientApiVersion, verbose, tokenSource, nil)
+}
 
+// NewServiceWithTransport is NewServiceWithTokenSource with the http.RoundTripper used to send the
+// authenticated requests, e.g. an *http.Transport with proxy and TLS settings. A nil base uses
+// http.DefaultTransport.
+//
+// Example Usage:
+//		base := http.DefaultTransport.(*http.Transport).Clone()
+//		base.Proxy = http.ProxyURL(proxyURL)
+//		s := cybr_pam_scim.NewServiceWithTransport(clientUrl, "scim", "v2", false, ts, base)
+//
+func NewServiceWithTransport(clientURL string, clientApiEndpoint string, clientApiVersion string, verbose bool, tokenSource oauth2.TokenSource, base http.RoundTripper) *Service {
+	return newServiceConfig([]ServiceOption{
+		WithBaseURL(fmt.Sprintf("https://%s/%s/%s", clientURL, clientApiEndpoint, clientApiVersion)),
+		WithVerbose(verbose),
+		WithTokenSource(tokenSource),
+		WithTransport(base),
+	}).service()
+}
+
+// NewServiceWithClient returns a Service which sends its requests with the provided Client.
+// The Client is responsible for authentication, e.g. a Client created from the http.Client
+// of a scimtest.Server.
+//
+// Example Usage:
+//		client := cybr_pam_scim.NewClient(httpClient, cybr_pam_scim.Options{ApiURL: "https://example.my.idaptive.app/scim/v2"})
+//		s := cybr_pam_scim.NewServiceWithClient(client)
+//
+func NewServiceWithClient(client *Client) *Service {
 	return &Service{
-		client: NewClient(
-			&http.Client{Transport: &t},
-			Options{
-				ApiURL:  fmt.Sprintf("https://%s/%s/%s", clientURL, clientApiEndpoint, clientApiVersion),
-				Verbose: verbose,
-			},
-		),
+		client: client,
 	}
 }
 
-func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
-	r := req.Clone(req.Context())
-	r.Header.Add("Content-Type", "application/json")
-	r.Header.Add("Accept", "application/json")
-	r.Header.Add("Authorization", "Bearer "+t.token)
+// validatePatch checks the patch request and that the service provider supports PATCH operations
+func (s *Service) validatePatch(ctx context.Context, patch *types.PatchRequest) error {
+	if err := patch.Validate(); err != nil {
+		return err
+	}
+	if !s.capabilities(ctx).PatchSupported() {
+		return ErrPatchNotSupported
+	}
 
-	return http.DefaultTransport.RoundTrip(r)
+	return nil
 }
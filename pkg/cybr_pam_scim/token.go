@@ -0,0 +1,71 @@
+package cybr_pam_scim
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// tokenExpiryDelta is how long before its expiry a cached token is considered
+// stale and a new one is requested from the underlying oauth2.TokenSource.
+const tokenExpiryDelta = 30 * time.Second
+
+// now returns the current time, tests replace it to control the expiry of tokens
+var now = time.Now
+
+// refreshingTokenSource caches the token returned by the wrapped oauth2.TokenSource
+// and requests a new one shortly before expiry or after the cached token was rejected.
+type refreshingTokenSource struct {
+	mu     sync.Mutex
+	source oauth2.TokenSource
+	token  *oauth2.Token
+}
+
+func newRefreshingTokenSource(source oauth2.TokenSource) *refreshingTokenSource {
+	return &refreshingTokenSource{
+		source: source,
+	}
+}
+
+// Token returns the cached token if it is still usable, otherwise a new token is
+// requested from the wrapped oauth2.TokenSource.
+func (r *refreshingTokenSource) Token() (*oauth2.Token, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.token != nil && !expiresSoon(r.token) {
+		return r.token, nil
+	}
+
+	token, err := r.source.Token()
+	if err != nil {
+		return nil, fmt.Errorf("failed to refresh SCIM Oauth2 Token: %w", err)
+	}
+	r.token = token
+
+	return token, nil
+}
+
+// invalidate discards the cached token if it is the token that was rejected by the
+// SCIM API. A token refreshed concurrently by another request is kept.
+func (r *refreshingTokenSource) invalidate(rejected *oauth2.Token) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.token == rejected {
+		r.token = nil
+	}
+}
+
+func expiresSoon(token *oauth2.Token) bool {
+	if token.AccessToken == "" {
+		return true
+	}
+	if token.Expiry.IsZero() {
+		return false
+	}
+
+	return now().Add(tokenExpiryDelta).After(token.Expiry)
+}
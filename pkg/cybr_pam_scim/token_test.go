@@ -0,0 +1,229 @@
+package cybr_pam_scim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// tenant is an identity tenant issuing tokens valid for an hour and a SCIM API accepting them
+type tenant struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	grants   []string
+	issued   int
+	revoked  map[string]bool
+	requests []string
+	bodies   []string
+}
+
+func newTenant() *tenant {
+	t := &tenant{revoked: map[string]bool{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth2/token/app", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		t.mu.Lock()
+		t.grants = append(t.grants, r.PostForm.Get("grant_type"))
+		t.issued++
+		n := t.issued
+		t.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":3600,"refresh_token":"refresh-%d"}`, n, n)
+	})
+	mux.HandleFunc("/scim/v2/", func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		body, _ := io.ReadAll(r.Body)
+		t.mu.Lock()
+		t.requests = append(t.requests, token)
+		t.bodies = append(t.bodies, string(body))
+		rejected := t.revoked[token] || t.revoked["*"]
+		t.mu.Unlock()
+		w.Header().Set("Content-Type", "application/scim+json")
+		if rejected {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"schemas":["urn:ietf:params:scim:api:messages:2.0:Error"],"status":"401","detail":"invalid token"}`)
+			return
+		}
+		fmt.Fprint(w, `{"schemas":["urn:ietf:params:scim:api:messages:2.0:ListResponse"],"totalResults":0,"Resources":[]}`)
+	})
+	t.Server = httptest.NewTLSServer(mux)
+
+	return t
+}
+
+func (t *tenant) revoke(token string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.revoked[token] = true
+}
+
+// counts returns the number of token grants and the tokens of the SCIM API requests
+func (t *tenant) counts() ([]string, []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]string(nil), t.grants...), append([]string(nil), t.requests...)
+}
+
+// client returns a Client requesting client credentials tokens from the tenant
+func (t *tenant) client() *Client {
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, t.Server.Client())
+	ts := OauthCredClientTokenSourceContext(ctx, "client", "secret", "app", strings.TrimPrefix(t.URL, "https://"))
+
+	return NewClient(t.Server.Client(), Options{ApiURL: t.URL + "/scim/v2", TokenSource: ts})
+}
+
+// advance moves the clock of the token expiry checks forward until the test ends
+func advance(t *testing.T, d *time.Duration) {
+	t.Cleanup(func() { now = time.Now })
+	now = func() time.Time { return time.Now().Add(*d) }
+}
+
+func TestAuthMiddlewareEarlyRefresh(t *testing.T) {
+	tenant := newTenant()
+	defer tenant.Close()
+	var offset time.Duration
+	advance(t, &offset)
+	c := tenant.client()
+	ctx := context.Background()
+
+	steps := []struct {
+		offset time.Duration
+		token  string
+	}{
+		{0, "token-1"},
+		{time.Hour - tokenExpiryDelta - time.Second, "token-1"},
+		// Within 30 seconds of the expiry the token is refreshed before sending the request
+		{time.Hour - tokenExpiryDelta + time.Second, "token-2"},
+	}
+	for _, step := range steps {
+		offset = step.offset
+		if err := c.Get(ctx, "/Users", nil); err != nil {
+			t.Fatal(err)
+		}
+		_, requests := tenant.counts()
+		if token := requests[len(requests)-1]; token != step.token {
+			t.Errorf("token after %s = %s, want %s", step.offset, token, step.token)
+		}
+	}
+	if grants, _ := tenant.counts(); len(grants) != 2 || grants[0] != "client_credentials" {
+		t.Errorf("grants = %v, want two client_credentials grants", grants)
+	}
+}
+
+func TestAuthMiddlewareRefreshAfterUnauthorized(t *testing.T) {
+	tenant := newTenant()
+	defer tenant.Close()
+	c := tenant.client()
+	ctx := context.Background()
+
+	if err := c.Get(ctx, "/Users", nil); err != nil {
+		t.Fatal(err)
+	}
+	// The token is revoked long before its expiry, the request is sent again with a new token
+	tenant.revoke("token-1")
+	if err := c.Post(ctx, "/Groups", map[string]string{"displayName": "App Admins"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	grants, requests := tenant.counts()
+	if want := []string{"token-1", "token-1", "token-2"}; strings.Join(requests, ",") != strings.Join(want, ",") {
+		t.Errorf("requests = %v, want %v", requests, want)
+	}
+	if len(grants) != 2 {
+		t.Errorf("grants = %d, want a single forced refresh", len(grants))
+	}
+	tenant.mu.Lock()
+	replayed := tenant.bodies[2]
+	tenant.mu.Unlock()
+	if replayed != `{"displayName":"App Admins"}` {
+		t.Errorf("body of the retry = %q, want the body replayed", replayed)
+	}
+
+	// The refreshed token is kept for the following requests
+	if err := c.Get(ctx, "/Users", nil); err != nil {
+		t.Fatal(err)
+	}
+	if grants, requests := tenant.counts(); len(grants) != 2 || requests[3] != "token-2" {
+		t.Errorf("grants = %v and requests = %v, want token-2 reused", grants, requests)
+	}
+}
+
+func TestAuthMiddlewareSecondUnauthorized(t *testing.T) {
+	tenant := newTenant()
+	defer tenant.Close()
+	tenant.revoke("*")
+	c := tenant.client()
+
+	err := c.Get(context.Background(), "/Users", nil)
+	var scimErr *ScimError
+	if !errors.As(err, &scimErr) || scimErr.StatusCode != http.StatusUnauthorized || !errors.Is(err, ErrUserAccessDenied) {
+		t.Fatalf("error = %v, want the 401 response", err)
+	}
+	// A single refresh and retry, the second 401 is returned
+	grants, requests := tenant.counts()
+	if len(grants) != 2 || strings.Join(requests, ",") != "token-1,token-2" {
+		t.Errorf("grants = %v and requests = %v, want a single retry with token-2", grants, requests)
+	}
+}
+
+func TestAuthMiddlewareUnreplayableBody(t *testing.T) {
+	tenant := newTenant()
+	defer tenant.Close()
+	tenant.revoke("token-1")
+	var next Handler = func(r *http.Request) (*http.Response, error) {
+		return tenant.Server.Client().Do(r)
+	}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, tenant.Server.Client())
+	handler := AuthMiddleware(OauthCredClientTokenSourceContext(ctx, "client", "secret", "app", strings.TrimPrefix(tenant.URL, "https://")))(next)
+
+	r, err := http.NewRequest(http.MethodPost, tenant.URL+"/scim/v2/Groups", io.NopCloser(strings.NewReader(`{}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := handler(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	// The body cannot be sent again, the 401 response is returned without a refresh
+	if grants, requests := tenant.counts(); resp.StatusCode != http.StatusUnauthorized || len(grants) != 1 || len(requests) != 1 {
+		t.Errorf("status = %d, grants = %v and requests = %v, want the 401 response of token-1", resp.StatusCode, grants, requests)
+	}
+}
+
+func TestResourceOwnerTokenSource(t *testing.T) {
+	tenant := newTenant()
+	defer tenant.Close()
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, tenant.Server.Client())
+	clientURL := strings.TrimPrefix(tenant.URL, "https://")
+
+	token, err := OauthResourceOwnerContext(ctx, "client", "secret", "app", clientURL, "john.smith", "ExamplePass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := OauthResourceOwnerTokenSourceContext(ctx, "client", "secret", "app", clientURL, token)
+	var tokens []string
+	for i := 0; i < 3; i++ {
+		token, err := ts.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, token.AccessToken)
+	}
+
+	// The initial token is used once, every later call redeems the latest refresh token
+	grants, _ := tenant.counts()
+	if strings.Join(tokens, ",") != "token-1,token-2,token-3" || strings.Join(grants, ",") != "password,refresh_token,refresh_token" {
+		t.Errorf("tokens = %v and grants = %v", tokens, grants)
+	}
+}
@@ -0,0 +1,43 @@
+package types
+
+import "encoding/json"
+
+const (
+	// BulkRequestSchema is the schema of SCIM bulk requests
+	BulkRequestSchema = "urn:ietf:params:scim:api:messages:2.0:BulkRequest"
+	// BulkResponseSchema is the schema of SCIM bulk responses
+	BulkResponseSchema = "urn:ietf:params:scim:api:messages:2.0:BulkResponse"
+)
+
+// BulkRequest is the body of a SCIM bulk request (RFC 7644 section 3.7)
+type BulkRequest struct {
+	Schemas      []string        `json:"schemas"`
+	FailOnErrors int             `json:"failOnErrors,omitempty"`
+	Operations   []BulkOperation `json:"Operations"`
+}
+
+// BulkOperation is a single operation of a BulkRequest
+type BulkOperation struct {
+	Method  string          `json:"method"`
+	BulkId  string          `json:"bulkId,omitempty"`
+	Version string          `json:"version,omitempty"`
+	Path    string          `json:"path"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// BulkResponse is the body of a SCIM bulk response
+type BulkResponse struct {
+	Schemas    []string                `json:"schemas"`
+	Operations []BulkOperationResponse `json:"Operations"`
+}
+
+// BulkOperationResponse is the result of a single operation of a BulkRequest. Status is
+// the HTTP status code of the operation, Response holds the SCIM error of failed operations.
+type BulkOperationResponse struct {
+	Location string          `json:"location,omitempty"`
+	Method   string          `json:"method"`
+	BulkId   string          `json:"bulkId,omitempty"`
+	Version  string          `json:"version,omitempty"`
+	Status   json.Number     `json:"status"`
+	Response json.RawMessage `json:"response,omitempty"`
+}
@@ -1,23 +1,56 @@
 package types
 
-type ContainerPermissions struct {
-	Schemas      []string              `json:"schemas"`
-	TotalResults int                   `json:"totalResults"`
-	ItemsPerPage int                   `json:"itemsPerPage"`
-	StartIndex   int                   `json:"startIndex"`
-	Resources    []ContainerPermission `json:"Resources"`
-}
+import "encoding/json"
+
+// ContainerPermissions is the ListResponse of the ContainerPermissions (Safe Permissions) endpoint
+type ContainerPermissions = ListResponse[ContainerPermission]
 
 type ContainerPermission struct {
 	Container                                    ContainerRef                                 `json:"container"`
 	User                                         UserRef                                      `json:"user,omitempty"`
 	Group                                        GroupRef                                     `json:"group,omitempty"`
-	Rights                                       []string                                     `json:"rights"`
+	Rights                                       Rights                                       `json:"rights"`
 	Schemas                                      []string                                     `json:"schemas"`
 	Id                                           string                                       `json:"id"`
 	ExternalId                                   string                                       `json:"externalId,omitempty"`
 	Meta                                         Meta                                         `json:"meta"`
 	UrnIetfParamsScimSchemasCyberark10SafeMember UrnIetfParamsScimSchemasCyberark10SafeMember `json:"urn:ietf:params:scim:schemas:cyberark:1.0:SafeMember"`
+	// UnknownRights are the right names returned by the SCIM API which are not Rights. They are
+	// sent back with Rights, so that replacing the permission does not revoke them.
+	UnknownRights []string      `json:"-"`
+	Extra         RawAttributes `json:"-"`
+}
+
+// MarshalJSON encodes the ContainerPermission followed by the attributes of Extra
+func (c ContainerPermission) MarshalJSON() ([]byte, error) {
+	type containerPermission ContainerPermission
+	if len(c.UnknownRights) == 0 {
+		return marshalExtra(containerPermission(c), c.Extra)
+	}
+
+	return marshalExtra(struct {
+		containerPermission
+		Rights []string `json:"rights"`
+	}{containerPermission(c), append(c.Rights.Names(), c.UnknownRights...)}, c.Extra)
+}
+
+// UnmarshalJSON decodes the ContainerPermission and keeps the attributes it does not model in Extra
+// and the right names which are not Rights in UnknownRights
+func (c *ContainerPermission) UnmarshalJSON(data []byte) error {
+	type containerPermission ContainerPermission
+	if err := unmarshalExtra(data, (*containerPermission)(c), &c.Extra); err != nil {
+		return err
+	}
+
+	var rights struct {
+		Rights []string `json:"rights"`
+	}
+	if err := json.Unmarshal(data, &rights); err != nil {
+		return err
+	}
+	_, c.UnknownRights = splitRights(rights.Rights)
+
+	return nil
 }
 
 type ContainerRef struct {
@@ -28,7 +61,20 @@ type ContainerRef struct {
 }
 
 type UrnIetfParamsScimSchemasCyberark10SafeMember struct {
-	MembershipExpirationDate int    `json:"membershipExpirationDate,omitempty"`
-	MemberType               string `json:"memberType,omitempty"`
-	SearchIn                 string `json:"searchIn,omitempty"`
+	MembershipExpirationDate int           `json:"membershipExpirationDate,omitempty"`
+	MemberType               string        `json:"memberType,omitempty"`
+	SearchIn                 string        `json:"searchIn,omitempty"`
+	Extra                    RawAttributes `json:"-"`
+}
+
+// MarshalJSON encodes the CyberArk SafeMember extension followed by the attributes of Extra
+func (e UrnIetfParamsScimSchemasCyberark10SafeMember) MarshalJSON() ([]byte, error) {
+	type extension UrnIetfParamsScimSchemasCyberark10SafeMember
+	return marshalExtra(extension(e), e.Extra)
+}
+
+// UnmarshalJSON decodes the CyberArk SafeMember extension and keeps the attributes it does not model in Extra
+func (e *UrnIetfParamsScimSchemasCyberark10SafeMember) UnmarshalJSON(data []byte) error {
+	type extension UrnIetfParamsScimSchemasCyberark10SafeMember
+	return unmarshalExtra(data, (*extension)(e), &e.Extra)
 }
@@ -1,12 +1,7 @@
 package types
 
-type Containers struct {
-	Schemas      []string    `json:"schemas"`
-	TotalResults int         `json:"totalResults"`
-	ItemsPerPage int         `json:"itemsPerPage"`
-	StartIndex   int         `json:"startIndex"`
-	Resources    []Container `json:"Resources"`
-}
+// Containers is the ListResponse of the Containers (Safes) endpoint
+type Containers = ListResponse[Container]
 
 type Container struct {
 	Name                                   string                                 `json:"name"`
@@ -21,12 +16,40 @@ type Container struct {
 	ExternalId                             string                                 `json:"externalId,omitempty"`
 	Meta                                   Meta                                   `json:"meta"`
 	UrnIetfParamsScimSchemasCyberark10Safe UrnIetfParamsScimSchemasCyberark10Safe `json:"urn:ietf:params:scim:schemas:cyberark:1.0:Safe"`
+	Extra                                  RawAttributes                          `json:"-"`
+	Extensions                             Extensions                             `json:"-"`
+}
+
+// MarshalJSON encodes the Container with its registered Extensions followed by the attributes of Extra
+func (c Container) MarshalJSON() ([]byte, error) {
+	type container Container
+	return marshalResource("Container", container(c), c.Extra, c.Extensions)
+}
+
+// UnmarshalJSON decodes the Container, its registered Extensions, and keeps the other attributes it
+// does not model in Extra
+func (c *Container) UnmarshalJSON(data []byte) error {
+	type container Container
+	return unmarshalResource("Container", data, (*container)(c), &c.Extra, &c.Extensions)
 }
 
 // Container Attribute
 type UrnIetfParamsScimSchemasCyberark10Safe struct {
-	NumberOfDaysRetention int    `json:"NumberOfDaysRetention,omitempty"`
-	ManagingCPM           string `json:"ManagingCPM,omitempty"`
+	NumberOfDaysRetention int           `json:"NumberOfDaysRetention,omitempty"`
+	ManagingCPM           string        `json:"ManagingCPM,omitempty"`
+	Extra                 RawAttributes `json:"-"`
+}
+
+// MarshalJSON encodes the CyberArk Safe extension followed by the attributes of Extra
+func (e UrnIetfParamsScimSchemasCyberark10Safe) MarshalJSON() ([]byte, error) {
+	type extension UrnIetfParamsScimSchemasCyberark10Safe
+	return marshalExtra(extension(e), e.Extra)
+}
+
+// UnmarshalJSON decodes the CyberArk Safe extension and keeps the attributes it does not model in Extra
+func (e *UrnIetfParamsScimSchemasCyberark10Safe) UnmarshalJSON(data []byte) error {
+	type extension UrnIetfParamsScimSchemasCyberark10Safe
+	return unmarshalExtra(data, (*extension)(e), &e.Extra)
 }
 
 type Owner struct {
@@ -0,0 +1,284 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Extensions holds the registered schema extensions of a User, Group, or Container by schema
+// URN. The values decoded from the SCIM API are pointers to the types passed to
+// RegisterExtension.
+type Extensions map[string]interface{}
+
+// extensionResources are the resource types accepting registered extensions
+var extensionResources = map[string]reflect.Type{
+	"User":      reflect.TypeOf(User{}),
+	"Group":     reflect.TypeOf(Group{}),
+	"Container": reflect.TypeOf(Container{}),
+}
+
+var extensionRegistry = struct {
+	sync.RWMutex
+	types map[string]map[string]reflect.Type // resource type, schema URN, extension type
+}{types: map[string]map[string]reflect.Type{}}
+
+// RegisterExtension registers T, a struct or map type, as the Go type of the schema extension
+// urn of a resource type (User, Group, or Container). The resources of the type then decode
+// the extension into Extensions as a *T, encode it from Extensions, and list its URN in their
+// schemas only when it is present. Extensions modeled by a struct field, such as the enterprise
+// User extension, cannot be registered except the custom extension
+// (urn:scim:schemas:extension:custom:2.0). RegisterExtension is typically called from init.
+//
+// Example Usage:
+//		type Badge struct {
+//			Number   string `json:"number"`
+//			Building string `json:"building,omitempty"`
+//		}
+//
+//		func init() {
+//			if err := types.RegisterExtension[Badge]("User", "urn:example:params:scim:schemas:badge:1.0"); err != nil {
+//				panic(err)
+//			}
+//		}
+//
+func RegisterExtension[T any](resourceType string, urn string) error {
+	resource, ok := extensionResources[resourceType]
+	if !ok {
+		return fmt.Errorf("invalid resourceType provided, accepted types are User, Group, or Container: %s", resourceType)
+	}
+	if urn == "" {
+		return fmt.Errorf("schema URN is required to register an extension")
+	}
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct && t.Kind() != reflect.Map {
+		return fmt.Errorf("extension %s must be a struct or map type: %s", urn, t)
+	}
+	if field, ok := fieldByName(resource, urn); ok && field.Type != reflect.TypeOf(RawAttributes{}) {
+		return fmt.Errorf("extension %s is modeled by the %s field of %s", urn, field.Name, resourceType)
+	}
+
+	extensionRegistry.Lock()
+	defer extensionRegistry.Unlock()
+	registered := extensionRegistry.types[resourceType]
+	if registered == nil {
+		registered = map[string]reflect.Type{}
+		extensionRegistry.types[resourceType] = registered
+	}
+	for existing, existingType := range registered {
+		if strings.EqualFold(existing, urn) && existingType != t {
+			return fmt.Errorf("extension %s of %s is already registered as %s", urn, resourceType, existingType)
+		}
+	}
+	registered[urn] = t
+
+	return nil
+}
+
+// registeredExtensions returns the extension types registered for a resource type
+func registeredExtensions(resourceType string) map[string]reflect.Type {
+	extensionRegistry.RLock()
+	defer extensionRegistry.RUnlock()
+
+	registered := make(map[string]reflect.Type, len(extensionRegistry.types[resourceType]))
+	for urn, t := range extensionRegistry.types[resourceType] {
+		registered[urn] = t
+	}
+
+	return registered
+}
+
+// GetExtension returns the extension of the schema URN as a *T and reports whether it is
+// present with that type
+//
+// Example Usage:
+//		badge, ok := types.GetExtension[Badge](user.Extensions, "urn:example:params:scim:schemas:badge:1.0")
+//
+func GetExtension[T any](e Extensions, urn string) (*T, bool) {
+	for key, value := range e {
+		if !strings.EqualFold(key, urn) {
+			continue
+		}
+		switch value := value.(type) {
+		case *T:
+			return value, value != nil
+		case T:
+			return &value, true
+		}
+	}
+
+	return nil, false
+}
+
+// Set sets the extension of the schema URN, replacing the extension of the same URN in any
+// case. The map is allocated if necessary and the URN is added to the schemas of the resource
+// when it is encoded.
+//
+// Example Usage:
+//		user.Extensions.Set("urn:example:params:scim:schemas:badge:1.0", &Badge{Number: "B-12"})
+//
+func (e *Extensions) Set(urn string, v interface{}) {
+	if *e == nil {
+		*e = Extensions{}
+	}
+	e.Delete(urn)
+	(*e)[urn] = v
+}
+
+// Delete removes the extension of the schema URN. The URN of a registered extension is removed
+// from the schemas of the resource when it is encoded.
+func (e Extensions) Delete(urn string) {
+	for key := range e {
+		if strings.EqualFold(key, urn) {
+			delete(e, key)
+		}
+	}
+}
+
+// marshalResource encodes v, a resource struct without MarshalJSON, with its extensions and
+// extra attributes, and with the registered extensions listed in its schemas only when present
+func marshalResource(resourceType string, v interface{}, extra RawAttributes, extensions Extensions) ([]byte, error) {
+	registered := registeredExtensions(resourceType)
+	if len(registered) == 0 && len(extensions) == 0 {
+		return marshalExtra(v, extra)
+	}
+
+	resource := reflect.New(reflect.TypeOf(v)).Elem()
+	resource.Set(reflect.ValueOf(v))
+	attributes := make(RawAttributes, len(extra)+len(extensions))
+	for key, value := range extra {
+		attributes[key] = value
+	}
+	for urn, extension := range extensions {
+		if extension == nil {
+			continue
+		}
+		data, err := json.Marshal(extension)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode extension %s: %w", urn, err)
+		}
+		attributes.Delete(urn)
+		attributes[urn] = data
+		// The extension replaces the raw attributes of a modeled custom extension
+		if field, ok := fieldByName(resource.Type(), urn); ok {
+			resource.FieldByIndex(field.Index).Set(reflect.Zero(field.Type))
+		}
+	}
+
+	urns := make([]string, 0, len(registered)+len(extensions))
+	for urn := range registered {
+		urns = append(urns, urn)
+	}
+	for urn := range extensions {
+		urns = append(urns, urn)
+	}
+	syncSchemas(resource, attributes, urns)
+
+	return marshalExtra(resource.Interface(), attributes)
+}
+
+// syncSchemas adds the extension URNs present in the resource or attributes to its schemas and
+// removes the absent ones
+func syncSchemas(resource reflect.Value, attributes RawAttributes, urns []string) {
+	schemas := resource.FieldByName("Schemas")
+	if !schemas.IsValid() {
+		return
+	}
+	current := schemas.Interface().([]string)
+	synced := make([]string, 0, len(current)+len(urns))
+	changed := false
+	for _, schema := range current {
+		if containsFold(urns, schema) && !extensionPresent(resource, attributes, schema) {
+			changed = true
+			continue
+		}
+		synced = append(synced, schema)
+	}
+	for _, urn := range urns {
+		if !containsFold(synced, urn) && extensionPresent(resource, attributes, urn) {
+			changed = true
+			synced = append(synced, urn)
+		}
+	}
+	if changed {
+		schemas.Set(reflect.ValueOf(synced))
+	}
+}
+
+func extensionPresent(resource reflect.Value, attributes RawAttributes, urn string) bool {
+	if _, ok := attributes.key(urn); ok {
+		return true
+	}
+	field, ok := fieldByName(resource.Type(), urn)
+	if !ok {
+		return false
+	}
+	value := resource.FieldByIndex(field.Index)
+	if value.Kind() == reflect.Map {
+		return value.Len() > 0
+	}
+
+	return !value.IsZero()
+}
+
+// unmarshalResource decodes data into v, a pointer to a resource struct without UnmarshalJSON,
+// decoding the registered extensions into extensions and keeping the other attributes it does
+// not model in extra
+func unmarshalResource(resourceType string, data []byte, v interface{}, extra *RawAttributes, extensions *Extensions) error {
+	if err := unmarshalExtra(data, v, extra); err != nil {
+		return err
+	}
+	*extensions = nil
+	registered := registeredExtensions(resourceType)
+	if len(registered) == 0 {
+		return nil
+	}
+
+	var attributes map[string]json.RawMessage
+	if err := json.Unmarshal(data, &attributes); err != nil || attributes == nil {
+		return err
+	}
+	resource := reflect.ValueOf(v).Elem()
+	for key, value := range attributes {
+		for urn, t := range registered {
+			if !strings.EqualFold(key, urn) {
+				continue
+			}
+			extension := reflect.New(t)
+			if err := json.Unmarshal(value, extension.Interface()); err != nil {
+				return fmt.Errorf("failed to decode extension %s: %w", urn, err)
+			}
+			extensions.Set(urn, extension.Interface())
+			extra.Delete(key)
+			if field, ok := fieldByName(resource.Type(), urn); ok {
+				resource.FieldByIndex(field.Index).Set(reflect.Zero(field.Type))
+			}
+		}
+	}
+
+	return nil
+}
+
+// fieldByName returns the field of a struct type encoded with the JSON name
+func fieldByName(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if tag := strings.Split(field.Tag.Get("json"), ",")[0]; tag != "" && strings.EqualFold(tag, name) {
+			return field, true
+		}
+	}
+
+	return reflect.StructField{}, false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+
+	return false
+}
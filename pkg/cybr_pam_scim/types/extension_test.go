@@ -0,0 +1,151 @@
+package types_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
+)
+
+type badge struct {
+	Number   string `json:"number"`
+	Building string `json:"building,omitempty"`
+}
+
+// The registry is global, the URNs are not used by the other tests
+const (
+	badgeURN    = "urn:example:params:scim:schemas:badge:2.0"
+	locationURN = "urn:example:params:scim:schemas:location:1.0"
+)
+
+func init() {
+	if err := types.RegisterExtension[badge]("User", badgeURN); err != nil {
+		panic(err)
+	}
+	if err := types.RegisterExtension[map[string]string]("Container", locationURN); err != nil {
+		panic(err)
+	}
+}
+
+func TestExtensionRoundTrip(t *testing.T) {
+	data := `{"userName":"john.smith","schemas":["urn:ietf:params:scim:schemas:core:2.0:User","` + badgeURN + `"],` +
+		`"` + strings.ToUpper(badgeURN) + `":{"number":"B-12","building":"HQ"}}`
+	var user types.User
+	if err := json.Unmarshal([]byte(data), &user); err != nil {
+		t.Fatal(err)
+	}
+	b, ok := types.GetExtension[badge](user.Extensions, badgeURN)
+	if !ok || *b != (badge{Number: "B-12", Building: "HQ"}) {
+		t.Fatalf("badge = %+v, %v", b, ok)
+	}
+	if len(user.Extra) != 0 {
+		t.Errorf("extra = %v, want the registered extension decoded into Extensions only", user.Extra)
+	}
+
+	b.Building = "Annex"
+	encoded, err := json.Marshal(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var again types.User
+	if err := json.Unmarshal(encoded, &again); err != nil {
+		t.Fatal(err)
+	}
+	if b, ok := types.GetExtension[badge](again.Extensions, badgeURN); !ok || *b != (badge{Number: "B-12", Building: "Annex"}) {
+		t.Errorf("badge = %+v, %v after encoding %s", b, ok, encoded)
+	}
+	if len(again.Schemas) != 2 {
+		t.Errorf("schemas = %v, want the URN listed once", again.Schemas)
+	}
+
+	var safe types.Container
+	if err := json.Unmarshal([]byte(`{"name":"AppSafe","`+locationURN+`":{"site":"Paris"}}`), &safe); err != nil {
+		t.Fatal(err)
+	}
+	if location, ok := types.GetExtension[map[string]string](safe.Extensions, locationURN); !ok || (*location)["site"] != "Paris" {
+		t.Errorf("location = %v, %v", location, ok)
+	}
+}
+
+func TestExtensionSchemas(t *testing.T) {
+	schemas := func(v interface{}) []string {
+		t.Helper()
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resource struct {
+			Schemas []string `json:"schemas"`
+		}
+		if err := json.Unmarshal(encoded, &resource); err != nil {
+			t.Fatal(err)
+		}
+		return resource.Schemas
+	}
+
+	user := types.User{UserName: "john.smith", Schemas: []string{"urn:ietf:params:scim:schemas:core:2.0:User"}}
+	user.Extensions.Set(badgeURN, &badge{Number: "B-12"})
+	if got := schemas(user); len(got) != 2 || got[1] != badgeURN {
+		t.Errorf("schemas = %v, want the URN added by Set", got)
+	}
+	// Set replaces the extension of the same URN in any case
+	user.Extensions.Set(strings.ToUpper(badgeURN), badge{Number: "B-13"})
+	if b, ok := types.GetExtension[badge](user.Extensions, badgeURN); len(user.Extensions) != 1 || !ok || b.Number != "B-13" {
+		t.Errorf("extensions = %v, want the badge replaced", user.Extensions)
+	}
+
+	user.Schemas = append(user.Schemas, badgeURN)
+	user.Extensions.Delete(badgeURN)
+	if got := schemas(user); len(got) != 1 || got[0] != "urn:ietf:params:scim:schemas:core:2.0:User" {
+		t.Errorf("schemas = %v, want the URN removed by Delete", got)
+	}
+	if user.Schemas[1] != badgeURN {
+		t.Errorf("schemas = %v, want the User left unmodified by encoding", user.Schemas)
+	}
+}
+
+func TestRegisterExtensionErrors(t *testing.T) {
+	if err := types.RegisterExtension[badge]("User", badgeURN); err != nil {
+		t.Errorf("registering the same type again = %v, want no error", err)
+	}
+	tests := []struct {
+		name     string
+		register func() error
+		err      string
+	}{
+		{"duplicate", func() error { return types.RegisterExtension[map[string]string]("User", badgeURN) }, "is already registered"},
+		{"duplicate in another case", func() error { return types.RegisterExtension[map[string]string]("User", strings.ToUpper(badgeURN)) }, "is already registered"},
+		{"modeled extension", func() error {
+			return types.RegisterExtension[badge]("User", "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User")
+		}, "is modeled by the UrnIetfParamsScimSchemasExtensionEnterprise20User field of User"},
+		{"modeled attribute", func() error { return types.RegisterExtension[badge]("Group", "displayName") }, "is modeled by the DisplayName field of Group"},
+		{"resource type", func() error { return types.RegisterExtension[badge]("PrivilegedData", badgeURN) }, "invalid resourceType"},
+		{"empty URN", func() error { return types.RegisterExtension[badge]("Group", "") }, "schema URN is required"},
+		{"kind", func() error { return types.RegisterExtension[string]("Group", badgeURN) }, "must be a struct or map type"},
+	}
+	for _, tt := range tests {
+		if err := tt.register(); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: error = %v, want %s", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestGetExtensionAbsent(t *testing.T) {
+	var user types.User
+	if err := json.Unmarshal([]byte(`{"userName":"john.smith"}`), &user); err != nil {
+		t.Fatal(err)
+	}
+	if b, ok := types.GetExtension[badge](user.Extensions, badgeURN); ok || b != nil {
+		t.Errorf("badge = %v, %v, want no extension", b, ok)
+	}
+
+	user.Extensions.Set(badgeURN, &badge{Number: "B-12"})
+	if _, ok := types.GetExtension[map[string]string](user.Extensions, badgeURN); ok {
+		t.Error("GetExtension returned the extension as another type")
+	}
+	user.Extensions.Set(badgeURN, (*badge)(nil))
+	if _, ok := types.GetExtension[badge](user.Extensions, badgeURN); ok {
+		t.Error("GetExtension returned a nil extension")
+	}
+}
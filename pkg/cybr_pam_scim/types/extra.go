@@ -0,0 +1,204 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RawAttributes holds attributes by name as undecoded JSON. The resources and their extensions
+// keep the attributes they do not model in an Extra field of this type, so that a resource
+// retrieved from the SCIM API and written back with a PUT request does not lose them. Names
+// are case insensitive like SCIM attribute names.
+type RawAttributes map[string]json.RawMessage
+
+// UrnScimSchemasExtensionCustom20 holds the attributes of the custom user extension
+// (urn:scim:schemas:extension:custom:2.0), whose attributes are defined by the tenant.
+//
+// Example Usage:
+//		var costCode string
+//		ok, err := user.UrnScimSchemasExtensionCustom20.Get("costCode", &costCode)
+//		err = user.UrnScimSchemasExtensionCustom20.Set("costCode", "CC-1024")
+//
+type UrnScimSchemasExtensionCustom20 = RawAttributes
+
+// Get decodes the attribute into v and reports whether the attribute is present
+//
+// Example Usage:
+//		var badge struct {
+//			Number string `json:"number"`
+//		}
+//		ok, err := user.Extra.Get("urn:example:params:scim:schemas:badge:1.0", &badge)
+//
+func (a RawAttributes) Get(name string, v interface{}) (bool, error) {
+	key, ok := a.key(name)
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal(a[key], v); err != nil {
+		return true, fmt.Errorf("failed to decode attribute %s: %w", name, err)
+	}
+
+	return true, nil
+}
+
+// Set encodes v as the value of the attribute, replacing the attribute of the same name in any
+// case. The map is allocated if necessary. Extension namespaces set on a resource should also
+// be listed in its Schemas.
+//
+// Example Usage:
+//		err := user.Extra.Set("urn:example:params:scim:schemas:badge:1.0", map[string]string{"number": "B-12"})
+//		user.Schemas = append(user.Schemas, "urn:example:params:scim:schemas:badge:1.0")
+//
+func (a *RawAttributes) Set(name string, v interface{}) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode attribute %s: %w", name, err)
+	}
+	if *a == nil {
+		*a = RawAttributes{}
+	}
+	if key, ok := a.key(name); ok {
+		delete(*a, key)
+	}
+	(*a)[name] = value
+
+	return nil
+}
+
+// Delete removes the attribute
+func (a RawAttributes) Delete(name string) {
+	if key, ok := a.key(name); ok {
+		delete(a, key)
+	}
+}
+
+// key returns the key of the attribute, exact matches first
+func (a RawAttributes) key(name string) (string, bool) {
+	if _, ok := a[name]; ok {
+		return name, true
+	}
+	for key := range a {
+		if strings.EqualFold(key, name) {
+			return key, true
+		}
+	}
+
+	return "", false
+}
+
+// marshalExtra encodes v, a struct without MarshalJSON, followed by the extra attributes it
+// does not model
+func marshalExtra(v interface{}, extra RawAttributes) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+
+	// Attributes encoded by the struct take precedence
+	var encoded map[string]json.RawMessage
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(extra))
+	for key := range extra {
+		if _, ok := RawAttributes(encoded).key(key); !ok {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return data, nil
+	}
+	sort.Strings(keys)
+
+	var b bytes.Buffer
+	b.Write(data[:len(data)-1])
+	for i, key := range keys {
+		if i > 0 || len(data) > 2 {
+			b.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		b.Write(name)
+		b.WriteByte(':')
+		if extra[key] == nil {
+			b.WriteString("null")
+		} else {
+			b.Write(extra[key])
+		}
+	}
+	b.WriteByte('}')
+
+	// Compact validates the extra values
+	var out bytes.Buffer
+	if err := json.Compact(&out, b.Bytes()); err != nil {
+		return nil, fmt.Errorf("failed to encode extra attributes: %w", err)
+	}
+
+	return out.Bytes(), nil
+}
+
+// unmarshalExtra decodes data into v, a pointer to a struct without UnmarshalJSON, and keeps
+// the attributes it does not model in extra
+func unmarshalExtra(data []byte, v interface{}, extra *RawAttributes) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+
+	var attributes map[string]json.RawMessage
+	if err := json.Unmarshal(data, &attributes); err != nil || attributes == nil {
+		return err
+	}
+	fields := knownFields(reflect.TypeOf(v).Elem())
+	*extra = nil
+	for key, value := range attributes {
+		if !fields[strings.ToLower(key)] {
+			if *extra == nil {
+				*extra = RawAttributes{}
+			}
+			(*extra)[key] = value
+		}
+	}
+
+	return nil
+}
+
+var fieldCache sync.Map // map[reflect.Type]map[string]bool
+
+// knownFields returns the lower case JSON names of the fields of a struct type
+func knownFields(t reflect.Type) map[string]bool {
+	if fields, ok := fieldCache.Load(t); ok {
+		return fields.(map[string]bool)
+	}
+
+	fields := map[string]bool{}
+	addFields(t, fields)
+	fieldCache.Store(t, fields)
+
+	return fields
+}
+
+func addFields(t reflect.Type, fields map[string]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if name == "" && field.Anonymous && field.Type.Kind() == reflect.Struct {
+			addFields(field.Type, fields)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[strings.ToLower(name)] = true
+	}
+}
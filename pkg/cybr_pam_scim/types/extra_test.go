@@ -0,0 +1,155 @@
+package types_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
+)
+
+// equalJSON reports whether two JSON documents encode the same value
+func equalJSON(t *testing.T, a []byte, b []byte) bool {
+	t.Helper()
+	var va, vb interface{}
+	if err := json.Unmarshal(a, &va); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		t.Fatal(err)
+	}
+
+	return reflect.DeepEqual(va, vb)
+}
+
+func TestExtraRoundTrip(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "user_extra.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var user types.User
+	if err := json.Unmarshal(data, &user); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unknown core attributes and extensions are kept, modeled attributes are not duplicated
+	for _, name := range []string{"costCenterCode", "preferences", "urn:example:params:scim:schemas:badge:1.0"} {
+		if _, ok := user.Extra[name]; !ok {
+			t.Errorf("%s was not kept in Extra", name)
+		}
+	}
+	if len(user.Extra) != 3 {
+		t.Errorf("extra = %v, want only the unknown attributes", user.Extra)
+	}
+	enterprise := user.UrnIetfParamsScimSchemasExtensionEnterprise20User
+	if enterprise.Department != "IT" || len(enterprise.Extra) != 1 {
+		t.Errorf("enterprise extension = %+v, want the department and the unknown building", enterprise)
+	}
+	var flags struct {
+		VIP bool `json:"vip"`
+	}
+	if ok, err := user.UrnScimSchemasExtensionCustom20.Get("flags", &flags); !ok || err != nil || !flags.VIP {
+		t.Errorf("custom flags = %+v, %v", flags, err)
+	}
+
+	// A PUT of the decoded User sends every attribute back unchanged
+	encoded, err := json.Marshal(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalJSON(t, encoded, data) {
+		t.Errorf("encoded user =\n%s\nwant:\n%s", encoded, data)
+	}
+
+	// Modified attributes are sent along with the unknown ones
+	user.Title = "Engineer"
+	if err := user.UrnScimSchemasExtensionCustom20.Set("costCode", "CC-2048"); err != nil {
+		t.Fatal(err)
+	}
+	if encoded, err = json.Marshal(user); err != nil {
+		t.Fatal(err)
+	}
+	var sent map[string]interface{}
+	if err := json.Unmarshal(encoded, &sent); err != nil {
+		t.Fatal(err)
+	}
+	custom := sent["urn:scim:schemas:extension:custom:2.0"].(map[string]interface{})
+	if sent["title"] != "Engineer" || sent["costCenterCode"] != "CC-1024" || custom["costCode"] != "CC-2048" || custom["flags"] == nil {
+		t.Errorf("encoded user = %s", encoded)
+	}
+}
+
+func TestRawAttributes(t *testing.T) {
+	var attributes types.RawAttributes
+	var s string
+	if ok, err := attributes.Get("costCode", &s); ok || err != nil {
+		t.Errorf("Get on nil attributes = %v, %v", ok, err)
+	}
+	attributes.Delete("costCode")
+
+	if err := attributes.Set("costCode", "CC-1024"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := attributes.Get("COSTCODE", &s); !ok || err != nil || s != "CC-1024" {
+		t.Errorf("Get = %q, %v, %v, want the attribute in any case", s, ok, err)
+	}
+
+	// Set replaces the attribute of the same name in any case
+	if err := attributes.Set("CostCode", map[string]int{"id": 7}); err != nil {
+		t.Fatal(err)
+	}
+	if len(attributes) != 1 || string(attributes["CostCode"]) != `{"id":7}` {
+		t.Errorf("attributes = %v, want CostCode replacing costCode", attributes)
+	}
+	var n int
+	if ok, err := attributes.Get("costCode", &n); !ok || err == nil {
+		t.Errorf("Get of an object into an int = %v, %v, want a decoding error", ok, err)
+	}
+	if err := attributes.Set("invalid", func() {}); err == nil {
+		t.Error("Set of a function succeeded")
+	}
+
+	attributes.Delete("COSTCODE")
+	if len(attributes) != 0 {
+		t.Errorf("attributes = %v, want CostCode deleted", attributes)
+	}
+}
+
+func TestExtraModeledFieldWins(t *testing.T) {
+	user := types.User{UserName: "john.smith", DisplayName: "John Smith", Schemas: []string{"urn:ietf:params:scim:schemas:core:2.0:User"}}
+	user.Extra = types.RawAttributes{
+		"userName":     json.RawMessage(`"jane.doe"`),
+		"DISPLAYNAME":  json.RawMessage(`"Jane Doe"`),
+		"title":        json.RawMessage(`"Engineer"`),
+		"employeeCode": json.RawMessage(`42`),
+	}
+	encoded, err := json.Marshal(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sent map[string]interface{}
+	if err := json.Unmarshal(encoded, &sent); err != nil {
+		t.Fatal(err)
+	}
+	if sent["userName"] != "john.smith" || sent["displayName"] != "John Smith" {
+		t.Errorf("encoded user = %s, want the modeled fields", encoded)
+	}
+	if _, ok := sent["DISPLAYNAME"]; ok {
+		t.Errorf("encoded user = %s, want the Extra key of a modeled field dropped", encoded)
+	}
+	// Extra keys of modeled fields left empty and omitted by the struct are sent
+	if sent["title"] != "Engineer" || sent["employeeCode"] != float64(42) {
+		t.Errorf("encoded user = %s, want the other Extra attributes", encoded)
+	}
+
+	// Modeled attributes are decoded into their field in any case and never kept in Extra
+	var decoded types.User
+	if err := json.Unmarshal([]byte(`{"USERNAME":"jane.doe","employeeCode":42}`), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.UserName != "jane.doe" || len(decoded.Extra) != 1 {
+		t.Errorf("user = %+v, want USERNAME decoded into UserName", decoded)
+	}
+}
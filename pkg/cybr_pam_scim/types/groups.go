@@ -1,12 +1,7 @@
 package types
 
-type Groups struct {
-	Schemas      []string `json:"schemas"`
-	TotalResults int      `json:"totalResults"`
-	Resources    []Groups `json:"Resources"`
-	StartIndex   int      `json:"startIndex,omitempty"`
-	ItemsPerPage int      `json:"itemsPerPage"`
-}
+// Groups is the ListResponse of the Groups endpoint
+type Groups = ListResponse[Group]
 
 type Group struct {
 	DisplayName                             string                                  `json:"displayName"`
@@ -16,6 +11,21 @@ type Group struct {
 	Meta                                    Meta                                    `json:"meta"`
 	ExternalId                              string                                  `json:"externalId,omitempty"`
 	UrnIetfParamsScimSchemasCyberark10Group UrnIetfParamsScimSchemasCyberark10Group `json:"urn:ietf:params:scim:schemas:cyberark:1.0:Group,omitempty"`
+	Extra                                   RawAttributes                           `json:"-"`
+	Extensions                              Extensions                              `json:"-"`
+}
+
+// MarshalJSON encodes the Group with its registered Extensions followed by the attributes of Extra
+func (g Group) MarshalJSON() ([]byte, error) {
+	type group Group
+	return marshalResource("Group", group(g), g.Extra, g.Extensions)
+}
+
+// UnmarshalJSON decodes the Group, its registered Extensions, and keeps the other attributes it
+// does not model in Extra
+func (g *Group) UnmarshalJSON(data []byte) error {
+	type group Group
+	return unmarshalResource("Group", data, (*group)(g), &g.Extra, &g.Extensions)
 }
 
 type Members struct {
@@ -26,6 +36,19 @@ type Members struct {
 }
 
 type UrnIetfParamsScimSchemasCyberark10Group struct {
-	DirectoryType string `json:"directoryType,omitempty"`
-	DirectoryName string `json:"directoryName,omitempty"`
+	DirectoryType string        `json:"directoryType,omitempty"`
+	DirectoryName string        `json:"directoryName,omitempty"`
+	Extra         RawAttributes `json:"-"`
+}
+
+// MarshalJSON encodes the CyberArk Group extension followed by the attributes of Extra
+func (e UrnIetfParamsScimSchemasCyberark10Group) MarshalJSON() ([]byte, error) {
+	type extension UrnIetfParamsScimSchemasCyberark10Group
+	return marshalExtra(extension(e), e.Extra)
+}
+
+// UnmarshalJSON decodes the CyberArk Group extension and keeps the attributes it does not model in Extra
+func (e *UrnIetfParamsScimSchemasCyberark10Group) UnmarshalJSON(data []byte) error {
+	type extension UrnIetfParamsScimSchemasCyberark10Group
+	return unmarshalExtra(data, (*extension)(e), &e.Extra)
 }
@@ -0,0 +1,63 @@
+package types
+
+// ListResponse is the response of a query returning resources of type T
+// (urn:ietf:params:scim:api:messages:2.0:ListResponse), e.g. ListResponse[User] for the
+// Users endpoint. TotalResults is the number of resources matching the query, which may
+// exceed the number of Resources of a single page.
+type ListResponse[T any] struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int      `json:"totalResults"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	StartIndex   int      `json:"startIndex"`
+	Resources    []T      `json:"Resources"`
+}
+
+// Items returns the resources of the page, nil for a nil response
+//
+// Example Usage:
+//		var groups types.Groups
+//		for _, group := range groups.Items() {
+//			fmt.Println(group.Id, group.DisplayName, len(group.Members))
+//		}
+//
+func (l *ListResponse[T]) Items() []T {
+	if l == nil {
+		return nil
+	}
+
+	return l.Resources
+}
+
+// Len returns the number of resources of the page
+func (l *ListResponse[T]) Len() int {
+	return len(l.Items())
+}
+
+// First returns the first resource of the page and reports whether the page has one
+func (l *ListResponse[T]) First() (*T, bool) {
+	if l.Len() == 0 {
+		return nil, false
+	}
+
+	return &l.Resources[0], true
+}
+
+// NextStartIndex returns the startIndex of the page following this one. Pages without a
+// startIndex are assumed to start at the first resource.
+func (l *ListResponse[T]) NextStartIndex() int {
+	if l == nil {
+		return 1
+	}
+	startIndex := l.StartIndex
+	if startIndex < 1 {
+		startIndex = 1
+	}
+
+	return startIndex + len(l.Resources)
+}
+
+// HasMore reports whether resources matching the query follow this page according to
+// TotalResults
+func (l *ListResponse[T]) HasMore() bool {
+	return l.Len() > 0 && l.NextStartIndex() <= l.TotalResults
+}
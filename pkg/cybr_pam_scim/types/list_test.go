@@ -0,0 +1,204 @@
+package types_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
+)
+
+// decodeGolden decodes a golden CyberArk SCIM payload of the testdata directory
+func decodeGolden(t *testing.T, name string, v interface{}) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("failed to decode %s: %v", name, err)
+	}
+}
+
+// checkPage checks the accessors of a decoded page
+func checkPage[T any](t *testing.T, page *types.ListResponse[T], length int, nextStartIndex int, hasMore bool) {
+	t.Helper()
+	if len(page.Schemas) != 1 || page.Schemas[0] != "urn:ietf:params:scim:api:messages:2.0:ListResponse" {
+		t.Errorf("schemas = %v, want the ListResponse schema", page.Schemas)
+	}
+	if page.Len() != length || len(page.Items()) != length {
+		t.Fatalf("len = %d, want %d", page.Len(), length)
+	}
+	if first, ok := page.First(); !ok || first != &page.Resources[0] {
+		t.Errorf("first = %v, want the first resource", first)
+	}
+	if page.NextStartIndex() != nextStartIndex || page.HasMore() != hasMore {
+		t.Errorf("next start index = %d and has more = %v, want %d and %v", page.NextStartIndex(), page.HasMore(), nextStartIndex, hasMore)
+	}
+}
+
+func TestDecodeUsers(t *testing.T) {
+	var users types.Users
+	decodeGolden(t, "users.json", &users)
+	checkPage(t, &users, 2, 3, true)
+
+	admin, user := users.Resources[0], users.Resources[1]
+	if admin.Id != "2" || admin.UserName != "Administrator" || admin.UserType != "Built-InAdmins" || !admin.Active {
+		t.Errorf("administrator = %+v", admin)
+	}
+	if len(admin.Groups) != 1 || admin.Groups[0].Display != "Vault Admins" || len(admin.Entitlements) != 10 {
+		t.Errorf("groups = %+v and entitlements = %v", admin.Groups, admin.Entitlements)
+	}
+	if cyberark := admin.UrnIetfParamsScimSchemasCyberark10User; len(cyberark.AuthenticationMethod) != 1 || !cyberark.PasswordNeverExpires {
+		t.Errorf("CyberArk extension = %+v", cyberark)
+	}
+	var authorizations []string
+	if ok, err := admin.UrnIetfParamsScimSchemasCyberark10User.Extra.Get("vaultAuthorization", &authorizations); !ok || err != nil || len(authorizations) != 2 {
+		t.Errorf("vaultAuthorization = %v, want it kept in Extra: %v", authorizations, err)
+	}
+	if admin.Meta.Version != `W/"2"` || admin.Meta.Created.IsZero() {
+		t.Errorf("meta = %+v", admin.Meta)
+	}
+
+	if user.Name.GivenName != "John" || user.Title != "Engineer" || len(user.Emails) != 1 || !user.Emails[0].Primary || len(user.PhoneNumbers) != 1 {
+		t.Errorf("user = %+v", user)
+	}
+	if user.ExternalId == "" || user.UrnIetfParamsScimSchemasExtensionEnterprise20User.Department != "IT" || user.UrnIetfParamsScimSchemasPam10LinkedObject.NativeIdentifier != "john.smith" {
+		t.Errorf("user extensions = %+v", user)
+	}
+}
+
+func TestDecodeGroups(t *testing.T) {
+	var groups types.Groups
+	decodeGolden(t, "groups.json", &groups)
+	checkPage(t, &groups, 2, 3, false)
+
+	group := groups.Resources[1]
+	if group.Id != "21" || group.DisplayName != "App Admins" || len(group.Members) != 2 || group.Members[1].Display != "jane.doe@example.com" {
+		t.Errorf("group = %+v", group)
+	}
+	if cyberark := group.UrnIetfParamsScimSchemasCyberark10Group; cyberark.DirectoryType != "LDAP" || cyberark.DirectoryName != "example.com" {
+		t.Errorf("CyberArk extension = %+v", cyberark)
+	}
+	var description string
+	if ok, _ := groups.Resources[0].UrnIetfParamsScimSchemasCyberark10Group.Extra.Get("description", &description); !ok || description == "" {
+		t.Error("description of the CyberArk extension was not kept in Extra")
+	}
+}
+
+func TestDecodeContainers(t *testing.T) {
+	var containers types.Containers
+	decodeGolden(t, "containers.json", &containers)
+	checkPage(t, &containers, 2, 3, false)
+
+	safe := containers.Resources[0]
+	if safe.Name != "AppSafe" || safe.Description != "Application accounts" || safe.Owner.Display != "Administrator" || len(safe.PrivilegedData) != 1 {
+		t.Errorf("safe = %+v", safe)
+	}
+	if cyberark := safe.UrnIetfParamsScimSchemasCyberark10Safe; cyberark.NumberOfDaysRetention != 7 || cyberark.ManagingCPM != "PasswordManager" {
+		t.Errorf("CyberArk extension = %+v", cyberark)
+	}
+	if _, ok := safe.UrnIetfParamsScimSchemasCyberark10Safe.Extra["OLACEnabled"]; !ok {
+		t.Error("OLACEnabled was not kept in Extra")
+	}
+	if internal := containers.Resources[1]; internal.Name != "VaultInternal" || !internal.Meta.Created.IsZero() {
+		t.Errorf("safe = %+v", internal)
+	}
+}
+
+func TestDecodeContainerPermissions(t *testing.T) {
+	var permissions types.ContainerPermissions
+	decodeGolden(t, "container_permissions.json", &permissions)
+	checkPage(t, &permissions, 2, 3, false)
+
+	group, user := permissions.Resources[0], permissions.Resources[1]
+	if group.Container.Name != "AppSafe" || group.Group.Display != "App Admins" || group.User.Display != "" || group.Rights != types.EndUserRights.Union(types.RightViewSafeMembers) {
+		t.Errorf("group permission = %+v", group)
+	}
+	if user.User.Value != "14" || user.Rights != types.AuditorRights.Union(types.RightRequestsAuthorizationLevel1) {
+		t.Errorf("user permission = %+v", user)
+	}
+	if member := user.UrnIetfParamsScimSchemasCyberark10SafeMember; member.MembershipExpirationDate != 1767225600 || member.MemberType != "User" || member.SearchIn != "example.com" {
+		t.Errorf("SafeMember extension = %+v", member)
+	}
+}
+
+func TestDecodePrivilegedDatas(t *testing.T) {
+	var privilegedDatas types.PrivilegedDatas
+	decodeGolden(t, "privileged_data.json", &privilegedDatas)
+	checkPage(t, &privilegedDatas, 2, 5, true)
+
+	account := privilegedDatas.Resources[1]
+	if account.Id != "12_4" || account.Type != "password" || account.Description != "SQL service account" {
+		t.Errorf("account = %+v", account)
+	}
+	cyberark := account.UrnIetfParamsScimSchemasCyberark10PrivilegedData
+	if cyberark.Safe != "AppSafe" || cyberark.Folder != "Root" || len(cyberark.Properties) != 3 || cyberark.Properties[1] != (types.Properties{Key: "UserName", Value: "svc-sql"}) {
+		t.Errorf("CyberArk extension = %+v", cyberark)
+	}
+}
+
+func TestDecodeResourceTypes(t *testing.T) {
+	var resourceTypes types.ResourceTypes
+	decodeGolden(t, "resource_types.json", &resourceTypes)
+	checkPage(t, &resourceTypes, 5, 6, false)
+
+	endpoints := map[string]string{}
+	for _, resourceType := range resourceTypes.Items() {
+		endpoints[resourceType.Name] = resourceType.Endpoint
+	}
+	for name, endpoint := range map[string]string{"User": "/Users", "Group": "/Groups", "Container": "/Containers", "ContainerPermission": "/ContainerPermissions", "PrivilegedData": "/PrivilegedData"} {
+		if endpoints[name] != endpoint {
+			t.Errorf("endpoint of %s = %q, want %q", name, endpoints[name], endpoint)
+		}
+	}
+	if extensions := resourceTypes.Resources[2].SchemaExtensions; len(extensions) != 1 || !extensions[0].Required {
+		t.Errorf("Container extensions = %+v, want the required Safe extension", extensions)
+	}
+}
+
+func TestDecodeSchemas(t *testing.T) {
+	var schemas types.Schemas
+	decodeGolden(t, "schemas.json", &schemas)
+	checkPage(t, &schemas, 2, 3, false)
+
+	group := schemas.Resources[0]
+	if group.Id != "urn:ietf:params:scim:schemas:core:2.0:Group" || len(group.Attributes) != 2 {
+		t.Fatalf("schema = %+v", group)
+	}
+	members := group.Attributes[1]
+	if members.Name != "members" || members.Type != "complex" || !members.MultiValued || len(members.SubAttributes) != 2 || members.SubAttributes[1].Name != "$ref" {
+		t.Errorf("members = %+v", members)
+	}
+	if displayName := group.Attributes[0]; !displayName.Required || displayName.Mutability != "readWrite" {
+		t.Errorf("displayName = %+v", displayName)
+	}
+}
+
+func TestListResponseAccessors(t *testing.T) {
+	var nilPage *types.Users
+	if nilPage.Items() != nil || nilPage.Len() != 0 || nilPage.HasMore() || nilPage.NextStartIndex() != 1 {
+		t.Error("nil page has resources")
+	}
+	if _, ok := nilPage.First(); ok {
+		t.Error("nil page has a first resource")
+	}
+
+	tests := []struct {
+		name           string
+		page           types.Groups
+		nextStartIndex int
+		hasMore        bool
+	}{
+		{"empty", types.Groups{TotalResults: 3, StartIndex: 4}, 4, false},
+		{"without startIndex", types.Groups{TotalResults: 3, Resources: make([]types.Group, 2)}, 3, true},
+		{"last page", types.Groups{TotalResults: 3, StartIndex: 3, Resources: make([]types.Group, 1)}, 4, false},
+		{"without totalResults", types.Groups{StartIndex: 1, Resources: make([]types.Group, 2)}, 3, false},
+	}
+	for _, tt := range tests {
+		if next, hasMore := tt.page.NextStartIndex(), tt.page.HasMore(); next != tt.nextStartIndex || hasMore != tt.hasMore {
+			t.Errorf("%s: next start index = %d and has more = %v, want %d and %v", tt.name, next, hasMore, tt.nextStartIndex, tt.hasMore)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+)
+
+// PatchOpSchema is the schema of SCIM PATCH requests
+const PatchOpSchema = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+
+// PatchOp is the operation performed by a PatchOperation
+type PatchOp string
+
+const (
+	PatchOpAdd     PatchOp = "add"
+	PatchOpRemove  PatchOp = "remove"
+	PatchOpReplace PatchOp = "replace"
+)
+
+// PatchRequest is the body of a SCIM PATCH request (RFC 7644 section 3.5.2)
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+// PatchOperation is a single add, remove, or replace operation. Path may contain a
+// value filter (e.g. members[value eq "12"]) and Value may be any JSON value.
+type PatchOperation struct {
+	Op    PatchOp     `json:"op"`
+	Path  string      `json:"path,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// NewPatchRequest returns an empty PatchRequest. Operations are added with Add, Remove, and Replace.
+//
+// Example Usage:
+//		patch := types.NewPatchRequest().
+//			Replace("active", false).
+//			Add("emails", []types.Emails{{Type: "work", Value: "john.smith@example.com"}}).
+//			Remove(`members[value eq "12"]`)
+//
+func NewPatchRequest() *PatchRequest {
+	return &PatchRequest{
+		Schemas: []string{PatchOpSchema},
+	}
+}
+
+// Add appends an "add" operation. The path may be empty to add attributes of the value object.
+func (p *PatchRequest) Add(path string, value interface{}) *PatchRequest {
+	p.Operations = append(p.Operations, PatchOperation{Op: PatchOpAdd, Path: path, Value: value})
+	return p
+}
+
+// Remove appends a "remove" operation
+func (p *PatchRequest) Remove(path string) *PatchRequest {
+	p.Operations = append(p.Operations, PatchOperation{Op: PatchOpRemove, Path: path})
+	return p
+}
+
+// Replace appends a "replace" operation. The path may be empty to replace attributes of the value object.
+func (p *PatchRequest) Replace(path string, value interface{}) *PatchRequest {
+	p.Operations = append(p.Operations, PatchOperation{Op: PatchOpReplace, Path: path, Value: value})
+	return p
+}
+
+// Validate reports an error if the request is nil, has no operations, or an operation is incomplete
+func (p *PatchRequest) Validate() error {
+	if p == nil {
+		return errors.New("patch request is required")
+	}
+	if len(p.Operations) == 0 {
+		return errors.New("patch request does not contain any operations")
+	}
+	for i, op := range p.Operations {
+		switch op.Op {
+		case PatchOpAdd, PatchOpReplace:
+			if op.Value == nil {
+				return fmt.Errorf("patch operation %d (%s %s) requires a value", i, op.Op, op.Path)
+			}
+		case PatchOpRemove:
+			if op.Path == "" {
+				return fmt.Errorf("patch operation %d (remove) requires a path", i)
+			}
+		default:
+			return fmt.Errorf("patch operation %d has an invalid op %q, accepted values are add, remove, or replace", i, op.Op)
+		}
+	}
+
+	return nil
+}
@@ -1,12 +1,7 @@
 package types
 
-type PrivilegedDatas struct {
-	Schemas      []string          `json:"schemas"`
-	TotalResults int               `json:"totalResults"`
-	ItemsPerPage int               `json:"itemsPerPage"`
-	StartIndex   int               `json:"startIndex,omitempty"`
-	Resources    []PrivilegedDatas `json:"Resources"`
-}
+// PrivilegedDatas is the ListResponse of the PrivilegedData (Accounts) endpoint
+type PrivilegedDatas = ListResponse[PrivilegedData]
 
 type PrivilegedData struct {
 	Name                                             string                                           `json:"name"`
@@ -18,6 +13,19 @@ type PrivilegedData struct {
 	Meta                                             Meta                                             `json:"meta"`
 	Operations                                       []Operations                                     `json:"Operations,omitempty"`
 	UrnIetfParamsScimSchemasCyberark10PrivilegedData UrnIetfParamsScimSchemasCyberark10PrivilegedData `json:"urn:ietf:params:scim:schemas:cyberark:1.0:PrivilegedData"`
+	Extra                                            RawAttributes                                    `json:"-"`
+}
+
+// MarshalJSON encodes the PrivilegedData followed by the attributes of Extra
+func (p PrivilegedData) MarshalJSON() ([]byte, error) {
+	type privilegedData PrivilegedData
+	return marshalExtra(privilegedData(p), p.Extra)
+}
+
+// UnmarshalJSON decodes the PrivilegedData and keeps the attributes it does not model in Extra
+func (p *PrivilegedData) UnmarshalJSON(data []byte) error {
+	type privilegedData PrivilegedData
+	return unmarshalExtra(data, (*privilegedData)(p), &p.Extra)
 }
 
 type Properties struct {
@@ -26,10 +34,23 @@ type Properties struct {
 }
 
 type UrnIetfParamsScimSchemasCyberark10PrivilegedData struct {
-	Safe       string       `json:"safe,omitempty"`
-	Folder     string       `json:"folder,omitempty"`
-	Password   string       `json:"password,omitempty"`
-	Properties []Properties `json:"properties,omitempty"`
+	Safe       string        `json:"safe,omitempty"`
+	Folder     string        `json:"folder,omitempty"`
+	Password   string        `json:"password,omitempty"`
+	Properties []Properties  `json:"properties,omitempty"`
+	Extra      RawAttributes `json:"-"`
+}
+
+// MarshalJSON encodes the CyberArk PrivilegedData extension followed by the attributes of Extra
+func (e UrnIetfParamsScimSchemasCyberark10PrivilegedData) MarshalJSON() ([]byte, error) {
+	type extension UrnIetfParamsScimSchemasCyberark10PrivilegedData
+	return marshalExtra(extension(e), e.Extra)
+}
+
+// UnmarshalJSON decodes the CyberArk PrivilegedData extension and keeps the attributes it does not model in Extra
+func (e *UrnIetfParamsScimSchemasCyberark10PrivilegedData) UnmarshalJSON(data []byte) error {
+	type extension UrnIetfParamsScimSchemasCyberark10PrivilegedData
+	return unmarshalExtra(data, (*extension)(e), &e.Extra)
 }
 
 // Used in Privileged Data PATCH functions
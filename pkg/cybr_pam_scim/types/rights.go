@@ -0,0 +1,221 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnknownRight is returned for a name which is not a safe member right
+var ErrUnknownRight = errors.New("unknown safe member right")
+
+// Rights is a set of safe member rights (permissions of a ContainerPermission). It is encoded
+// as the list of right names expected by the SCIM API, in the order of the Right constants.
+//
+// Example Usage:
+//		permission.Rights = types.EndUserRights.Union(types.RightViewSafeMembers)
+//		if permission.Rights.Has(types.RightManageSafe) {
+//			// Safe manager
+//		}
+//
+type Rights uint32
+
+// Safe member rights
+const (
+	RightUseAccounts Rights = 1 << iota
+	RightRetrieveAccounts
+	RightListAccounts
+	RightAddAccounts
+	RightUpdateAccountContent
+	RightUpdateAccountProperties
+	RightInitiateCPMAccountManagementOperations
+	RightSpecifyNextAccountContent
+	RightRenameAccounts
+	RightDeleteAccounts
+	RightUnlockAccounts
+	RightManageSafe
+	RightManageSafeMembers
+	RightBackupSafe
+	RightViewAuditLog
+	RightViewSafeMembers
+	RightAccessWithoutConfirmation
+	RightCreateFolders
+	RightDeleteFolders
+	RightMoveAccountsAndFolders
+	RightRequestsAuthorizationLevel1
+	RightRequestsAuthorizationLevel2
+
+	// AllRights grants every safe member right which may be granted together. The two
+	// authorization levels are mutually exclusive, AllRights authorizes requests of the first
+	// level: use AllRights.Without(RightRequestsAuthorizationLevel1).Union(RightRequestsAuthorizationLevel2)
+	// for the second.
+	AllRights = RightRequestsAuthorizationLevel2 - 1
+)
+
+// Role presets
+const (
+	// ViewerRights list and retrieve accounts
+	ViewerRights = RightListAccounts | RightRetrieveAccounts
+	// EndUserRights list, use, and retrieve accounts
+	EndUserRights = RightListAccounts | RightUseAccounts | RightRetrieveAccounts
+	// AuditorRights list accounts and view the audit log and safe members
+	AuditorRights = RightListAccounts | RightViewAuditLog | RightViewSafeMembers
+	// ApproverRights authorize requests of the first confirmation level and manage safe members
+	ApproverRights = RightListAccounts | RightViewSafeMembers | RightManageSafeMembers | RightRequestsAuthorizationLevel1
+	// SafeManagerRights manage the safe, its members and accounts, and authorize requests of
+	// the first confirmation level
+	SafeManagerRights = RightUseAccounts | RightRetrieveAccounts | RightListAccounts |
+		RightAddAccounts | RightUpdateAccountContent | RightUpdateAccountProperties |
+		RightInitiateCPMAccountManagementOperations | RightSpecifyNextAccountContent |
+		RightRenameAccounts | RightDeleteAccounts | RightUnlockAccounts | RightManageSafe |
+		RightManageSafeMembers | RightBackupSafe | RightViewAuditLog | RightViewSafeMembers |
+		RightAccessWithoutConfirmation | RightCreateFolders | RightDeleteFolders |
+		RightMoveAccountsAndFolders | RightRequestsAuthorizationLevel1
+)
+
+// rightNames are the names of the rights in the order of the Right constants
+var rightNames = []string{
+	"UseAccounts",
+	"RetrieveAccounts",
+	"ListAccounts",
+	"AddAccounts",
+	"UpdateAccountContent",
+	"UpdateAccountProperties",
+	"InitiateCPMAccountManagementOperations",
+	"SpecifyNextAccountContent",
+	"RenameAccounts",
+	"DeleteAccounts",
+	"UnlockAccounts",
+	"ManageSafe",
+	"ManageSafeMembers",
+	"BackupSafe",
+	"ViewAuditLog",
+	"ViewSafeMembers",
+	"AccessWithoutConfirmation",
+	"CreateFolders",
+	"DeleteFolders",
+	"MoveAccountsAndFolders",
+	"RequestsAuthorizationLevel1",
+	"RequestsAuthorizationLevel2",
+}
+
+// ParseRights returns the set of the rights named case insensitively. An error wrapping
+// ErrUnknownRight is returned for a name which is not a right, e.g. "RetreiveAccounts".
+//
+// Example Usage:
+//		rights, err := types.ParseRights("ListAccounts", "RetrieveAccounts")
+//
+func ParseRights(names ...string) (Rights, error) {
+	var rights Rights
+	for _, name := range names {
+		right, ok := parseRight(name)
+		if !ok {
+			return 0, fmt.Errorf("%w %q", ErrUnknownRight, name)
+		}
+		rights |= right
+	}
+
+	return rights, nil
+}
+
+func parseRight(name string) (Rights, bool) {
+	for i, rightName := range rightNames {
+		if strings.EqualFold(rightName, name) {
+			return 1 << i, true
+		}
+	}
+
+	return 0, false
+}
+
+// Names returns the names of the rights in the order of the Right constants
+func (r Rights) Names() []string {
+	names := []string{}
+	for i, name := range rightNames {
+		if r&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+func (r Rights) String() string {
+	return strings.Join(r.Names(), ",")
+}
+
+// Has reports whether every right of other is in the set
+func (r Rights) Has(other Rights) bool {
+	return r&other == other
+}
+
+// Union returns the rights in either set
+func (r Rights) Union(other Rights) Rights {
+	return r | other
+}
+
+// Intersect returns the rights in both sets
+func (r Rights) Intersect(other Rights) Rights {
+	return r & other
+}
+
+// Without returns the rights of the set which are not in other
+func (r Rights) Without(other Rights) Rights {
+	return r &^ other
+}
+
+// Len returns the number of rights in the set
+func (r Rights) Len() int {
+	n := 0
+	for ; r != 0; r &= r - 1 {
+		n++
+	}
+
+	return n
+}
+
+// MarshalJSON encodes the rights as a list of right names
+func (r Rights) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.Names())
+}
+
+// UnmarshalJSON decodes a list of right names. Names which are not rights, e.g. rights added
+// by a later PVWA version, are skipped so that the permission still decodes;
+// ContainerPermission keeps them in UnknownRights.
+func (r *Rights) UnmarshalJSON(data []byte) error {
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return err
+	}
+	rights, _ := splitRights(names)
+	*r = rights
+
+	return nil
+}
+
+// splitRights returns the set of the rights named and the names which are not rights
+func splitRights(names []string) (Rights, []string) {
+	var rights Rights
+	var unknown []string
+	for _, name := range names {
+		if right, ok := parseRight(name); ok {
+			rights |= right
+		} else {
+			unknown = append(unknown, name)
+		}
+	}
+
+	return rights, unknown
+}
+
+// DiffRights returns the rights granted by to but not by from, and the rights granted by from
+// but not by to, e.g. the rights added and removed between a reviewed and a live permission.
+//
+// Example Usage:
+//		added, removed := types.DiffRights(reviewed, *live)
+//		fmt.Printf("added: %s, removed: %s\n", added, removed)
+//
+func DiffRights(from ContainerPermission, to ContainerPermission) (added Rights, removed Rights) {
+	return to.Rights.Without(from.Rights), from.Rights.Without(to.Rights)
+}
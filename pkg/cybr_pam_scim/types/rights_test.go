@@ -0,0 +1,90 @@
+package types_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
+)
+
+func TestRightsUnmarshalSkipsUnknownNames(t *testing.T) {
+	var rights types.Rights
+	if err := json.Unmarshal([]byte(`["ListAccounts","ManageSessions","retrieveaccounts"]`), &rights); err != nil {
+		t.Fatal(err)
+	}
+	if rights != types.ViewerRights {
+		t.Errorf("rights = %s, want %s", rights, types.ViewerRights)
+	}
+
+	if _, err := types.ParseRights("ListAccounts", "ManageSessions"); !errors.Is(err, types.ErrUnknownRight) {
+		t.Errorf("error = %v, want ErrUnknownRight from ParseRights", err)
+	}
+}
+
+func TestContainerPermissionKeepsUnknownRights(t *testing.T) {
+	var permission types.ContainerPermission
+	data := `{"container":{"name":"AppSafe"},"user":{"display":"john.smith"},"rights":["ListAccounts","ManageSessions","UseAccounts"]}`
+	if err := json.Unmarshal([]byte(data), &permission); err != nil {
+		t.Fatal(err)
+	}
+	if permission.Rights != types.RightListAccounts|types.RightUseAccounts {
+		t.Errorf("rights = %s", permission.Rights)
+	}
+	if !reflect.DeepEqual(permission.UnknownRights, []string{"ManageSessions"}) {
+		t.Errorf("unknown rights = %v, want ManageSessions", permission.UnknownRights)
+	}
+
+	permission.Rights = permission.Rights.Union(types.RightRetrieveAccounts)
+	encoded, err := json.Marshal(permission)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sent struct {
+		Rights []string `json:"rights"`
+	}
+	if err := json.Unmarshal(encoded, &sent); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"UseAccounts", "RetrieveAccounts", "ListAccounts", "ManageSessions"}
+	if !reflect.DeepEqual(sent.Rights, want) {
+		t.Errorf("rights sent = %v, want %v", sent.Rights, want)
+	}
+
+	// Without unknown rights the struct encodes as before
+	permission.UnknownRights = nil
+	if encoded, err = json.Marshal(permission); err != nil {
+		t.Fatal(err)
+	}
+	var again types.ContainerPermission
+	if err := json.Unmarshal(encoded, &again); err != nil {
+		t.Fatal(err)
+	}
+	if again.Rights != permission.Rights || again.UnknownRights != nil || again.Extra != nil {
+		t.Errorf("permission = %+v, want %+v", again, permission)
+	}
+}
+
+func TestRightsPresetsAuthorizationLevels(t *testing.T) {
+	levels := types.RightRequestsAuthorizationLevel1 | types.RightRequestsAuthorizationLevel2
+	presets := map[string]types.Rights{
+		"AllRights":         types.AllRights,
+		"ViewerRights":      types.ViewerRights,
+		"EndUserRights":     types.EndUserRights,
+		"AuditorRights":     types.AuditorRights,
+		"ApproverRights":    types.ApproverRights,
+		"SafeManagerRights": types.SafeManagerRights,
+	}
+	for name, rights := range presets {
+		if rights.Has(levels) {
+			t.Errorf("%s grants both authorization levels", name)
+		}
+	}
+	if types.AllRights.Len() != 21 || !types.AllRights.Has(types.RightRequestsAuthorizationLevel1) {
+		t.Errorf("AllRights = %s, want every right but RequestsAuthorizationLevel2", types.AllRights)
+	}
+	if types.SafeManagerRights != types.AllRights {
+		t.Errorf("SafeManagerRights = %s, want every right but RequestsAuthorizationLevel2", types.SafeManagerRights)
+	}
+}
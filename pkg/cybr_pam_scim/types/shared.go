@@ -1,6 +1,7 @@
 package types
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -37,6 +38,14 @@ type UserRef struct {
 	Display string `json:"display,omitempty"`
 }
 
+// SCIM Error Response //////////////////////////////////////////////////////////////////
+type ErrorResponse struct {
+	Schemas  []string    `json:"schemas"`
+	ScimType string      `json:"scimType,omitempty"`
+	Detail   string      `json:"detail,omitempty"`
+	Status   json.Number `json:"status,omitempty"`
+}
+
 // SCIM Service Provider Config //////////////////////////////////////////////////////////////////
 type ScimConfig struct {
 	Schemas               []string                `json:"schemas"`
@@ -84,13 +93,8 @@ type AuthenticationSchemes struct {
 }
 
 // SCIM Resource Types ///////////////////////////////////////////
-type ResourceTypes struct {
-	Schemas      []string       `json:"schemas"`
-	TotalResults int            `json:"totalResults"`
-	ItemsPerPage int            `json:"itemsPerPage"`
-	StartIndex   int            `json:"startIndex"`
-	Resources    []ResourceType `json:"Resources"`
-}
+// ResourceTypes is the ListResponse of the ResourceTypes endpoint
+type ResourceTypes = ListResponse[ResourceType]
 
 type ResourceType struct {
 	Name             string             `json:"name"`
@@ -108,13 +112,8 @@ type SchemaExtensions struct {
 }
 
 // SCIM Schemas ///////////////////////////////////////////
-type Schemas struct {
-	Schemas      []string `json:"schemas"`
-	TotalResults int      `json:"totalResults"`
-	ItemsPerPage int      `json:"itemsPerPage"`
-	StartIndex   int      `json:"startIndex"`
-	Resources    []Schema `json:"Resources"`
-}
+// Schemas is the ListResponse of the Schemas endpoint
+type Schemas = ListResponse[Schema]
 
 type Schema struct {
 	Name        string       `json:"name"`
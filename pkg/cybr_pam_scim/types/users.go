@@ -1,12 +1,7 @@
 package types
 
-type Users struct {
-	Schemas      []string `json:"schemas"`
-	TotalResults int      `json:"totalResults"`
-	ItemsPerPage int      `json:"itemsPerPage"`
-	StartIndex   int      `json:"startIndex"`
-	Resources    []User   `json:"Resources"`
-}
+// Users is the ListResponse of the Users endpoint
+type Users = ListResponse[User]
 
 type User struct {
 	UserName                                          string                                            `json:"userName"`
@@ -20,7 +15,7 @@ type User struct {
 	Locale                                            string                                            `json:"locale,omitempty"`
 	Timezone                                          string                                            `json:"timezone,omitempty"`
 	Active                                            bool                                              `json:"active,omitempty"`
-	Password                                          string                                            `json:"-,omitempty"`
+	Password                                          string                                            `json:"password,omitempty"`
 	Emails                                            []Emails                                          `json:"emails,omitempty"`
 	PhoneNumbers                                      []PhoneNumbers                                    `json:"phoneNumbers,omitempty"`
 	Ims                                               []Ims                                             `json:"ims,omitempty"`
@@ -38,6 +33,21 @@ type User struct {
 	UrnIetfParamsScimSchemasExtensionEnterprise20User UrnIetfParamsScimSchemasExtensionEnterprise20User `json:"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User,omitempty"`
 	UrnIetfParamsScimSchemasCyberark10User            UrnIetfParamsScimSchemasCyberark10User            `json:"urn:ietf:params:scim:schemas:cyberark:1.0:User,omitempty"`
 	UrnScimSchemasExtensionCustom20                   UrnScimSchemasExtensionCustom20                   `json:"urn:scim:schemas:extension:custom:2.0,omitempty"`
+	Extra                                             RawAttributes                                     `json:"-"`
+	Extensions                                        Extensions                                        `json:"-"`
+}
+
+// MarshalJSON encodes the User with its registered Extensions followed by the attributes of Extra
+func (u User) MarshalJSON() ([]byte, error) {
+	type user User
+	return marshalResource("User", user(u), u.Extra, u.Extensions)
+}
+
+// UnmarshalJSON decodes the User, its registered Extensions, and keeps the other attributes it
+// does not model in Extra
+func (u *User) UnmarshalJSON(data []byte) error {
+	type user User
+	return unmarshalResource("User", data, (*user)(u), &u.Extra, &u.Extensions)
 }
 
 type UsersGroups struct {
@@ -115,17 +125,43 @@ type X509Certificates struct {
 }
 
 type UrnIetfParamsScimSchemasPam10LinkedObject struct {
-	Source           string `json:"source,omitempty"`
-	NativeIdentifier string `json:"nativeIdentifier,omitempty"`
+	Source           string        `json:"source,omitempty"`
+	NativeIdentifier string        `json:"nativeIdentifier,omitempty"`
+	Extra            RawAttributes `json:"-"`
+}
+
+// MarshalJSON encodes the LinkedObject extension followed by the attributes of Extra
+func (e UrnIetfParamsScimSchemasPam10LinkedObject) MarshalJSON() ([]byte, error) {
+	type extension UrnIetfParamsScimSchemasPam10LinkedObject
+	return marshalExtra(extension(e), e.Extra)
+}
+
+// UnmarshalJSON decodes the LinkedObject extension and keeps the attributes it does not model in Extra
+func (e *UrnIetfParamsScimSchemasPam10LinkedObject) UnmarshalJSON(data []byte) error {
+	type extension UrnIetfParamsScimSchemasPam10LinkedObject
+	return unmarshalExtra(data, (*extension)(e), &e.Extra)
 }
 
 type UrnIetfParamsScimSchemasExtensionEnterprise20User struct {
-	EmployeeNumber string    `json:"employeeNumber,omitempty"`
-	CostCenter     string    `json:"costCenter,omitempty"`
-	Organization   string    `json:"organization,omitempty"`
-	Division       string    `json:"division,omitempty"`
-	Department     string    `json:"department,omitempty"`
-	Manager        []Manager `json:"manager,omitempty"`
+	EmployeeNumber string        `json:"employeeNumber,omitempty"`
+	CostCenter     string        `json:"costCenter,omitempty"`
+	Organization   string        `json:"organization,omitempty"`
+	Division       string        `json:"division,omitempty"`
+	Department     string        `json:"department,omitempty"`
+	Manager        []Manager     `json:"manager,omitempty"`
+	Extra          RawAttributes `json:"-"`
+}
+
+// MarshalJSON encodes the enterprise User extension followed by the attributes of Extra
+func (e UrnIetfParamsScimSchemasExtensionEnterprise20User) MarshalJSON() ([]byte, error) {
+	type extension UrnIetfParamsScimSchemasExtensionEnterprise20User
+	return marshalExtra(extension(e), e.Extra)
+}
+
+// UnmarshalJSON decodes the enterprise User extension and keeps the attributes it does not model in Extra
+func (e *UrnIetfParamsScimSchemasExtensionEnterprise20User) UnmarshalJSON(data []byte) error {
+	type extension UrnIetfParamsScimSchemasExtensionEnterprise20User
+	return unmarshalExtra(data, (*extension)(e), &e.Extra)
 }
 
 type Manager struct {
@@ -135,13 +171,23 @@ type Manager struct {
 }
 
 type UrnIetfParamsScimSchemasCyberark10User struct {
-	AuthenticationMethod  []string `json:"authenticationMethod,omitempty"`
-	ExpiryDate            int64    `json:"expiryDate,omitempty"`
-	ChangePassOnNextLogon bool     `json:"changePassOnNextLogon,omitempty"`
-	PasswordNeverExpires  bool     `json:"passwordNeverExpires,omitempty"`
-	DistinguishedName     string   `json:"distinguishedName,omitempty"`
-	DirectoryType         string   `json:"directoryType,omitempty"`
+	AuthenticationMethod  []string      `json:"authenticationMethod,omitempty"`
+	ExpiryDate            int64         `json:"expiryDate,omitempty"`
+	ChangePassOnNextLogon bool          `json:"changePassOnNextLogon,omitempty"`
+	PasswordNeverExpires  bool          `json:"passwordNeverExpires,omitempty"`
+	DistinguishedName     string        `json:"distinguishedName,omitempty"`
+	DirectoryType         string        `json:"directoryType,omitempty"`
+	Extra                 RawAttributes `json:"-"`
+}
+
+// MarshalJSON encodes the CyberArk User extension followed by the attributes of Extra
+func (e UrnIetfParamsScimSchemasCyberark10User) MarshalJSON() ([]byte, error) {
+	type extension UrnIetfParamsScimSchemasCyberark10User
+	return marshalExtra(extension(e), e.Extra)
 }
 
-type UrnScimSchemasExtensionCustom20 struct {
+// UnmarshalJSON decodes the CyberArk User extension and keeps the attributes it does not model in Extra
+func (e *UrnIetfParamsScimSchemasCyberark10User) UnmarshalJSON(data []byte) error {
+	type extension UrnIetfParamsScimSchemasCyberark10User
+	return unmarshalExtra(data, (*extension)(e), &e.Extra)
 }
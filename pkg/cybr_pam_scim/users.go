@@ -6,14 +6,13 @@ import (
 	"net/url"
 	"strconv"
 
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/filter"
 	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
 	"golang.org/x/exp/slices"
 )
 
-var (
-	Users types.Users
-	User  types.User
-)
+// userSortBy contains the attributes Users may be sorted by
+var userSortBy = []string{"active", "userName", "displayName", "name.familyName", "name.givenName", "userType", "id", "meta.created", "meta.lastmodified", "meta.location"}
 
 // GetUsers retrieves all users via the SCIM API.
 // The response from the SCIM API is returned as the types.Users struct
@@ -21,12 +20,17 @@ var (
 // Example Usage:
 //		getUsers, err := s.GetUsers(context.Background)
 //
-func (s *Service) GetUsers(ctx context.Context) (*types.Users, error) {
-	if err := s.client.Get(ctx, fmt.Sprintf("/%s", "users"), &Users); err != nil {
+func (s *Service) GetUsers(ctx context.Context, opts ...ReadOption) (*types.Users, error) {
+	var users types.Users
+	path, err := s.readPath(ctx, "User", fmt.Sprintf("/%s", "users"), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+	if err := s.client.Get(ctx, path, &users); err != nil {
 		return nil, fmt.Errorf("failed to get users: %w", err)
 	}
 
-	return &Users, nil
+	return &users, nil
 }
 
 // GetUsersIndex retrieves a limited subset of Users based on a starting index and count.
@@ -37,13 +41,18 @@ func (s *Service) GetUsers(ctx context.Context) (*types.Users, error) {
 // Example Usage:
 //		getUsersIndex, err := s.GetUsersIndex(context.Background, 1, 5)
 //
-func (s *Service) GetUsersIndex(ctx context.Context, startIndex int, count int) (*types.Users, error) {
+func (s *Service) GetUsersIndex(ctx context.Context, startIndex int, count int, opts ...ReadOption) (*types.Users, error) {
+	var users types.Users
 	pathEscapedQuery := url.PathEscape("startIndex=" + strconv.Itoa(startIndex) + "&count=" + strconv.Itoa(count))
-	if err := s.client.Get(ctx, fmt.Sprintf("/%s?%s", "Users", pathEscapedQuery), &Users); err != nil {
+	path, err := s.readPath(ctx, "User", fmt.Sprintf("/%s?%s", "Users", pathEscapedQuery), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Users: %w", err)
+	}
+	if err := s.client.Get(ctx, path, &users); err != nil {
 		return nil, fmt.Errorf("failed to get Users: %w", err)
 	}
 
-	return &Users, nil
+	return &users, nil
 }
 
 // GetUsersSort retrieves and sorts all users via the SCIM API based on provided
@@ -56,11 +65,15 @@ func (s *Service) GetUsersIndex(ctx context.Context, startIndex int, count int)
 // Example Usage:
 //		getUsersSort, err := s.GetUsersSort(context.Background, "userName", "ascending")
 //
-func (s *Service) GetUsersSort(ctx context.Context, sortBy string, sortOrder string) (*types.Users, error) {
-	allowedSortBy := []string{"active", "userName", "displayName", "name.familyName", "name.givenName", "userType", "id", "meta.created", "meta.lastmodified", "meta.location"}
+func (s *Service) GetUsersSort(ctx context.Context, sortBy string, sortOrder string, opts ...ReadOption) (*types.Users, error) {
+	if !s.capabilities(ctx).SortSupported() {
+		return nil, ErrSortNotSupported
+	}
+
+	var users types.Users
 	var pathEscapedQuery string
 	// Input validations:
-	if slices.Contains(allowedSortBy, sortBy) {
+	if slices.Contains(userSortBy, sortBy) {
 		if sortOrder == "ascending" || sortOrder == "descending" {
 			pathEscapedQuery = url.PathEscape("sortBy=" + sortBy + "&sortOrder=" + sortOrder)
 		} else if sortOrder == "" {
@@ -71,11 +84,15 @@ func (s *Service) GetUsersSort(ctx context.Context, sortBy string, sortOrder str
 	} else {
 		return nil, fmt.Errorf("invalid sortBy value provided, accepted values are active, userName, displayName, name.givenName, name.familyName, userType, id, meta.created, meta.lastmodified, or meta.location")
 	}
-	if err := s.client.Get(ctx, fmt.Sprintf("/%s?%s", "users", pathEscapedQuery), &Users); err != nil {
+	path, err := s.readPath(ctx, "User", fmt.Sprintf("/%s?%s", "users", pathEscapedQuery), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+	if err := s.client.Get(ctx, path, &users); err != nil {
 		return nil, fmt.Errorf("failed to get users: %w", err)
 	}
 
-	return &Users, nil
+	return &users, nil
 }
 
 // GetUserById retrieves a single user by User Id via the SCIM API.
@@ -86,12 +103,17 @@ func (s *Service) GetUsersSort(ctx context.Context, sortBy string, sortOrder str
 // Example Usage:
 //		getUserById, err := s.GetUserById(context.Background, "1")
 //
-func (s *Service) GetUserById(ctx context.Context, id string) (*types.User, error) {
-	if err := s.client.Get(ctx, fmt.Sprintf("/%s/%s", "users", id), &User); err != nil {
+func (s *Service) GetUserById(ctx context.Context, id string, opts ...ReadOption) (*types.User, error) {
+	var user types.User
+	path, err := s.readPath(ctx, "User", fmt.Sprintf("/%s/%s", "users", id), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user %s: %w", id, err)
+	}
+	if err := s.client.Get(ctx, path, &user); err != nil {
 		return nil, fmt.Errorf("failed to get user %s: %w", id, err)
 	}
 
-	return &User, nil
+	return &user, nil
 }
 
 // GetUserByFilter retrieves a single user based on a provided filter via the SCIM API.
@@ -105,13 +127,48 @@ func (s *Service) GetUserById(ctx context.Context, id string) (*types.User, erro
 //		getUserByFilter, err := s.GetUserByFilter(context.Background, "userName", "john.smith@example.com")
 //		getUserByFilter, err := s.GetUserByFilter(context.Background, "name.familyName", "Smith")
 //
-func (s *Service) GetUserByFilter(ctx context.Context, filterType string, filterQuery string) (*types.User, error) {
-	pathEscapedQuery := url.PathEscape("filter=" + filterType + " eq \"" + filterQuery + "\"")
-	if err := s.client.Get(ctx, fmt.Sprintf("/%s?%s", "users", pathEscapedQuery), &User); err != nil {
+func (s *Service) GetUserByFilter(ctx context.Context, filterType string, filterQuery string, opts ...ReadOption) (*types.User, error) {
+	var users types.ListResponse[types.User]
+	query := url.Values{"filter": {filter.Attr(filterType).Eq(filterQuery).String()}}
+	path, err := s.readPath(ctx, "User", fmt.Sprintf("/%s?%s", "users", encodeQuery(query)), opts)
+	if err != nil {
 		return nil, fmt.Errorf("failed to get user based on filter parameters - %s = %s: %w", filterType, filterQuery, err)
 	}
+	if err := s.client.Get(ctx, path, &users); err != nil {
+		return nil, fmt.Errorf("failed to get user based on filter parameters - %s = %s: %w", filterType, filterQuery, err)
+	}
+	user, ok := users.First()
+	if !ok {
+		return nil, fmt.Errorf("failed to get user based on filter parameters - %s = %s: %w", filterType, filterQuery, ErrNotFound)
+	}
+
+	return user, nil
+}
+
+// GetUsersByFilterExpr retrieves all users matching a filter expression via the SCIM API.
+// The response from the SCIM API is returned as the types.Users struct.
+// The attributes referenced by the expression are validated against filter.Users.
+//
+// Example Usage:
+//		expr := filter.And(filter.Attr("userName").Sw("john"), filter.Attr("active").Eq(true))
+//		getUsersByFilterExpr, err := s.GetUsersByFilterExpr(context.Background, expr)
+//
+func (s *Service) GetUsersByFilterExpr(ctx context.Context, expr filter.Expression, opts ...ReadOption) (*types.Users, error) {
+	if err := filter.Users.Validate(expr); err != nil {
+		return nil, fmt.Errorf("invalid filter provided: %w", err)
+	}
 
-	return &User, nil
+	var users types.Users
+	query := url.Values{"filter": {expr.String()}}
+	path, err := s.readPath(ctx, "User", fmt.Sprintf("/%s?%s", "users", encodeQuery(query)), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users based on filter %s: %w", expr, err)
+	}
+	if err := s.client.Get(ctx, path, &users); err != nil {
+		return nil, fmt.Errorf("failed to get users based on filter %s: %w", expr, err)
+	}
+
+	return &users, nil
 }
 
 // AddUser attempts add a single user and requires a types.User struct with the
@@ -131,11 +188,12 @@ func (s *Service) GetUserByFilter(ctx context.Context, filterType string, filter
 //      addUser, err := s.AddUser(context.Background, user)
 //
 func (s *Service) AddUser(ctx context.Context, user types.User) (*types.User, error) {
-	if err := s.client.Post(ctx, fmt.Sprintf("/%s", "users"), User, &User); err != nil {
+	var userResponse types.User
+	if err := s.client.Post(ctx, fmt.Sprintf("/%s", "users"), user, &userResponse); err != nil {
 		return nil, fmt.Errorf("failed to add user %s: %w", user.UserName, err)
 	}
 
-	return &User, nil
+	return &userResponse, nil
 }
 
 // UpdateUser attempts to perform a "PUT" operation against a single User and requires
@@ -151,11 +209,104 @@ func (s *Service) AddUser(ctx context.Context, user types.User) (*types.User, er
 //      updateUser, err := s.UpdateUser(context.Background, user)
 //
 func (s *Service) UpdateUser(ctx context.Context, user types.User) (*types.User, error) {
-	if err := s.client.Put(ctx, fmt.Sprintf("/%s/%s", "users", user.Id), User, &User); err != nil {
+	var userResponse types.User
+	if err := s.client.Put(ctx, fmt.Sprintf("/%s/%s", "users", user.Id), user, &userResponse); err != nil {
 		return nil, fmt.Errorf("failed to update user %s: %w", user.Id, err)
 	}
 
-	return &User, nil
+	return &userResponse, nil
+}
+
+// UpdateUserIfMatch performs the "PUT" operation of UpdateUser only if the User has not been
+// modified since user.Meta.Version was retrieved, ErrPreconditionFailed is returned otherwise.
+// ErrETagNotSupported is returned if the service provider does not support ETags.
+//
+// Example Usage:
+//		user, err := s.GetUserById(context.Background, "8")
+//		user.DisplayName = "John Smith"
+//		update, err := s.UpdateUserIfMatch(context.Background, *user)
+//
+func (s *Service) UpdateUserIfMatch(ctx context.Context, user types.User) (*types.User, error) {
+	ctx, err := s.ifMatch(ctx, user.Meta.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update user %s: %w", user.Id, err)
+	}
+
+	return s.UpdateUser(ctx, user)
+}
+
+// EditUser retrieves a single User by User Id, applies fn to it, and replaces it with
+// UpdateUserIfMatch. The User is retrieved and fn applied again when it has been modified
+// concurrently, so fn must only modify the provided User. An error returned by fn aborts the
+// edit.
+//
+// Example Usage:
+//		edit, err := s.EditUser(context.Background, "8", func(user *types.User) error {
+//			user.DisplayName = "John Smith"
+//			return nil
+//		})
+//
+func (s *Service) EditUser(ctx context.Context, id string, fn func(user *types.User) error) (*types.User, error) {
+	user, err := edit(ctx, func(ctx context.Context) (*types.User, error) {
+		return s.GetUserById(ctx, id)
+	}, fn, func(ctx context.Context, user *types.User) (*types.User, error) {
+		return s.UpdateUserIfMatch(ctx, *user)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to edit user %s: %w", id, err)
+	}
+
+	return user, nil
+}
+
+// PatchUser attempts to perform a "PATCH" operation against a single User by User Id and
+// requires a types.PatchRequest with the desired operations. Only the attributes referenced
+// by the operations are modified. The response from the SCIM API is returned as the types.User struct,
+// which is empty if the SCIM API does not return the updated User.
+//
+// Example Usage:
+//		// Disable a user and replace the work email
+//		patch := types.NewPatchRequest().
+//			Replace("active", false).
+//			Replace(`emails[type eq "work"].value`, "john.smith@example.com")
+//		patchUser, err := s.PatchUser(context.Background, "8", patch)
+//
+func (s *Service) PatchUser(ctx context.Context, id string, patch *types.PatchRequest) (*types.User, error) {
+	if err := s.validatePatch(ctx, patch); err != nil {
+		return nil, fmt.Errorf("failed to patch user %s: %w", id, err)
+	}
+
+	var user types.User
+	if err := s.client.Patch(ctx, fmt.Sprintf("/%s/%s", "users", id), patch, &user); err != nil {
+		return nil, fmt.Errorf("failed to patch user %s: %w", id, err)
+	}
+
+	return &user, nil
+}
+
+// SetUserPassword sets or changes the password of a single User by User Id with a "PATCH"
+// operation replacing the password attribute. The password is sent only in the request body,
+// it is hidden from the verbose output by DefaultRedactionPolicy and never returned by the
+// SCIM API. ErrChangePasswordNotSupported is returned if the service provider does not
+// support changing passwords.
+//
+// Example Usage:
+//		err := s.SetUserPassword(context.Background, "8", "NewExamplePass")
+//
+func (s *Service) SetUserPassword(ctx context.Context, id string, password string) error {
+	if password == "" {
+		return fmt.Errorf("failed to set password of user %s: password is required", id)
+	}
+	if !s.capabilities(ctx).ChangePasswordSupported() {
+		return fmt.Errorf("failed to set password of user %s: %w", id, ErrChangePasswordNotSupported)
+	}
+
+	patch := types.NewPatchRequest().Replace("password", password)
+	if _, err := s.PatchUser(ctx, id, patch); err != nil {
+		return fmt.Errorf("failed to set password of user %s: %w", id, err)
+	}
+
+	return nil
 }
 
 // DeleteUser attempts to perform a "DELETE" operation against a single User by
@@ -0,0 +1,270 @@
+package cybr_pam_scim_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/scimtest"
+	"github.com/strick-j/cybr_pam_scim/pkg/cybr_pam_scim/types"
+)
+
+// storedPassword returns the password the server stored for the User
+func storedPassword(t *testing.T, srv *scimtest.Server, id string) string {
+	t.Helper()
+	var user types.User
+	if ok, err := srv.Resource("Users", id, &user); !ok || err != nil {
+		t.Fatalf("user %s not found: %v", id, err)
+	}
+
+	return user.Password
+}
+
+// echoPasswords is a middleware adding a password to every User and PrivilegedData returned
+// by the server, like a service provider which does not honor the write-only mutability
+func echoPasswords(next cybr_pam_scim.Handler) cybr_pam_scim.Handler {
+	return func(r *http.Request) (*http.Response, error) {
+		resp, err := next(r)
+		if err != nil || resp.StatusCode == http.StatusNoContent {
+			return resp, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		var v interface{}
+		if err := json.Unmarshal(body, &v); err == nil {
+			echoPassword(v)
+			body, _ = json.Marshal(v)
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+
+		return resp, nil
+	}
+}
+
+func echoPassword(v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if _, ok := v["userName"]; ok {
+			v["password"] = "Echoed"
+		}
+		if extension, ok := v["urn:ietf:params:scim:schemas:cyberark:1.0:PrivilegedData"].(map[string]interface{}); ok {
+			extension["password"] = "Echoed"
+		}
+		for _, value := range v {
+			echoPassword(value)
+		}
+	case []interface{}:
+		for _, value := range v {
+			echoPassword(value)
+		}
+	}
+}
+
+func TestAddUserSendsPassword(t *testing.T) {
+	srv := scimtest.NewServer()
+	defer srv.Close()
+	s := srv.NewService()
+
+	user, err := s.AddUser(context.Background(), types.User{UserName: "john.smith", Password: "ExamplePass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Password != "" {
+		t.Errorf("password = %q, want it cleared from the response", user.Password)
+	}
+	if password := storedPassword(t, srv, user.Id); password != "ExamplePass" {
+		t.Errorf("stored password = %q, want the password to be sent", password)
+	}
+}
+
+func TestSetUserPassword(t *testing.T) {
+	srv := scimtest.NewServer()
+	defer srv.Close()
+	s := srv.NewService()
+	ctx := context.Background()
+	user, err := s.AddUser(ctx, types.User{UserName: "john.smith", Password: "ExamplePass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SetUserPassword(ctx, user.Id, "NewExamplePass"); err != nil {
+		t.Fatal(err)
+	}
+	if password := storedPassword(t, srv, user.Id); password != "NewExamplePass" {
+		t.Errorf("stored password = %q, want the new password", password)
+	}
+	if err := s.SetUserPassword(ctx, user.Id, ""); err == nil {
+		t.Error("SetUserPassword succeeded without a password")
+	}
+}
+
+func TestSetUserPasswordNotSupported(t *testing.T) {
+	srv := scimtest.NewServer()
+	defer srv.Close()
+	config := srv.Config()
+	config.ChangePassword.Supported = false
+	srv.SetConfig(config)
+	id, err := srv.Seed("Users", types.User{UserName: "john.smith", Password: "ExamplePass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := cybr_pam_scim.NewClient(srv.Client(), cybr_pam_scim.Options{ApiURL: srv.URL})
+	var patches int
+	client.Use(func(next cybr_pam_scim.Handler) cybr_pam_scim.Handler {
+		return func(r *http.Request) (*http.Response, error) {
+			if r.Method == http.MethodPatch {
+				patches++
+			}
+			return next(r)
+		}
+	})
+	s := cybr_pam_scim.NewServiceWithClient(client)
+
+	err = s.SetUserPassword(context.Background(), id, "NewExamplePass")
+	if !errors.Is(err, cybr_pam_scim.ErrChangePasswordNotSupported) {
+		t.Errorf("error = %v, want ErrChangePasswordNotSupported", err)
+	}
+	if patches != 0 {
+		t.Errorf("patches = %d, want the request refused before sending it", patches)
+	}
+	if password := storedPassword(t, srv, id); password != "ExamplePass" {
+		t.Errorf("stored password = %q, want it unchanged", password)
+	}
+}
+
+func TestReadPathsClearPasswords(t *testing.T) {
+	srv := scimtest.NewServer()
+	defer srv.Close()
+	id, err := srv.Seed("Users", types.User{UserName: "john.smith", Password: "ExamplePass", Active: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := cybr_pam_scim.NewClient(srv.Client(), cybr_pam_scim.Options{ApiURL: srv.URL})
+	client.Use(echoPasswords)
+	s := cybr_pam_scim.NewServiceWithClient(client)
+	ctx := context.Background()
+
+	// The middleware must reach the client, otherwise the test proves nothing
+	var raw json.RawMessage
+	if err := client.Get(ctx, "/Users/"+id, &raw); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(raw), `"password":"Echoed"`) {
+		t.Fatalf("response = %s, want the echoed password", raw)
+	}
+
+	users := map[string]func() ([]types.User, error){
+		"GetUserById": func() ([]types.User, error) {
+			user, err := s.GetUserById(ctx, id)
+			return []types.User{*user}, err
+		},
+		"GetUserByFilter": func() ([]types.User, error) {
+			user, err := s.GetUserByFilter(ctx, "userName", "john.smith")
+			return []types.User{*user}, err
+		},
+		"GetUsers": func() ([]types.User, error) {
+			page, err := s.GetUsers(ctx)
+			return page.Items(), err
+		},
+		"ListResponse": func() ([]types.User, error) {
+			var page types.ListResponse[types.User]
+			err := client.Get(ctx, "/Users", &page)
+			return page.Items(), err
+		},
+		"Users.All": func() ([]types.User, error) {
+			var users []types.User
+			it := s.Users().All(ctx, nil)
+			for it.Next() {
+				users = append(users, it.Value())
+			}
+			return users, it.Err()
+		},
+		"Users.List": func() ([]types.User, error) {
+			return s.Users().List(ctx, nil)
+		},
+		"PatchUser": func() ([]types.User, error) {
+			user, err := s.PatchUser(ctx, id, types.NewPatchRequest().Replace("title", "Engineer"))
+			return []types.User{*user}, err
+		},
+		"UpdateUser": func() ([]types.User, error) {
+			user, err := s.UpdateUser(ctx, types.User{Id: id, UserName: "john.smith", Active: true})
+			return []types.User{*user}, err
+		},
+		"EditUser": func() ([]types.User, error) {
+			user, err := s.EditUser(ctx, id, func(user *types.User) error {
+				if user.Password != "" {
+					t.Errorf("EditUser: password = %q, want it cleared before the edit", user.Password)
+				}
+				user.Title = "Manager"
+				return nil
+			})
+			return []types.User{*user}, err
+		},
+	}
+	for name, read := range users {
+		got, err := read()
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if len(got) != 1 {
+			t.Errorf("%s: users = %d, want 1", name, len(got))
+			continue
+		}
+		if got[0].Password != "" {
+			t.Errorf("%s: password = %q, want it cleared", name, got[0].Password)
+		}
+	}
+	if password := storedPassword(t, srv, id); password != "ExamplePass" {
+		t.Errorf("stored password = %q, want the reads to leave it unchanged", password)
+	}
+}
+
+func TestReadPathsClearPrivilegedDataPasswords(t *testing.T) {
+	srv := scimtest.NewServer()
+	defer srv.Close()
+	client := cybr_pam_scim.NewClient(srv.Client(), cybr_pam_scim.Options{ApiURL: srv.URL})
+	client.Use(echoPasswords)
+	s := cybr_pam_scim.NewServiceWithClient(client)
+	ctx := context.Background()
+	if _, err := s.AddSafe(ctx, types.Container{Name: "AppSafe"}); err != nil {
+		t.Fatal(err)
+	}
+
+	account := types.PrivilegedData{Name: "svc-app", Type: "password"}
+	account.UrnIetfParamsScimSchemasCyberark10PrivilegedData.Safe = "AppSafe"
+	account.UrnIetfParamsScimSchemasCyberark10PrivilegedData.Password = "ExamplePass"
+	added, err := s.AddPrivilegedData(ctx, account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if password := added.UrnIetfParamsScimSchemasCyberark10PrivilegedData.Password; password != "" {
+		t.Errorf("AddPrivilegedData: password = %q, want it cleared", password)
+	}
+	byId, err := s.GetPrivilegedDataById(ctx, added.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if password := byId.UrnIetfParamsScimSchemasCyberark10PrivilegedData.Password; password != "" {
+		t.Errorf("GetPrivilegedDataById: password = %q, want it cleared", password)
+	}
+	accounts, err := s.PrivilegedData().List(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, account := range accounts {
+		if password := account.UrnIetfParamsScimSchemasCyberark10PrivilegedData.Password; password != "" {
+			t.Errorf("PrivilegedData.List: password = %q, want it cleared", password)
+		}
+	}
+}